@@ -0,0 +1,434 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Default approval configuration values
+const (
+	DefaultNamespace     = "scale-system"
+	DefaultTTL           = time.Hour
+	DefaultRetention     = 7 * 24 * time.Hour
+	DefaultSweepInterval = time.Minute
+
+	configMapPrefix = "scale-approval-"
+	configMapKey    = "approval.json"
+	// LabelApproval marks ConfigMaps that hold approval requests
+	LabelApproval = "scale-to-zero.io/approval"
+	// LabelStatus mirrors the approval status for kubectl users
+	LabelStatus = "scale-to-zero.io/approval-status"
+)
+
+// Status is the state of an approval request
+type Status string
+
+// Approval statuses
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusRejected Status = "rejected"
+	StatusExpired  Status = "expired"
+	StatusFailed   Status = "failed"
+)
+
+// Errors returned by Store
+var (
+	ErrNotFound     = errors.New("approval request not found")
+	ErrNotPending   = errors.New("approval request has already been decided")
+	ErrExpired      = errors.New("approval request has expired")
+	ErrSelfApproval = errors.New("approval requests cannot be decided by the requester")
+	ErrNotApprover  = errors.New("caller is not an approver")
+	ErrNoApprover   = errors.New("no principal other than the requester can approve the request")
+)
+
+// Approval is a scale-up waiting for, or decided by, a second person
+type Approval struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas"`
+	Reason    string `json:"reason"`
+	// LeaseDuration and LeaseExpiresAt time-box the scale-up once approved
+	LeaseDuration  string     `json:"leaseDuration,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty"`
+	Reasons        []string   `json:"reasons,omitempty"`
+	RequestedBy    string     `json:"requestedBy"`
+	Status         Status     `json:"status"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	DecidedBy      string     `json:"decidedBy,omitempty"`
+	DecidedAt      time.Time  `json:"decidedAt,omitzero"`
+	Comment        string     `json:"comment,omitempty"`
+	Error          string     `json:"error,omitempty"`
+
+	// resourceVersion of the backing ConfigMap for optimistic concurrency
+	resourceVersion string
+}
+
+// Request describes a scale-up that needs approval
+type Request struct {
+	Namespace      string
+	Name           string
+	Replicas       int32
+	Reason         string
+	LeaseDuration  string
+	LeaseExpiresAt *time.Time
+	Reasons        []string
+	RequestedBy    string
+}
+
+// Config holds approval store configuration
+type Config struct {
+	// Namespace where approval requests are persisted as ConfigMaps
+	Namespace string
+	// TTL after which a pending request expires
+	TTL time.Duration
+	// Retention after which decided requests are deleted
+	Retention time.Duration
+	// Approvers allowed to decide requests. Empty allows any principal other
+	// than the requester.
+	Approvers []string
+	// Principals lists the callers that can authenticate. Requests nobody but
+	// the requester could decide are refused. Empty skips the check, e.g.
+	// when authentication is disabled.
+	Principals []string
+}
+
+// NewConfig returns the default approval configuration. The caller sets the
+// namespace, TTL and approvers from the application configuration.
+func NewConfig() *Config {
+	return &Config{
+		Namespace: DefaultNamespace,
+		TTL:       DefaultTTL,
+		Retention: DefaultRetention,
+	}
+}
+
+// Store persists approval requests as ConfigMaps so they survive restarts and
+// are shared by all replicas of the API
+type Store struct {
+	clientset kubernetes.Interface
+	now       func() time.Time
+
+	// mu guards config, which SetApprovalPolicy replaces
+	mu     sync.RWMutex
+	config *Config
+}
+
+// NewStore creates a new approval store
+func NewStore(clientset kubernetes.Interface, config *Config) *Store {
+	return &Store{
+		clientset: clientset,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// settings returns the configuration in effect
+func (s *Store) settings() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// SetApprovalPolicy replaces the TTL of new requests, the approvers and the
+// principals that can authenticate
+func (s *Store) SetApprovalPolicy(ttl time.Duration, approvers, principals []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config := *s.config
+	config.TTL = ttl
+	config.Approvers = approvers
+	config.Principals = principals
+	s.config = &config
+}
+
+// Create persists a new pending approval request. It returns ErrNoApprover
+// when nobody but the requester could decide it, e.g. when every caller
+// shares one API key.
+func (s *Store) Create(ctx context.Context, req Request) (*Approval, error) {
+	if !s.settings().canBeDecided(req.RequestedBy) {
+		return nil, ErrNoApprover
+	}
+
+	now := s.now().UTC()
+	approval := &Approval{
+		ID:             uuid.NewString(),
+		Namespace:      req.Namespace,
+		Name:           req.Name,
+		Replicas:       req.Replicas,
+		Reason:         req.Reason,
+		LeaseDuration:  req.LeaseDuration,
+		LeaseExpiresAt: req.LeaseExpiresAt,
+		Reasons:        req.Reasons,
+		RequestedBy:    req.RequestedBy,
+		Status:         StatusPending,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.settings().TTL),
+	}
+
+	configMap, err := toConfigMap(approval, s.settings().Namespace)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.clientset.CoreV1().ConfigMaps(s.settings().Namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist approval request: %w", err)
+	}
+	approval.resourceVersion = created.ResourceVersion
+	return approval, nil
+}
+
+// Get returns an approval request. Pending requests past their TTL are
+// reported, and persisted, as expired.
+func (s *Store) Get(ctx context.Context, id string) (*Approval, error) {
+	configMap, err := s.clientset.CoreV1().ConfigMaps(s.settings().Namespace).Get(ctx, configMapPrefix+id, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approval request %s: %w", id, err)
+	}
+
+	approval, err := fromConfigMap(configMap)
+	if err != nil {
+		return nil, err
+	}
+	return s.expireIfDue(ctx, approval)
+}
+
+// List returns approval requests, newest first, optionally filtered by status
+func (s *Store) List(ctx context.Context, status Status) ([]*Approval, error) {
+	configMaps, err := s.clientset.CoreV1().ConfigMaps(s.settings().Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: LabelApproval + "=true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list approval requests: %w", err)
+	}
+
+	approvals := make([]*Approval, 0, len(configMaps.Items))
+	for i := range configMaps.Items {
+		approval, err := fromConfigMap(&configMaps.Items[i])
+		if err != nil {
+			log.Printf("Skipping malformed approval request %s: %v", configMaps.Items[i].Name, err)
+			continue
+		}
+		if approval, err = s.expireIfDue(ctx, approval); err != nil {
+			return nil, err
+		}
+		if status == "" || approval.Status == status {
+			approvals = append(approvals, approval)
+		}
+	}
+
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.After(approvals[j].CreatedAt)
+	})
+	return approvals, nil
+}
+
+// Decide approves or rejects a pending request on behalf of principal.
+// Only one decision can succeed; concurrent decisions get ErrNotPending.
+func (s *Store) Decide(ctx context.Context, id, principal string, approve bool, comment string) (*Approval, error) {
+	if !s.IsApprover(principal) {
+		return nil, ErrNotApprover
+	}
+
+	approval, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case approval.Status == StatusExpired:
+		return nil, ErrExpired
+	case approval.Status != StatusPending:
+		return nil, ErrNotPending
+	case approval.RequestedBy == principal:
+		return nil, ErrSelfApproval
+	}
+
+	approval.Status = StatusRejected
+	if approve {
+		approval.Status = StatusApproved
+	}
+	approval.DecidedBy = principal
+	approval.DecidedAt = s.now().UTC()
+	approval.Comment = comment
+
+	if err := s.update(ctx, approval); err != nil {
+		if k8serrors.IsConflict(err) {
+			return nil, ErrNotPending
+		}
+		return nil, err
+	}
+	return approval, nil
+}
+
+// Reopen returns an approved request to pending, so a scale-up that was
+// refused for a transient reason, e.g. a concurrent operation or a rate
+// limit, can be approved again
+func (s *Store) Reopen(ctx context.Context, approval *Approval) error {
+	approval.Status = StatusPending
+	approval.DecidedBy = ""
+	approval.DecidedAt = time.Time{}
+	approval.Comment = ""
+	return s.update(ctx, approval)
+}
+
+// Fail records that an approved scale-up could not be executed
+func (s *Store) Fail(ctx context.Context, approval *Approval, cause error) error {
+	approval.Status = StatusFailed
+	approval.Error = cause.Error()
+	return s.update(ctx, approval)
+}
+
+// canBeDecided reports whether a principal other than requester may decide
+// its requests
+func (c *Config) canBeDecided(requester string) bool {
+	candidates := c.Approvers
+	if len(candidates) == 0 {
+		candidates = c.Principals
+		if len(candidates) == 0 {
+			return true
+		}
+	}
+	for _, principal := range candidates {
+		if principal != requester {
+			return true
+		}
+	}
+	return false
+}
+
+// IsApprover reports whether principal may decide approval requests
+func (s *Store) IsApprover(principal string) bool {
+	approvers := s.settings().Approvers
+	if len(approvers) == 0 {
+		return true
+	}
+	for _, approver := range approvers {
+		if approver == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// Run expires pending requests and deletes old decided requests until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sweep(ctx); err != nil {
+				log.Printf("Failed to sweep approval requests: %v", err)
+			}
+		}
+	}
+}
+
+// Sweep expires pending requests past their TTL and deletes decided requests
+// older than the retention period
+func (s *Store) Sweep(ctx context.Context) error {
+	approvals, err := s.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	for _, approval := range approvals {
+		if approval.Status == StatusPending {
+			continue
+		}
+		decidedAt := approval.DecidedAt
+		if decidedAt.IsZero() {
+			decidedAt = approval.ExpiresAt
+		}
+		if now.Sub(decidedAt) < s.settings().Retention {
+			continue
+		}
+		err := s.clientset.CoreV1().ConfigMaps(s.settings().Namespace).Delete(ctx, configMapPrefix+approval.ID, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete approval request %s: %w", approval.ID, err)
+		}
+	}
+	return nil
+}
+
+// expireIfDue marks a pending request past its TTL as expired
+func (s *Store) expireIfDue(ctx context.Context, approval *Approval) (*Approval, error) {
+	if approval.Status != StatusPending || s.now().Before(approval.ExpiresAt) {
+		return approval, nil
+	}
+
+	approval.Status = StatusExpired
+	if err := s.update(ctx, approval); err != nil && !k8serrors.IsConflict(err) {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// update writes the approval back, failing with a conflict if it changed since it was read
+func (s *Store) update(ctx context.Context, approval *Approval) error {
+	configMap, err := toConfigMap(approval, s.settings().Namespace)
+	if err != nil {
+		return err
+	}
+	configMap.ResourceVersion = approval.resourceVersion
+
+	updated, err := s.clientset.CoreV1().ConfigMaps(s.settings().Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update approval request %s: %w", approval.ID, err)
+	}
+	approval.resourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// toConfigMap serializes an approval request
+func toConfigMap(approval *Approval, namespace string) (*corev1.ConfigMap, error) {
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode approval request: %w", err)
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapPrefix + approval.ID,
+			Namespace: namespace,
+			Labels: map[string]string{
+				LabelApproval:               "true",
+				LabelStatus:                 string(approval.Status),
+				"app.kubernetes.io/name":    "scale-api",
+				"app.kubernetes.io/part-of": "aks-scale-to-zero",
+			},
+		},
+		Data: map[string]string{configMapKey: string(data)},
+	}, nil
+}
+
+// fromConfigMap deserializes an approval request
+func fromConfigMap(configMap *corev1.ConfigMap) (*Approval, error) {
+	approval := &Approval{}
+	if err := json.Unmarshal([]byte(configMap.Data[configMapKey]), approval); err != nil {
+		return nil, fmt.Errorf("failed to decode approval request %s: %w", configMap.Name, err)
+	}
+	approval.resourceVersion = configMap.ResourceVersion
+	return approval, nil
+}
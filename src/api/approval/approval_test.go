@@ -0,0 +1,226 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestStore(approvers ...string) (*Store, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset()
+	store := NewStore(clientset, &Config{
+		Namespace: DefaultNamespace,
+		TTL:       time.Hour,
+		Retention: 24 * time.Hour,
+		Approvers: approvers,
+	})
+	return store, clientset
+}
+
+func testRequest() Request {
+	return Request{
+		Namespace:   "project-b",
+		Name:        "sample-app-b",
+		Replicas:    4,
+		Reason:      "Load test",
+		Reasons:     []string{"requested 4 replicas exceeds approval threshold of 2 (namespace project-b policy)"},
+		RequestedBy: "alice",
+	}
+}
+
+func TestCreateAndGet(t *testing.T) {
+	store, clientset := newTestStore()
+
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, created.Status)
+	assert.Equal(t, created.CreatedAt.Add(time.Hour), created.ExpiresAt)
+
+	// Persisted in-cluster
+	configMap, err := clientset.CoreV1().ConfigMaps(DefaultNamespace).Get(context.Background(), configMapPrefix+created.ID, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", configMap.Labels[LabelApproval])
+	assert.Equal(t, "pending", configMap.Labels[LabelStatus])
+
+	got, err := store.Get(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+	assert.Equal(t, int32(4), got.Replicas)
+	assert.Equal(t, "alice", got.RequestedBy)
+
+	_, err = store.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDecide_Approve(t *testing.T) {
+	store, _ := newTestStore()
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	decided, err := store.Decide(context.Background(), created.ID, "bob", true, "ok for the demo")
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, decided.Status)
+	assert.Equal(t, "bob", decided.DecidedBy)
+	assert.Equal(t, "ok for the demo", decided.Comment)
+
+	// A second decision is refused
+	_, err = store.Decide(context.Background(), created.ID, "carol", false, "")
+	assert.ErrorIs(t, err, ErrNotPending)
+}
+
+func TestCreate_NobodyElseCanApprove(t *testing.T) {
+	store, _ := newTestStore()
+	store.SetApprovalPolicy(time.Hour, nil, []string{"alice"})
+
+	_, err := store.Create(context.Background(), testRequest())
+	assert.ErrorIs(t, err, ErrNoApprover)
+
+	// Approvers take precedence over the principals
+	store.SetApprovalPolicy(time.Hour, []string{"alice"}, []string{"alice", "bob"})
+	_, err = store.Create(context.Background(), testRequest())
+	assert.ErrorIs(t, err, ErrNoApprover)
+
+	store.SetApprovalPolicy(time.Hour, nil, []string{"alice", "bob"})
+	_, err = store.Create(context.Background(), testRequest())
+	assert.NoError(t, err)
+}
+
+func TestReopen(t *testing.T) {
+	store, _ := newTestStore()
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+	decided, err := store.Decide(context.Background(), created.ID, "bob", true, "ok")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Reopen(context.Background(), decided))
+
+	got, err := store.Get(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, got.Status)
+	assert.Empty(t, got.DecidedBy)
+	_, err = store.Decide(context.Background(), created.ID, "carol", true, "")
+	assert.NoError(t, err)
+}
+
+func TestDecide_SelfApproval(t *testing.T) {
+	store, _ := newTestStore()
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	_, err = store.Decide(context.Background(), created.ID, "alice", true, "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+}
+
+func TestDecide_NotApprover(t *testing.T) {
+	store, _ := newTestStore("bob")
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	_, err = store.Decide(context.Background(), created.ID, "mallory", true, "")
+	assert.ErrorIs(t, err, ErrNotApprover)
+
+	decided, err := store.Decide(context.Background(), created.ID, "bob", false, "too expensive")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, decided.Status)
+}
+
+func TestDecide_Expired(t *testing.T) {
+	store, _ := newTestStore()
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	store.now = func() time.Time { return created.ExpiresAt.Add(time.Second) }
+
+	_, err = store.Decide(context.Background(), created.ID, "bob", true, "")
+	assert.ErrorIs(t, err, ErrExpired)
+
+	got, err := store.Get(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusExpired, got.Status)
+}
+
+func TestDecide_ConcurrentUpdate(t *testing.T) {
+	store, clientset := newTestStore()
+	created, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	// Another replica decided the request between our read and write
+	clientset.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, created.ID, nil)
+	})
+
+	_, err = store.Decide(context.Background(), created.ID, "bob", true, "")
+	assert.ErrorIs(t, err, ErrNotPending)
+}
+
+func TestList(t *testing.T) {
+	store, _ := newTestStore()
+	base := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+
+	store.now = func() time.Time { return base }
+	first, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+	store.now = func() time.Time { return base.Add(time.Minute) }
+	second, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+	_, err = store.Decide(context.Background(), first.ID, "bob", false, "")
+	require.NoError(t, err)
+
+	all, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, second.ID, all[0].ID)
+
+	pending, err := store.List(context.Background(), StatusPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, second.ID, pending[0].ID)
+}
+
+func TestSweep(t *testing.T) {
+	store, _ := newTestStore()
+	base := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return base }
+
+	decided, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+	_, err = store.Decide(context.Background(), decided.ID, "bob", false, "")
+	require.NoError(t, err)
+	pending, err := store.Create(context.Background(), testRequest())
+	require.NoError(t, err)
+
+	// Past the TTL but within retention: the pending request expires
+	store.now = func() time.Time { return base.Add(2 * time.Hour) }
+	require.NoError(t, store.Sweep(context.Background()))
+	got, err := store.Get(context.Background(), pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusExpired, got.Status)
+
+	// Past retention: both are deleted
+	store.now = func() time.Time { return base.Add(48 * time.Hour) }
+	require.NoError(t, store.Sweep(context.Background()))
+	remaining, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestNewConfig(t *testing.T) {
+	// The environment is read by the config package only
+	t.Setenv("APPROVAL_TTL", "30m")
+	t.Setenv("APPROVERS", "bob, carol,")
+
+	config := NewConfig()
+
+	assert.Equal(t, DefaultTTL, config.TTL)
+	assert.Empty(t, config.Approvers)
+	assert.Equal(t, DefaultNamespace, config.Namespace)
+}
@@ -0,0 +1,334 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultResourceManagerEndpoint is the Azure Resource Manager endpoint of the public cloud
+const DefaultResourceManagerEndpoint = "https://management.azure.com"
+
+// APIVersion is the Microsoft.ContainerService API version used for agent pools
+const APIVersion = "2024-09-01"
+
+// MaxNodeCount is the largest node count of an agent pool
+const MaxNodeCount = 1000
+
+var (
+	// ErrInvalidScale is returned when the requested counts are not valid for the pool
+	ErrInvalidScale = errors.New("invalid node pool scale request")
+	// ErrNodePoolNotAllowed is returned for agent pools the API may not scale
+	ErrNodePoolNotAllowed = errors.New("node pool is not managed by the scale API")
+)
+
+// Config identifies the AKS cluster whose agent pools are managed
+type Config struct {
+	SubscriptionID string
+	ResourceGroup  string
+	ClusterName    string
+	// Endpoint is the Azure Resource Manager endpoint
+	Endpoint string
+	// NodePools lists the agent pools that may be scaled. Empty allows none.
+	NodePools []string
+}
+
+// AgentPool is the part of an AKS agent pool the API reports
+type AgentPool struct {
+	Name              string
+	Mode              string
+	VMSize            string
+	Count             int32
+	MinCount          *int32
+	MaxCount          *int32
+	EnableAutoScaling bool
+	ProvisioningState string
+}
+
+// ScaleRequest sets the node count and/or the autoscaler minimum of an agent pool
+type ScaleRequest struct {
+	Count    *int32
+	MinCount *int32
+}
+
+// AgentPoolsClient reads and scales agent pools of the cluster
+type AgentPoolsClient interface {
+	Get(ctx context.Context, name string) (*AgentPool, error)
+	Scale(ctx context.Context, name string, request ScaleRequest) (*AgentPool, error)
+}
+
+// ResponseError is an error returned by Azure Resource Manager
+type ResponseError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("azure resource manager returned %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client calls the Agent Pools REST API of Azure Resource Manager
+type Client struct {
+	config     Config
+	credential TokenSource
+	httpClient *http.Client
+}
+
+// Ensure Client implements AgentPoolsClient
+var _ AgentPoolsClient = (*Client)(nil)
+
+// NewClient creates a new agent pools client authenticating with credential
+func NewClient(config Config, credential TokenSource) *Client {
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultResourceManagerEndpoint
+	}
+	return &Client{
+		config:     config,
+		credential: credential,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// agentPoolResource is the ARM representation of an agent pool. Properties
+// are kept as raw JSON so an update writes back everything it read.
+type agentPoolResource struct {
+	Name       string                     `json:"name"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+// agentPoolProperties are the properties the API reads
+type agentPoolProperties struct {
+	Mode              string `json:"mode"`
+	VMSize            string `json:"vmSize"`
+	Count             int32  `json:"count"`
+	MinCount          *int32 `json:"minCount"`
+	MaxCount          *int32 `json:"maxCount"`
+	EnableAutoScaling bool   `json:"enableAutoScaling"`
+	ProvisioningState string `json:"provisioningState"`
+}
+
+// Get returns the agent pool with name
+func (c *Client) Get(ctx context.Context, name string) (*AgentPool, error) {
+	if err := c.checkAllowed(name); err != nil {
+		return nil, err
+	}
+	resource, err := c.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return resource.agentPool()
+}
+
+// Scale updates the node count and/or minimum of an agent pool. Raising the
+// minimum of an autoscaled pool above its current count also raises the
+// count, so nodes are added right away instead of when pods are pending.
+// The update completes asynchronously; the returned pool reports its
+// provisioning state.
+func (c *Client) Scale(ctx context.Context, name string, request ScaleRequest) (*AgentPool, error) {
+	if err := c.checkAllowed(name); err != nil {
+		return nil, err
+	}
+	if request.Count == nil && request.MinCount == nil {
+		return nil, fmt.Errorf("%w: count or minCount is required", ErrInvalidScale)
+	}
+
+	resource, err := c.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	current, err := resource.agentPool()
+	if err != nil {
+		return nil, err
+	}
+
+	count, minCount, err := scaleCounts(current, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := resource.set("count", count); err != nil {
+		return nil, err
+	}
+	if minCount != nil {
+		if err := resource.set("minCount", *minCount); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{"properties": resource.Properties})
+	if err != nil {
+		return nil, err
+	}
+	var updated agentPoolResource
+	if err := c.do(ctx, http.MethodPut, name, body, &updated); err != nil {
+		return nil, err
+	}
+	return updated.agentPool()
+}
+
+// scaleCounts validates the request against the pool and returns the new
+// count and minimum
+func scaleCounts(pool *AgentPool, request ScaleRequest) (int32, *int32, error) {
+	count := pool.Count
+	minCount := pool.MinCount
+
+	if request.MinCount != nil {
+		if !pool.EnableAutoScaling {
+			return 0, nil, fmt.Errorf("%w: minCount requires the cluster autoscaler on node pool %s", ErrInvalidScale, pool.Name)
+		}
+		if *request.MinCount < 0 || pool.MaxCount != nil && *request.MinCount > *pool.MaxCount {
+			return 0, nil, fmt.Errorf("%w: minCount %d must be between 0 and the maxCount %d of node pool %s",
+				ErrInvalidScale, *request.MinCount, deref(pool.MaxCount), pool.Name)
+		}
+		minCount = request.MinCount
+		if count < *minCount {
+			count = *minCount
+		}
+	}
+
+	if request.Count != nil {
+		count = *request.Count
+		if count < 0 || count > MaxNodeCount {
+			return 0, nil, fmt.Errorf("%w: count %d must be between 0 and %d", ErrInvalidScale, count, MaxNodeCount)
+		}
+		if pool.EnableAutoScaling && (count < deref(minCount) || pool.MaxCount != nil && count > *pool.MaxCount) {
+			return 0, nil, fmt.Errorf("%w: count %d must be between the minCount %d and maxCount %d of node pool %s",
+				ErrInvalidScale, count, deref(minCount), deref(pool.MaxCount), pool.Name)
+		}
+	}
+
+	return count, minCount, nil
+}
+
+// checkAllowed rejects agent pools outside the configured list. Pools must be
+// listed explicitly so the system pool is never scaled by accident.
+func (c *Client) checkAllowed(name string) error {
+	if !slices.Contains(c.config.NodePools, name) {
+		return fmt.Errorf("%w: %s", ErrNodePoolNotAllowed, name)
+	}
+	return nil
+}
+
+// get reads the ARM resource of an agent pool
+func (c *Client) get(ctx context.Context, name string) (*agentPoolResource, error) {
+	var resource agentPoolResource
+	if err := c.do(ctx, http.MethodGet, name, nil, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// do sends a request for an agent pool and decodes the response into out
+func (c *Client) do(ctx context.Context, method, name string, body []byte, out any) error {
+	token, err := c.credential.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.agentPoolURL(name), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Azure Resource Manager: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Azure Resource Manager response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid agent pool response: %w", err)
+	}
+	return nil
+}
+
+// agentPoolURL returns the ARM URL of an agent pool
+func (c *Client) agentPoolURL(name string) string {
+	return fmt.Sprintf("%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/agentPools/%s?api-version=%s",
+		strings.TrimSuffix(c.config.Endpoint, "/"),
+		url.PathEscape(c.config.SubscriptionID),
+		url.PathEscape(c.config.ResourceGroup),
+		url.PathEscape(c.config.ClusterName),
+		url.PathEscape(name),
+		APIVersion)
+}
+
+// responseError decodes an ARM error response
+func responseError(statusCode int, body []byte) error {
+	var response struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || response.Error.Code == "" {
+		return &ResponseError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return &ResponseError{StatusCode: statusCode, Code: response.Error.Code, Message: response.Error.Message}
+}
+
+// agentPool decodes the properties the API reports
+func (r *agentPoolResource) agentPool() (*AgentPool, error) {
+	data, err := json.Marshal(r.Properties)
+	if err != nil {
+		return nil, err
+	}
+	var properties agentPoolProperties
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return nil, fmt.Errorf("invalid agent pool properties: %w", err)
+	}
+	return &AgentPool{
+		Name:              r.Name,
+		Mode:              properties.Mode,
+		VMSize:            properties.VMSize,
+		Count:             properties.Count,
+		MinCount:          properties.MinCount,
+		MaxCount:          properties.MaxCount,
+		EnableAutoScaling: properties.EnableAutoScaling,
+		ProvisioningState: properties.ProvisioningState,
+	}, nil
+}
+
+// set replaces one property
+func (r *agentPoolResource) set(name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if r.Properties == nil {
+		r.Properties = map[string]json.RawMessage{}
+	}
+	r.Properties[name] = data
+	return nil
+}
+
+// deref returns the value of p or zero
+func deref(p *int32) int32 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
@@ -0,0 +1,152 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// newTestARM serves one agent pool with properties and records the last PUT body
+func newTestARM(t *testing.T, properties map[string]any, put *map[string]any) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/aks/agentPools/gpu", r.URL.Path)
+		assert.Equal(t, APIVersion, r.URL.Query().Get("api-version"))
+
+		if r.Method == http.MethodPut {
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			*put = body
+			properties = body["properties"].(map[string]any)
+			properties["provisioningState"] = "Scaling"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": "gpu", "properties": properties})
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(Config{SubscriptionID: "sub", ResourceGroup: "rg", ClusterName: "aks", Endpoint: server.URL, NodePools: []string{"gpu"}}, staticToken("test-token"))
+}
+
+func gpuPool() map[string]any {
+	return map[string]any{
+		"mode":              "User",
+		"vmSize":            "Standard_NC4as_T4_v3",
+		"count":             0,
+		"minCount":          0,
+		"maxCount":          5,
+		"enableAutoScaling": true,
+		"provisioningState": "Succeeded",
+		"nodeTaints":        []any{"sku=gpu:NoSchedule"},
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	client := newTestARM(t, gpuPool(), nil)
+
+	pool, err := client.Get(context.Background(), "gpu")
+	require.NoError(t, err)
+	assert.Equal(t, &AgentPool{
+		Name:              "gpu",
+		Mode:              "User",
+		VMSize:            "Standard_NC4as_T4_v3",
+		Count:             0,
+		MinCount:          ptr.To[int32](0),
+		MaxCount:          ptr.To[int32](5),
+		EnableAutoScaling: true,
+		ProvisioningState: "Succeeded",
+	}, pool)
+}
+
+func TestClient_ScaleMinCount(t *testing.T) {
+	// Setup
+	var put map[string]any
+	client := newTestARM(t, gpuPool(), &put)
+
+	// Test
+	pool, err := client.Scale(context.Background(), "gpu", ScaleRequest{MinCount: ptr.To[int32](2)})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), pool.Count)
+	assert.Equal(t, int32(2), *pool.MinCount)
+	assert.Equal(t, "Scaling", pool.ProvisioningState)
+
+	// Other properties are written back unchanged
+	properties := put["properties"].(map[string]any)
+	assert.Equal(t, float64(2), properties["count"])
+	assert.Equal(t, float64(2), properties["minCount"])
+	assert.Equal(t, []any{"sku=gpu:NoSchedule"}, properties["nodeTaints"])
+}
+
+func TestClient_ScaleInvalid(t *testing.T) {
+	manual := gpuPool()
+	manual["enableAutoScaling"] = false
+	delete(manual, "minCount")
+	delete(manual, "maxCount")
+
+	tests := []struct {
+		name    string
+		pool    map[string]any
+		request ScaleRequest
+		wantErr string
+	}{
+		{name: "empty", pool: gpuPool(), wantErr: "count or minCount is required"},
+		{name: "min above max", pool: gpuPool(), request: ScaleRequest{MinCount: ptr.To[int32](6)}, wantErr: "must be between 0 and the maxCount 5"},
+		{name: "count above max", pool: gpuPool(), request: ScaleRequest{Count: ptr.To[int32](6)}, wantErr: "must be between the minCount 0 and maxCount 5"},
+		{name: "count below new min", pool: gpuPool(), request: ScaleRequest{Count: ptr.To[int32](1), MinCount: ptr.To[int32](2)}, wantErr: "must be between the minCount 2"},
+		{name: "min without autoscaler", pool: manual, request: ScaleRequest{MinCount: ptr.To[int32](1)}, wantErr: "requires the cluster autoscaler"},
+		{name: "negative count", pool: manual, request: ScaleRequest{Count: ptr.To[int32](-1)}, wantErr: "must be between 0 and 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var put map[string]any
+			client := newTestARM(t, tt.pool, &put)
+
+			_, err := client.Scale(context.Background(), "gpu", tt.request)
+			assert.ErrorIs(t, err, ErrInvalidScale)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.Nil(t, put)
+		})
+	}
+}
+
+func TestClient_NotAllowed(t *testing.T) {
+	client := NewClient(Config{NodePools: []string{"projecta"}}, staticToken("test-token"))
+
+	_, err := client.Scale(context.Background(), "system", ScaleRequest{Count: ptr.To[int32](0)})
+	assert.ErrorIs(t, err, ErrNodePoolNotAllowed)
+
+	// Without a list no pool may be scaled
+	client = NewClient(Config{}, staticToken("test-token"))
+	_, err = client.Scale(context.Background(), "projecta", ScaleRequest{Count: ptr.To[int32](0)})
+	assert.ErrorIs(t, err, ErrNodePoolNotAllowed)
+}
+
+func TestClient_ResponseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":"NotFound","message":"agent pool missing not found"}}`))
+	}))
+	defer server.Close()
+	client := NewClient(Config{SubscriptionID: "sub", ResourceGroup: "rg", ClusterName: "aks", Endpoint: server.URL, NodePools: []string{"missing"}}, staticToken("test-token"))
+
+	_, err := client.Get(context.Background(), "missing")
+
+	var responseErr *ResponseError
+	require.True(t, errors.As(err, &responseErr))
+	assert.Equal(t, http.StatusNotFound, responseErr.StatusCode)
+	assert.Equal(t, "NotFound", responseErr.Code)
+}
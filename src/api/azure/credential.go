@@ -0,0 +1,170 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoints used to obtain tokens
+const (
+	// DefaultAuthorityHost is the Microsoft Entra ID endpoint for workload identity
+	DefaultAuthorityHost = "https://login.microsoftonline.com/"
+	// DefaultIMDSEndpoint is the instance metadata token endpoint for managed identity
+	DefaultIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// tokenRefreshMargin is how long before expiry a cached token is refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// TokenSource provides bearer tokens for Azure Resource Manager
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// ManagedIdentityCredential obtains Azure Resource Manager tokens for the
+// pod's identity. When AKS workload identity injected a federated token it is
+// exchanged with Microsoft Entra ID; otherwise the managed identity of the
+// node is used through the instance metadata service.
+type ManagedIdentityCredential struct {
+	ClientID      string
+	TenantID      string
+	TokenFile     string
+	AuthorityHost string
+	IMDSEndpoint  string
+	// Scope is the resource the token is for, without the /.default suffix
+	Scope      string
+	HTTPClient *http.Client
+
+	now       func() time.Time
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewManagedIdentityCredential creates a credential from the environment set
+// by AKS workload identity. A non-empty clientID selects a user-assigned
+// identity instead of AZURE_CLIENT_ID.
+func NewManagedIdentityCredential(clientID, scope string) *ManagedIdentityCredential {
+	if clientID == "" {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = DefaultAuthorityHost
+	}
+	return &ManagedIdentityCredential{
+		ClientID:      clientID,
+		TenantID:      os.Getenv("AZURE_TENANT_ID"),
+		TokenFile:     os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		AuthorityHost: authorityHost,
+		IMDSEndpoint:  DefaultIMDSEndpoint,
+		Scope:         scope,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+		now:           time.Now,
+	}
+}
+
+// tokenResponse is the token response of Entra ID and the metadata service.
+// The metadata service returns expires_in as a string.
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// Token returns a cached token, requesting a new one when it is about to expire
+func (c *ManagedIdentityCredential) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.token != "" && now.Add(tokenRefreshMargin).Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if c.TokenFile != "" && c.TenantID != "" {
+		req, err = c.workloadIdentityRequest(ctx)
+	} else {
+		req, err = c.imdsRequest(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request Azure token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Azure token response: %w", err)
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("invalid Azure token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to get Azure token (HTTP %d): %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	expiresIn, err := token.ExpiresIn.Int64()
+	if err != nil {
+		return "", fmt.Errorf("invalid Azure token expiry %q: %w", token.ExpiresIn, err)
+	}
+	c.token = token.AccessToken
+	c.expiresAt = now.Add(time.Duration(expiresIn) * time.Second)
+	return c.token, nil
+}
+
+// workloadIdentityRequest exchanges the federated service account token
+func (c *ManagedIdentityCredential) workloadIdentityRequest(ctx context.Context) (*http.Request, error) {
+	assertion, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read federated token: %w", err)
+	}
+
+	form := url.Values{
+		"client_id":             {c.ClientID},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"grant_type":            {"client_credentials"},
+		"scope":                 {strings.TrimSuffix(c.Scope, "/") + "/.default"},
+	}
+	endpoint := strings.TrimSuffix(c.AuthorityHost, "/") + "/" + url.PathEscape(c.TenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// imdsRequest requests a token for the managed identity of the node
+func (c *ManagedIdentityCredential) imdsRequest(ctx context.Context) (*http.Request, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {c.Scope},
+	}
+	if c.ClientID != "" {
+		query.Set("client_id", c.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.IMDSEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
@@ -0,0 +1,92 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagedIdentityCredential_WorkloadIdentity(t *testing.T) {
+	// Setup
+	tokenFile := filepath.Join(t.TempDir(), "azure-identity-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("federated-token\n"), 0o600))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/tenant/oauth2/v2.0/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client", r.PostForm.Get("client_id"))
+		assert.Equal(t, "federated-token", r.PostForm.Get("client_assertion"))
+		assert.Equal(t, "https://management.azure.com/.default", r.PostForm.Get("scope"))
+		_, _ = w.Write([]byte(`{"access_token":"arm-token","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+	t.Setenv("AZURE_AUTHORITY_HOST", server.URL+"/")
+	credential := NewManagedIdentityCredential("client", "https://management.azure.com/")
+	now := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	credential.now = func() time.Time { return now }
+
+	// Test
+	token, err := credential.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "arm-token", token)
+
+	// Cached until shortly before expiry
+	now = now.Add(50 * time.Minute)
+	_, err = credential.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	now = now.Add(6 * time.Minute)
+	_, err = credential.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestManagedIdentityCredential_IMDS(t *testing.T) {
+	// Setup
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, "https://management.azure.com/", r.URL.Query().Get("resource"))
+		assert.Equal(t, "client", r.URL.Query().Get("client_id"))
+		_, _ = w.Write([]byte(`{"access_token":"imds-token","expires_in":"86399"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+	credential := NewManagedIdentityCredential("client", "https://management.azure.com/")
+	credential.IMDSEndpoint = server.URL
+
+	// Test
+	token, err := credential.Token(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "imds-token", token)
+}
+
+func TestManagedIdentityCredential_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_request","error_description":"Identity not found"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+	credential := NewManagedIdentityCredential("", "https://management.azure.com/")
+	credential.IMDSEndpoint = server.URL
+
+	_, err := credential.Token(context.Background())
+	assert.ErrorContains(t, err, "Identity not found")
+}
@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Result statuses
+const (
+	ResultSuccess = "success"
+	ResultSkipped = "skipped"
+	ResultError   = "error"
+)
+
+// Result is the outcome of terminating one Job
+type Result struct {
+	Namespace  string
+	Name       string
+	Status     string
+	Message    string
+	Violations []string
+	Err        error
+}
+
+// Manager suspends and resumes CronJobs and terminates Jobs that keep node
+// pools busy, with the scaling policies, locks, throttle and notifications
+// applied to Deployments
+type Manager struct {
+	k8sClient    k8s.ClientInterface
+	locker       lock.Locker
+	policyEngine *policy.Engine
+	throttle     *throttle.Limiter
+	notifier     *notify.Notifier
+}
+
+// NewManager creates a new batch workload manager. The locker serializes
+// changes with hibernation and other requests for the same CronJob.
+func NewManager(k8sClient k8s.ClientInterface, locker lock.Locker, policyEngine *policy.Engine) *Manager {
+	return &Manager{
+		k8sClient:    k8sClient,
+		locker:       locker,
+		policyEngine: policyEngine,
+	}
+}
+
+// SetThrottle sets the per-workload limits. CronJobs are not changed through
+// ScaleDeployment, so the limits are checked here, separately from a
+// deployment of the same name. Suspending counts as a scale-down and resuming
+// as a scale-up. Terminating Jobs changes no replicas and is not limited.
+func (m *Manager) SetThrottle(limiter *throttle.Limiter) {
+	m.throttle = limiter
+}
+
+// SetNotifier sets the notifier told when a CronJob was suspended or resumed
+// or a Job terminated, or when doing so failed
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// SetSuspended suspends or resumes a CronJob. Suspending is checked like a
+// scale to zero and resuming against allowed windows. When the policy denies
+// the change, the CronJob is left unchanged and the decision is returned.
+// lock.ErrLocked is returned while another operation changes the CronJob and
+// a *throttle.Error when it is changed too often.
+func (m *Manager) SetSuspended(ctx context.Context, namespace, name string, suspend bool, principal, reason string) (*k8s.CronJob, *policy.Decision, error) {
+	release, err := m.locker.Acquire(ctx, lock.CronJobKey(namespace, name), false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	cronJob, err := m.k8sClient.GetCronJob(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op := policy.OperationResume
+	if suspend {
+		op = policy.OperationScaleToZero
+	}
+	decision, err := m.evaluate(ctx, namespace, name, cronJob.Annotations, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !decision.Allowed || cronJob.Suspended == suspend {
+		return cronJob, decision, nil
+	}
+
+	// A running CronJob counts as one replica
+	previous, replicas := int32(1), int32(0)
+	if !suspend {
+		previous, replicas = 0, 1
+	}
+	undo, err := m.throttle.ReserveKind("CronJob", namespace, name, replicas)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = m.k8sClient.SuspendCronJob(ctx, namespace, name, suspend)
+	m.notifier.NotifyScale(namespace, name, previous, replicas, principal, reason, err)
+	if err != nil {
+		undo()
+		return nil, nil, err
+	}
+	cronJob.Suspended = suspend
+	return cronJob, decision, nil
+}
+
+// ListJobs lists the unfinished Jobs on a node pool. An empty namespace lists
+// Jobs in all namespaces.
+func (m *Manager) ListJobs(ctx context.Context, namespace, nodePool string) ([]*k8s.Job, error) {
+	return m.k8sClient.ListJobs(ctx, namespace, nodePool)
+}
+
+// TerminateJobs deletes the unfinished Jobs on a node pool together with their
+// pods. Jobs a policy protects from scaling to zero are skipped; Jobs created
+// by a CronJob are checked against the CronJob's policy. CronJobs keep
+// creating Jobs unless they are suspended as well.
+func (m *Manager) TerminateJobs(ctx context.Context, namespace, nodePool, principal, reason string) ([]Result, error) {
+	jobs, err := m.k8sClient.ListJobs(ctx, namespace, nodePool)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(jobs))
+	for _, job := range jobs {
+		results = append(results, m.terminate(ctx, job, principal, reason))
+	}
+	return results, nil
+}
+
+// terminate deletes one Job. A Job created by a CronJob is locked and checked
+// as the CronJob, so it is not terminated while the CronJob is being resumed.
+func (m *Manager) terminate(ctx context.Context, job *k8s.Job, principal, reason string) Result {
+	result := Result{Namespace: job.Namespace, Name: job.Name}
+
+	owner, annotations := job.Name, job.Annotations
+	if job.CronJob != "" {
+		owner = job.CronJob
+		release, err := m.locker.Acquire(ctx, lock.CronJobKey(job.Namespace, owner), false)
+		if err != nil {
+			result.Status, result.Err = ResultError, err
+			return result
+		}
+		defer release()
+
+		cronJob, err := m.k8sClient.GetCronJob(ctx, job.Namespace, job.CronJob)
+		switch {
+		case err == nil:
+			annotations = cronJob.Annotations
+		case k8serrors.IsNotFound(err):
+			// The CronJob was deleted; the Job stands on its own
+		default:
+			result.Status, result.Err = ResultError, err
+			return result
+		}
+	}
+
+	decision, err := m.evaluate(ctx, job.Namespace, owner, annotations, policy.OperationScaleToZero)
+	if err != nil {
+		result.Status, result.Err = ResultError, err
+		return result
+	}
+	if !decision.Allowed {
+		result.Status, result.Message = ResultSkipped, "scaling policy does not allow terminating"
+		result.Violations = decision.Violations
+		return result
+	}
+
+	err = m.k8sClient.DeleteJob(ctx, job.Namespace, job.Name)
+	m.notifier.NotifyScale(job.Namespace, job.Name, job.ActivePods, 0, principal, reason, err)
+	if err != nil {
+		result.Status, result.Err = ResultError, err
+		return result
+	}
+	result.Status = ResultSuccess
+	result.Message = fmt.Sprintf("Job terminated with %d active pods", job.ActivePods)
+	return result
+}
+
+// evaluate checks a batch workload against the scaling policies
+func (m *Manager) evaluate(ctx context.Context, namespace, name string, annotations map[string]string, op policy.Operation) (*policy.Decision, error) {
+	if m.policyEngine == nil {
+		return &policy.Decision{Allowed: true}, nil
+	}
+	return m.policyEngine.Evaluate(ctx, policy.Request{
+		Namespace:   namespace,
+		Name:        name,
+		Annotations: annotations,
+		Operation:   op,
+	})
+}
@@ -0,0 +1,210 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTestManager(mockClient *mocks.MockK8sClient) *Manager {
+	return NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+}
+
+func TestSetSuspended(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b", Schedule: "0 2 * * *"}, nil)
+	mockClient.On("SuspendCronJob", mock.Anything, "project-b", "nightly-eval", true).Return(nil)
+
+	cronJob, decision, err := newTestManager(mockClient).SetSuspended(context.Background(), "project-b", "nightly-eval", true, "alice", "End of day")
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.True(t, cronJob.Suspended)
+	mockClient.AssertExpectations(t)
+}
+
+func TestSetSuspended_AlreadySuspended(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b", Suspended: true}, nil)
+
+	cronJob, _, err := newTestManager(mockClient).SetSuspended(context.Background(), "project-b", "nightly-eval", true, "alice", "End of day")
+
+	require.NoError(t, err)
+	assert.True(t, cronJob.Suspended)
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetSuspended_Protected(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "report").Return(&k8s.CronJob{
+		Name: "report", Namespace: "project-b",
+		Annotations: map[string]string{policy.AnnotationProtected: "true"},
+	}, nil)
+
+	_, decision, err := newTestManager(mockClient).SetSuspended(context.Background(), "project-b", "report", true, "alice", "End of day")
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, http.StatusForbidden, decision.StatusCode)
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTerminateJobs(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("ListJobs", mock.Anything, "", "projectb").Return([]*k8s.Job{
+		{Name: "nightly-eval-29000000", Namespace: "project-b", NodePool: "projectb", ActivePods: 2},
+		{Name: "finetune", Namespace: "project-b", NodePool: "projectb", Annotations: map[string]string{policy.AnnotationProtected: "true"}},
+		{Name: "embeddings", Namespace: "project-c", NodePool: "projectb", ActivePods: 1},
+	}, nil)
+	mockClient.On("DeleteJob", mock.Anything, "project-b", "nightly-eval-29000000").Return(nil)
+	mockClient.On("DeleteJob", mock.Anything, "project-c", "embeddings").Return(errors.New("forbidden"))
+
+	results, err := newTestManager(mockClient).TerminateJobs(context.Background(), "", "projectb", "alice", "End of day")
+
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, ResultSuccess, results[0].Status)
+	assert.Equal(t, "Job terminated with 2 active pods", results[0].Message)
+	assert.Equal(t, ResultSkipped, results[1].Status)
+	assert.NotEmpty(t, results[1].Violations)
+	assert.Equal(t, ResultError, results[2].Status)
+	assert.EqualError(t, results[2].Err, "forbidden")
+	mockClient.AssertNotCalled(t, "DeleteJob", mock.Anything, "project-b", "finetune")
+}
+
+func TestSetSuspended_Locked(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	manager := newTestManager(mockClient)
+	release, err := manager.locker.Acquire(context.Background(), lock.CronJobKey("project-b", "nightly-eval"), false)
+	require.NoError(t, err)
+	defer release()
+
+	_, _, err = manager.SetSuspended(context.Background(), "project-b", "nightly-eval", true, "alice", "End of day")
+
+	assert.ErrorIs(t, err, lock.ErrLocked)
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetSuspended_DeploymentOfSameName(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b"}, nil)
+	mockClient.On("SuspendCronJob", mock.Anything, "project-b", "nightly-eval", true).Return(nil)
+	manager := newTestManager(mockClient)
+	limiter := throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute})
+	manager.SetThrottle(limiter)
+
+	// A deployment of the same name is being scaled
+	release, err := manager.locker.Acquire(context.Background(), lock.Key("project-b", "nightly-eval"), false)
+	require.NoError(t, err)
+	defer release()
+
+	_, _, err = manager.SetSuspended(context.Background(), "project-b", "nightly-eval", true, "alice", "End of day")
+	require.NoError(t, err)
+
+	// Suspending the CronJob does not start the deployment's cooldown
+	_, err = limiter.Reserve("project-b", "nightly-eval", 1)
+	assert.NoError(t, err)
+}
+
+func TestSetSuspended_Throttled(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b"}, nil).Once()
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b", Suspended: true}, nil).Once()
+	mockClient.On("SuspendCronJob", mock.Anything, "project-b", "nightly-eval", true).Return(nil)
+	manager := newTestManager(mockClient)
+	manager.SetThrottle(throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute}))
+
+	_, _, err := manager.SetSuspended(context.Background(), "project-b", "nightly-eval", true, "alice", "End of day")
+	require.NoError(t, err)
+	_, _, err = manager.SetSuspended(context.Background(), "project-b", "nightly-eval", false, "alice", "Forgot a run")
+
+	// Resuming right after suspending is within the cooldown
+	var throttled *throttle.Error
+	assert.ErrorAs(t, err, &throttled)
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, "project-b", "nightly-eval", false)
+}
+
+func TestTerminateJobs_CronJobPolicy(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("ListJobs", mock.Anything, "project-b", "projectb").Return([]*k8s.Job{
+		{Name: "report-29000000", Namespace: "project-b", CronJob: "report", NodePool: "projectb", ActivePods: 1},
+		{Name: "nightly-eval-29000000", Namespace: "project-b", CronJob: "nightly-eval", NodePool: "projectb", ActivePods: 1},
+		{Name: "orphan-29000000", Namespace: "project-b", CronJob: "orphan", NodePool: "projectb", ActivePods: 1},
+	}, nil)
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "report").Return(&k8s.CronJob{
+		Name: "report", Namespace: "project-b",
+		Annotations: map[string]string{policy.AnnotationProtected: "true"},
+	}, nil)
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b"}, nil)
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "orphan").
+		Return(nil, k8serrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "cronjobs"}, "orphan"))
+	mockClient.On("DeleteJob", mock.Anything, "project-b", "nightly-eval-29000000").Return(nil)
+	mockClient.On("DeleteJob", mock.Anything, "project-b", "orphan-29000000").Return(nil)
+	manager := newTestManager(mockClient)
+
+	// A CronJob being resumed holds its lock
+	release, err := manager.locker.Acquire(context.Background(), lock.CronJobKey("project-b", "nightly-eval"), false)
+	require.NoError(t, err)
+	results, err := manager.TerminateJobs(context.Background(), "project-b", "projectb", "alice", "End of day")
+	release()
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, ResultError, results[1].Status)
+	assert.ErrorIs(t, results[1].Err, lock.ErrLocked)
+
+	results, err = manager.TerminateJobs(context.Background(), "project-b", "projectb", "alice", "End of day")
+
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	// The protected CronJob's Jobs are skipped even though they are not annotated
+	assert.Equal(t, ResultSkipped, results[0].Status)
+	assert.NotEmpty(t, results[0].Violations)
+	assert.Equal(t, ResultSuccess, results[1].Status)
+	// Jobs of deleted CronJobs stand on their own
+	assert.Equal(t, ResultSuccess, results[2].Status)
+	mockClient.AssertNotCalled(t, "DeleteJob", mock.Anything, "project-b", "report-29000000")
+}
+
+func TestTerminateJobs_NotThrottled(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	jobs := make([]*k8s.Job, 0, 5)
+	for i := range 5 {
+		name := fmt.Sprintf("nightly-eval-2900000%d", i)
+		jobs = append(jobs, &k8s.Job{Name: name, Namespace: "project-b", CronJob: "nightly-eval", NodePool: "projectb", ActivePods: 1})
+		mockClient.On("DeleteJob", mock.Anything, "project-b", name).Return(nil)
+	}
+	mockClient.On("ListJobs", mock.Anything, "project-b", "projectb").Return(jobs, nil)
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b"}, nil)
+	manager := newTestManager(mockClient)
+	manager.SetThrottle(throttle.NewLimiter(throttle.NewConfig()))
+
+	results, err := manager.TerminateJobs(context.Background(), "project-b", "projectb", "alice", "End of day")
+
+	// More Jobs of one CronJob than the burst are all terminated
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	for _, result := range results {
+		assert.Equal(t, ResultSuccess, result.Status)
+	}
+}
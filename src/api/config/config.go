@@ -3,14 +3,130 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
-// Config holds the application configuration
+// Config holds the application configuration. It is read from the YAML file
+// named by CONFIG_FILE (usually a mounted ConfigMap), and environment
+// variables override the values in the file.
 type Config struct {
-	Port     string
-	LogLevel string
+	ServerConfig `json:"server"`
+	Auth         AuthConfig       `json:"auth"`
+	RateLimit    RateLimitConfig  `json:"rateLimit"`
+	Kubernetes   KubernetesConfig `json:"kubernetes"`
+	Policies     PoliciesConfig   `json:"policies"`
+	Schedules    SchedulesConfig  `json:"schedules"`
+	Webhook      WebhookConfig    `json:"webhook"`
+	Azure        AzureConfig      `json:"azure"`
+}
+
+// ServerConfig holds HTTP server settings
+type ServerConfig struct {
+	Port     string `json:"port"`
+	LogLevel string `json:"logLevel"`
+	// ShutdownTimeout is how long outstanding requests get to complete on shutdown
+	ShutdownTimeout metav1.Duration `json:"shutdownTimeout"`
+	// ReadinessNamespace is listed by /ready to check Kubernetes connectivity
+	ReadinessNamespace string `json:"readinessNamespace"`
+	// IdempotencyTTL is how long responses to Idempotency-Key requests are kept
+	IdempotencyTTL metav1.Duration `json:"idempotencyTTL"`
+}
+
+// AuthConfig holds API key authentication settings
+type AuthConfig struct {
+	// APIKey is the shared key. Prefer setting it with API_KEY from a Secret.
+	APIKey string `json:"apiKey,omitempty"`
+	// APIKeys maps principal names to their API keys
+	APIKeys          map[string]string `json:"apiKeys,omitempty"`
+	ExcludedPaths    []string          `json:"excludedPaths"`
+	ExcludedPrefixes []string          `json:"excludedPrefixes"`
+	// Approvers may decide approval requests. Empty allows anyone but the requester.
+	Approvers []string `json:"approvers,omitempty"`
+	// Admins may read the effective configuration
+	Admins []string `json:"admins,omitempty"`
+}
+
+// RateLimitConfig holds rate limiting settings
+type RateLimitConfig struct {
+	PrincipalPerMinute float64         `json:"principalPerMinute"`
+	PrincipalBurst     int             `json:"principalBurst"`
+	TargetPerMinute    float64         `json:"targetPerMinute"`
+	TargetBurst        int             `json:"targetBurst"`
+	DirectionCooldown  metav1.Duration `json:"directionCooldown"`
+}
+
+// KubernetesConfig holds settings for the workloads the API manages
+type KubernetesConfig struct {
+	// ManagedSelector selects the workloads that opted in. Empty manages all.
+	ManagedSelector  string   `json:"managedSelector"`
+	DeniedNamespaces []string `json:"deniedNamespaces"`
+	// DistributedLock adds a cluster-wide Lease to the per-deployment lock
+	DistributedLock bool `json:"distributedLock"`
+	// Namespace is where the API runs and stores approval requests and lock
+	// Leases. POD_NAMESPACE, set from the downward API, overrides it.
+	Namespace string `json:"namespace"`
+	// Identity names this replica as the holder of lock Leases. It is taken
+	// from POD_NAME, or the hostname, and cannot be set in the file.
+	Identity string `json:"-"`
+}
+
+// PoliciesConfig holds settings for approvals and leases. Per-deployment
+// scaling policies and quotas stay in their own ConfigMaps.
+type PoliciesConfig struct {
+	ApprovalTTL      metav1.Duration `json:"approvalTTL"`
+	MaxLeaseDuration metav1.Duration `json:"maxLeaseDuration"`
+}
+
+// SchedulesConfig holds settings for the ScaleToZeroPolicy controller that
+// applies schedules and idle timeouts
+type SchedulesConfig struct {
+	// Interval is how often policies are reconciled
+	Interval metav1.Duration `json:"interval"`
+	// DefaultTimezone applies to policies that do not set a timezone
+	DefaultTimezone string `json:"defaultTimezone"`
+}
+
+// WebhookConfig holds settings for the admission webhook that guards
+// replica changes made outside the API
+type WebhookConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Port     string `json:"port"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// Mode is "enforce" to deny out-of-band replica changes or "warn" to log them
+	Mode string `json:"mode"`
+	// AllowedUsers may change replicas directly. The defaults include the HPA
+	// controller and the KEDA operator, which change the replicas of
+	// autoscaled deployments.
+	AllowedUsers []string `json:"allowedUsers"`
+}
+
+// AzureConfig identifies the AKS cluster whose node pools the API scales.
+// Node pool scaling is enabled when the cluster is set.
+type AzureConfig struct {
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	ResourceGroup  string `json:"resourceGroup,omitempty"`
+	ClusterName    string `json:"clusterName,omitempty"`
+	// ResourceManagerEndpoint is the Azure Resource Manager endpoint of the cloud
+	ResourceManagerEndpoint string `json:"resourceManagerEndpoint"`
+	// ClientID selects a user-assigned managed identity. Empty uses AZURE_CLIENT_ID
+	// set by workload identity.
+	ClientID string `json:"clientId,omitempty"`
+	// NodePools lists the node pools that may be scaled. Empty allows none.
+	NodePools []string `json:"nodePools,omitempty"`
+}
+
+// Enabled reports whether the cluster is configured
+func (c AzureConfig) Enabled() bool {
+	return c.SubscriptionID != "" && c.ResourceGroup != "" && c.ClusterName != ""
 }
 
 var (
@@ -18,32 +134,249 @@ var (
 	once     sync.Once
 )
 
-// Default configuration values
+// Default configuration values. The packages configured from these settings
+// keep the same defaults for callers that build their configuration
+// directly; the config package does not import them, so it stays the only
+// place the environment is read without depending on every component.
+const (
+	DefaultPort               = "8080"
+	DefaultLogLevel           = "info"
+	DefaultShutdownTimeout    = 30 * time.Second
+	DefaultReadinessNamespace = "scale-system"
+	DefaultIdempotencyTTL     = 24 * time.Hour
+
+	DefaultPrincipalPerMinute = 60
+	DefaultPrincipalBurst     = 20
+	DefaultTargetPerMinute    = 6
+	DefaultTargetBurst        = 3
+	DefaultDirectionCooldown  = 30 * time.Second
+
+	DefaultManagedSelector = "scale-to-zero.io/managed=true"
+	// DefaultNamespace is where the API runs and stores approval requests
+	// and lock Leases
+	DefaultNamespace = "scale-system"
+
+	DefaultApprovalTTL      = time.Hour
+	DefaultMaxLeaseDuration = 7 * 24 * time.Hour
+
+	DefaultScheduleInterval = 30 * time.Second
+	DefaultScheduleTimezone = "UTC"
+
+	DefaultWebhookPort     = "8443"
+	DefaultWebhookCertFile = "/etc/webhook/tls/tls.crt"
+	DefaultWebhookKeyFile  = "/etc/webhook/tls/tls.key"
+
+	DefaultResourceManagerEndpoint = "https://management.azure.com"
+)
+
+// Webhook modes
 const (
-	DefaultPort     = "8080"
-	DefaultLogLevel = "info"
+	WebhookModeEnforce = "enforce"
+	WebhookModeWarn    = "warn"
 )
 
+// defaultDeniedNamespaces are never managed, whatever their workloads' labels
+func defaultDeniedNamespaces() []string {
+	return []string{"kube-system", "kube-public", "kube-node-lease"}
+}
+
+// defaultWebhookAllowedUsers are the Scale API and the autoscalers it pauses
+// and resumes
+func defaultWebhookAllowedUsers() []string {
+	return []string{
+		"system:serviceaccount:scale-system:scale-api-sa",
+		"system:serviceaccount:kube-system:horizontal-pod-autoscaler",
+		"system:serviceaccount:kube-system:keda-operator",
+	}
+}
+
 // GetConfig returns the singleton instance of Config
 func GetConfig() *Config {
 	once.Do(func() {
-		instance = &Config{
-			Port:     getEnv("PORT", DefaultPort),
-			LogLevel: getEnv("LOG_LEVEL", DefaultLogLevel),
-		}
-		if err := instance.validate(); err != nil {
+		config, err := Load(os.Getenv("CONFIG_FILE"))
+		if err != nil {
 			panic(fmt.Sprintf("invalid configuration: %v", err))
 		}
+		instance = config
 	})
 	return instance
 }
 
-// getEnv reads an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Default returns the configuration used when neither the file nor the
+// environment sets a value
+func Default() *Config {
+	return &Config{
+		ServerConfig: ServerConfig{
+			Port:               DefaultPort,
+			LogLevel:           DefaultLogLevel,
+			ShutdownTimeout:    metav1.Duration{Duration: DefaultShutdownTimeout},
+			ReadinessNamespace: DefaultReadinessNamespace,
+			IdempotencyTTL:     metav1.Duration{Duration: DefaultIdempotencyTTL},
+		},
+		Auth: AuthConfig{
+			ExcludedPaths:    []string{"/health", "/ready", "/metrics"},
+			ExcludedPrefixes: []string{"/swagger/", "/docs/"},
+		},
+		RateLimit: RateLimitConfig{
+			PrincipalPerMinute: DefaultPrincipalPerMinute,
+			PrincipalBurst:     DefaultPrincipalBurst,
+			TargetPerMinute:    DefaultTargetPerMinute,
+			TargetBurst:        DefaultTargetBurst,
+			DirectionCooldown:  metav1.Duration{Duration: DefaultDirectionCooldown},
+		},
+		Kubernetes: KubernetesConfig{
+			ManagedSelector:  DefaultManagedSelector,
+			DeniedNamespaces: defaultDeniedNamespaces(),
+			Namespace:        DefaultNamespace,
+		},
+		Policies: PoliciesConfig{
+			ApprovalTTL:      metav1.Duration{Duration: DefaultApprovalTTL},
+			MaxLeaseDuration: metav1.Duration{Duration: DefaultMaxLeaseDuration},
+		},
+		Schedules: SchedulesConfig{
+			Interval:        metav1.Duration{Duration: DefaultScheduleInterval},
+			DefaultTimezone: DefaultScheduleTimezone,
+		},
+		Webhook: WebhookConfig{
+			Port:         DefaultWebhookPort,
+			CertFile:     DefaultWebhookCertFile,
+			KeyFile:      DefaultWebhookKeyFile,
+			Mode:         WebhookModeEnforce,
+			AllowedUsers: defaultWebhookAllowedUsers(),
+		},
+		Azure: AzureConfig{
+			ResourceManagerEndpoint: DefaultResourceManagerEndpoint,
+		},
+	}
+}
+
+// Load reads the configuration file at path on top of the defaults, applies
+// environment overrides and validates the result. An empty path uses the
+// defaults and the environment only.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv overrides values with the environment variables that are set
+func (c *Config) applyEnv() error {
+	var errs []string
+	str := func(key string, target *string) {
+		if value := os.Getenv(key); value != "" {
+			*target = value
+		}
+	}
+	list := func(key string, target *[]string) {
+		if value, ok := os.LookupEnv(key); ok {
+			*target = splitList(value)
+		}
+	}
+	duration := func(key string, target *metav1.Duration) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+				return
+			}
+			target.Duration = parsed
+		}
+	}
+	integer := func(key string, target *int) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+				return
+			}
+			*target = parsed
+		}
+	}
+	float := func(key string, target *float64) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	str("PORT", &c.Port)
+	str("LOG_LEVEL", &c.LogLevel)
+	duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	str("READINESS_NAMESPACE", &c.ReadinessNamespace)
+	duration("IDEMPOTENCY_TTL", &c.IdempotencyTTL)
+
+	str("API_KEY", &c.Auth.APIKey)
+	if value := os.Getenv("API_KEYS"); value != "" {
+		c.Auth.APIKeys = parseAPIKeys(value)
+	}
+	list("APPROVERS", &c.Auth.Approvers)
+	list("ADMINS", &c.Auth.Admins)
+
+	float("RATE_LIMIT_PRINCIPAL_PER_MINUTE", &c.RateLimit.PrincipalPerMinute)
+	integer("RATE_LIMIT_PRINCIPAL_BURST", &c.RateLimit.PrincipalBurst)
+	float("RATE_LIMIT_TARGET_PER_MINUTE", &c.RateLimit.TargetPerMinute)
+	integer("RATE_LIMIT_TARGET_BURST", &c.RateLimit.TargetBurst)
+	duration("RATE_LIMIT_DIRECTION_COOLDOWN", &c.RateLimit.DirectionCooldown)
+
+	// An empty MANAGED_SELECTOR disables the opt-in label
+	if value, ok := os.LookupEnv("MANAGED_SELECTOR"); ok {
+		c.Kubernetes.ManagedSelector = value
+	}
+	list("DENIED_NAMESPACES", &c.Kubernetes.DeniedNamespaces)
+	if value := os.Getenv("DISTRIBUTED_LOCK"); value != "" {
+		c.Kubernetes.DistributedLock = value == "true"
+	}
+	str("POD_NAMESPACE", &c.Kubernetes.Namespace)
+	str("POD_NAME", &c.Kubernetes.Identity)
+	if c.Kubernetes.Identity == "" {
+		c.Kubernetes.Identity, _ = os.Hostname()
+	}
+
+	duration("APPROVAL_TTL", &c.Policies.ApprovalTTL)
+	duration("SCALE_LEASE_MAX_DURATION", &c.Policies.MaxLeaseDuration)
+
+	duration("SCHEDULE_INTERVAL", &c.Schedules.Interval)
+	str("SCHEDULE_DEFAULT_TIMEZONE", &c.Schedules.DefaultTimezone)
+
+	if value := os.Getenv("WEBHOOK_ENABLED"); value != "" {
+		c.Webhook.Enabled = value == "true"
 	}
-	return defaultValue
+	str("WEBHOOK_PORT", &c.Webhook.Port)
+	str("WEBHOOK_CERT_FILE", &c.Webhook.CertFile)
+	str("WEBHOOK_KEY_FILE", &c.Webhook.KeyFile)
+	str("WEBHOOK_MODE", &c.Webhook.Mode)
+	list("WEBHOOK_ALLOWED_USERS", &c.Webhook.AllowedUsers)
+
+	str("AZURE_SUBSCRIPTION_ID", &c.Azure.SubscriptionID)
+	str("AZURE_RESOURCE_GROUP", &c.Azure.ResourceGroup)
+	str("AKS_CLUSTER_NAME", &c.Azure.ClusterName)
+	str("AZURE_RESOURCE_MANAGER_ENDPOINT", &c.Azure.ResourceManagerEndpoint)
+	str("AZURE_MANAGED_IDENTITY_CLIENT_ID", &c.Azure.ClientID)
+	list("NODE_POOLS", &c.Azure.NodePools)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment: %s", strings.Join(errs, "; "))
+	}
+	return nil
 }
 
 // validate checks if the configuration is valid
@@ -65,9 +398,187 @@ func (c *Config) validate() error {
 		return fmt.Errorf("invalid log level: %s", c.LogLevel)
 	}
 
+	if c.ShutdownTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid shutdown timeout: %s", c.ShutdownTimeout.Duration)
+	}
+	if c.ReadinessNamespace == "" {
+		return fmt.Errorf("readiness namespace is required")
+	}
+	if c.IdempotencyTTL.Duration <= 0 {
+		return fmt.Errorf("invalid idempotency TTL: %s", c.IdempotencyTTL.Duration)
+	}
+
+	principals := map[string]string{}
+	for name, key := range c.Auth.APIKeys {
+		if name == "" || key == "" {
+			return fmt.Errorf("api keys need a name and a key")
+		}
+		if other, ok := principals[key]; ok {
+			return fmt.Errorf("api keys of %s and %s are the same", other, name)
+		}
+		principals[key] = name
+	}
+
+	// Approvers are told apart by their per-user keys; everyone using the
+	// shared key is the same principal
+	for _, approver := range c.Auth.Approvers {
+		if _, ok := c.Auth.APIKeys[approver]; !ok {
+			return fmt.Errorf("approver %q needs a per-user api key", approver)
+		}
+	}
+
+	if c.RateLimit.PrincipalBurst < 0 || c.RateLimit.TargetBurst < 0 || c.RateLimit.DirectionCooldown.Duration < 0 {
+		return fmt.Errorf("rate limit bursts and cooldown must not be negative")
+	}
+	if c.RateLimit.PrincipalPerMinute > 0 && c.RateLimit.PrincipalBurst < 1 ||
+		c.RateLimit.TargetPerMinute > 0 && c.RateLimit.TargetBurst < 1 {
+		return fmt.Errorf("rate limit bursts must be at least 1 when the rate is set")
+	}
+
+	if _, err := labels.Parse(c.Kubernetes.ManagedSelector); err != nil {
+		return fmt.Errorf("invalid managed selector %q: %w", c.Kubernetes.ManagedSelector, err)
+	}
+	if c.Kubernetes.Namespace == "" {
+		return fmt.Errorf("kubernetes namespace is required")
+	}
+
+	if c.Policies.ApprovalTTL.Duration <= 0 {
+		return fmt.Errorf("invalid approval TTL: %s", c.Policies.ApprovalTTL.Duration)
+	}
+	if c.Policies.MaxLeaseDuration.Duration <= 0 {
+		return fmt.Errorf("invalid maximum lease duration: %s", c.Policies.MaxLeaseDuration.Duration)
+	}
+
+	if c.Schedules.Interval.Duration <= 0 {
+		return fmt.Errorf("invalid schedule interval: %s", c.Schedules.Interval.Duration)
+	}
+	if _, err := time.LoadLocation(c.Schedules.DefaultTimezone); err != nil || c.Schedules.DefaultTimezone == "" {
+		return fmt.Errorf("invalid default schedule timezone %q", c.Schedules.DefaultTimezone)
+	}
+
+	if c.Webhook.Mode != WebhookModeEnforce && c.Webhook.Mode != WebhookModeWarn {
+		return fmt.Errorf("invalid webhook mode %q: must be %q or %q", c.Webhook.Mode, WebhookModeEnforce, WebhookModeWarn)
+	}
+	if c.Webhook.Enabled {
+		if port, err := strconv.Atoi(c.Webhook.Port); err != nil || port < 1 || port > 65535 || c.Webhook.Port == c.Port {
+			return fmt.Errorf("invalid webhook port: %s", c.Webhook.Port)
+		}
+		if c.Webhook.CertFile == "" || c.Webhook.KeyFile == "" {
+			return fmt.Errorf("webhook certificate and key files are required")
+		}
+	}
+
+	if (c.Azure.SubscriptionID != "" || c.Azure.ResourceGroup != "" || c.Azure.ClusterName != "") && !c.Azure.Enabled() {
+		return fmt.Errorf("azure subscriptionId, resourceGroup and clusterName must be set together")
+	}
+	if c.Azure.Enabled() && !strings.HasPrefix(c.Azure.ResourceManagerEndpoint, "https://") {
+		return fmt.Errorf("invalid azure resource manager endpoint: %s", c.Azure.ResourceManagerEndpoint)
+	}
+
 	return nil
 }
 
+// Redacted returns a copy of the configuration with secrets masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Auth.APIKey != "" {
+		redacted.Auth.APIKey = redactedValue
+	}
+	if len(c.Auth.APIKeys) > 0 {
+		redacted.Auth.APIKeys = make(map[string]string, len(c.Auth.APIKeys))
+		for name := range c.Auth.APIKeys {
+			redacted.Auth.APIKeys[name] = redactedValue
+		}
+	}
+	return &redacted
+}
+
+// redactedValue replaces secrets in the redacted configuration
+const redactedValue = "REDACTED"
+
+// RestartRequired lists the settings that differ between c and next but only
+// take effect after a restart
+func (c *Config) RestartRequired(next *Config) []string {
+	var changed []string
+	if c.Port != next.Port {
+		changed = append(changed, "server.port")
+	}
+	if c.ReadinessNamespace != next.ReadinessNamespace {
+		changed = append(changed, "server.readinessNamespace")
+	}
+	if c.IdempotencyTTL != next.IdempotencyTTL {
+		changed = append(changed, "server.idempotencyTTL")
+	}
+	if c.Kubernetes.ManagedSelector != next.Kubernetes.ManagedSelector ||
+		strings.Join(c.Kubernetes.DeniedNamespaces, ",") != strings.Join(next.Kubernetes.DeniedNamespaces, ",") ||
+		c.Kubernetes.DistributedLock != next.Kubernetes.DistributedLock ||
+		c.Kubernetes.Namespace != next.Kubernetes.Namespace {
+		changed = append(changed, "kubernetes")
+	}
+	if c.Policies.MaxLeaseDuration != next.Policies.MaxLeaseDuration {
+		changed = append(changed, "policies.maxLeaseDuration")
+	}
+	if c.Schedules != next.Schedules {
+		changed = append(changed, "schedules")
+	}
+	if c.Webhook.Enabled != next.Webhook.Enabled || c.Webhook.Port != next.Webhook.Port ||
+		c.Webhook.CertFile != next.Webhook.CertFile || c.Webhook.KeyFile != next.Webhook.KeyFile {
+		changed = append(changed, "webhook.enabled/port/certFile/keyFile")
+	}
+	if !reflect.DeepEqual(c.Azure, next.Azure) {
+		changed = append(changed, "azure")
+	}
+	return changed
+}
+
+// keepRestartSettings copies the settings listed by RestartRequired from previous
+func (c *Config) keepRestartSettings(previous *Config) {
+	c.Port = previous.Port
+	c.ReadinessNamespace = previous.ReadinessNamespace
+	c.IdempotencyTTL = previous.IdempotencyTTL
+	c.Kubernetes = previous.Kubernetes
+	c.Policies.MaxLeaseDuration = previous.Policies.MaxLeaseDuration
+	c.Schedules = previous.Schedules
+	c.Webhook.Enabled = previous.Webhook.Enabled
+	c.Webhook.Port = previous.Webhook.Port
+	c.Webhook.CertFile = previous.Webhook.CertFile
+	c.Webhook.KeyFile = previous.Webhook.KeyFile
+	c.Azure = previous.Azure
+}
+
+// parseAPIKeys parses per-user API keys in the form "alice=key1,bob=key2"
+func parseAPIKeys(value string) map[string]string {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		name, key, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || name == "" || key == "" {
+			continue
+		}
+		keys[name] = key
+	}
+	return keys
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Principals returns the API keys mapped to the principal they authenticate as
+func (a AuthConfig) Principals() map[string]string {
+	principals := make(map[string]string, len(a.APIKeys))
+	for name, key := range a.APIKeys {
+		principals[key] = name
+	}
+	return principals
+}
+
 // String returns a string representation of the config
 func (c *Config) String() string {
 	return fmt.Sprintf("Config{Port: %s, LogLevel: %s}", c.Port, c.LogLevel)
@@ -1,10 +1,23 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/approval"
+	"github.com/torumakabe/aks-scale-to-zero/api/azure"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/scalepolicy"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	"github.com/torumakabe/aks-scale-to-zero/api/webhook"
 )
 
 func TestGetConfig_DefaultValues(t *testing.T) {
@@ -32,3 +45,144 @@ func TestConfig_String(t *testing.T) {
 	assert.Contains(t, str, "Port:")
 	assert.Contains(t, str, "LogLevel:")
 }
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_FileWithEnvOverrides(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: "9090"
+  shutdownTimeout: 45s
+auth:
+  apiKeys:
+    alice: alice-key
+    bob: bob-key
+    carol: carol-key
+  admins: [alice]
+rateLimit:
+  targetBurst: 5
+kubernetes:
+  deniedNamespaces: [kube-system, prod]
+policies:
+  approvalTTL: 2h
+schedules:
+  interval: 1m
+  defaultTimezone: Asia/Tokyo
+`)
+	t.Setenv("PORT", "8081")
+	t.Setenv("APPROVERS", "bob, carol")
+
+	config, err := Load(path)
+	require.NoError(t, err)
+
+	// Environment wins over the file, the file over the defaults
+	assert.Equal(t, "8081", config.Port)
+	assert.Equal(t, 45*time.Second, config.ShutdownTimeout.Duration)
+	assert.Equal(t, DefaultReadinessNamespace, config.ReadinessNamespace)
+	assert.Equal(t, map[string]string{"alice-key": "alice", "bob-key": "bob", "carol-key": "carol"}, config.Auth.Principals())
+	assert.Equal(t, []string{"bob", "carol"}, config.Auth.Approvers)
+	assert.Equal(t, []string{"/health", "/ready", "/metrics"}, config.Auth.ExcludedPaths)
+	assert.Equal(t, 5, config.RateLimit.TargetBurst)
+	assert.Equal(t, 20, config.RateLimit.PrincipalBurst)
+	assert.Equal(t, []string{"kube-system", "prod"}, config.Kubernetes.DeniedNamespaces)
+	assert.Equal(t, "scale-to-zero.io/managed=true", config.Kubernetes.ManagedSelector)
+	assert.Equal(t, 2*time.Hour, config.Policies.ApprovalTTL.Duration)
+	assert.Equal(t, time.Minute, config.Schedules.Interval.Duration)
+	assert.Equal(t, "Asia/Tokyo", config.Schedules.DefaultTimezone)
+}
+
+func TestLoad_APIKeysFromEnv(t *testing.T) {
+	t.Setenv("API_KEY", "shared-key")
+	t.Setenv("API_KEYS", "bob=bob-key, alice=alice-key,invalid")
+
+	config, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"alice-key": "alice", "bob-key": "bob"}, config.Auth.Principals())
+}
+
+func TestLoad_PodFromEnv(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "scale-api")
+	t.Setenv("POD_NAME", "scale-api-7d9f-abcde")
+
+	config, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "scale-api", config.Kubernetes.Namespace)
+	assert.Equal(t, "scale-api-7d9f-abcde", config.Kubernetes.Identity)
+}
+
+func TestDefault_MatchesPackages(t *testing.T) {
+	config := Default()
+
+	assert.Equal(t, middleware.DefaultIdempotencyTTL, config.IdempotencyTTL.Duration)
+	assert.Equal(t, float64(middleware.DefaultPrincipalRatePerMinute), config.RateLimit.PrincipalPerMinute)
+	assert.Equal(t, middleware.DefaultPrincipalBurst, config.RateLimit.PrincipalBurst)
+	assert.Equal(t, float64(throttle.DefaultRatePerMinute), config.RateLimit.TargetPerMinute)
+	assert.Equal(t, throttle.DefaultBurst, config.RateLimit.TargetBurst)
+	assert.Equal(t, throttle.DefaultDirectionCooldown, config.RateLimit.DirectionCooldown.Duration)
+	assert.Equal(t, k8s.DefaultManagedSelector, config.Kubernetes.ManagedSelector)
+	assert.Equal(t, k8s.DefaultDeniedNamespaces, config.Kubernetes.DeniedNamespaces)
+	assert.Equal(t, approval.DefaultNamespace, config.Kubernetes.Namespace)
+	assert.Equal(t, lock.DefaultLeaseNamespace, config.Kubernetes.Namespace)
+	assert.Equal(t, approval.DefaultTTL, config.Policies.ApprovalTTL.Duration)
+	assert.Equal(t, lease.DefaultMaxDuration, config.Policies.MaxLeaseDuration.Duration)
+	assert.Equal(t, scalepolicy.DefaultInterval, config.Schedules.Interval.Duration)
+	assert.Equal(t, scalepolicy.DefaultTimezone, config.Schedules.DefaultTimezone)
+	assert.Equal(t, webhook.DefaultPort, config.Webhook.Port)
+	assert.Equal(t, webhook.DefaultCertFile, config.Webhook.CertFile)
+	assert.Equal(t, webhook.DefaultKeyFile, config.Webhook.KeyFile)
+	assert.Equal(t, webhook.ModeEnforce, config.Webhook.Mode)
+	assert.Equal(t, webhook.ModeWarn, WebhookModeWarn)
+	assert.Equal(t, webhook.DefaultAllowedUsers(), config.Webhook.AllowedUsers)
+	assert.Equal(t, azure.DefaultResourceManagerEndpoint, config.Azure.ResourceManagerEndpoint)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown field", content: "server:\n  prot: 8080\n", wantErr: "failed to parse"},
+		{name: "invalid port", content: "server:\n  port: \"0\"\n", wantErr: "invalid port"},
+		{name: "invalid selector", content: "kubernetes:\n  managedSelector: \"a in (\"\n", wantErr: "invalid managed selector"},
+		{name: "shared api key", content: "auth:\n  apiKeys: {alice: key, bob: key}\n", wantErr: "are the same"},
+		{name: "invalid env", env: map[string]string{"APPROVAL_TTL": "soon"}, wantErr: "APPROVAL_TTL"},
+		{name: "approver without key", content: "auth:\n  apiKey: shared\n  approvers: [api-key]\n", wantErr: `approver "api-key" needs a per-user api key`},
+		{name: "invalid schedule interval", content: "schedules:\n  interval: 0s\n", wantErr: "invalid schedule interval"},
+		{name: "invalid timezone", env: map[string]string{"SCHEDULE_DEFAULT_TIMEZONE": "Mars/Olympus"}, wantErr: "invalid default schedule timezone"},
+		{name: "invalid webhook mode", content: "webhook:\n  mode: audit\n", wantErr: "invalid webhook mode"},
+		{name: "webhook on api port", content: "webhook:\n  enabled: true\n  port: \"8080\"\n", wantErr: "invalid webhook port"},
+		{name: "partial azure cluster", content: "azure:\n  subscriptionId: sub\n  clusterName: aks\n", wantErr: "must be set together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(writeConfig(t, tt.content))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	config := Default()
+	config.Auth.APIKey = "shared-key"
+	config.Auth.APIKeys = map[string]string{"alice": "alice-key"}
+
+	redacted := config.Redacted()
+	assert.Equal(t, "REDACTED", redacted.Auth.APIKey)
+	assert.Equal(t, map[string]string{"alice": "REDACTED"}, redacted.Auth.APIKeys)
+
+	// The original is unchanged
+	assert.Equal(t, "alice-key", config.Auth.APIKeys["alice"])
+}
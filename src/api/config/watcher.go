@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often the configuration file is checked for changes
+const DefaultWatchInterval = 10 * time.Second
+
+// Watcher reloads the configuration file when its content changes. Invalid
+// files are logged and ignored, keeping the last valid configuration.
+type Watcher struct {
+	path string
+
+	mu        sync.RWMutex
+	current   *Config
+	content   []byte
+	loadedAt  time.Time
+	listeners []func(*Config)
+}
+
+// NewWatcher loads the configuration at path (empty for environment only)
+func NewWatcher(path string) (*Watcher, error) {
+	config, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{path: path, current: config, loadedAt: time.Now().UTC()}
+	if path != "" {
+		// Load has just read it successfully; a failure here only delays change detection
+		w.content, _ = os.ReadFile(path)
+	}
+	return w, nil
+}
+
+// Current returns the configuration in effect
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Path returns the configuration file, empty when only the environment is used
+func (w *Watcher) Path() string {
+	return w.path
+}
+
+// LoadedAt returns when the configuration in effect was loaded
+func (w *Watcher) LoadedAt() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.loadedAt
+}
+
+// OnChange registers fn to be called with each reloaded configuration.
+// Listeners apply the settings that can change without a restart.
+func (w *Watcher) OnChange(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Run checks the file every interval until ctx is done
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	if w.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Reload()
+		}
+	}
+}
+
+// Reload reloads the file if its content changed and reports whether a new
+// configuration took effect
+func (w *Watcher) Reload() bool {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		log.Printf("Failed to read config file %s: %v", w.path, err)
+		return false
+	}
+
+	w.mu.RLock()
+	unchanged := bytes.Equal(content, w.content)
+	w.mu.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	next, err := Load(w.path)
+	if err != nil {
+		log.Printf("Ignoring invalid config file %s: %v", w.path, err)
+		// Do not report the same invalid content again
+		w.mu.Lock()
+		w.content = content
+		w.mu.Unlock()
+		return false
+	}
+
+	w.mu.Lock()
+	previous := w.current
+	if changed := previous.RestartRequired(next); len(changed) > 0 {
+		log.Printf("Config file %s changed settings that take effect after a restart: %s", w.path, strings.Join(changed, ", "))
+		// Current reports the settings actually in effect
+		next.keepRestartSettings(previous)
+	}
+	w.current = next
+	w.content = content
+	w.loadedAt = time.Now().UTC()
+	listeners := append([]func(*Config){}, w.listeners...)
+	w.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(next)
+	}
+	log.Printf("Reloaded config file %s", w.path)
+	return true
+}
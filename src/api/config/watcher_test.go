@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	t.Setenv("API_KEYS", "alice=alice-key,bob=bob-key")
+	path := writeConfig(t, "auth:\n  approvers: [alice]\n")
+	watcher, err := NewWatcher(path)
+	require.NoError(t, err)
+
+	var reloaded []*Config
+	watcher.OnChange(func(config *Config) { reloaded = append(reloaded, config) })
+
+	// Unchanged content is not reloaded
+	assert.False(t, watcher.Reload())
+
+	// Hot settings take effect; restart-only settings keep their running value
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: \"9090\"\nauth:\n  approvers: [bob]\nrateLimit:\n  targetBurst: 1\n"), 0o600))
+	assert.True(t, watcher.Reload())
+	require.Len(t, reloaded, 1)
+	assert.Equal(t, []string{"bob"}, watcher.Current().Auth.Approvers)
+	assert.Equal(t, 1, watcher.Current().RateLimit.TargetBurst)
+	assert.Equal(t, DefaultPort, watcher.Current().Port)
+
+	// Invalid content keeps the last valid configuration
+	require.NoError(t, os.WriteFile(path, []byte("auth:\n  approvers: bob\n"), 0o600))
+	assert.False(t, watcher.Reload())
+	assert.Len(t, reloaded, 1)
+	assert.Equal(t, []string{"bob"}, watcher.Current().Auth.Approvers)
+}
+
+func TestConfig_RestartRequired(t *testing.T) {
+	current := Default()
+	next := Default()
+	next.Port = "9090"
+	next.Kubernetes.DeniedNamespaces = append(next.Kubernetes.DeniedNamespaces, "prod")
+	next.RateLimit.TargetBurst = 1
+	next.ShutdownTimeout.Duration = time.Minute
+
+	assert.Equal(t, []string{"server.port", "kubernetes"}, current.RestartRequired(next))
+}
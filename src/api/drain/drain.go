@@ -0,0 +1,497 @@
+package drain
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Deployment annotations
+const (
+	// AnnotationDrain declares how a deployment is drained before it is
+	// scaled to zero, as a JSON or YAML Spec
+	AnnotationDrain = "scale-to-zero.io/drain"
+	// AnnotationDrainingSince marks a deployment that is being drained (RFC 3339)
+	AnnotationDrainingSince = "scale-to-zero.io/draining-since"
+)
+
+// Drain types
+const (
+	// TypeMetric waits until a Prometheus metric of the pods sums to zero
+	TypeMetric = "metric"
+	// TypeHTTP asks the pods to drain with a POST to an endpoint and waits
+	// until a GET of the same endpoint returns 200
+	TypeHTTP = "http"
+)
+
+// Default drain settings
+const (
+	DefaultTimeout      = 5 * time.Minute
+	MaxTimeout          = 30 * time.Minute
+	DefaultPollInterval = 5 * time.Second
+	DefaultMetricsPath  = "/metrics"
+	// requestTimeout bounds each request to a pod
+	requestTimeout = 5 * time.Second
+	// maxResponseSize bounds how much of a metrics response is read
+	maxResponseSize = 4 << 20
+)
+
+// OperationType is the type of drain operations in the operation store
+const OperationType = "drain-scale-to-zero"
+
+// recoveredBy starts the drains resumed after a restart
+const recoveredBy = "scale-api"
+
+// Spec declares how a deployment is drained
+type Spec struct {
+	Type string `json:"type"`
+	Port int32  `json:"port"`
+	// Path is the metrics path for metric drains and the drain endpoint for
+	// HTTP drains
+	Path string `json:"path,omitempty"`
+	// Metric is the in-flight request metric, e.g. nv_inference_pending_request_count
+	Metric string `json:"metric,omitempty"`
+	// Timeout bounds the drain, e.g. "10m". The deployment is scaled to zero
+	// when it elapses even if requests are still in flight.
+	Timeout string `json:"timeout,omitempty"`
+
+	timeout time.Duration
+}
+
+// ParseSpec parses the drain declared in a deployment's annotations. It
+// returns nil when none is declared.
+func ParseSpec(annotations map[string]string) (*Spec, error) {
+	value := strings.TrimSpace(annotations[AnnotationDrain])
+	if value == "" {
+		return nil, nil
+	}
+	var spec Spec
+	if err := yaml.UnmarshalStrict([]byte(value), &spec); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationDrain, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationDrain, err)
+	}
+	return &spec, nil
+}
+
+// validate checks a spec and fills in defaults
+func (s *Spec) validate() error {
+	switch s.Type {
+	case TypeMetric:
+		if s.Metric == "" {
+			return fmt.Errorf("metric is required")
+		}
+		if s.Path == "" {
+			s.Path = DefaultMetricsPath
+		}
+	case TypeHTTP:
+		if s.Path == "" {
+			return fmt.Errorf("path is required")
+		}
+	default:
+		return fmt.Errorf("type must be %q or %q", TypeMetric, TypeHTTP)
+	}
+	if s.Port <= 0 {
+		return fmt.Errorf("port is required")
+	}
+	if !strings.HasPrefix(s.Path, "/") {
+		s.Path = "/" + s.Path
+	}
+
+	s.timeout = DefaultTimeout
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err != nil || d <= 0 || d > MaxTimeout {
+			return fmt.Errorf("timeout must be a duration greater than 0 and at most %s", MaxTimeout)
+		}
+		s.timeout = d
+	}
+	return nil
+}
+
+// DrainingSince returns when a deployment started draining
+func DrainingSince(annotations map[string]string) (time.Time, bool) {
+	since, err := time.Parse(time.RFC3339, annotations[AnnotationDrainingSince])
+	return since, err == nil
+}
+
+// Config holds drain configuration
+type Config struct {
+	PollInterval time.Duration
+}
+
+// NewConfig returns the default drain configuration
+func NewConfig() *Config {
+	return &Config{
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// Manager drains deployments before scaling them to zero. Drains run in the
+// background and report progress to the operation store.
+type Manager struct {
+	k8sClient  k8s.ClientInterface
+	clientset  kubernetes.Interface
+	operations *operation.Store
+	config     *Config
+	httpClient *http.Client
+	notifier   *notify.Notifier
+	now        func() time.Time
+}
+
+// NewManager creates a new drain manager
+func NewManager(k8sClient k8s.ClientInterface, operations *operation.Store, config *Config) *Manager {
+	return &Manager{
+		k8sClient:  k8sClient,
+		clientset:  k8sClient.GetClientset(),
+		operations: operations,
+		config:     config,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		now:        time.Now,
+	}
+}
+
+// SetNotifier sets the notifier told when a drained deployment was scaled to
+// zero or failed to be
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// Start marks a deployment as draining and returns the operation that waits
+// for it to become idle and then scales it to zero. release is the caller's
+// lock on the deployment; once Start succeeds the operation owns it and
+// releases it when finished.
+func (m *Manager) Start(ctx context.Context, status *k8s.DeploymentStatus, spec *Spec, reason, startedBy string, release func()) (operation.Operation, error) {
+	since := m.now().UTC().Format(time.RFC3339)
+	err := m.k8sClient.PatchDeploymentMetadata(ctx, status.Namespace, status.Name, nil, map[string]*string{AnnotationDrainingSince: &since})
+	if err != nil {
+		return operation.Operation{}, fmt.Errorf("failed to mark deployment as draining: %w", err)
+	}
+
+	return m.launch(ctx, status, spec, reason, startedBy, release), nil
+}
+
+// StartDeclared starts the drain a deployment declares, if any. It returns a
+// nil operation, and leaves release to the caller, when the deployment
+// declares no drain, is already at zero or m is nil. Background operations
+// that scale to zero use it so a declared drain is never skipped.
+func (m *Manager) StartDeclared(ctx context.Context, status *k8s.DeploymentStatus, reason, startedBy string, release func()) (*operation.Operation, error) {
+	if m == nil || status.DesiredReplicas == 0 {
+		return nil, nil
+	}
+	spec, err := ParseSpec(status.Annotations)
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	op, err := m.Start(ctx, status, spec, reason, startedBy, release)
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// Recover handles the draining marks left by a previous run of the API.
+// Drains run in-process, so a restart leaves deployments marked as draining
+// with nothing draining them. Drains marked within MaxTimeout are resumed
+// for the rest of their timeout; older marks, and marks of deployments that
+// no longer declare a drain or are already scaled to zero, are removed.
+// Deployments locked by another replica are left to it.
+func (m *Manager) Recover(ctx context.Context, locker lock.Locker) {
+	deployments, err := m.k8sClient.ListDeployments(ctx, "", "")
+	if err != nil {
+		log.Printf("Failed to list deployments to recover drains: %v", err)
+		return
+	}
+	for _, d := range deployments {
+		if _, ok := d.Annotations[AnnotationDrainingSince]; ok {
+			m.recover(ctx, locker, d)
+		}
+	}
+}
+
+// recover resumes or clears the drain of a deployment marked as draining
+func (m *Manager) recover(ctx context.Context, locker lock.Locker, status *k8s.DeploymentStatus) {
+	namespace, name := status.Namespace, status.Name
+	release, err := locker.Acquire(ctx, lock.Key(namespace, name), false)
+	if err != nil {
+		if !errors.Is(err, lock.ErrLocked) {
+			log.Printf("Failed to lock deployment %s/%s to recover its drain: %v", namespace, name, err)
+		}
+		return
+	}
+
+	spec, err := ParseSpec(status.Annotations)
+	since, ok := DrainingSince(status.Annotations)
+	elapsed := m.now().Sub(since)
+	if err != nil || spec == nil || !ok || elapsed > MaxTimeout || status.DesiredReplicas == 0 {
+		defer release()
+		err := m.k8sClient.PatchDeploymentMetadata(ctx, namespace, name, nil, map[string]*string{AnnotationDrainingSince: nil})
+		if err != nil {
+			log.Printf("Failed to remove stale draining mark of deployment %s/%s: %v", namespace, name, err)
+			return
+		}
+		log.Printf("Removed stale draining mark of deployment %s/%s", namespace, name)
+		return
+	}
+
+	// A drain whose timeout elapsed during the restart scales to zero at once
+	resumed := *spec
+	resumed.timeout = max(spec.timeout-elapsed, 0)
+	m.launch(ctx, status, &resumed, "Drain resumed after restart", recoveredBy, release)
+}
+
+// launch starts the operation that drains a deployment marked as draining
+func (m *Manager) launch(ctx context.Context, status *k8s.DeploymentStatus, spec *Spec, reason, startedBy string, release func()) operation.Operation {
+	op := m.operations.Start(OperationType, status.Namespace+"/"+status.Name, reason, startedBy, []operation.Step{
+		{Stage: 0, Namespace: status.Namespace, Name: status.Name, Replicas: status.DesiredReplicas},
+		{Stage: 1, Namespace: status.Namespace, Name: status.Name, Replicas: 0},
+	})
+	log.Printf("Draining deployment %s/%s before scaling to zero (operation %s)", status.Namespace, status.Name, op.ID)
+
+	// The operation outlives the request that started it
+	go func() {
+		defer release()
+		err := m.run(context.WithoutCancel(ctx), op.ID, status, spec)
+		m.operations.Finish(op.ID, err)
+		m.notifier.NotifyScale(status.Namespace, status.Name, status.DesiredReplicas, 0, startedBy, reason, err)
+		if err != nil {
+			log.Printf("Failed to drain and scale deployment %s/%s to zero (operation %s): %v", status.Namespace, status.Name, op.ID, err)
+			return
+		}
+		log.Printf("Drained and scaled deployment %s/%s to zero (operation %s)", status.Namespace, status.Name, op.ID)
+	}()
+	return op
+}
+
+// run waits for the deployment to become idle, then scales it to zero and
+// removes the draining mark
+func (m *Manager) run(ctx context.Context, opID string, status *k8s.DeploymentStatus, spec *Spec) error {
+	namespace, name := status.Namespace, status.Name
+	defer func() {
+		err := m.k8sClient.PatchDeploymentMetadata(ctx, namespace, name, nil, map[string]*string{AnnotationDrainingSince: nil})
+		if err != nil {
+			log.Printf("Failed to remove draining mark of deployment %s/%s: %v", namespace, name, err)
+		}
+	}()
+
+	m.operations.UpdateStep(opID, 0, operation.StatusRunning, "waiting for in-flight requests")
+	m.operations.UpdateStep(opID, 0, operation.StatusSucceeded, m.wait(ctx, opID, namespace, name, spec))
+
+	m.operations.UpdateStep(opID, 1, operation.StatusRunning, "")
+	if err := m.k8sClient.ScaleDeployment(ctx, namespace, name, 0); err != nil {
+		m.operations.UpdateStep(opID, 1, operation.StatusFailed, err.Error())
+		return err
+	}
+	if status.Labels[lease.LabelLeased] == "true" {
+		if err := lease.Clear(ctx, m.k8sClient, namespace, name); err != nil {
+			log.Printf("Failed to release lease of deployment %s/%s: %v", namespace, name, err)
+		}
+	}
+	m.operations.UpdateStep(opID, 1, operation.StatusSucceeded, "scaled to zero")
+	return nil
+}
+
+// wait polls the deployment's pods until they are idle or the drain times
+// out, and describes the outcome. What keeps the pods busy is reported as
+// the message of the drain step.
+func (m *Manager) wait(ctx context.Context, opID, namespace, name string, spec *Spec) string {
+	start := m.now()
+	ctx, cancel := context.WithTimeout(ctx, spec.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	requested := map[string]bool{}
+	var cause error
+	for {
+		busy, err := m.busy(ctx, namespace, name, spec, requested)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			cause = err
+		case busy == "":
+			return fmt.Sprintf("idle after %s", m.now().Sub(start).Round(time.Second))
+		default:
+			cause = errors.New(busy)
+			m.operations.UpdateStep(opID, 0, operation.StatusRunning, "waiting: "+busy)
+		}
+
+		select {
+		case <-ctx.Done():
+			if cause == nil {
+				cause = ctx.Err()
+			}
+			return fmt.Sprintf("timed out after %s (%v); scaling to zero anyway", spec.timeout, cause)
+		case <-ticker.C:
+		}
+	}
+}
+
+// busy checks every pod of the deployment and describes those still busy,
+// or returns "" when all are idle. HTTP drains are requested once per pod;
+// requested tracks the pods already asked.
+func (m *Manager) busy(ctx context.Context, namespace, name string, spec *Spec, requested map[string]bool) (string, error) {
+	ips, err := m.podIPs(ctx, namespace, name)
+	if err != nil {
+		return "", err
+	}
+
+	var busy []string
+	var inFlight float64
+	for _, ip := range ips {
+		target := "http://" + net.JoinHostPort(ip, strconv.Itoa(int(spec.Port))) + spec.Path
+		switch spec.Type {
+		case TypeMetric:
+			value, err := m.metric(ctx, target, spec.Metric)
+			if err != nil {
+				busy = append(busy, fmt.Sprintf("%s: %v", ip, err))
+			} else if value > 0 {
+				inFlight += value
+				busy = append(busy, ip)
+			}
+		case TypeHTTP:
+			if !requested[ip] {
+				if err := m.request(ctx, http.MethodPost, target); err != nil {
+					busy = append(busy, fmt.Sprintf("%s: drain request failed: %v", ip, err))
+					continue
+				}
+				requested[ip] = true
+			}
+			if err := m.request(ctx, http.MethodGet, target); err != nil {
+				busy = append(busy, fmt.Sprintf("%s: %v", ip, err))
+			}
+		}
+	}
+	if len(busy) == 0 {
+		return "", nil
+	}
+	if spec.Type == TypeMetric && inFlight > 0 {
+		return fmt.Sprintf("%s=%g on %d pods", spec.Metric, inFlight, len(busy)), nil
+	}
+	return strings.Join(busy, "; "), nil
+}
+
+// podIPs returns the IPs of the deployment's running pods. Pods are included
+// whether or not they are ready, since draining pods often stop being ready.
+func (m *Manager) podIPs(ctx context.Context, namespace, name string) ([]string, error) {
+	deployment, err := m.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+	pods, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var ips []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && pod.Labels[prewarm.LabelPlaceholderFor] == "" {
+			ips = append(ips, pod.Status.PodIP)
+		}
+	}
+	return ips, nil
+}
+
+// request sends a request to a pod's drain endpoint and expects 2xx
+func (m *Manager) request(ctx context.Context, method, target string) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %d", method, req.URL.Path, resp.StatusCode)
+	}
+	return nil
+}
+
+// metric scrapes a Prometheus text endpoint and sums the samples of a metric
+// across all label sets
+func (m *Manager) metric(ctx context.Context, target, metric string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s returned %d", req.URL.Path, resp.StatusCode)
+	}
+	return sumMetric(io.LimitReader(resp.Body, maxResponseSize), metric)
+}
+
+// sumMetric sums the samples of a metric in the Prometheus text format
+func sumMetric(r io.Reader, metric string) (float64, error) {
+	var sum float64
+	found := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, metric) {
+			continue
+		}
+		rest := line[len(metric):]
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				continue
+			}
+			rest = rest[end+1:]
+		} else if !strings.HasPrefix(rest, " ") {
+			// Another metric sharing the prefix
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid sample of %s: %w", metric, err)
+		}
+		sum += value
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not found", metric)
+	}
+	return sum, nil
+}
@@ -0,0 +1,246 @@
+package drain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec(map[string]string{AnnotationDrain: `{"type":"metric","port":8002,"metric":"nv_inference_pending_request_count"}`})
+	require.NoError(t, err)
+	assert.Equal(t, "/metrics", spec.Path)
+	assert.Equal(t, DefaultTimeout, spec.timeout)
+
+	spec, err = ParseSpec(map[string]string{AnnotationDrain: "type: http\nport: 8080\npath: drain\ntimeout: 10m"})
+	require.NoError(t, err)
+	assert.Equal(t, "/drain", spec.Path)
+	assert.Equal(t, 10*time.Minute, spec.timeout)
+
+	spec, err = ParseSpec(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, spec)
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{value: `{"type":"grpc","port":8001}`, wantErr: "type must be"},
+		{value: `{"type":"metric","port":8002}`, wantErr: "metric is required"},
+		{value: `{"type":"http","port":8080}`, wantErr: "path is required"},
+		{value: `{"type":"http","path":"/drain"}`, wantErr: "port is required"},
+		{value: `{"type":"http","port":8080,"path":"/drain","timeout":"1h"}`, wantErr: "at most 30m0s"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSpec(map[string]string{AnnotationDrain: tt.value})
+		assert.ErrorContains(t, err, tt.wantErr, tt.value)
+	}
+}
+
+func TestSumMetric(t *testing.T) {
+	text := `# HELP nv_inference_pending_request_count Instantaneous number of pending requests
+# TYPE nv_inference_pending_request_count gauge
+nv_inference_pending_request_count{model="resnet50",version="1"} 2
+nv_inference_pending_request_count{model="densenet",version="1"} 1
+nv_inference_pending_request_count_total 7
+`
+	sum, err := sumMetric(strings.NewReader(text), "nv_inference_pending_request_count")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), sum)
+
+	_, err = sumMetric(strings.NewReader(text), "nv_inference_request_success")
+	assert.ErrorContains(t, err, "not found")
+}
+
+// setupManager returns a manager for a deployment whose single pod serves
+// handler on 127.0.0.1
+func setupManager(t *testing.T, handler http.Handler) (*mocks.MockK8sClient, *operation.Store, *Manager, int32) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	var port int32
+	_, err := fmt.Sscanf(server.URL, "http://127.0.0.1:%d", &port)
+	require.NoError(t, err)
+
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b", Namespace: "project-b"},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-app-b"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b-1", Namespace: "project-b", Labels: map[string]string{"app": "sample-app-b"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "127.0.0.1"},
+		},
+	)
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(clientset)
+	operations := operation.NewStore(operation.DefaultRetention)
+	manager := NewManager(mockClient, operations, &Config{PollInterval: time.Millisecond})
+	return mockClient, operations, manager, port
+}
+
+// finished waits for an operation to finish
+func finished(t *testing.T, operations *operation.Store, id string) operation.Operation {
+	t.Helper()
+	var op operation.Operation
+	require.Eventually(t, func() bool {
+		op, _ = operations.Get(id)
+		return op.Status != operation.StatusRunning
+	}, 5*time.Second, time.Millisecond)
+	return op
+}
+
+func TestStart_Metric(t *testing.T) {
+	// Setup: two scrapes with a request in flight, then idle
+	var scrapes atomic.Int32
+	mockClient, operations, manager, port := setupManager(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending := 0
+		if scrapes.Add(1) <= 2 {
+			pending = 1
+		}
+		_, _ = fmt.Fprintf(w, "nv_inference_pending_request_count{model=\"resnet50\",version=\"1\"} %d\n", pending)
+	}))
+	spec, err := ParseSpec(map[string]string{AnnotationDrain: fmt.Sprintf(`{"type":"metric","port":%d,"metric":"nv_inference_pending_request_count"}`, port)})
+	require.NoError(t, err)
+	status := mocks.MockDeploymentStatus("sample-app-b", "project-b", 1, 1)
+
+	// Mock expectations
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", map[string]*string(nil),
+		mock.MatchedBy(func(a map[string]*string) bool { return a[AnnotationDrainingSince] != nil })).Return(nil).Once()
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0)).Return(nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", map[string]*string(nil),
+		map[string]*string{AnnotationDrainingSince: nil}).Return(nil).Once()
+
+	// Test
+	released := make(chan struct{})
+	op, err := manager.Start(context.Background(), status, spec, "End of day", "alice", func() { close(released) })
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, OperationType, op.Type)
+	op = finished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Contains(t, op.Steps[0].Message, "idle after")
+	assert.Equal(t, "scaled to zero", op.Steps[1].Message)
+	assert.GreaterOrEqual(t, scrapes.Load(), int32(3))
+	<-released
+	mockClient.AssertExpectations(t)
+}
+
+func TestStart_HTTPTimeout(t *testing.T) {
+	// Setup: the app accepts the drain request but never becomes idle
+	var drainRequests atomic.Int32
+	mockClient, operations, manager, port := setupManager(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/drain", r.URL.Path)
+		if r.Method == http.MethodPost {
+			drainRequests.Add(1)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	spec, err := ParseSpec(map[string]string{AnnotationDrain: fmt.Sprintf(`{"type":"http","port":%d,"path":"/drain","timeout":"50ms"}`, port)})
+	require.NoError(t, err)
+	status := mocks.MockDeploymentStatus("sample-app-b", "project-b", 1, 1)
+
+	// Mock expectations
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0)).Return(nil)
+
+	// Test
+	op, err := manager.Start(context.Background(), status, spec, "End of day", "alice", func() {})
+	require.NoError(t, err)
+
+	// Assert: scaled to zero anyway
+	op = finished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Contains(t, op.Steps[0].Message, "timed out after 50ms")
+	assert.Contains(t, op.Steps[0].Message, "GET /drain returned 503")
+	assert.Equal(t, int32(1), drainRequests.Load())
+	mockClient.AssertCalled(t, "ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0))
+}
+
+func TestStart_ScaleError(t *testing.T) {
+	mockClient, operations, manager, port := setupManager(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	spec, err := ParseSpec(map[string]string{AnnotationDrain: fmt.Sprintf(`{"type":"http","port":%d,"path":"/drain"}`, port)})
+	require.NoError(t, err)
+
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0)).Return(fmt.Errorf("conflict"))
+
+	op, err := manager.Start(context.Background(), mocks.MockDeploymentStatus("sample-app-b", "project-b", 1, 1), spec, "End of day", "alice", func() {})
+	require.NoError(t, err)
+
+	op = finished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusFailed, op.Status)
+	assert.Equal(t, operation.StatusFailed, op.Steps[1].Status)
+	// The draining mark is removed
+	mockClient.AssertCalled(t, "PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", map[string]*string(nil), map[string]*string{AnnotationDrainingSince: nil})
+}
+
+func TestRecover(t *testing.T) {
+	// Setup: a drain cut short a minute ago, a stale mark and a deployment
+	// drained by another replica
+	mockClient, _, manager, port := setupManager(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	now := time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+	drainSpec := fmt.Sprintf(`{"type":"http","port":%d,"path":"/drain"}`, port)
+
+	resumed := mocks.MockDeploymentStatus("sample-app-b", "project-b", 1, 1)
+	resumed.Annotations = map[string]string{AnnotationDrain: drainSpec, AnnotationDrainingSince: now.Add(-time.Minute).Format(time.RFC3339)}
+	stale := mocks.MockDeploymentStatus("sample-app-a", "project-a", 1, 1)
+	stale.Annotations = map[string]string{AnnotationDrain: drainSpec, AnnotationDrainingSince: now.Add(-time.Hour).Format(time.RFC3339)}
+	locked := mocks.MockDeploymentStatus("sample-app-c", "project-b", 1, 1)
+	locked.Annotations = map[string]string{AnnotationDrain: drainSpec, AnnotationDrainingSince: now.Add(-time.Minute).Format(time.RFC3339)}
+	idle := mocks.MockDeploymentStatus("web", "project-a", 1, 1)
+
+	locker := lock.NewLocalLocker()
+	releaseLocked, err := locker.Acquire(context.Background(), lock.Key("project-b", "sample-app-c"), false)
+	require.NoError(t, err)
+	defer releaseLocked()
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").Return([]*k8s.DeploymentStatus{resumed, stale, locked, idle}, nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, mock.Anything, mock.Anything, map[string]*string(nil),
+		map[string]*string{AnnotationDrainingSince: nil}).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0)).Return(nil)
+
+	// Test
+	manager.Recover(context.Background(), locker)
+
+	// Assert: the drain is resumed and releases its lock when finished
+	require.Eventually(t, func() bool {
+		release, err := locker.Acquire(context.Background(), lock.Key("project-b", "sample-app-b"), false)
+		if err != nil {
+			return false
+		}
+		release()
+		return true
+	}, 5*time.Second, time.Millisecond)
+	mockClient.AssertCalled(t, "ScaleDeployment", mock.Anything, "project-b", "sample-app-b", int32(0))
+	mockClient.AssertCalled(t, "PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-b", map[string]*string(nil), map[string]*string{AnnotationDrainingSince: nil})
+
+	// The stale mark is removed without scaling
+	mockClient.AssertCalled(t, "PatchDeploymentMetadata", mock.Anything, "project-a", "sample-app-a", map[string]*string(nil), map[string]*string{AnnotationDrainingSince: nil})
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, "project-a", "sample-app-a", mock.Anything)
+
+	// Deployments locked elsewhere are left alone
+	mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, "project-b", "sample-app-c", mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, "project-a", "web", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,289 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/metrics"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationMode selects how drift of a deployment is handled
+const AnnotationMode = "scale-to-zero.io/drift"
+
+// Drift modes
+const (
+	// ModeReport only reports drift. It is the default.
+	ModeReport = "report"
+	// ModeEnforce re-applies the recorded replica count
+	ModeEnforce = "enforce"
+)
+
+// Event reasons recorded on drifting deployments
+const (
+	ReasonDrift          = "ReplicaDrift"
+	ReasonDriftCorrected = "ReplicaDriftCorrected"
+)
+
+// DefaultInterval is how often deployments are checked for drift
+const DefaultInterval = time.Minute
+
+// correctedBy is the principal of drift corrections in notifications
+const correctedBy = "scale-api"
+
+// Drift is a deployment whose replica count differs from the one last set
+// through the API
+type Drift struct {
+	Namespace        string
+	Name             string
+	RecordedReplicas int32
+	LiveReplicas     int32
+	RecordedAt       time.Time
+	// DetectedAt is when the drift was first seen
+	DetectedAt time.Time
+	Mode       string
+	// Corrected is set when the recorded replica count was re-applied
+	Corrected bool
+	// Error explains why a correction failed
+	Error string
+}
+
+// Detector periodically compares live replica counts with the recorded ones
+type Detector struct {
+	k8sClient k8s.ClientInterface
+	locker    lock.Locker
+	notifier  *notify.Notifier
+	now       func() time.Time
+
+	replicaDrift *metrics.Gauge
+	detected     *metrics.Counter
+	corrected    *metrics.Counter
+
+	mu        sync.RWMutex
+	drifts    map[string]*Drift
+	checkedAt time.Time
+}
+
+// NewDetector creates a new drift detector reporting to registry. The locker
+// serializes corrections with scale operations from the API.
+func NewDetector(k8sClient k8s.ClientInterface, locker lock.Locker, registry *metrics.Registry) *Detector {
+	return &Detector{
+		k8sClient: k8sClient,
+		locker:    locker,
+		now:       time.Now,
+		replicaDrift: registry.Gauge("scale_api_deployment_replica_drift",
+			"Live minus recorded replicas of deployments changed outside the Scale API.", "namespace", "deployment"),
+		detected: registry.Counter("scale_api_drift_detected_total",
+			"Replica changes made outside the Scale API.", "namespace"),
+		corrected: registry.Counter("scale_api_drift_corrected_total",
+			"Recorded replica counts re-applied to deployments in enforce mode.", "namespace"),
+		drifts: map[string]*Drift{},
+	}
+}
+
+// SetNotifier sets the notifier told when drift was corrected or the
+// correction failed
+func (d *Detector) SetNotifier(notifier *notify.Notifier) {
+	d.notifier = notifier
+}
+
+// Run checks for drift every interval until ctx is done
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Check(ctx); err != nil {
+				log.Printf("Failed to check deployments for replica drift: %v", err)
+			}
+		}
+	}
+}
+
+// Check compares every managed deployment with its recorded replica count.
+// New drift is logged and recorded as an Event; deployments in enforce mode
+// are scaled back to the recorded count. Deployments with an active
+// autoscaler are skipped.
+func (d *Detector) Check(ctx context.Context) error {
+	deployments, err := d.k8sClient.ListDeployments(ctx, "", "")
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	previous := d.drifts
+	d.mu.RUnlock()
+
+	now := d.now()
+	current := map[string]*Drift{}
+	for _, deployment := range deployments {
+		recorded, recordedAt, ok := Recorded(deployment.Annotations)
+		if !ok || deployment.DesiredReplicas == recorded {
+			continue
+		}
+		// Autoscalers change replicas by design, so their changes are not drift.
+		// Lists leave out autoscalers, so they are looked up for drifted
+		// deployments only.
+		status, err := d.k8sClient.GetDeploymentStatus(ctx, deployment.Namespace, deployment.Name)
+		if err != nil {
+			log.Printf("Failed to check autoscalers of deployment %s/%s for drift: %v", deployment.Namespace, deployment.Name, err)
+			continue
+		}
+		if autoscaled(status) {
+			continue
+		}
+
+		key := lock.Key(deployment.Namespace, deployment.Name)
+		drift := &Drift{
+			Namespace:        deployment.Namespace,
+			Name:             deployment.Name,
+			RecordedReplicas: recorded,
+			LiveReplicas:     deployment.DesiredReplicas,
+			RecordedAt:       recordedAt,
+			DetectedAt:       now,
+			Mode:             Mode(deployment.Annotations),
+		}
+		if p, ok := previous[key]; ok && p.RecordedReplicas == recorded && p.LiveReplicas == drift.LiveReplicas && !p.Corrected {
+			drift.DetectedAt = p.DetectedAt
+		} else {
+			d.report(ctx, drift)
+		}
+
+		if drift.Mode == ModeEnforce {
+			d.correct(ctx, drift)
+		}
+		current[key] = drift
+	}
+
+	d.replicaDrift.Reset()
+	for _, drift := range current {
+		if !drift.Corrected {
+			d.replicaDrift.Set(float64(drift.LiveReplicas-drift.RecordedReplicas), drift.Namespace, drift.Name)
+		}
+	}
+
+	d.mu.Lock()
+	d.drifts = current
+	d.checkedAt = now
+	d.mu.Unlock()
+	return nil
+}
+
+// List returns the drift found by the last check, optionally limited to a
+// namespace, and when that check ran. Corrected drift is listed until the
+// next check.
+func (d *Detector) List(namespace string) ([]Drift, time.Time) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	drifts := make([]Drift, 0, len(d.drifts))
+	for _, drift := range d.drifts {
+		if namespace == "" || drift.Namespace == namespace {
+			drifts = append(drifts, *drift)
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Namespace != drifts[j].Namespace {
+			return drifts[i].Namespace < drifts[j].Namespace
+		}
+		return drifts[i].Name < drifts[j].Name
+	})
+	return drifts, d.checkedAt
+}
+
+// report logs newly detected drift and records it as an Event
+func (d *Detector) report(ctx context.Context, drift *Drift) {
+	d.detected.Inc(drift.Namespace)
+
+	message := fmt.Sprintf("replicas changed outside the Scale API: %d recorded at %s, %d live",
+		drift.RecordedReplicas, drift.RecordedAt.Format(time.RFC3339), drift.LiveReplicas)
+	log.Printf("Deployment %s/%s drifted: %s", drift.Namespace, drift.Name, message)
+	if err := d.k8sClient.RecordDeploymentEvent(ctx, drift.Namespace, drift.Name, corev1.EventTypeWarning, ReasonDrift, message); err != nil {
+		log.Printf("Failed to record drift event: %v", err)
+	}
+}
+
+// correct re-applies the recorded replica count under the deployment lock.
+// Deployments locked by an ongoing operation are retried on the next check.
+func (d *Detector) correct(ctx context.Context, drift *Drift) {
+	release, err := d.locker.Acquire(ctx, lock.Key(drift.Namespace, drift.Name), false)
+	if err != nil {
+		drift.Error = err.Error()
+		return
+	}
+	defer release()
+
+	// The API may have scaled the deployment since it was listed
+	status, err := d.k8sClient.GetDeploymentStatus(ctx, drift.Namespace, drift.Name)
+	if err != nil {
+		drift.Error = err.Error()
+		return
+	}
+	recorded, _, ok := Recorded(status.Annotations)
+	if !ok || status.DesiredReplicas == recorded || autoscaled(status) {
+		drift.Corrected = true
+		return
+	}
+
+	err = d.k8sClient.ScaleDeployment(ctx, drift.Namespace, drift.Name, recorded)
+	d.notifier.NotifyScale(drift.Namespace, drift.Name, status.DesiredReplicas, recorded, correctedBy, "replica drift corrected", err)
+	if err != nil {
+		drift.Error = err.Error()
+		log.Printf("Failed to correct replica drift of deployment %s/%s: %v", drift.Namespace, drift.Name, err)
+		return
+	}
+	drift.Corrected = true
+	d.corrected.Inc(drift.Namespace)
+
+	message := fmt.Sprintf("scaled from %d back to the recorded %d replicas", status.DesiredReplicas, recorded)
+	log.Printf("Deployment %s/%s drift corrected: %s", drift.Namespace, drift.Name, message)
+	if err := d.k8sClient.RecordDeploymentEvent(ctx, drift.Namespace, drift.Name, corev1.EventTypeNormal, ReasonDriftCorrected, message); err != nil {
+		log.Printf("Failed to record drift event: %v", err)
+	}
+}
+
+// Recorded returns the replica count last set through the API and when
+func Recorded(annotations map[string]string) (int32, time.Time, bool) {
+	value, ok := annotations[k8s.AnnotationRecordedReplicas]
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 0 {
+		return 0, time.Time{}, false
+	}
+	// A missing or invalid time does not invalidate the replica count
+	recordedAt, _ := time.Parse(time.RFC3339, annotations[k8s.AnnotationRecordedAt])
+	return int32(replicas), recordedAt, true
+}
+
+// autoscaled reports whether a deployment has an autoscaler that is not
+// paused
+func autoscaled(status *k8s.DeploymentStatus) bool {
+	for _, a := range status.Autoscalers {
+		if !a.Paused {
+			return true
+		}
+	}
+	return false
+}
+
+// Mode returns the drift mode declared in a deployment's annotations
+func Mode(annotations map[string]string) string {
+	if annotations[AnnotationMode] == ModeEnforce {
+		return ModeEnforce
+	}
+	return ModeReport
+}
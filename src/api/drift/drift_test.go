@@ -0,0 +1,222 @@
+package drift
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/metrics"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func recordedStatus(name string, recorded, live int32, mode string) *k8s.DeploymentStatus {
+	status := mocks.MockDeploymentStatus(name, "test-ns", live, live)
+	status.Annotations = map[string]string{
+		k8s.AnnotationRecordedReplicas: strconv.Itoa(int(recorded)),
+		k8s.AnnotationRecordedAt:       "2025-07-14T09:00:00Z",
+	}
+	if mode != "" {
+		status.Annotations[AnnotationMode] = mode
+	}
+	return status
+}
+
+func metricsText(t *testing.T, registry *metrics.Registry) string {
+	t.Helper()
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	return b.String()
+}
+
+func TestCheck_Report(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	registry := metrics.NewRegistry()
+	detector := NewDetector(mockClient, lock.NewLocalLocker(), registry)
+	first := time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC)
+	detector.now = func() time.Time { return first }
+
+	drifted := recordedStatus("drifted-app", 0, 3, "")
+	inSync := recordedStatus("in-sync-app", 2, 2, "")
+	unrecorded := mocks.MockDeploymentStatus("unrecorded-app", "test-ns", 5, 5)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{drifted, inSync, unrecorded}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "drifted-app").Return(drifted, nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "drifted-app", corev1.EventTypeWarning, ReasonDrift, mock.Anything).
+		Return(nil).Once()
+
+	// Test
+	require.NoError(t, detector.Check(context.Background()))
+	detector.now = func() time.Time { return first.Add(time.Minute) }
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Assert
+	drifts, checkedAt := detector.List("")
+	require.Len(t, drifts, 1)
+	assert.Equal(t, "drifted-app", drifts[0].Name)
+	assert.Equal(t, int32(0), drifts[0].RecordedReplicas)
+	assert.Equal(t, int32(3), drifts[0].LiveReplicas)
+	assert.Equal(t, ModeReport, drifts[0].Mode)
+	assert.Equal(t, first, drifts[0].DetectedAt)
+	assert.Equal(t, time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC), drifts[0].RecordedAt)
+	assert.False(t, drifts[0].Corrected)
+	assert.Equal(t, first.Add(time.Minute), checkedAt)
+
+	others, _ := detector.List("other-ns")
+	assert.Empty(t, others)
+
+	text := metricsText(t, registry)
+	assert.Contains(t, text, `scale_api_deployment_replica_drift{namespace="test-ns",deployment="drifted-app"} 3`)
+	assert.Contains(t, text, `scale_api_drift_detected_total{namespace="test-ns"} 1`)
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+func TestCheck_Resolved(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	registry := metrics.NewRegistry()
+	detector := NewDetector(mockClient, lock.NewLocalLocker(), registry)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{recordedStatus("app", 0, 2, "")}, nil).Once()
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "app").Return(recordedStatus("app", 0, 2, ""), nil).Once()
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{recordedStatus("app", 0, 0, "")}, nil).Once()
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "app", corev1.EventTypeWarning, ReasonDrift, mock.Anything).
+		Return(nil).Once()
+
+	// Test
+	require.NoError(t, detector.Check(context.Background()))
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Assert
+	drifts, _ := detector.List("")
+	assert.Empty(t, drifts)
+	assert.NotContains(t, metricsText(t, registry), "scale_api_deployment_replica_drift{")
+	mockClient.AssertExpectations(t)
+}
+
+func TestCheck_Enforce(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	registry := metrics.NewRegistry()
+	detector := NewDetector(mockClient, lock.NewLocalLocker(), registry)
+
+	drifted := recordedStatus("enforced-app", 1, 4, ModeEnforce)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{drifted}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "enforced-app").Return(drifted, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "enforced-app", int32(1)).Return(nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "enforced-app", corev1.EventTypeWarning, ReasonDrift, mock.Anything).
+		Return(nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "enforced-app", corev1.EventTypeNormal, ReasonDriftCorrected, mock.Anything).
+		Return(nil)
+
+	// Test
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Assert
+	drifts, _ := detector.List("test-ns")
+	require.Len(t, drifts, 1)
+	assert.Equal(t, ModeEnforce, drifts[0].Mode)
+	assert.True(t, drifts[0].Corrected)
+	assert.Empty(t, drifts[0].Error)
+
+	text := metricsText(t, registry)
+	assert.Contains(t, text, `scale_api_drift_corrected_total{namespace="test-ns"} 1`)
+	assert.NotContains(t, text, "scale_api_deployment_replica_drift{")
+	mockClient.AssertExpectations(t)
+}
+
+func TestCheck_EnforceLocked(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	detector := NewDetector(mockClient, locker, metrics.NewRegistry())
+
+	release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "enforced-app"), false)
+	require.NoError(t, err)
+	defer release()
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{recordedStatus("enforced-app", 1, 4, ModeEnforce)}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "enforced-app").Return(recordedStatus("enforced-app", 1, 4, ModeEnforce), nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "enforced-app", corev1.EventTypeWarning, ReasonDrift, mock.Anything).
+		Return(nil)
+
+	// Test
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Assert
+	drifts, _ := detector.List("")
+	require.Len(t, drifts, 1)
+	assert.False(t, drifts[0].Corrected)
+	assert.Equal(t, lock.ErrLocked.Error(), drifts[0].Error)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCheck_Autoscaled(t *testing.T) {
+	// Setup: an HPA scaled the deployment out; a paused one does not count
+	mockClient := mocks.NewMockK8sClient()
+	registry := metrics.NewRegistry()
+	detector := NewDetector(mockClient, lock.NewLocalLocker(), registry)
+
+	autoscaledApp := recordedStatus("autoscaled-app", 1, 4, ModeEnforce)
+	autoscaledApp.Autoscalers = []k8s.Autoscaler{{Kind: k8s.KindHorizontalPodAutoscaler, Name: "autoscaled-app", MinReplicas: 1, MaxReplicas: 4}}
+	pausedApp := recordedStatus("paused-app", 0, 2, "")
+	pausedApp.Autoscalers = []k8s.Autoscaler{{Kind: k8s.KindScaledObject, Name: "paused-app", MaxReplicas: 4, Paused: true}}
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{autoscaledApp, pausedApp}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "autoscaled-app").Return(autoscaledApp, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "paused-app").Return(pausedApp, nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, "test-ns", "paused-app", corev1.EventTypeWarning, ReasonDrift, mock.Anything).
+		Return(nil)
+
+	// Test
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Assert
+	drifts, _ := detector.List("")
+	require.Len(t, drifts, 1)
+	assert.Equal(t, "paused-app", drifts[0].Name)
+	assert.NotContains(t, metricsText(t, registry), `deployment="autoscaled-app"`)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+func TestRecorded(t *testing.T) {
+	replicas, recordedAt, ok := Recorded(map[string]string{
+		k8s.AnnotationRecordedReplicas: "3",
+		k8s.AnnotationRecordedAt:       "2025-07-14T09:00:00Z",
+	})
+	require.True(t, ok)
+	assert.Equal(t, int32(3), replicas)
+	assert.Equal(t, time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC), recordedAt)
+
+	_, _, ok = Recorded(map[string]string{k8s.AnnotationRecordedReplicas: "three"})
+	assert.False(t, ok)
+
+	_, _, ok = Recorded(map[string]string{k8s.AnnotationRecordedReplicas: "-1"})
+	assert.False(t, ok)
+
+	_, _, ok = Recorded(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,646 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Default scale group ConfigMap location and timings
+const (
+	DefaultConfigMapNamespace = "scale-system"
+	DefaultConfigMapName      = "scale-groups"
+	DefaultCacheTTL           = 30 * time.Second
+	DefaultReadinessTimeout   = 5 * time.Minute
+	DefaultPollInterval       = 2 * time.Second
+
+	// configMapKey is the ConfigMap data key holding the group document
+	configMapKey = "groups.yaml"
+	// lockWaitTimeout bounds how long a member waits for a concurrent
+	// operation on the same deployment
+	lockWaitTimeout = 60 * time.Second
+)
+
+// Group scale directions
+const (
+	DirectionScaleUp     = "scale-up"
+	DirectionScaleToZero = "scale-to-zero"
+)
+
+// ErrNotFound is returned for groups not defined in the ConfigMap
+var ErrNotFound = errors.New("scale group not found")
+
+// Member is a deployment of a scale group
+type Member struct {
+	// Namespace defaults to the group namespace
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Replicas is the replica count the member is scaled up to
+	Replicas int32 `json:"replicas"`
+	// MinAvailable is the number of available replicas the next stage waits
+	// for. Defaults to Replicas.
+	MinAvailable *int32 `json:"minAvailable,omitempty"`
+	// DependsOn lists members ("name" or "namespace/name") that must be
+	// available before this member is scaled up
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Group is a set of deployments scaled together in dependency order
+type Group struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Members   []Member `json:"members"`
+	// ReadinessTimeout bounds how long each stage may take to become available
+	ReadinessTimeout string `json:"readinessTimeout,omitempty"`
+
+	// stages holds member indices in scale-up order, computed by Validate
+	stages [][]int
+}
+
+// Document is the scale group ConfigMap content
+type Document struct {
+	Groups map[string]*Group `json:"groups,omitempty"`
+}
+
+// Key returns the namespace/name of a member
+func (m *Member) Key() string {
+	return m.Namespace + "/" + m.Name
+}
+
+// Available returns the number of available replicas the member needs before
+// dependent members are scaled up
+func (m *Member) Available() int32 {
+	if m.MinAvailable != nil {
+		return *m.MinAvailable
+	}
+	return m.Replicas
+}
+
+// Timeout returns the readiness timeout of a stage
+func (g *Group) Timeout() time.Duration {
+	if d, err := time.ParseDuration(g.ReadinessTimeout); err == nil {
+		return d
+	}
+	return DefaultReadinessTimeout
+}
+
+// Stages returns the members in scale-up order. Members of the same stage do
+// not depend on each other and are scaled together.
+func (g *Group) Stages() [][]Member {
+	stages := make([][]Member, len(g.stages))
+	for i, indices := range g.stages {
+		for _, index := range indices {
+			stages[i] = append(stages[i], g.Members[index])
+		}
+	}
+	return stages
+}
+
+// Validate checks the group, fills in member namespaces and orders the
+// members into stages. Dependencies must name members of the group and must
+// not form a cycle.
+func (g *Group) Validate() error {
+	if len(g.Members) == 0 {
+		return fmt.Errorf("at least one member is required")
+	}
+	if g.ReadinessTimeout != "" {
+		if d, err := time.ParseDuration(g.ReadinessTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid readinessTimeout %q", g.ReadinessTimeout)
+		}
+	}
+
+	index := make(map[string]int, len(g.Members))
+	for i := range g.Members {
+		m := &g.Members[i]
+		if m.Namespace == "" {
+			m.Namespace = g.Namespace
+		}
+		if m.Namespace == "" || m.Name == "" {
+			return fmt.Errorf("member %d: namespace and name are required", i)
+		}
+		if m.Replicas < 1 {
+			return fmt.Errorf("member %s: replicas must be at least 1", m.Key())
+		}
+		if m.MinAvailable != nil && (*m.MinAvailable < 0 || *m.MinAvailable > m.Replicas) {
+			return fmt.Errorf("member %s: minAvailable must be between 0 and replicas", m.Key())
+		}
+		if _, ok := index[m.Key()]; ok {
+			return fmt.Errorf("member %s is listed twice", m.Key())
+		}
+		index[m.Key()] = i
+	}
+
+	// Resolve dependencies to member indices
+	deps := make([][]int, len(g.Members))
+	for i := range g.Members {
+		m := &g.Members[i]
+		for _, dep := range m.DependsOn {
+			key := dep
+			if !strings.Contains(dep, "/") {
+				key = m.Namespace + "/" + dep
+			}
+			j, ok := index[key]
+			if !ok {
+				return fmt.Errorf("member %s depends on %s, which is not a member", m.Key(), dep)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	// A member's stage is one after the latest stage it depends on
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(g.Members))
+	stage := make([]int, len(g.Members))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through member %s", g.Members[i].Key())
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+			if stage[j]+1 > stage[i] {
+				stage[i] = stage[j] + 1
+			}
+		}
+		state[i] = done
+		return nil
+	}
+
+	g.stages = nil
+	for i := range g.Members {
+		if err := visit(i); err != nil {
+			return err
+		}
+		for len(g.stages) <= stage[i] {
+			g.stages = append(g.stages, nil)
+		}
+	}
+	for i := range g.Members {
+		g.stages[stage[i]] = append(g.stages[stage[i]], i)
+	}
+	return nil
+}
+
+// ParseDocument parses and validates a scale group document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse scale group document: %w", err)
+	}
+
+	for name, g := range doc.Groups {
+		if g == nil {
+			delete(doc.Groups, name)
+			continue
+		}
+		if err := g.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid scale group %s: %w", name, err)
+		}
+	}
+	return doc, nil
+}
+
+// Config holds scale group configuration
+type Config struct {
+	ConfigMapNamespace string
+	ConfigMapName      string
+	CacheTTL           time.Duration
+	PollInterval       time.Duration
+}
+
+// NewConfig returns the default scale group configuration
+func NewConfig() *Config {
+	return &Config{
+		ConfigMapNamespace: DefaultConfigMapNamespace,
+		ConfigMapName:      DefaultConfigMapName,
+		CacheTTL:           DefaultCacheTTL,
+		PollInterval:       DefaultPollInterval,
+	}
+}
+
+// Manager scales the scale groups declared in the scale group ConfigMap. Group
+// operations run in the background and report progress to the operation store.
+type Manager struct {
+	k8sClient    k8s.ClientInterface
+	locker       lock.Locker
+	policyEngine *policy.Engine
+	quotaEngine  *quota.Engine
+	operations   *operation.Store
+	notifier     *notify.Notifier
+	drains       *drain.Manager
+	config       *Config
+	now          func() time.Time
+	// ctx is the context group operations run under
+	ctx context.Context
+
+	mu       sync.Mutex
+	cached   *Document
+	cachedAt time.Time
+}
+
+// NewManager creates a new scale group manager. Members are scaled under
+// their deployment lock and checked against scaling policies and, when
+// quotaEngine is not nil, namespace quotas.
+func NewManager(k8sClient k8s.ClientInterface, locker lock.Locker, policyEngine *policy.Engine, quotaEngine *quota.Engine, operations *operation.Store, config *Config) *Manager {
+	return &Manager{
+		k8sClient:    k8sClient,
+		locker:       locker,
+		policyEngine: policyEngine,
+		quotaEngine:  quotaEngine,
+		operations:   operations,
+		config:       config,
+		now:          time.Now,
+		ctx:          context.Background(),
+	}
+}
+
+// SetNotifier sets the notifier told when a member was scaled or failed to be
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// SetDrainManager sets the drain manager used to drain members that declare a
+// drain before they are scaled to zero. It must record its operations in the
+// same operation store as m.
+func (m *Manager) SetDrainManager(drains *drain.Manager) {
+	m.drains = drains
+}
+
+// SetContext sets the context group operations run under. Operations outlive
+// the request that started them; cancelling ctx, for example on shutdown,
+// stops them at the next member.
+func (m *Manager) SetContext(ctx context.Context) {
+	m.ctx = ctx
+}
+
+// List returns the names of all scale groups, sorted
+func (m *Manager) List(ctx context.Context) ([]string, error) {
+	doc, err := m.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(doc.Groups))
+	for name := range doc.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns a scale group
+func (m *Manager) Get(ctx context.Context, name string) (*Group, error) {
+	doc, err := m.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ok := doc.Groups[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return g, nil
+}
+
+// Start begins scaling a group in the background and returns the operation
+// tracking it. Scale-ups go stage by stage, waiting for each stage to become
+// available; scale-to-zero goes through the stages in reverse, draining the
+// members that declare a drain. It returns lock.ErrLocked if the group is
+// already being scaled.
+func (m *Manager) Start(ctx context.Context, name, direction, reason, startedBy string) (operation.Operation, error) {
+	g, err := m.Get(ctx, name)
+	if err != nil {
+		return operation.Operation{}, err
+	}
+
+	release, err := m.locker.Acquire(ctx, lock.GroupKey(name), false)
+	if err != nil {
+		return operation.Operation{}, err
+	}
+
+	stages := g.Stages()
+	if direction == DirectionScaleToZero {
+		for i, j := 0, len(stages)-1; i < j; i, j = i+1, j-1 {
+			stages[i], stages[j] = stages[j], stages[i]
+		}
+	}
+
+	var steps []operation.Step
+	for i, members := range stages {
+		for _, member := range members {
+			replicas := member.Replicas
+			if direction == DirectionScaleToZero {
+				replicas = 0
+			}
+			steps = append(steps, operation.Step{Stage: i, Namespace: member.Namespace, Name: member.Name, Replicas: replicas})
+		}
+	}
+
+	op := m.operations.Start("group-"+direction, name, reason, startedBy, steps)
+	log.Printf("Started %s of scale group %s (operation %s)", direction, name, op.ID)
+
+	// The operation outlives the request that started it
+	go func() {
+		defer release()
+		memberReason := fmt.Sprintf("%s (scale group %s)", reason, name)
+		err := m.run(m.ctx, op.ID, g, stages, direction, memberReason, startedBy)
+		m.operations.Finish(op.ID, err)
+		if err != nil {
+			log.Printf("Failed %s of scale group %s (operation %s): %v", direction, name, op.ID, err)
+			return
+		}
+		log.Printf("Completed %s of scale group %s (operation %s)", direction, name, op.ID)
+	}()
+	return op, nil
+}
+
+// run scales the members stage by stage. It stops at the first member that
+// fails; members already scaled are left as they are. Each member's scale is
+// notified with reason and startedBy.
+func (m *Manager) run(ctx context.Context, opID string, g *Group, stages [][]Member, direction, reason, startedBy string) error {
+	step := 0
+	for _, members := range stages {
+		first := step
+		for _, member := range members {
+			m.operations.UpdateStep(opID, step, operation.StatusRunning, "")
+			var message string
+			var err error
+			if direction == DirectionScaleUp {
+				message, err = m.scaleUp(ctx, member, reason, startedBy)
+			} else {
+				message, err = m.scaleToZero(ctx, member, reason, startedBy)
+			}
+			if err != nil {
+				m.operations.UpdateStep(opID, step, operation.StatusFailed, err.Error())
+				return fmt.Errorf("member %s: %w", member.Key(), err)
+			}
+			if direction == DirectionScaleToZero {
+				m.operations.UpdateStep(opID, step, operation.StatusSucceeded, message)
+			} else {
+				m.operations.UpdateStep(opID, step, operation.StatusRunning, message+", waiting for available replicas")
+			}
+			step++
+		}
+
+		if direction == DirectionScaleUp {
+			if err := m.waitAvailable(ctx, opID, first, members, g.Timeout()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitAvailable polls the members of a stage until each has its required
+// available replicas or the timeout elapses
+func (m *Manager) waitAvailable(ctx context.Context, opID string, first int, members []Member, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	ready := make([]bool, len(members))
+	for {
+		pending := 0
+		for i, member := range members {
+			if ready[i] {
+				continue
+			}
+			status, err := m.k8sClient.GetDeploymentStatus(ctx, member.Namespace, member.Name)
+			if err == nil && status.AvailableReplicas >= member.Available() {
+				ready[i] = true
+				m.operations.UpdateStep(opID, first+i, operation.StatusSucceeded,
+					fmt.Sprintf("%d replicas available", status.AvailableReplicas))
+				continue
+			}
+			pending++
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			stopped := !errors.Is(ctx.Err(), context.DeadlineExceeded)
+			for i, member := range members {
+				if !ready[i] {
+					message := fmt.Sprintf("fewer than %d replicas available after %s", member.Available(), timeout)
+					if stopped {
+						message = "stopped waiting for available replicas"
+					}
+					m.operations.UpdateStep(opID, first+i, operation.StatusFailed, message)
+				}
+			}
+			if stopped {
+				return fmt.Errorf("stopped waiting for the stage: %w", ctx.Err())
+			}
+			return fmt.Errorf("stage did not become available within %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// scaleUp scales one member up under its deployment lock
+func (m *Manager) scaleUp(ctx context.Context, member Member, reason, startedBy string) (string, error) {
+	release, err := m.acquire(ctx, lock.Key(member.Namespace, member.Name))
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	status, err := m.k8sClient.GetDeploymentStatus(ctx, member.Namespace, member.Name)
+	if err != nil {
+		return "", err
+	}
+	if status.DesiredReplicas >= member.Replicas {
+		return fmt.Sprintf("already at %d replicas", status.DesiredReplicas), nil
+	}
+
+	decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+		Namespace:   member.Namespace,
+		Name:        member.Name,
+		Annotations: status.Annotations,
+		Operation:   policy.OperationScaleUp,
+		Replicas:    member.Replicas,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !decision.Allowed {
+		return "", fmt.Errorf("scaling policy violation: %s", strings.Join(decision.Violations, "; "))
+	}
+	if decision.RequiresApproval() {
+		return "", fmt.Errorf("scale-up requires approval: %s", strings.Join(decision.ApprovalReasons, "; "))
+	}
+
+	if m.quotaEngine != nil {
+		releaseNamespace, err := m.acquire(ctx, lock.NamespaceKey(member.Namespace))
+		if err != nil {
+			return "", err
+		}
+		defer releaseNamespace()
+
+		quotaDecision, err := m.quotaEngine.Check(ctx, member.Namespace, member.Name, member.Replicas)
+		if err != nil {
+			return "", err
+		}
+		if !quotaDecision.Allowed {
+			return "", fmt.Errorf("namespace quota exceeded: %s", strings.Join(quotaDecision.Violations, "; "))
+		}
+	}
+
+	err = m.k8sClient.ScaleDeployment(ctx, member.Namespace, member.Name, member.Replicas)
+	m.notifier.NotifyScale(member.Namespace, member.Name, status.DesiredReplicas, member.Replicas, startedBy, reason, err)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("scaled from %d to %d replicas", status.DesiredReplicas, member.Replicas), nil
+}
+
+// scaleToZero scales one member to zero under its deployment lock. Members
+// that declare a drain are drained first and the drain is waited for.
+func (m *Manager) scaleToZero(ctx context.Context, member Member, reason, startedBy string) (string, error) {
+	release, err := m.acquire(ctx, lock.Key(member.Namespace, member.Name))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	status, err := m.k8sClient.GetDeploymentStatus(ctx, member.Namespace, member.Name)
+	if err != nil {
+		return "", err
+	}
+	if status.DesiredReplicas == 0 {
+		return "already scaled to zero", nil
+	}
+
+	decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+		Namespace:   member.Namespace,
+		Name:        member.Name,
+		Annotations: status.Annotations,
+		Operation:   policy.OperationScaleToZero,
+	})
+	if err != nil {
+		return "", err
+	}
+	if !decision.Allowed {
+		return "", fmt.Errorf("scaling policy violation: %s", strings.Join(decision.Violations, "; "))
+	}
+
+	// The drain takes the deployment lock over and notifies the scale
+	op, err := m.drains.StartDeclared(ctx, status, reason, startedBy, release)
+	if err != nil {
+		return "", err
+	}
+	message := fmt.Sprintf("scaled from %d to 0 replicas", status.DesiredReplicas)
+	if op != nil {
+		release = nil
+		if err := m.waitDrain(ctx, op.ID); err != nil {
+			return "", err
+		}
+		message = fmt.Sprintf("drained and scaled from %d to 0 replicas (operation %s)", status.DesiredReplicas, op.ID)
+	} else {
+		err = m.k8sClient.ScaleDeployment(ctx, member.Namespace, member.Name, 0)
+		m.notifier.NotifyScale(member.Namespace, member.Name, status.DesiredReplicas, 0, startedBy, reason, err)
+		if err != nil {
+			return "", err
+		}
+	}
+	if status.Labels[lease.LabelLeased] == "true" {
+		if err := lease.Clear(ctx, m.k8sClient, member.Namespace, member.Name); err != nil {
+			log.Printf("Failed to release lease of deployment %s: %v", member.Key(), err)
+		}
+	}
+	return message, nil
+}
+
+// waitDrain polls a drain operation until it finishes
+func (m *Manager) waitDrain(ctx context.Context, opID string) error {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		op, ok := m.operations.Get(opID)
+		if !ok {
+			return fmt.Errorf("drain operation %s not found", opID)
+		}
+		switch op.Status {
+		case operation.StatusSucceeded:
+			return nil
+		case operation.StatusFailed:
+			return fmt.Errorf("drain operation %s failed: %s", opID, op.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for drain operation %s: %w", opID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquire waits for a lock held by a concurrent operation
+func (m *Manager) acquire(ctx context.Context, key string) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, lockWaitTimeout)
+	defer cancel()
+	return m.locker.Acquire(ctx, key, true)
+}
+
+// document returns the scale group document from the ConfigMap, cached for CacheTTL
+func (m *Manager) document(ctx context.Context) (*Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cached != nil && m.now().Sub(m.cachedAt) < m.config.CacheTTL {
+		return m.cached, nil
+	}
+
+	configMap, err := m.k8sClient.GetClientset().CoreV1().ConfigMaps(m.config.ConfigMapNamespace).Get(ctx, m.config.ConfigMapName, metav1.GetOptions{})
+	var doc *Document
+	switch {
+	case k8serrors.IsNotFound(err):
+		doc = &Document{}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get scale group configmap %s/%s: %w", m.config.ConfigMapNamespace, m.config.ConfigMapName, err)
+	default:
+		doc, err = ParseDocument([]byte(configMap.Data[configMapKey]))
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %w", m.config.ConfigMapNamespace, m.config.ConfigMapName, err)
+		}
+	}
+
+	m.cached = doc
+	m.cachedAt = m.now()
+	return doc, nil
+}
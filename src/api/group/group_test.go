@@ -0,0 +1,278 @@
+package group
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testDocument = `
+groups:
+  inference-b:
+    namespace: project-b
+    readinessTimeout: 1s
+    members:
+      - name: frontend
+        replicas: 2
+        minAvailable: 1
+        dependsOn: [preprocessor]
+      - name: preprocessor
+        replicas: 1
+        dependsOn: [model-server]
+      - name: model-server
+        replicas: 1
+      - name: metrics
+        replicas: 1
+`
+
+func TestParseDocument_Stages(t *testing.T) {
+	doc, err := ParseDocument([]byte(testDocument))
+	require.NoError(t, err)
+
+	g := doc.Groups["inference-b"]
+	require.NotNil(t, g)
+	assert.Equal(t, time.Second, g.Timeout())
+
+	var names [][]string
+	for _, members := range g.Stages() {
+		var stage []string
+		for _, member := range members {
+			assert.Equal(t, "project-b", member.Namespace)
+			stage = append(stage, member.Name)
+		}
+		names = append(names, stage)
+	}
+	assert.Equal(t, [][]string{{"model-server", "metrics"}, {"preprocessor"}, {"frontend"}}, names)
+}
+
+func TestParseDocument_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "no members",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members: []\n",
+			wantErr: "at least one member",
+		},
+		{
+			name:    "unknown dependency",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members:\n      - {name: a, replicas: 1, dependsOn: [b]}\n",
+			wantErr: "not a member",
+		},
+		{
+			name:    "cycle",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members:\n      - {name: a, replicas: 1, dependsOn: [b]}\n      - {name: b, replicas: 1, dependsOn: [a]}\n",
+			wantErr: "dependency cycle",
+		},
+		{
+			name:    "zero replicas",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members:\n      - {name: a, replicas: 0}\n",
+			wantErr: "replicas must be at least 1",
+		},
+		{
+			name:    "minAvailable above replicas",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members:\n      - {name: a, replicas: 1, minAvailable: 2}\n",
+			wantErr: "minAvailable",
+		},
+		{
+			name:    "unknown field",
+			doc:     "groups:\n  g:\n    namespace: ns\n    members:\n      - {name: a, replicas: 1, after: [b]}\n",
+			wantErr: "failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDocument([]byte(tt.doc))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func newTestManager(mockClient *mocks.MockK8sClient) (*Manager, *operation.Store) {
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultConfigMapName, Namespace: DefaultConfigMapNamespace},
+		Data:       map[string]string{configMapKey: testDocument},
+	})
+	mockClient.On("GetClientset").Return(clientset)
+
+	operations := operation.NewStore(operation.DefaultRetention)
+	config := NewConfig()
+	config.PollInterval = 10 * time.Millisecond
+	m := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()), nil, operations, config)
+	return m, operations
+}
+
+// waitFinished polls the store until the operation is no longer running
+func waitFinished(t *testing.T, operations *operation.Store, id string) operation.Operation {
+	t.Helper()
+	var op operation.Operation
+	require.Eventually(t, func() bool {
+		op, _ = operations.Get(id)
+		return op.Status != operation.StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return op
+}
+
+func TestStart_ScaleUpInOrder(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, operations := newTestManager(mockClient)
+
+	var order []string
+	for _, name := range []string{"model-server", "metrics", "preprocessor", "frontend"} {
+		name := name
+		// Before scaling: at zero. Afterwards: available.
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).
+			Return(mocks.MockDeploymentStatus(name, "project-b", 0, 0), nil).Once()
+		mockClient.On("ScaleDeployment", mock.Anything, "project-b", name, mock.Anything).
+			Run(func(mock.Arguments) { order = append(order, name) }).Return(nil)
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).
+			Return(mocks.MockDeploymentStatus(name, "project-b", 2, 2), nil)
+	}
+
+	op, err := m.Start(context.Background(), "inference-b", DirectionScaleUp, "Demo", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "group-scale-up", op.Type)
+	require.Len(t, op.Steps, 4)
+
+	finished := waitFinished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusSucceeded, finished.Status, finished.Error)
+	assert.Equal(t, []string{"model-server", "metrics", "preprocessor", "frontend"}, order)
+	for _, step := range finished.Steps {
+		assert.Equal(t, operation.StatusSucceeded, step.Status)
+	}
+	assert.Equal(t, 2, finished.Steps[3].Stage)
+}
+
+func TestStart_ReadinessTimeoutStops(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, operations := newTestManager(mockClient)
+
+	// model-server never becomes available
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "model-server").
+		Return(mocks.MockDeploymentStatus("model-server", "project-b", 0, 0), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "model-server", int32(1)).Return(nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "metrics").
+		Return(mocks.MockDeploymentStatus("metrics", "project-b", 1, 1), nil)
+
+	op, err := m.Start(context.Background(), "inference-b", DirectionScaleUp, "", "alice")
+	require.NoError(t, err)
+
+	finished := waitFinished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusFailed, finished.Status)
+	assert.Contains(t, finished.Error, "did not become available")
+	assert.Equal(t, operation.StatusFailed, finished.Steps[0].Status)
+	assert.Equal(t, operation.StatusSucceeded, finished.Steps[1].Status)
+	assert.Equal(t, operation.StatusSkipped, finished.Steps[2].Status)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, "project-b", "preprocessor", mock.Anything)
+}
+
+func TestStart_ScaleToZeroReversed(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, operations := newTestManager(mockClient)
+
+	var order []string
+	for _, name := range []string{"model-server", "metrics", "preprocessor", "frontend"} {
+		name := name
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).
+			Return(mocks.MockDeploymentStatus(name, "project-b", 1, 1), nil)
+		mockClient.On("ScaleDeployment", mock.Anything, "project-b", name, int32(0)).
+			Run(func(mock.Arguments) { order = append(order, name) }).Return(nil)
+	}
+
+	op, err := m.Start(context.Background(), "inference-b", DirectionScaleToZero, "", "alice")
+	require.NoError(t, err)
+
+	finished := waitFinished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusSucceeded, finished.Status, finished.Error)
+	assert.Equal(t, []string{"frontend", "preprocessor", "model-server", "metrics"}, order)
+}
+
+func TestStart_ScaleToZeroDrains(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, operations := newTestManager(mockClient)
+	m.SetDrainManager(drain.NewManager(mockClient, operations, &drain.Config{PollInterval: time.Millisecond}))
+
+	// model-server has no running pods, so its drain finishes right away
+	_, err := mockClient.GetClientset().AppsV1().Deployments("project-b").Create(context.Background(), &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "model-server", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "model-server"}}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	var order []string
+	for _, name := range []string{"model-server", "metrics", "preprocessor", "frontend"} {
+		name := name
+		status := mocks.MockDeploymentStatus(name, "project-b", 1, 1)
+		if name == "model-server" {
+			status.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"metric","port":8002,"metric":"nv_inference_pending_request_count"}`}
+		}
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).Return(status, nil)
+		mockClient.On("ScaleDeployment", mock.Anything, "project-b", name, int32(0)).
+			Run(func(mock.Arguments) { order = append(order, name) }).Return(nil)
+	}
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "model-server", mock.Anything, mock.Anything).Return(nil)
+
+	op, err := m.Start(context.Background(), "inference-b", DirectionScaleToZero, "", "alice")
+	require.NoError(t, err)
+
+	finished := waitFinished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusSucceeded, finished.Status, finished.Error)
+	// The drain finished before the next member was scaled
+	assert.Equal(t, []string{"frontend", "preprocessor", "model-server", "metrics"}, order)
+	assert.Contains(t, finished.Steps[2].Message, "drained")
+}
+
+func TestStart_StopsWithContext(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, operations := newTestManager(mockClient)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.SetContext(ctx)
+
+	// model-server never becomes available; the context is cancelled while
+	// the first stage is waited for
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "model-server").
+		Return(mocks.MockDeploymentStatus("model-server", "project-b", 0, 0), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "model-server", int32(1)).
+		Run(func(mock.Arguments) { cancel() }).Return(nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "metrics").
+		Return(mocks.MockDeploymentStatus("metrics", "project-b", 1, 1), nil)
+
+	op, err := m.Start(context.Background(), "inference-b", DirectionScaleUp, "", "alice")
+	require.NoError(t, err)
+
+	finished := waitFinished(t, operations, op.ID)
+	assert.Equal(t, operation.StatusFailed, finished.Status)
+	assert.Contains(t, finished.Error, context.Canceled.Error())
+	assert.Equal(t, operation.StatusSkipped, finished.Steps[2].Status)
+}
+
+func TestStart_Errors(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m, _ := newTestManager(mockClient)
+
+	_, err := m.Start(context.Background(), "missing", DirectionScaleUp, "", "alice")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	release, err := m.locker.Acquire(context.Background(), lock.GroupKey("inference-b"), false)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = m.Start(context.Background(), "inference-b", DirectionScaleUp, "", "alice")
+	assert.ErrorIs(t, err, lock.ErrLocked)
+}
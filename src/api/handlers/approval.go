@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/approval"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// ApprovalHandler handles scale-up approval requests
+type ApprovalHandler struct {
+	store       *approval.Store
+	deployments *DeploymentHandler
+}
+
+// NewApprovalHandler creates a new approval handler. Approved scale-ups are
+// executed through the deployment handler so they pass the same locking,
+// policy and quota checks as direct requests.
+func NewApprovalHandler(store *approval.Store, deployments *DeploymentHandler) *ApprovalHandler {
+	return &ApprovalHandler{
+		store:       store,
+		deployments: deployments,
+	}
+}
+
+// ListApprovals handles GET /api/v1/approvals
+func (h *ApprovalHandler) ListApprovals(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	approvals, err := h.store.List(c.Request.Context(), approval.Status(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApprovalResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to list approval requests",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	infos := make([]models.ApprovalInfo, 0, len(approvals))
+	for _, a := range approvals {
+		infos = append(infos, *approvalInfo(a))
+	}
+
+	c.JSON(http.StatusOK, models.ApprovalListResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Approval requests retrieved successfully",
+		Approvals: infos,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// GetApproval handles GET /api/v1/approvals/{id}
+func (h *ApprovalHandler) GetApproval(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	a, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApprovalResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Approval request retrieved successfully",
+		Approval:  approvalInfo(a),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// Approve handles POST /api/v1/approvals/{id}/approve. The scale-up is
+// executed immediately and the response is that of the scale-up.
+func (h *ApprovalHandler) Approve(c *gin.Context) {
+	a, ok := h.decide(c, true)
+	if !ok {
+		return
+	}
+
+	h.deployments.scaleUp(c, a.Namespace, a.Name, models.ScaleUpRequest{
+		Replicas:  a.Replicas,
+		Reason:    a.Reason,
+		Duration:  a.LeaseDuration,
+		ExpiresAt: a.LeaseExpiresAt,
+	}, false, 0, a)
+
+	// The scale-up may still be refused, e.g. if the policy or quota changed
+	// while the request was pending. A concurrent operation or a rate limit
+	// passes, so the request stays pending and can be approved again.
+	switch status := c.Writer.Status(); status {
+	case http.StatusOK:
+	case http.StatusConflict, http.StatusTooManyRequests:
+		if err := h.store.Reopen(c.Request.Context(), a); err != nil {
+			log.Printf("Failed to reopen approval request %s: %v", a.ID, err)
+		}
+	default:
+		cause := fmt.Errorf("scale-up failed with HTTP status %d", status)
+		if err := h.store.Fail(c.Request.Context(), a, cause); err != nil {
+			log.Printf("Failed to record failure of approval request %s: %v", a.ID, err)
+		}
+	}
+}
+
+// Reject handles POST /api/v1/approvals/{id}/reject
+func (h *ApprovalHandler) Reject(c *gin.Context) {
+	a, ok := h.decide(c, false)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApprovalResponse{
+		Status:    models.StatusSuccess,
+		Message:   fmt.Sprintf("Scale-up of deployment %s/%s was rejected", a.Namespace, a.Name),
+		Approval:  approvalInfo(a),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// decide records the caller's decision on a pending approval request
+func (h *ApprovalHandler) decide(c *gin.Context, approve bool) (*approval.Approval, bool) {
+	if !h.available(c) {
+		return nil, false
+	}
+
+	var req models.ApprovalDecisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ApprovalResponse{
+				Status:    models.StatusError,
+				Message:   "Invalid request body",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return nil, false
+		}
+	}
+
+	a, err := h.store.Decide(c.Request.Context(), c.Param("id"), middleware.Principal(c), approve, req.Comment)
+	if err != nil {
+		h.respondError(c, err)
+		return nil, false
+	}
+	return a, true
+}
+
+// available responds with 503 when approvals are not configured
+func (h *ApprovalHandler) available(c *gin.Context) bool {
+	if h.store != nil {
+		return true
+	}
+	c.JSON(http.StatusServiceUnavailable, models.ApprovalResponse{
+		Status:    models.StatusError,
+		Message:   "Approvals not available",
+		Error:     "Kubernetes client not available",
+		Timestamp: time.Now().UTC(),
+	})
+	return false
+}
+
+// respondError maps approval store errors to HTTP responses
+func (h *ApprovalHandler) respondError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	message := "Failed to process approval request"
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		statusCode, message = http.StatusNotFound, "Approval request not found"
+	case errors.Is(err, approval.ErrNotApprover), errors.Is(err, approval.ErrSelfApproval):
+		statusCode, message = http.StatusForbidden, "Not allowed to decide this approval request"
+	case errors.Is(err, approval.ErrNotPending):
+		statusCode, message = http.StatusConflict, "Approval request is no longer pending"
+	case errors.Is(err, approval.ErrExpired):
+		statusCode, message = http.StatusGone, "Approval request has expired"
+	}
+
+	c.JSON(statusCode, models.ApprovalResponse{
+		Status:    models.StatusError,
+		Message:   message,
+		Error:     err.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// approvalInfo converts an approval request to its API representation
+func approvalInfo(a *approval.Approval) *models.ApprovalInfo {
+	info := &models.ApprovalInfo{
+		ID:              a.ID,
+		Namespace:       a.Namespace,
+		Deployment:      a.Name,
+		Replicas:        a.Replicas,
+		Reason:          a.Reason,
+		LeaseDuration:   a.LeaseDuration,
+		LeaseExpiresAt:  a.LeaseExpiresAt,
+		ApprovalReasons: a.Reasons,
+		RequestedBy:     a.RequestedBy,
+		Status:          string(a.Status),
+		CreatedAt:       a.CreatedAt,
+		ExpiresAt:       a.ExpiresAt,
+		DecidedBy:       a.DecidedBy,
+		Comment:         a.Comment,
+		Error:           a.Error,
+	}
+	if !a.DecidedAt.IsZero() {
+		decidedAt := a.DecidedAt
+		info.DecidedAt = &decidedAt
+	}
+	return info
+}
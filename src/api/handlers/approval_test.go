@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/approval"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// setupApprovalRouter wires scale-up and approval routes. The caller's
+// principal is taken from the X-Principal header.
+func setupApprovalRouter(mockClient *mocks.MockK8sClient) *gin.Engine {
+	store := approval.NewStore(fake.NewSimpleClientset(), &approval.Config{
+		Namespace: approval.DefaultNamespace,
+		TTL:       time.Hour,
+		Retention: time.Hour,
+	})
+	deploymentHandler := NewDeploymentHandler(mockClient,
+		WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())),
+		WithApprovalStore(store),
+	)
+	approvalHandler := NewApprovalHandler(store, deploymentHandler)
+
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(middleware.PrincipalKey, c.GetHeader("X-Principal"))
+	})
+	router.POST("/deployments/:namespace/:name/scale-up", deploymentHandler.ScaleUp)
+	router.GET("/approvals", approvalHandler.ListApprovals)
+	router.GET("/approvals/:id", approvalHandler.GetApproval)
+	router.POST("/approvals/:id/approve", approvalHandler.Approve)
+	router.POST("/approvals/:id/reject", approvalHandler.Reject)
+	return router
+}
+
+func requestAs(router *gin.Engine, principal, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Principal", principal)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// requestPendingScaleUp asks alice's scale-up of test-app to 3 replicas, above
+// the approval threshold of 1, and returns the pending approval
+func requestPendingScaleUp(t *testing.T, router *gin.Engine, mockClient *mocks.MockK8sClient) *models.ApprovalInfo {
+	t.Helper()
+
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationApprovalReplicas: "1"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	w := requestAs(router, "alice", "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{
+		Replicas: 3,
+		Reason:   "Load test",
+	})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Approval)
+	return response.Approval
+}
+
+func TestScaleUp_RequiresApproval(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+
+	// Test
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Assert
+	assert.Equal(t, "pending", pending.Status)
+	assert.Equal(t, "alice", pending.RequestedBy)
+	assert.Equal(t, int32(3), pending.Replicas)
+	assert.Equal(t, []string{"requested 3 replicas exceeds approval threshold of 1 (deployment annotations)"}, pending.ApprovalReasons)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	w := requestAs(router, "bob", "GET", "/approvals?status=pending", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list models.ApprovalListResponse
+	helpers.ParseJSONResponse(t, w, &list)
+	require.Len(t, list.Approvals, 1)
+	assert.Equal(t, pending.ID, list.Approvals[0].ID)
+}
+
+func TestApprove_ExecutesScaleUp(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Mock expectations
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(3)).Return(nil)
+
+	// Test
+	w := requestAs(router, "bob", "POST", "/approvals/"+pending.ID+"/approve", models.ApprovalDecisionRequest{Comment: "go ahead"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	assert.Equal(t, int32(3), response.Deployment.TargetReplicas)
+	assert.Equal(t, "approved", response.Approval.Status)
+	assert.Equal(t, "bob", response.Approval.DecidedBy)
+	mockClient.AssertExpectations(t)
+
+	// The decision is final
+	w = requestAs(router, "carol", "POST", "/approvals/"+pending.ID+"/reject", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestApprove_SelfApprovalForbidden(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Test
+	w := requestAs(router, "alice", "POST", "/approvals/"+pending.ID+"/approve", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReject(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Test
+	w := requestAs(router, "bob", "POST", "/approvals/"+pending.ID+"/reject", models.ApprovalDecisionRequest{Comment: "not today"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ApprovalResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "rejected", response.Approval.Status)
+	assert.Equal(t, "not today", response.Approval.Comment)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApprove_ScaleFailureRecorded(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Mock expectations
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(3)).Return(assert.AnError)
+
+	// Test
+	w := requestAs(router, "bob", "POST", "/approvals/"+pending.ID+"/approve", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = requestAs(router, "bob", "GET", "/approvals/"+pending.ID, nil)
+	var response models.ApprovalResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "failed", response.Approval.Status)
+	assert.Contains(t, response.Approval.Error, "500")
+}
+
+func TestApprove_ThrottledStaysPending(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+	pending := requestPendingScaleUp(t, router, mockClient)
+
+	// Mock expectations
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(3)).
+		Return(&throttle.Error{Message: "Rate limit exceeded for deployment test-ns/test-app", RetryAfter: time.Second}).Once()
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(3)).Return(nil).Once()
+
+	// Test
+	w := requestAs(router, "bob", "POST", "/approvals/"+pending.ID+"/approve", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = requestAs(router, "bob", "GET", "/approvals/"+pending.ID, nil)
+	var response models.ApprovalResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "pending", response.Approval.Status)
+	assert.Empty(t, response.Approval.DecidedBy)
+
+	// The request can be approved again once the limit has passed
+	w = requestAs(router, "bob", "POST", "/approvals/"+pending.ID+"/approve", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleUp_ApprovalNobodyElseCanApprove(t *testing.T) {
+	// Setup: every caller shares one API key
+	mockClient := mocks.NewMockK8sClient()
+	store := approval.NewStore(fake.NewSimpleClientset(), &approval.Config{
+		Namespace:  approval.DefaultNamespace,
+		TTL:        time.Hour,
+		Principals: []string{middleware.DefaultPrincipal},
+	})
+	handler := NewDeploymentHandler(mockClient,
+		WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())),
+		WithApprovalStore(store),
+	)
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, middleware.DefaultPrincipal) })
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationApprovalReplicas: "1"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 3, Reason: "Load test"})
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "nobody else can approve it")
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetApproval_NotFound(t *testing.T) {
+	// Setup
+	router := setupApprovalRouter(mocks.NewMockK8sClient())
+
+	// Test
+	w := requestAs(router, "bob", "GET", "/approvals/missing", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestScaleUp_DryRunReportsApproval(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupApprovalRouter(mockClient)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationApprovalReplicas: "1"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("DryRunScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 0, 2), nil)
+
+	// Test
+	w := requestAs(router, "alice", "POST", "/deployments/test-ns/test-app/scale-up?dryRun=true", models.ScaleUpRequest{
+		Replicas: 2,
+		Reason:   "Preview",
+	})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Contains(t, response.Deployment.PolicyDecisions, "approval required: requested 2 replicas exceeds approval threshold of 1 (deployment annotations)")
+}
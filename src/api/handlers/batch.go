@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/batch"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// BatchHandler handles CronJob and Job requests
+type BatchHandler struct {
+	batches *batch.Manager
+}
+
+// NewBatchHandler creates a new batch workload handler
+func NewBatchHandler(batches *batch.Manager) *BatchHandler {
+	return &BatchHandler{
+		batches: batches,
+	}
+}
+
+// SuspendCronJob handles POST /api/v1/namespaces/{namespace}/cronjobs/{name}/suspend
+func (h *BatchHandler) SuspendCronJob(c *gin.Context) {
+	h.setSuspended(c, true)
+}
+
+// ResumeCronJob handles POST /api/v1/namespaces/{namespace}/cronjobs/{name}/resume
+func (h *BatchHandler) ResumeCronJob(c *gin.Context) {
+	h.setSuspended(c, false)
+}
+
+// setSuspended suspends or resumes a CronJob and writes the response
+func (h *BatchHandler) setSuspended(c *gin.Context, suspend bool) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	if h.batches == nil {
+		c.JSON(http.StatusServiceUnavailable, models.CronJobResponse{
+			Status:    models.StatusError,
+			Message:   "Batch workload management not available",
+			Error:     "Kubernetes client not available",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.CronJobResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	action, done := "resume", "resumed"
+	if suspend {
+		action, done = "suspend", "suspended"
+	}
+
+	cronJob, decision, err := h.batches.SetSuspended(c.Request.Context(), namespace, name, suspend, middleware.Principal(c), req.Reason)
+	if err != nil {
+		var throttled *throttle.Error
+		statusCode, message := http.StatusInternalServerError, fmt.Sprintf("Failed to %s CronJob %s/%s", action, namespace, name)
+		switch {
+		case errors.Is(err, lock.ErrLocked):
+			statusCode, message = http.StatusConflict, fmt.Sprintf("CronJob %s/%s is being changed by another request", namespace, name)
+		case errors.As(err, &throttled):
+			middleware.SetRetryAfter(c, throttled.RetryAfter)
+			statusCode, message = http.StatusTooManyRequests, fmt.Sprintf("CronJob %s/%s is suspended or resumed too often", namespace, name)
+		case errors.Is(err, k8s.ErrNotManaged):
+			statusCode, message = http.StatusForbidden, fmt.Sprintf("CronJob %s/%s is not managed by the Scale API", namespace, name)
+		case k8serrors.IsNotFound(err):
+			statusCode, message = http.StatusNotFound, fmt.Sprintf("CronJob %s/%s not found", namespace, name)
+		}
+		c.JSON(statusCode, models.CronJobResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	if !decision.Allowed {
+		c.JSON(decision.StatusCode, models.CronJobResponse{
+			Status:     models.StatusError,
+			Message:    fmt.Sprintf("Scaling policy does not allow %s of CronJob %s/%s", action, namespace, name),
+			Error:      strings.Join(decision.Violations, "; "),
+			Violations: decision.Violations,
+			Timestamp:  time.Now().UTC(),
+		})
+		return
+	}
+
+	log.Printf("CronJob %s/%s %s by %s: %s", namespace, name, done, middleware.Principal(c), req.Reason)
+
+	c.JSON(http.StatusOK, models.CronJobResponse{
+		Status:  models.StatusSuccess,
+		Message: fmt.Sprintf("CronJob %s/%s %s", namespace, name, done),
+		CronJob: &models.CronJobInfo{
+			Name:             cronJob.Name,
+			Namespace:        cronJob.Namespace,
+			Schedule:         cronJob.Schedule,
+			Suspended:        cronJob.Suspended,
+			ActiveJobs:       cronJob.ActiveJobs,
+			LastScheduleTime: cronJob.LastScheduleTime,
+		},
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// ListJobs handles GET /api/v1/nodepools/{name}/jobs. The optional namespace
+// query parameter limits the Jobs to one namespace.
+func (h *BatchHandler) ListJobs(c *gin.Context) {
+	nodePool := c.Param("name")
+
+	if !h.available(c) {
+		return
+	}
+
+	jobs, err := h.batches.ListJobs(c.Request.Context(), c.Query("namespace"), nodePool)
+	if err != nil {
+		h.jobsError(c, fmt.Sprintf("Failed to list Jobs on node pool %s", nodePool), err)
+		return
+	}
+
+	infos := make([]models.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, models.JobInfo{
+			Name:       job.Name,
+			Namespace:  job.Namespace,
+			CronJob:    job.CronJob,
+			NodePool:   job.NodePool,
+			ActivePods: job.ActivePods,
+			StartTime:  job.StartTime,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.JobListResponse{
+		Status:    models.StatusSuccess,
+		Message:   fmt.Sprintf("%d Jobs on node pool %s", len(infos), nodePool),
+		NodePool:  nodePool,
+		Jobs:      infos,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// TerminateJobs handles POST /api/v1/nodepools/{name}/jobs/terminate. As with
+// bulk scaling, 207 Multi-Status is returned if any Job failed.
+func (h *BatchHandler) TerminateJobs(c *gin.Context) {
+	nodePool := c.Param("name")
+
+	if !h.available(c) {
+		return
+	}
+
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.JobListResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			NodePool:  nodePool,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	results, err := h.batches.TerminateJobs(c.Request.Context(), c.Query("namespace"), nodePool, middleware.Principal(c), req.Reason)
+	if err != nil {
+		h.jobsError(c, fmt.Sprintf("Failed to list Jobs on node pool %s", nodePool), err)
+		return
+	}
+
+	succeeded, failed := 0, 0
+	jobResults := make([]models.JobResult, 0, len(results))
+	for _, r := range results {
+		result := models.JobResult{
+			Name:       r.Name,
+			Namespace:  r.Namespace,
+			Status:     r.Status,
+			Message:    r.Message,
+			Violations: r.Violations,
+		}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+		}
+		switch r.Status {
+		case batch.ResultSuccess:
+			succeeded++
+			log.Printf("Job %s/%s on node pool %s terminated by %s: %s", r.Namespace, r.Name, nodePool, middleware.Principal(c), req.Reason)
+		case batch.ResultError:
+			failed++
+		}
+		jobResults = append(jobResults, result)
+	}
+
+	statusCode, status := http.StatusOK, models.StatusSuccess
+	if failed > 0 {
+		statusCode, status = http.StatusMultiStatus, models.ResponseStatusPartial
+		if succeeded == 0 {
+			status = models.StatusError
+		}
+	}
+
+	c.JSON(statusCode, models.JobListResponse{
+		Status:    status,
+		Message:   fmt.Sprintf("Jobs on node pool %s: %d terminated, %d failed", nodePool, succeeded, failed),
+		NodePool:  nodePool,
+		Results:   jobResults,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// jobsError writes a failed Job listing. Denied namespaces are rejected with 403.
+func (h *BatchHandler) jobsError(c *gin.Context, message string, err error) {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, k8s.ErrNotManaged) {
+		statusCode, message = http.StatusForbidden, fmt.Sprintf("Namespace %s is not managed by the Scale API", c.Query("namespace"))
+	}
+	c.JSON(statusCode, models.JobListResponse{
+		Status:    models.StatusError,
+		Message:   message,
+		NodePool:  c.Param("name"),
+		Error:     err.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// available responds with 503 when batch workload management is not configured
+func (h *BatchHandler) available(c *gin.Context) bool {
+	if h.batches != nil {
+		return true
+	}
+	c.JSON(http.StatusServiceUnavailable, models.JobListResponse{
+		Status:    models.StatusError,
+		Message:   "Batch workload management not available",
+		NodePool:  c.Param("name"),
+		Error:     "Kubernetes client not available",
+		Timestamp: time.Now().UTC(),
+	})
+	return false
+}
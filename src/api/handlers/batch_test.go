@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/batch"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTestBatchHandler(mockClient *mocks.MockK8sClient) *BatchHandler {
+	return NewBatchHandler(batch.NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig())))
+}
+
+func TestSuspendCronJob_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/cronjobs/:name/suspend", handler.SuspendCronJob)
+
+	// Mock expectations
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b", Schedule: "0 2 * * *", ActiveJobs: 1}, nil)
+	mockClient.On("SuspendCronJob", mock.Anything, "project-b", "nightly-eval", true).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/cronjobs/nightly-eval/suspend", models.BatchRequest{Reason: "End of day"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.CronJobResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "CronJob project-b/nightly-eval suspended", response.Message)
+	require.NotNil(t, response.CronJob)
+	assert.True(t, response.CronJob.Suspended)
+	assert.Equal(t, int32(1), response.CronJob.ActiveJobs)
+	mockClient.AssertExpectations(t)
+}
+
+func TestSuspendCronJob_Protected(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/cronjobs/:name/suspend", handler.SuspendCronJob)
+
+	// Mock expectations
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "report").Return(&k8s.CronJob{
+		Name: "report", Namespace: "project-b",
+		Annotations: map[string]string{policy.AnnotationProtected: "true"},
+	}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/cronjobs/report/suspend", models.BatchRequest{Reason: "End of day"})
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.CronJobResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.NotEmpty(t, response.Violations)
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResumeCronJob_Throttled(t *testing.T) {
+	// Setup: the CronJob was suspended a moment ago
+	mockClient := mocks.NewMockK8sClient()
+	batches := batch.NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+	limiter := throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute})
+	_, err := limiter.ReserveKind("CronJob", "project-b", "nightly-eval", 0)
+	require.NoError(t, err)
+	batches.SetThrottle(limiter)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/cronjobs/:name/resume", NewBatchHandler(batches).ResumeCronJob)
+
+	// Mock expectations
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "nightly-eval").
+		Return(&k8s.CronJob{Name: "nightly-eval", Namespace: "project-b", Suspended: true}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/cronjobs/nightly-eval/resume", models.BatchRequest{Reason: "Forgot a run"})
+
+	// Assert
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	mockClient.AssertNotCalled(t, "SuspendCronJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResumeCronJob_NotFound(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/cronjobs/:name/resume", handler.ResumeCronJob)
+
+	// Mock expectations
+	notFound := k8serrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "cronjobs"}, "missing")
+	mockClient.On("GetCronJob", mock.Anything, "project-b", "missing").Return(nil, notFound)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/cronjobs/missing/resume", models.BatchRequest{Reason: "Morning"})
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSuspendCronJob_MissingReason(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/cronjobs/:name/suspend", handler.SuspendCronJob)
+
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/cronjobs/nightly-eval/suspend", map[string]string{})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListJobs(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/nodepools/:name/jobs", handler.ListJobs)
+
+	// Mock expectations
+	mockClient.On("ListJobs", mock.Anything, "project-b", "projectb").Return([]*k8s.Job{
+		{Name: "nightly-eval-29000000", Namespace: "project-b", CronJob: "nightly-eval", NodePool: "projectb", ActivePods: 2},
+	}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/nodepools/projectb/jobs?namespace=project-b", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.JobListResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.Len(t, response.Jobs, 1)
+	assert.Equal(t, "nightly-eval", response.Jobs[0].CronJob)
+	assert.Equal(t, int32(2), response.Jobs[0].ActivePods)
+}
+
+func TestTerminateJobs_PartialFailure(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestBatchHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/nodepools/:name/jobs/terminate", handler.TerminateJobs)
+
+	// Mock expectations
+	mockClient.On("ListJobs", mock.Anything, "", "projectb").Return([]*k8s.Job{
+		{Name: "eval", Namespace: "project-b", NodePool: "projectb", ActivePods: 1},
+		{Name: "embeddings", Namespace: "project-c", NodePool: "projectb", ActivePods: 1},
+	}, nil)
+	mockClient.On("DeleteJob", mock.Anything, "project-b", "eval").Return(nil)
+	mockClient.On("DeleteJob", mock.Anything, "project-c", "embeddings").Return(k8s.ErrNotManaged)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/nodepools/projectb/jobs/terminate", models.BatchRequest{Reason: "Free GPU nodes"})
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.JobListResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.ResponseStatusPartial, response.Status)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, batch.ResultSuccess, response.Results[0].Status)
+	assert.Equal(t, batch.ResultError, response.Results[1].Status)
+}
+
+func TestListJobs_NotAvailable(t *testing.T) {
+	handler := NewBatchHandler(nil)
+	router := helpers.SetupTestRouter()
+	router.GET("/nodepools/:name/jobs", handler.ListJobs)
+
+	w := helpers.MakeRequest(router, "GET", "/nodepools/projectb/jobs", nil)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
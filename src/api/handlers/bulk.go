@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Bulk operations scale this many deployments at once unless ?concurrency is set
+const (
+	DefaultBulkConcurrency = 5
+	MaxBulkConcurrency     = 20
+)
+
+// BulkScaleToZero handles POST /api/v1/deployments/scale-to-zero?labelSelector=...
+func (h *DeploymentHandler) BulkScaleToZero(c *gin.Context) {
+	var req models.ScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BulkScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// Deployments that declare a drain are drained as with the
+	// single-deployment endpoint; their results are pending with the
+	// operation to follow
+	drainFirst := true
+	if value := c.Query("drain"); value != "" {
+		var err error
+		if drainFirst, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.BulkScaleResponse{
+				Status:    models.StatusError,
+				Message:   "Invalid drain value",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+	}
+
+	h.bulkScale(c, 0, func(c *gin.Context, d *k8s.DeploymentStatus, dryRun bool) {
+		h.scaleToZero(c, d.Namespace, d.Name, req, dryRun, drainFirst)
+	})
+}
+
+// BulkScaleUp handles POST /api/v1/deployments/scale-up?labelSelector=...
+func (h *DeploymentHandler) BulkScaleUp(c *gin.Context) {
+	var req models.ScaleUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BulkScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	h.bulkScale(c, req.Replicas, func(c *gin.Context, d *k8s.DeploymentStatus, dryRun bool) {
+		h.scaleUp(c, d.Namespace, d.Name, req, dryRun, 0, nil)
+	})
+}
+
+// bulkScale runs a single-deployment scale operation on every deployment
+// matching ?labelSelector (in ?namespace, or all namespaces), at most
+// ?concurrency at a time. Each deployment goes through the same locking,
+// policy, quota and approval checks as a direct request, and one failing
+// does not undo the others. With ?failFast=true, deployments not started yet
+// are skipped after the first failure.
+func (h *DeploymentHandler) bulkScale(c *gin.Context, targetReplicas int32, scale func(*gin.Context, *k8s.DeploymentStatus, bool)) {
+	selector := c.Query("labelSelector")
+	namespace := c.Query("namespace")
+
+	badRequest := func(message string, err error) {
+		c.JSON(http.StatusBadRequest, models.BulkScaleResponse{
+			Status:        models.StatusError,
+			Message:       message,
+			LabelSelector: selector,
+			Namespace:     namespace,
+			Error:         err.Error(),
+			Timestamp:     time.Now().UTC(),
+		})
+	}
+
+	// An empty selector would match every deployment in the cluster
+	if selector == "" {
+		badRequest("Invalid label selector", fmt.Errorf("labelSelector is required"))
+		return
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		badRequest("Invalid label selector", err)
+		return
+	}
+
+	concurrency := DefaultBulkConcurrency
+	if value := c.Query("concurrency"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxBulkConcurrency {
+			badRequest("Invalid concurrency value", fmt.Errorf("concurrency must be between 1 and %d", MaxBulkConcurrency))
+			return
+		}
+		concurrency = n
+	}
+
+	failFast := false
+	if value := c.Query("failFast"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			badRequest("Invalid failFast value", fmt.Errorf("failFast must be true or false"))
+			return
+		}
+		failFast = parsed
+	}
+
+	if onConflict := c.DefaultQuery("onConflict", OnConflictReject); onConflict != OnConflictReject && onConflict != OnConflictWait {
+		badRequest("Invalid onConflict value", fmt.Errorf("onConflict must be %q or %q", OnConflictReject, OnConflictWait))
+		return
+	}
+
+	dryRun, ok := parseDryRun(c)
+	if !ok {
+		return
+	}
+
+	deployments, err := h.k8sClient.ListDeployments(c.Request.Context(), namespace, selector)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.BulkScaleResponse{
+			Status:        models.StatusError,
+			Message:       "Failed to list deployments",
+			LabelSelector: selector,
+			Namespace:     namespace,
+			Error:         err.Error(),
+			Timestamp:     time.Now().UTC(),
+		})
+		return
+	}
+	if len(deployments) == 0 {
+		c.JSON(http.StatusNotFound, models.BulkScaleResponse{
+			Status:        models.StatusError,
+			Message:       "No deployments match the label selector",
+			LabelSelector: selector,
+			Namespace:     namespace,
+			Timestamp:     time.Now().UTC(),
+		})
+		return
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		if deployments[i].Namespace != deployments[j].Namespace {
+			return deployments[i].Namespace < deployments[j].Namespace
+		}
+		return deployments[i].Name < deployments[j].Name
+	})
+
+	results := make([]models.BulkScaleResult, len(deployments))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i, d := range deployments {
+		results[i] = models.BulkScaleResult{
+			Namespace:        d.Namespace,
+			Name:             d.Name,
+			PreviousReplicas: d.DesiredReplicas,
+			TargetReplicas:   targetReplicas,
+		}
+		// Each deployment gets its own copy of the context so the
+		// single-deployment code can write its response concurrently
+		sub := c.Copy()
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(result *models.BulkScaleResult, d *k8s.DeploymentStatus) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if failFast && failed.Load() {
+				result.Status = models.ResponseStatusSkipped
+				result.Message = "Skipped after an earlier failure"
+				return
+			}
+
+			code, response := recordScale(sub, func(c *gin.Context) { scale(c, d, dryRun) })
+			applyBulkResult(result, code, response)
+			if result.Status == models.StatusError {
+				failed.Store(true)
+			}
+		}(&results[i], d)
+	}
+	wg.Wait()
+
+	summary := &models.BulkScaleSummary{Total: len(results)}
+	for _, r := range results {
+		switch r.Status {
+		case models.StatusSuccess:
+			summary.Succeeded++
+		case models.ResponseStatusPending:
+			summary.Pending++
+		case models.ResponseStatusSkipped:
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+	}
+
+	// 207 Multi-Status tells the caller to inspect the per-deployment results
+	statusCode, status := http.StatusOK, models.StatusSuccess
+	if summary.Failed > 0 || summary.Skipped > 0 {
+		statusCode, status = http.StatusMultiStatus, models.ResponseStatusPartial
+		if summary.Succeeded == 0 && summary.Pending == 0 {
+			status = models.StatusError
+		}
+	}
+
+	c.JSON(statusCode, models.BulkScaleResponse{
+		Status: status,
+		Message: fmt.Sprintf("%d of %d deployments scaled, %d pending, %d failed, %d skipped",
+			summary.Succeeded, summary.Total, summary.Pending, summary.Failed, summary.Skipped),
+		LabelSelector: selector,
+		Namespace:     namespace,
+		DryRun:        dryRun,
+		Summary:       summary,
+		Results:       results,
+		Timestamp:     time.Now().UTC(),
+	})
+}
+
+// applyBulkResult fills a bulk result from the response of a single-deployment
+// scale operation
+func applyBulkResult(result *models.BulkScaleResult, code int, response models.ScaleResponse) {
+	result.HTTPStatus = code
+	result.Message = response.Message
+	result.Error = response.Error
+	result.Violations = response.Violations
+	result.Approval = response.Approval
+	result.Operation = response.Operation
+	if response.Deployment != nil {
+		result.PreviousReplicas = response.Deployment.PreviousReplicas
+	}
+
+	switch code {
+	case http.StatusOK:
+		result.Status = models.StatusSuccess
+	case http.StatusAccepted:
+		result.Status = models.ResponseStatusPending
+	default:
+		result.Status = models.StatusError
+	}
+}
+
+// recordScale runs fn with a response writer that captures the JSON response
+// instead of sending it
+func recordScale(c *gin.Context, fn func(*gin.Context)) (int, models.ScaleResponse) {
+	recorder := &responseRecorder{header: http.Header{}}
+	c.Writer = recorder
+	fn(c)
+
+	var response models.ScaleResponse
+	if err := json.Unmarshal(recorder.body.Bytes(), &response); err != nil {
+		response = models.ScaleResponse{
+			Status: models.StatusError,
+			Error:  fmt.Sprintf("unreadable response: %v", err),
+		}
+	}
+	return recorder.Status(), response
+}
+
+// responseRecorder is an in-memory gin.ResponseWriter. Methods for streaming
+// and hijacking are not implemented.
+type responseRecorder struct {
+	gin.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *responseRecorder) WriteHeaderNow() {}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *responseRecorder) Size() int {
+	return r.body.Len()
+}
+
+func (r *responseRecorder) Written() bool {
+	return r.status != 0
+}
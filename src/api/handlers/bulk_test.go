@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBulkScaleToZero_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/scale-to-zero", handler.BulkScaleToZero)
+
+	appB := mocks.MockDeploymentStatus("app-b", "project-b", 2, 2)
+	appA := mocks.MockDeploymentStatus("app-a", "project-b", 1, 1)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "project-b", "project=b").
+		Return([]*k8s.DeploymentStatus{appB, appA}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "app-a").Return(appA, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "app-b").Return(appB, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "app-a", int32(0)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "app-b", int32(0)).Return(nil)
+
+	// Test
+	body := models.ScaleRequest{Reason: "End of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/scale-to-zero?labelSelector=project%3Db&namespace=project-b", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BulkScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	assert.Equal(t, 2, response.Summary.Succeeded)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "app-a", response.Results[0].Name)
+	assert.Equal(t, int32(1), response.Results[0].PreviousReplicas)
+	assert.Equal(t, "app-b", response.Results[1].Name)
+	assert.Equal(t, int32(2), response.Results[1].PreviousReplicas)
+	mockClient.AssertExpectations(t)
+}
+
+func TestBulkScaleToZero_Drain(t *testing.T) {
+	// Setup: app-a has no running pods, so its drain finishes right away
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-a", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app-a"}}},
+	})
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(clientset)
+	operations := operation.NewStore(operation.DefaultRetention)
+	handler := NewDeploymentHandler(mockClient,
+		WithDrainManager(drain.NewManager(mockClient, operations, &drain.Config{PollInterval: time.Millisecond})))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/scale-to-zero", handler.BulkScaleToZero)
+
+	appA := mocks.MockDeploymentStatus("app-a", "project-b", 1, 1)
+	appA.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"metric","port":8002,"metric":"nv_inference_pending_request_count"}`}
+	appB := mocks.MockDeploymentStatus("app-b", "project-b", 2, 2)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "project-b", "project=b").
+		Return([]*k8s.DeploymentStatus{appA, appB}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "app-a").Return(appA, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "app-b").Return(appB, nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "app-a", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "app-a", int32(0)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "app-b", int32(0)).Return(nil)
+
+	// Test
+	body := models.ScaleRequest{Reason: "End of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/scale-to-zero?labelSelector=project%3Db&namespace=project-b", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BulkScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, 1, response.Summary.Succeeded)
+	assert.Equal(t, 1, response.Summary.Pending)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, models.ResponseStatusPending, response.Results[0].Status)
+	assert.Equal(t, http.StatusAccepted, response.Results[0].HTTPStatus)
+	if assert.NotNil(t, response.Results[0].Operation) {
+		assert.Equal(t, drain.OperationType, response.Results[0].Operation.Type)
+
+		assert.Eventually(t, func() bool {
+			op, _ := operations.Get(response.Results[0].Operation.ID)
+			return op.Status == operation.StatusSucceeded
+		}, 5*time.Second, time.Millisecond)
+	}
+	assert.Equal(t, models.StatusSuccess, response.Results[1].Status)
+	assert.Nil(t, response.Results[1].Operation)
+	mockClient.AssertCalled(t, "ScaleDeployment", mock.Anything, "project-b", "app-a", int32(0))
+}
+
+func TestBulkScaleUp_PartialFailure(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/scale-up", handler.BulkScaleUp)
+
+	appA := mocks.MockDeploymentStatus("app-a", "project-a", 0, 0)
+	appB := mocks.MockDeploymentStatus("app-b", "project-b", 0, 0)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "tier=web").
+		Return([]*k8s.DeploymentStatus{appA, appB}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "app-a").Return(appA, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "app-b").Return(appB, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-a", "app-a", int32(2)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "app-b", int32(2)).Return(errors.New("quota exceeded"))
+
+	// Test
+	body := models.ScaleUpRequest{Replicas: 2, Reason: "Start of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/scale-up?labelSelector=tier%3Dweb", body)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.BulkScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.ResponseStatusPartial, response.Status)
+	assert.Equal(t, 1, response.Summary.Succeeded)
+	assert.Equal(t, 1, response.Summary.Failed)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, models.StatusSuccess, response.Results[0].Status)
+	assert.Equal(t, int32(2), response.Results[0].TargetReplicas)
+	assert.Equal(t, models.StatusError, response.Results[1].Status)
+	assert.Equal(t, http.StatusInternalServerError, response.Results[1].HTTPStatus)
+	assert.Equal(t, "quota exceeded", response.Results[1].Error)
+}
+
+func TestBulkScaleUp_FailFast(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/scale-up", handler.BulkScaleUp)
+
+	appA := mocks.MockDeploymentStatus("app-a", "project-a", 0, 0)
+	appB := mocks.MockDeploymentStatus("app-b", "project-a", 0, 0)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "tier=web").
+		Return([]*k8s.DeploymentStatus{appA, appB}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "app-a").Return(appA, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-a", "app-a", int32(1)).Return(errors.New("boom"))
+
+	// Test
+	body := models.ScaleUpRequest{Replicas: 1, Reason: "Start of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/scale-up?labelSelector=tier%3Dweb&concurrency=1&failFast=true", body)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.BulkScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Equal(t, 1, response.Summary.Failed)
+	assert.Equal(t, 1, response.Summary.Skipped)
+	assert.Equal(t, models.ResponseStatusSkipped, response.Results[1].Status)
+	mockClient.AssertNotCalled(t, "GetDeploymentStatus", mock.Anything, "project-a", "app-b")
+}
+
+func TestBulkScale_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"missing selector", ""},
+		{"invalid selector", "?labelSelector=a%3D%3D%3Db"},
+		{"concurrency too high", "?labelSelector=a%3Db&concurrency=100"},
+		{"invalid failFast", "?labelSelector=a%3Db&failFast=maybe"},
+		{"invalid onConflict", "?labelSelector=a%3Db&onConflict=skip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockClient := mocks.NewMockK8sClient()
+			handler := NewDeploymentHandler(mockClient)
+			router := helpers.SetupTestRouter()
+			router.POST("/deployments/scale-to-zero", handler.BulkScaleToZero)
+
+			// Test
+			w := helpers.MakeRequest(router, "POST", "/deployments/scale-to-zero"+tt.query, models.ScaleRequest{Reason: "Test"})
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockClient.AssertNotCalled(t, "ListDeployments", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestBulkScale_NoMatches(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/scale-to-zero", handler.BulkScaleToZero)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "project=z").Return([]*k8s.DeploymentStatus{}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/scale-to-zero?labelSelector=project%3Dz", models.ScaleRequest{Reason: "Test"})
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// ConfigHandler handles configuration requests
+type ConfigHandler struct {
+	watcher *config.Watcher
+}
+
+// NewConfigHandler creates a new configuration handler
+func NewConfigHandler(watcher *config.Watcher) *ConfigHandler {
+	return &ConfigHandler{
+		watcher: watcher,
+	}
+}
+
+// GetConfig handles GET /api/v1/config. Only the principals listed in
+// auth.admins may read the configuration, and secrets are redacted.
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	current := h.watcher.Current()
+
+	principal := middleware.Principal(c)
+	if !isAdmin(h.watcher, principal) {
+		c.JSON(http.StatusForbidden, models.ConfigResponse{
+			Status:    models.StatusError,
+			Message:   "Reading the configuration requires an admin",
+			Error:     principal + " is not listed in auth.admins",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	loadedAt := h.watcher.LoadedAt()
+	c.JSON(http.StatusOK, models.ConfigResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Configuration retrieved successfully",
+		Source:    h.watcher.Path(),
+		LoadedAt:  &loadedAt,
+		Config:    current.Redacted(),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// isAdmin reports whether principal is listed in auth.admins. Without a
+// configuration nobody is an admin.
+func isAdmin(watcher *config.Watcher, principal string) bool {
+	if watcher == nil {
+		return false
+	}
+	return slices.Contains(watcher.Current().Auth.Admins, principal)
+}
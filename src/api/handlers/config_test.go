@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+)
+
+// setupConfigRouter serves GET /config with the caller authenticated as principal
+func setupConfigRouter(t *testing.T, principal string) *gin.Engine {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("auth:\n  apiKeys:\n    alice: alice-key\n  admins: [alice]\n"), 0o600))
+	watcher, err := config.NewWatcher(path)
+	require.NoError(t, err)
+
+	handler := NewConfigHandler(watcher)
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.GET("/config", handler.GetConfig)
+	return router
+}
+
+func TestGetConfig_Admin(t *testing.T) {
+	// Setup
+	router := setupConfigRouter(t, "alice")
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/config", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "alice-key")
+
+	var response models.ConfigResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Config)
+	assert.Equal(t, "REDACTED", response.Config.Auth.APIKeys["alice"])
+	assert.Equal(t, config.DefaultPort, response.Config.Port)
+	assert.NotEmpty(t, response.Source)
+}
+
+func TestGetConfig_NotAdmin(t *testing.T) {
+	// Setup
+	router := setupConfigRouter(t, "bob")
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/config", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "alice-key")
+}
@@ -1,25 +1,402 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/approval"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
 	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
 	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/prepull"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+	"github.com/torumakabe/aks-scale-to-zero/api/readiness"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+)
+
+// DefaultLockWaitTimeout bounds how long a queued request waits for a deployment lock
+const DefaultLockWaitTimeout = 60 * time.Second
+
+// Timeouts of scale-ups that wait for readiness with ?wait=true
+const (
+	DefaultReadyWaitTimeout = 5 * time.Minute
+	MaxReadyWaitTimeout     = 15 * time.Minute
+	// readyPollInterval is how often a waiting scale-up checks readiness
+	readyPollInterval = 2 * time.Second
+)
+
+// Conflict behaviours selectable with the onConflict query parameter
+const (
+	OnConflictReject = "reject"
+	OnConflictWait   = "wait"
 )
 
 // DeploymentHandler handles deployment-related requests
 type DeploymentHandler struct {
-	k8sClient k8s.ClientInterface
+	k8sClient        k8s.ClientInterface
+	locker           lock.Locker
+	lockWaitTimeout  time.Duration
+	policyEngine     *policy.Engine
+	quotaEngine      *quota.Engine
+	approvals        *approval.Store
+	maxLeaseDuration time.Duration
+	configWatcher    *config.Watcher
+	prepuller        *prepull.Manager
+	readiness        *readiness.Checker
+	readyPoll        time.Duration
+	drains           *drain.Manager
+	notifier         *notify.Notifier
+}
+
+// DeploymentHandlerOption configures optional DeploymentHandler dependencies
+type DeploymentHandlerOption func(*DeploymentHandler)
+
+// WithLocker sets the locker used to serialize operations on a deployment
+func WithLocker(locker lock.Locker) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.locker = locker
+	}
+}
+
+// WithLockWaitTimeout sets how long a queued request waits for a deployment lock
+func WithLockWaitTimeout(timeout time.Duration) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.lockWaitTimeout = timeout
+	}
+}
+
+// WithPolicyEngine sets the engine that evaluates scaling policies before a
+// deployment is scaled
+func WithPolicyEngine(engine *policy.Engine) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.policyEngine = engine
+	}
+}
+
+// WithQuotaEngine sets the engine that enforces namespace replica and GPU quotas
+// on scale-ups
+func WithQuotaEngine(engine *quota.Engine) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.quotaEngine = engine
+	}
+}
+
+// WithApprovalStore sets the store where scale-ups that need a second person's
+// approval are kept until they are decided
+func WithApprovalStore(store *approval.Store) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.approvals = store
+	}
+}
+
+// WithMaxLeaseDuration sets the longest lease a time-boxed scale-up may request
+func WithMaxLeaseDuration(d time.Duration) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.maxLeaseDuration = d
+	}
+}
+
+// WithConfigWatcher sets the configuration whose auth.admins may act on
+// leases held by others
+func WithConfigWatcher(watcher *config.Watcher) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.configWatcher = watcher
+	}
+}
+
+// WithPrepuller sets the manager whose image pre-pull progress is reported in
+// the deployment status
+func WithPrepuller(prepuller *prepull.Manager) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.prepuller = prepuller
+	}
+}
+
+// WithReadinessChecker sets the checker running the readiness checks declared
+// on deployments, reported in their status and awaited by ?wait=true scale-ups
+func WithReadinessChecker(checker *readiness.Checker) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.readiness = checker
+	}
+}
+
+// WithDrainManager sets the manager that drains deployments declaring a
+// drain before they are scaled to zero
+func WithDrainManager(drains *drain.Manager) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.drains = drains
+	}
+}
+
+// WithNotifier sets the notifier that tells subscribed webhooks about scale
+// operations and their failures
+func WithNotifier(notifier *notify.Notifier) DeploymentHandlerOption {
+	return func(h *DeploymentHandler) {
+		h.notifier = notifier
+	}
 }
 
 // NewDeploymentHandler creates a new deployment handler
-func NewDeploymentHandler(k8sClient k8s.ClientInterface) *DeploymentHandler {
-	return &DeploymentHandler{
-		k8sClient: k8sClient,
+func NewDeploymentHandler(k8sClient k8s.ClientInterface, opts ...DeploymentHandlerOption) *DeploymentHandler {
+	h := &DeploymentHandler{
+		k8sClient:        k8sClient,
+		locker:           lock.NewLocalLocker(),
+		lockWaitTimeout:  DefaultLockWaitTimeout,
+		maxLeaseDuration: lease.DefaultMaxDuration,
+		readyPoll:        readyPollInterval,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// acquireLock serializes scale operations on a deployment. By default a
+// concurrent operation is rejected; with ?onConflict=wait the request is
+// queued until the lock is free or the wait timeout elapses.
+func (h *DeploymentHandler) acquireLock(c *gin.Context, namespace, name string) (func(), bool) {
+	onConflict := c.DefaultQuery("onConflict", OnConflictReject)
+	if onConflict != OnConflictReject && onConflict != OnConflictWait {
+		c.JSON(http.StatusBadRequest, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid onConflict value",
+			Error:     fmt.Sprintf("onConflict must be %q or %q", OnConflictReject, OnConflictWait),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.lockWaitTimeout)
+	defer cancel()
+
+	release, err := h.locker.Acquire(ctx, lock.Key(namespace, name), onConflict == OnConflictWait)
+	if err == nil {
+		return release, true
+	}
+
+	switch {
+	case errors.Is(err, lock.ErrLocked), errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusConflict, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Deployment %s/%s is being scaled by another request", namespace, name),
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to acquire deployment lock",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
 	}
+	return nil, false
+}
+
+// checkPolicy evaluates scaling policies for the operation. It responds with
+// 403 or 422 and the violations when the operation is not allowed, and with
+// 422 when the deployment's policy annotations cannot be parsed.
+func (h *DeploymentHandler) checkPolicy(c *gin.Context, status *k8s.DeploymentStatus, op policy.Operation, replicas int32) (*policy.Decision, bool) {
+	if h.policyEngine == nil {
+		return &policy.Decision{Allowed: true}, true
+	}
+
+	decision, err := h.policyEngine.Evaluate(c.Request.Context(), policy.Request{
+		Namespace:   status.Namespace,
+		Name:        status.Name,
+		Annotations: status.Annotations,
+		Operation:   op,
+		Replicas:    replicas,
+	})
+	if errors.Is(err, policy.ErrInvalidAnnotations) {
+		c.JSON(http.StatusUnprocessableEntity, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Scaling policy annotations of deployment %s/%s are invalid", status.Namespace, status.Name),
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to evaluate scaling policy",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+
+	if !decision.Allowed {
+		c.JSON(decision.StatusCode, models.ScaleResponse{
+			Status:     models.StatusError,
+			Message:    fmt.Sprintf("Scaling policy does not allow %s of deployment %s/%s", op, status.Namespace, status.Name),
+			Error:      strings.Join(decision.Violations, "; "),
+			Violations: decision.Violations,
+			Timestamp:  time.Now().UTC(),
+		})
+		return nil, false
+	}
+	return decision, true
+}
+
+// checkQuota verifies that a scale-up keeps the namespace within its quota. It
+// responds with 403 and the violations when the quota would be exceeded.
+func (h *DeploymentHandler) checkQuota(c *gin.Context, status *k8s.DeploymentStatus, replicas int32) bool {
+	if h.quotaEngine == nil {
+		return true
+	}
+
+	decision, err := h.quotaEngine.Check(c.Request.Context(), status.Namespace, status.Name, replicas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to evaluate namespace quota",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return false
+	}
+
+	if !decision.Allowed {
+		c.JSON(http.StatusForbidden, models.ScaleResponse{
+			Status:     models.StatusError,
+			Message:    fmt.Sprintf("Namespace quota does not allow scaling deployment %s/%s to %d replicas", status.Namespace, status.Name, replicas),
+			Error:      strings.Join(decision.Violations, "; "),
+			Violations: decision.Violations,
+			Timestamp:  time.Now().UTC(),
+		})
+		return false
+	}
+	return true
+}
+
+// acquireNamespaceLock serializes quota-checked scale-ups within a namespace so
+// that concurrent requests for different deployments cannot both pass the check
+func (h *DeploymentHandler) acquireNamespaceLock(c *gin.Context, namespace string) (func(), bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.lockWaitTimeout)
+	defer cancel()
+
+	release, err := h.locker.Acquire(ctx, lock.NamespaceKey(namespace), true)
+	if err != nil {
+		c.JSON(http.StatusConflict, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Namespace %s quota is being updated by another request", namespace),
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+	return release, true
+}
+
+// parseDryRun reads the dryRun query parameter
+func parseDryRun(c *gin.Context) (bool, bool) {
+	value := c.Query("dryRun")
+	if value == "" {
+		return false, true
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid dryRun value",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return false, false
+	}
+	return dryRun, true
+}
+
+// parseWait reads the wait and timeout query parameters. It returns how long
+// a scale-up waits for the deployment to become ready, or 0 to not wait.
+func parseWait(c *gin.Context) (time.Duration, bool) {
+	invalid := func(message string, err error) (time.Duration, bool) {
+		c.JSON(http.StatusBadRequest, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return 0, false
+	}
+
+	wait := false
+	if value := c.Query("wait"); value != "" {
+		var err error
+		if wait, err = strconv.ParseBool(value); err != nil {
+			return invalid("Invalid wait value", err)
+		}
+	}
+	if !wait {
+		return 0, true
+	}
+
+	timeout := DefaultReadyWaitTimeout
+	if value := c.Query("timeout"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err == nil && (d <= 0 || d > MaxReadyWaitTimeout) {
+			err = fmt.Errorf("timeout must be greater than 0 and at most %s", MaxReadyWaitTimeout)
+		}
+		if err != nil {
+			return invalid("Invalid timeout value", err)
+		}
+		timeout = d
+	}
+	return timeout, true
+}
+
+// dryRunScale previews a scale operation with server-side dry run and responds
+// with the deployment as it would be after scaling
+func (h *DeploymentHandler) dryRunScale(c *gin.Context, status *k8s.DeploymentStatus, replicas int32, targetStatus, reason string, decision *policy.Decision) {
+	preview, err := h.k8sClient.DryRunScaleDeployment(c.Request.Context(), status.Namespace, status.Name, replicas)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to dry-run scale deployment",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Dry run: deployment would be scaled to %d replicas", replicas)
+	if replicas == 0 {
+		message = "Dry run: deployment would be scaled to zero"
+	}
+
+	c.JSON(http.StatusOK, models.ScaleResponse{
+		Status:    models.StatusSuccess,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+		Deployment: &models.DeploymentInfo{
+			Name:             status.Name,
+			Namespace:        status.Namespace,
+			PreviousReplicas: status.DesiredReplicas,
+			CurrentReplicas:  status.CurrentReplicas,
+			TargetReplicas:   preview.DesiredReplicas,
+			TargetStatus:     targetStatus,
+			ScalingReason:    reason,
+			NodePool:         preview.NodePool,
+			DryRun:           true,
+			PolicyDecisions:  decision.Notes,
+		},
+	})
 }
 
 // ScaleToZero handles POST /api/v1/deployments/{namespace}/{name}/scale-to-zero
@@ -37,12 +414,55 @@ func (h *DeploymentHandler) ScaleToZero(c *gin.Context) {
 		return
 	}
 
+	dryRun, ok := parseDryRun(c)
+	if !ok {
+		return
+	}
+
+	drainFirst := true
+	if value := c.Query("drain"); value != "" {
+		var err error
+		if drainFirst, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.ScaleResponse{
+				Status:    models.StatusError,
+				Message:   "Invalid drain value",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+	}
+
+	h.scaleToZero(c, namespace, name, req, dryRun, drainFirst)
+}
+
+// scaleToZero runs the scale-to-zero of a deployment and writes the response.
+// An active scale-up lease is released. With drainFirst, a deployment that
+// declares a drain is drained in the background and the response is 202
+// Accepted with the operation to poll.
+func (h *DeploymentHandler) scaleToZero(c *gin.Context, namespace, name string, req models.ScaleRequest, dryRun, drainFirst bool) {
+	// Dry runs do not change the cluster, so they do not need the lock. A
+	// drain takes the lock over until the deployment is scaled to zero.
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+	if !dryRun {
+		var ok bool
+		if release, ok = h.acquireLock(c, namespace, name); !ok {
+			return
+		}
+	}
+
 	// Get current deployment status
 	status, err := h.k8sClient.GetDeploymentStatus(c.Request.Context(), namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ScaleResponse{
+		statusCode, message := lookupError(namespace, name, err)
+		c.JSON(statusCode, models.ScaleResponse{
 			Status:  models.StatusError,
-			Message: fmt.Sprintf("Deployment %s/%s not found", namespace, name),
+			Message: message,
 			Error:   err.Error(),
 		})
 		return
@@ -50,17 +470,49 @@ func (h *DeploymentHandler) ScaleToZero(c *gin.Context) {
 
 	previousReplicas := status.DesiredReplicas
 
+	decision, ok := h.checkPolicy(c, status, policy.OperationScaleToZero, 0)
+	if !ok {
+		return
+	}
+
+	if dryRun {
+		h.dryRunScale(c, status, 0, "scaled-to-zero", req.Reason, decision)
+		return
+	}
+
+	if drainFirst && h.drains != nil && status.DesiredReplicas > 0 {
+		spec, err := drain.ParseSpec(status.Annotations)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, models.ScaleResponse{
+				Status:    models.StatusError,
+				Message:   "Invalid drain declaration",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		if spec != nil {
+			if h.startDrain(c, status, spec, req, decision, release) {
+				release = nil
+			}
+			return
+		}
+	}
+
 	// Scale to zero
 	err = h.k8sClient.ScaleDeployment(c.Request.Context(), namespace, name, 0)
+	h.notifyScale(c, status, 0, req.Reason, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
-			Status:  models.StatusError,
-			Message: "Failed to scale deployment",
-			Error:   err.Error(),
-		})
+		scaleFailed(c, err)
 		return
 	}
 
+	if status.Labels[lease.LabelLeased] == "true" {
+		if err := lease.Clear(c.Request.Context(), h.k8sClient, namespace, name); err != nil {
+			log.Printf("Failed to release lease of deployment %s/%s: %v", namespace, name, err)
+		}
+	}
+
 	response := models.ScaleResponse{
 		Status:    models.StatusSuccess,
 		Message:   "Deployment scaled to zero",
@@ -74,12 +526,51 @@ func (h *DeploymentHandler) ScaleToZero(c *gin.Context) {
 			TargetStatus:     "scaled-to-zero",
 			ScalingReason:    req.Reason,
 			ScheduledScaleUp: req.ScheduledScaleUp,
+			NodePool:         status.NodePool,
+			PolicyDecisions:  decision.Notes,
 		},
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// startDrain hands the deployment lock to a background drain and responds
+// with 202 Accepted and the operation to poll. It reports whether the drain
+// took over the lock.
+func (h *DeploymentHandler) startDrain(c *gin.Context, status *k8s.DeploymentStatus, spec *drain.Spec, req models.ScaleRequest, decision *policy.Decision, release func()) bool {
+	op, err := h.drains.Start(c.Request.Context(), status, spec, req.Reason, middleware.Principal(c), release)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to start drain",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return false
+	}
+
+	c.Header("Location", "/api/v1/operations/"+op.ID)
+	c.JSON(http.StatusAccepted, models.ScaleResponse{
+		Status:    models.ResponseStatusPending,
+		Message:   "Draining deployment before scaling to zero",
+		Timestamp: time.Now().UTC(),
+		Deployment: &models.DeploymentInfo{
+			Name:             status.Name,
+			Namespace:        status.Namespace,
+			PreviousReplicas: status.DesiredReplicas,
+			CurrentReplicas:  status.CurrentReplicas,
+			TargetReplicas:   0,
+			TargetStatus:     models.StatusDraining,
+			ScalingReason:    req.Reason,
+			ScheduledScaleUp: req.ScheduledScaleUp,
+			NodePool:         status.NodePool,
+			PolicyDecisions:  decision.Notes,
+		},
+		Operation: operationInfo(op),
+	})
+	return true
+}
+
 // ScaleUp handles POST /api/v1/deployments/{namespace}/{name}/scale-up
 func (h *DeploymentHandler) ScaleUp(c *gin.Context) {
 	namespace := c.Param("namespace")
@@ -95,12 +586,61 @@ func (h *DeploymentHandler) ScaleUp(c *gin.Context) {
 		return
 	}
 
+	dryRun, ok := parseDryRun(c)
+	if !ok {
+		return
+	}
+	wait, ok := parseWait(c)
+	if !ok {
+		return
+	}
+
+	h.scaleUp(c, namespace, name, req, dryRun, wait, nil)
+}
+
+// scaleUp runs the scale-up of a deployment and writes the response. approved
+// is the approval request that authorized the scale-up, or nil for a direct
+// request, in which case scale-ups above the approval threshold are held as a
+// pending approval request instead of being executed. With a wait timeout,
+// the response is sent once the deployment is ready or the timeout elapses.
+func (h *DeploymentHandler) scaleUp(c *gin.Context, namespace, name string, req models.ScaleUpRequest, dryRun bool, wait time.Duration, approved *approval.Approval) {
+	leaseExpiry, err := lease.Resolve(req.Duration, req.ExpiresAt, time.Now(), h.maxLeaseDuration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid lease",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// Locks are released before waiting for the deployment to become ready
+	var releases []func()
+	releaseLocks := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+		releases = nil
+	}
+	defer releaseLocks()
+
+	// Dry runs do not change the cluster, so they do not need the lock
+	if !dryRun {
+		release, ok := h.acquireLock(c, namespace, name)
+		if !ok {
+			return
+		}
+		releases = append(releases, release)
+	}
+
 	// Get current deployment status
 	status, err := h.k8sClient.GetDeploymentStatus(c.Request.Context(), namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ScaleResponse{
+		statusCode, message := lookupError(namespace, name, err)
+		c.JSON(statusCode, models.ScaleResponse{
 			Status:  models.StatusError,
-			Message: fmt.Sprintf("Deployment %s/%s not found", namespace, name),
+			Message: message,
 			Error:   err.Error(),
 		})
 		return
@@ -108,14 +648,79 @@ func (h *DeploymentHandler) ScaleUp(c *gin.Context) {
 
 	previousReplicas := status.DesiredReplicas
 
+	decision, ok := h.checkPolicy(c, status, policy.OperationScaleUp, req.Replicas)
+	if !ok {
+		return
+	}
+
+	if h.quotaEngine != nil && !dryRun {
+		release, ok := h.acquireNamespaceLock(c, namespace)
+		if !ok {
+			return
+		}
+		releases = append(releases, release)
+	}
+
+	if !h.checkQuota(c, status, req.Replicas) {
+		return
+	}
+
+	if approved == nil && decision.RequiresApproval() {
+		if dryRun {
+			for _, reason := range decision.ApprovalReasons {
+				decision.Notes = append(decision.Notes, "approval required: "+reason)
+			}
+		} else {
+			h.requestApproval(c, status, req, decision)
+			return
+		}
+	}
+
+	if dryRun {
+		h.dryRunScale(c, status, req.Replicas, "scaling-up", req.Reason, decision)
+		return
+	}
+
+	// Record the lease before scaling so a scale-up is never left without its
+	// time box. A scale-up without a lease ends the previous one.
+	_, hadLease := lease.FromAnnotations(status.Annotations)
+	leaseChanged := !leaseExpiry.IsZero() || hadLease
+	if leaseExpiry.IsZero() && hadLease {
+		if err := lease.Clear(c.Request.Context(), h.k8sClient, namespace, name); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+				Status:    models.StatusError,
+				Message:   "Failed to release scale-up lease",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+	}
+	if !leaseExpiry.IsZero() {
+		holder := middleware.Principal(c)
+		if approved != nil {
+			holder = approved.RequestedBy
+		}
+		err := lease.Set(c.Request.Context(), h.k8sClient, namespace, name, lease.Lease{ExpiresAt: leaseExpiry, Holder: holder})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+				Status:    models.StatusError,
+				Message:   "Failed to record scale-up lease",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+	}
+
 	// Scale up
 	err = h.k8sClient.ScaleDeployment(c.Request.Context(), namespace, name, req.Replicas)
+	h.notifyScale(c, status, req.Replicas, req.Reason, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
-			Status:  models.StatusError,
-			Message: "Failed to scale deployment",
-			Error:   err.Error(),
-		})
+		if leaseChanged {
+			h.restoreLease(c, status)
+		}
+		scaleFailed(c, err)
 		return
 	}
 
@@ -131,12 +736,194 @@ func (h *DeploymentHandler) ScaleUp(c *gin.Context) {
 			TargetReplicas:   req.Replicas,
 			TargetStatus:     "scaling-up",
 			ScalingReason:    req.Reason,
+			NodePool:         status.NodePool,
+			PolicyDecisions:  decision.Notes,
 		},
 	}
+	if !leaseExpiry.IsZero() {
+		response.Deployment.LeaseExpiresAt = &leaseExpiry
+	}
+	if approved != nil {
+		response.Approval = approvalInfo(approved)
+	}
+
+	if wait > 0 {
+		releaseLocks()
+		current, report, err := h.waitReady(c.Request.Context(), namespace, name, req.Replicas, wait)
+		if current != nil {
+			response.Deployment.CurrentReplicas = current.CurrentReplicas
+		}
+		response.Deployment.Readiness = readinessStatus(report, nil)
+		if err != nil {
+			response.Status = models.StatusError
+			response.Message = fmt.Sprintf("Deployment scaled to %d replicas, but is not ready", req.Replicas)
+			response.Error = err.Error()
+			c.JSON(http.StatusGatewayTimeout, response)
+			return
+		}
+		response.Deployment.TargetStatus = "ready"
+		response.Message = fmt.Sprintf("Deployment scaled to %d replicas and ready", req.Replicas)
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// scaleFailed responds to a failed replica change. Changes rejected by the
+// per-deployment throttle are answered with 429 and a Retry-After header.
+func scaleFailed(c *gin.Context, err error) {
+	var throttled *throttle.Error
+	if errors.As(err, &throttled) {
+		middleware.SetRetryAfter(c, throttled.RetryAfter)
+		c.JSON(http.StatusTooManyRequests, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Deployment is scaled too often",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+		Status:  models.StatusError,
+		Message: "Failed to scale deployment",
+		Error:   err.Error(),
+	})
+}
+
+// notifyScale reports the outcome of scaling a deployment to replicas
+func (h *DeploymentHandler) notifyScale(c *gin.Context, status *k8s.DeploymentStatus, replicas int32, reason string, err error) {
+	h.notifier.NotifyScale(status.Namespace, status.Name, status.DesiredReplicas, replicas, middleware.Principal(c), reason, err)
+}
+
+// waitReady polls a scaled-up deployment until replicas pods are available
+// and its readiness checks pass, or the timeout elapses. It returns the last
+// deployment status and readiness report seen.
+func (h *DeploymentHandler) waitReady(ctx context.Context, namespace, name string, replicas int32, timeout time.Duration) (*k8s.DeploymentStatus, *readiness.Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(h.readyPoll)
+	defer ticker.Stop()
+
+	var (
+		status *k8s.DeploymentStatus
+		report *readiness.Report
+		cause  error
+	)
+	for {
+		current, err := h.k8sClient.GetDeploymentStatus(ctx, namespace, name)
+		switch {
+		case ctx.Err() != nil:
+			// Cut short by the timeout; the last complete results are reported
+		case err != nil:
+			cause = err
+		case current.AvailableReplicas < replicas:
+			status = current
+			cause = fmt.Errorf("%d of %d replicas available", current.AvailableReplicas, replicas)
+		case h.readiness == nil:
+			return current, nil, nil
+		default:
+			status = current
+			next, err := h.readiness.Check(ctx, namespace, name, current.Annotations)
+			switch {
+			case ctx.Err() != nil:
+			case err != nil:
+				// Invalid checks do not become valid by waiting
+				return status, nil, err
+			case next == nil || next.Ready:
+				return status, next, nil
+			default:
+				report = next
+				cause = fmt.Errorf("readiness checks failed")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if cause == nil {
+				cause = ctx.Err()
+			}
+			return status, report, fmt.Errorf("not ready within %s: %w", timeout, cause)
+		case <-ticker.C:
+		}
+	}
+}
+
+// restoreLease puts back the lease a deployment had before a failed scale-up
+func (h *DeploymentHandler) restoreLease(c *gin.Context, status *k8s.DeploymentStatus) {
+	var err error
+	if previous, ok := lease.FromAnnotations(status.Annotations); ok {
+		err = lease.Set(c.Request.Context(), h.k8sClient, status.Namespace, status.Name, *previous)
+	} else {
+		err = lease.Clear(c.Request.Context(), h.k8sClient, status.Namespace, status.Name)
+	}
+	if err != nil {
+		log.Printf("Failed to restore lease of deployment %s/%s: %v", status.Namespace, status.Name, err)
+	}
+}
+
+// requestApproval holds a scale-up as a pending approval request and responds
+// with 202 Accepted
+func (h *DeploymentHandler) requestApproval(c *gin.Context, status *k8s.DeploymentStatus, req models.ScaleUpRequest, decision *policy.Decision) {
+	if h.approvals == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ScaleResponse{
+			Status:     models.StatusError,
+			Message:    "Scale-up requires approval, but approvals are not available",
+			Error:      strings.Join(decision.ApprovalReasons, "; "),
+			Violations: decision.ApprovalReasons,
+			Timestamp:  time.Now().UTC(),
+		})
+		return
+	}
+
+	pending, err := h.approvals.Create(c.Request.Context(), approval.Request{
+		Namespace:      status.Namespace,
+		Name:           status.Name,
+		Replicas:       req.Replicas,
+		Reason:         req.Reason,
+		LeaseDuration:  req.Duration,
+		LeaseExpiresAt: req.ExpiresAt,
+		Reasons:        decision.ApprovalReasons,
+		RequestedBy:    middleware.Principal(c),
+	})
+	if errors.Is(err, approval.ErrNoApprover) {
+		c.JSON(http.StatusUnprocessableEntity, models.ScaleResponse{
+			Status:     models.StatusError,
+			Message:    "Scale-up requires approval, but nobody else can approve it",
+			Error:      err.Error(),
+			Violations: decision.ApprovalReasons,
+			Timestamp:  time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to create approval request",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.ScaleResponse{
+		Status:    models.ResponseStatusPending,
+		Message:   fmt.Sprintf("Scale-up to %d replicas requires approval", req.Replicas),
+		Timestamp: time.Now().UTC(),
+		Deployment: &models.DeploymentInfo{
+			Name:             status.Name,
+			Namespace:        status.Namespace,
+			PreviousReplicas: status.DesiredReplicas,
+			CurrentReplicas:  status.CurrentReplicas,
+			TargetReplicas:   req.Replicas,
+			TargetStatus:     "pending-approval",
+			ScalingReason:    req.Reason,
+			NodePool:         status.NodePool,
+			PolicyDecisions:  decision.Notes,
+		},
+		Approval: approvalInfo(pending),
+	})
+}
+
 // GetStatus handles GET /api/v1/deployments/{namespace}/{name}/status
 func (h *DeploymentHandler) GetStatus(c *gin.Context) {
 	namespace := c.Param("namespace")
@@ -144,15 +931,59 @@ func (h *DeploymentHandler) GetStatus(c *gin.Context) {
 
 	status, err := h.k8sClient.GetDeploymentStatus(c.Request.Context(), namespace, name)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.DeploymentStatusResponse{
+		statusCode, message := lookupError(namespace, name, err)
+		c.JSON(statusCode, models.DeploymentStatusResponse{
 			Status:    models.StatusError,
-			Message:   fmt.Sprintf("Deployment %s/%s not found", namespace, name),
+			Message:   message,
 			Error:     err.Error(),
 			Timestamp: time.Now(),
 		})
 		return
 	}
 
+	info := deploymentInfo(status)
+	if h.readiness != nil && status.DesiredReplicas > 0 {
+		report, err := h.readiness.Check(c.Request.Context(), namespace, name, status.Annotations)
+		info.Readiness = readinessStatus(report, err)
+	}
+	if h.prepuller != nil && prepull.Enabled(status.Annotations) {
+		progress, err := h.prepuller.Progress(c.Request.Context(), namespace, name)
+		if err != nil {
+			log.Printf("Failed to get image pre-pull progress of deployment %s/%s: %v", namespace, name, err)
+		} else if progress != nil {
+			info.Prepull = &models.PrepullStatus{
+				Images:       progress.Images,
+				DesiredNodes: progress.DesiredNodes,
+				PulledNodes:  progress.PulledNodes,
+				FailedNodes:  progress.FailedNodes,
+				Errors:       progress.Errors,
+				Complete:     progress.Complete(),
+				StartedAt:    progress.StartedAt,
+			}
+		}
+	}
+
+	response := models.DeploymentStatusResponse{
+		Status:     models.StatusSuccess,
+		Message:    "Deployment status retrieved successfully",
+		Deployment: info,
+		Timestamp:  time.Now(),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// lookupError maps a failed deployment lookup to a status code and message.
+// Deployments outside the managed scope are rejected with 403.
+func lookupError(namespace, name string, err error) (int, string) {
+	if errors.Is(err, k8s.ErrNotManaged) {
+		return http.StatusForbidden, fmt.Sprintf("Deployment %s/%s is not managed by the Scale API", namespace, name)
+	}
+	return http.StatusNotFound, fmt.Sprintf("Deployment %s/%s not found", namespace, name)
+}
+
+// deploymentInfo converts a deployment's status into its API representation
+func deploymentInfo(status *k8s.DeploymentStatus) *models.DeploymentStatus {
 	// Determine status
 	deploymentStatus := models.StatusActive
 	if status.DesiredReplicas == 0 {
@@ -161,23 +992,55 @@ func (h *DeploymentHandler) GetStatus(c *gin.Context) {
 		deploymentStatus = models.StatusScaling
 	}
 
-	deploymentInfo := &models.DeploymentStatus{
+	info := &models.DeploymentStatus{
 		Name:              status.Name,
 		Namespace:         status.Namespace,
-		Deployment:        name,
+		Deployment:        status.Name,
 		CurrentReplicas:   status.CurrentReplicas,
 		DesiredReplicas:   status.DesiredReplicas,
 		AvailableReplicas: status.AvailableReplicas,
 		Status:            deploymentStatus,
+		NodePool:          status.NodePool,
 		LastScaleTime:     status.CreationTime,
 	}
+	if l, ok := lease.FromAnnotations(status.Annotations); ok {
+		info.Lease = leaseInfo(status.Namespace, status.Name, l)
+	}
+	if since, ok := drain.DrainingSince(status.Annotations); ok {
+		info.Status = models.StatusDraining
+		info.DrainingSince = &since
+	}
+	for _, a := range status.Autoscalers {
+		info.Autoscalers = append(info.Autoscalers, models.AutoscalerInfo{
+			Kind:        a.Kind,
+			Name:        a.Name,
+			MinReplicas: a.MinReplicas,
+			MaxReplicas: a.MaxReplicas,
+			Paused:      a.Paused,
+		})
+	}
+	return info
+}
 
-	response := models.DeploymentStatusResponse{
-		Status:     models.StatusSuccess,
-		Message:    "Deployment status retrieved successfully",
-		Deployment: deploymentInfo,
-		Timestamp:  time.Now(),
+// readinessStatus converts a readiness report into its API representation. It
+// returns nil when the deployment declares no readiness checks.
+func readinessStatus(report *readiness.Report, err error) *models.ReadinessStatus {
+	if err != nil {
+		return &models.ReadinessStatus{Error: err.Error()}
+	}
+	if report == nil {
+		return nil
 	}
 
-	c.JSON(http.StatusOK, response)
+	status := &models.ReadinessStatus{Ready: report.Ready, CheckedAt: report.CheckedAt}
+	for _, result := range report.Results {
+		status.Checks = append(status.Checks, models.ReadinessCheck{
+			Name:    result.Name,
+			Type:    result.Type,
+			Target:  result.Target,
+			Ready:   result.Ready,
+			Message: result.Message,
+		})
+	}
+	return status
 }
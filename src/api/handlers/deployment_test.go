@@ -1,19 +1,37 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
 	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
 	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/prepull"
+	"github.com/torumakabe/aks-scale-to-zero/api/readiness"
 	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
 	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestScaleToZero_Success(t *testing.T) {
@@ -56,6 +74,171 @@ func TestScaleToZero_Success(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestScaleUp_Notifies(t *testing.T) {
+	// Setup
+	received := make(chan string, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Text string `json:"text"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &message)
+		received <- message.Text
+	}))
+	defer receiver.Close()
+
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: notify.DefaultConfigMapName, Namespace: notify.DefaultConfigMapNamespace},
+		Data: map[string]string{"notifications.yaml": `
+sinks:
+  team:
+    type: slack
+    url: ` + receiver.URL + `
+subscriptions:
+  - namespaces: [test-ns]
+    events: [scaled, failed]
+    sinks: [team]
+`},
+	})
+	notifier := notify.NewNotifier(clientset, notify.NewConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithNotifier(notifier))
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, "alice") })
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).Return(nil).Once()
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(3)).Return(fmt.Errorf("quota exceeded"))
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 2, Reason: "Demo"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 3, Reason: "Demo"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Assert
+	for _, want := range []string{
+		"Deployment test-ns/test-app scaled from 0 to 2 replicas by alice: Demo",
+		"Deployment test-ns/test-app could not be scaled from 0 to 3 replicas by alice: Demo (quota exceeded)",
+	} {
+		select {
+		case text := <-received:
+			assert.Equal(t, want, text)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notification %q not received", want)
+		}
+	}
+}
+
+func TestScaleToZero_Drain(t *testing.T) {
+	// Setup: the deployment has no running pods, so it is idle right away
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test-app"}}},
+	})
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(clientset)
+	operations := operation.NewStore(operation.DefaultRetention)
+	locker := lock.NewLocalLocker()
+	handler := NewDeploymentHandler(mockClient,
+		WithLocker(locker),
+		WithDrainManager(drain.NewManager(mockClient, operations, &drain.Config{PollInterval: time.Millisecond})))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2)
+	status.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"metric","port":8002,"metric":"nv_inference_pending_request_count"}`}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).Return(nil)
+
+	// Test
+	body := models.ScaleRequest{Reason: "End of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero", body)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.ResponseStatusPending, response.Status)
+	assert.Equal(t, models.StatusDraining, response.Deployment.TargetStatus)
+	if assert.NotNil(t, response.Operation) {
+		assert.Equal(t, "/api/v1/operations/"+response.Operation.ID, w.Header().Get("Location"))
+		assert.Equal(t, drain.OperationType, response.Operation.Type)
+
+		assert.Eventually(t, func() bool {
+			op, _ := operations.Get(response.Operation.ID)
+			return op.Status == operation.StatusSucceeded
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	// The lock is released once the deployment is scaled to zero
+	assert.Eventually(t, func() bool {
+		release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "test-app"), false)
+		if err == nil {
+			release()
+		}
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+	mockClient.AssertCalled(t, "ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0))
+}
+
+func TestScaleToZero_DrainSkipped(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(fake.NewSimpleClientset())
+	handler := NewDeploymentHandler(mockClient,
+		WithDrainManager(drain.NewManager(mockClient, operation.NewStore(operation.DefaultRetention), drain.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2)
+	status.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"http","port":8080,"path":"/drain"}`}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).Return(nil)
+
+	// Test
+	body := models.ScaleRequest{Reason: "Emergency stop"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero?drain=false", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleToZero_InvalidDrain(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(fake.NewSimpleClientset())
+	handler := NewDeploymentHandler(mockClient,
+		WithDrainManager(drain.NewManager(mockClient, operation.NewStore(operation.DefaultRetention), drain.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2)
+	status.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"metric"}`}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	body := models.ScaleRequest{Reason: "End of day"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero", body)
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, http.StatusBadRequest, helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero?drain=soon", body).Code)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestScaleToZero_AlreadyScaledToZero(t *testing.T) {
 	// Setup
 	mockClient := mocks.NewMockK8sClient()
@@ -128,6 +311,30 @@ func TestScaleToZero_DeploymentNotFound(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestScaleToZero_NotManaged(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	notManagedErr := fmt.Errorf("%w: namespace kube-system is denied", k8s.ErrNotManaged)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "kube-system", "coredns").Return(nil, notManagedErr)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/kube-system/coredns/scale-to-zero", models.ScaleRequest{})
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Contains(t, response.Message, "not managed by the Scale API")
+	assert.Contains(t, response.Error, "namespace kube-system is denied")
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestScaleUp_Success(t *testing.T) {
 	// Setup
 	mockClient := mocks.NewMockK8sClient()
@@ -167,6 +374,123 @@ func TestScaleUp_Success(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+// readinessAnnotations declares a Triton model check against a test server
+// reached through the test-app Service of newTestReadinessChecker
+func readinessAnnotations(t *testing.T, model string) map[string]string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/models/resnet50/ready" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	_, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	return map[string]string{
+		readiness.AnnotationChecks: fmt.Sprintf(`[{"type":"triton","model":%q,"host":"test-app","port":%s}]`, model, port),
+	}
+}
+
+// newTestReadinessChecker returns a checker seeing test-ns/test-app and its
+// Service on the loopback address
+func newTestReadinessChecker() *readiness.Checker {
+	podLabels := map[string]string{"app": "test-app"}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns"},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: podLabels}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns"},
+			Spec:       corev1.ServiceSpec{Selector: podLabels, ClusterIP: "127.0.0.1"},
+		},
+	)
+	return readiness.NewChecker(clientset, readiness.DefaultTimeout)
+}
+
+func TestScaleUp_Wait(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithReadinessChecker(newTestReadinessChecker()))
+	handler.readyPoll = time.Millisecond
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	annotations := readinessAnnotations(t, "resnet50")
+	scaledToZero := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	starting := mocks.MockDeploymentStatus("test-app", "test-ns", 1, 2)
+	available := mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2)
+	available.Annotations = annotations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(scaledToZero, nil).Once()
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).Return(nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(starting, nil).Once()
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(available, nil)
+
+	// Test
+	body := models.ScaleUpRequest{Replicas: 2, Reason: "Resume operations"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?wait=true", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "Deployment scaled to 2 replicas and ready", response.Message)
+	assert.Equal(t, "ready", response.Deployment.TargetStatus)
+	assert.Equal(t, int32(2), response.Deployment.CurrentReplicas)
+	if assert.NotNil(t, response.Deployment.Readiness) {
+		assert.True(t, response.Deployment.Readiness.Ready)
+		assert.Equal(t, "triton", response.Deployment.Readiness.Checks[0].Type)
+	}
+}
+
+func TestScaleUp_WaitTimeout(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithReadinessChecker(newTestReadinessChecker()))
+	handler.readyPoll = time.Millisecond
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations: the model is never loaded
+	scaledToZero := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	available := mocks.MockDeploymentStatus("test-app", "test-ns", 1, 1)
+	available.Annotations = readinessAnnotations(t, "densenet")
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(scaledToZero, nil).Once()
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(1)).Return(nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(available, nil)
+
+	// Test
+	body := models.ScaleUpRequest{Replicas: 1, Reason: "Resume operations"}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?wait=true&timeout=50ms", body)
+
+	// Assert
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Contains(t, response.Error, "not ready within 50ms: readiness checks failed")
+	if assert.NotNil(t, response.Deployment.Readiness) {
+		assert.False(t, response.Deployment.Readiness.Ready)
+		assert.Contains(t, response.Deployment.Readiness.Checks[0].Message, "returned 400")
+	}
+}
+
+func TestScaleUp_InvalidWait(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	body := models.ScaleUpRequest{Replicas: 1, Reason: "Resume operations"}
+	for _, query := range []string{"wait=maybe", "wait=true&timeout=1h", "wait=true&timeout=soon"} {
+		w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?"+query, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestScaleUp_InvalidReplicaCount(t *testing.T) {
 	// Setup
 	mockClient := mocks.NewMockK8sClient()
@@ -248,6 +572,115 @@ func TestGetStatus_Success(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestGetStatus_Prepull(t *testing.T) {
+	// Setup
+	clientset := fake.NewSimpleClientset(&appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app-prepull", Namespace: "test-ns"},
+		Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "pull-0", Image: "nvcr.io/nvidia/tritonserver:24.05-py3"}},
+		}}},
+		Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2},
+	})
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(clientset)
+	handler := NewDeploymentHandler(mockClient, WithPrepuller(prepull.NewManager(mockClient, prepull.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments/:namespace/:name/status", handler.GetStatus)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 1)
+	status.Annotations = map[string]string{prepull.AnnotationPrepull: "true"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments/test-ns/test-app/status", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DeploymentStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	prepullStatus := response.Deployment.Prepull
+	if assert.NotNil(t, prepullStatus) {
+		assert.Equal(t, []string{"nvcr.io/nvidia/tritonserver:24.05-py3"}, prepullStatus.Images)
+		assert.Equal(t, int32(2), prepullStatus.PulledNodes)
+		assert.True(t, prepullStatus.Complete)
+	}
+}
+
+func TestGetStatus_Readiness(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithReadinessChecker(newTestReadinessChecker()))
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments/:namespace/:name/status", handler.GetStatus)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 1, 1)
+	status.Annotations = readinessAnnotations(t, "resnet50")
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments/test-ns/test-app/status", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DeploymentStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	if assert.NotNil(t, response.Deployment.Readiness) {
+		assert.True(t, response.Deployment.Readiness.Ready)
+		assert.Len(t, response.Deployment.Readiness.Checks, 1)
+	}
+}
+
+func TestGetStatus_Draining(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments/:namespace/:name/status", handler.GetStatus)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2)
+	status.Annotations = map[string]string{drain.AnnotationDrainingSince: "2025-07-17T18:00:00Z"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments/test-ns/test-app/status", nil)
+
+	// Assert
+	var response models.DeploymentStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusDraining, response.Deployment.Status)
+	if assert.NotNil(t, response.Deployment.DrainingSince) {
+		assert.Equal(t, time.Date(2025, 7, 17, 18, 0, 0, 0, time.UTC), response.Deployment.DrainingSince.UTC())
+	}
+}
+
+func TestGetStatus_Autoscalers(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments/:namespace/:name/status", handler.GetStatus)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Autoscalers = []k8s.Autoscaler{{Kind: k8s.KindHorizontalPodAutoscaler, Name: "test-app", MinReplicas: 1, MaxReplicas: 4, Paused: true}}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments/test-ns/test-app/status", nil)
+
+	// Assert
+	var response models.DeploymentStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, []models.AutoscalerInfo{
+		{Kind: "HorizontalPodAutoscaler", Name: "test-app", MinReplicas: 1, MaxReplicas: 4, Paused: true},
+	}, response.Deployment.Autoscalers)
+}
+
 func TestGetStatus_NotFound(t *testing.T) {
 	// Setup
 	mockClient := mocks.NewMockK8sClient()
@@ -315,6 +748,36 @@ func TestScaleDeployment_ScaleError(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestScaleDeployment_Throttled(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 3, 3)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).Return(&throttle.Error{
+		Message:    "Deployment test-ns/test-app was recently scaled in the opposite direction",
+		RetryAfter: 12500 * time.Millisecond,
+	})
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero", models.ScaleRequest{Reason: "Test"})
+
+	// Assert
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "13", w.Header().Get("Retry-After"))
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Contains(t, response.Error, "opposite direction")
+
+	mockClient.AssertExpectations(t)
+}
+
 func TestScaleToZero_InvalidJSON(t *testing.T) {
 	// Setup
 	mockClient := mocks.NewMockK8sClient()
@@ -352,3 +815,316 @@ func TestScaleUp_InvalidJSON(t *testing.T) {
 	assert.Equal(t, models.StatusError, response.Status)
 	assert.Contains(t, response.Error, "cannot unmarshal")
 }
+
+func TestScaleUp_ConcurrentOperationRejected(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	handler := NewDeploymentHandler(mockClient, WithLocker(locker))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Simulate an in-flight operation on the same deployment
+	release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "test-app"), false)
+	assert.NoError(t, err)
+	defer release()
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 2,
+		Reason:   "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Contains(t, response.Message, "being scaled by another request")
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleToZero_ConcurrentOperationQueued(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	handler := NewDeploymentHandler(mockClient, WithLocker(locker))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 2, 2), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).Return(nil)
+
+	// Hold the lock briefly so the request has to queue
+	release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "test-app"), false)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release()
+	}()
+
+	// Test
+	body := models.ScaleRequest{
+		Reason: "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero?onConflict=wait", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleUp_QueuedOperationTimesOut(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	handler := NewDeploymentHandler(mockClient, WithLocker(locker), WithLockWaitTimeout(20*time.Millisecond))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "test-app"), false)
+	assert.NoError(t, err)
+	defer release()
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?onConflict=wait", body)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestScaleUp_InvalidOnConflict(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?onConflict=later", body)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "Invalid onConflict value", response.Message)
+}
+
+func TestScaleUp_DryRun(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	preview := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 2)
+	preview.NodePool = "projectb"
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("DryRunScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).Return(preview, nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 2,
+		Reason:   "Preview",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?dryRun=true", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	assert.Equal(t, "Dry run: deployment would be scaled to 2 replicas", response.Message)
+	assert.True(t, response.Deployment.DryRun)
+	assert.Equal(t, int32(0), response.Deployment.PreviousReplicas)
+	assert.Equal(t, int32(2), response.Deployment.TargetReplicas)
+	assert.Equal(t, "projectb", response.Deployment.NodePool)
+
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleToZero_DryRun(t *testing.T) {
+	// Every form strconv.ParseBool accepts is a dry run. Dry runs never reach
+	// ScaleDeployment, so they do not count against the per-deployment limits.
+	for _, value := range []string{"true", "1", "TRUE"} {
+		t.Run(value, func(t *testing.T) {
+			// Setup
+			mockClient := mocks.NewMockK8sClient()
+			handler := NewDeploymentHandler(mockClient)
+			router := helpers.SetupTestRouter()
+			router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+			// Mock expectations
+			status := mocks.MockDeploymentStatus("test-app", "test-ns", 3, 3)
+			mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+			mockClient.On("DryRunScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).
+				Return(mocks.MockDeploymentStatus("test-app", "test-ns", 3, 0), nil)
+
+			// Test
+			body := models.ScaleRequest{
+				Reason: "Preview",
+			}
+			w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero?dryRun="+value, body)
+
+			// Assert
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response models.ScaleResponse
+			helpers.ParseJSONResponse(t, w, &response)
+			assert.Equal(t, "Dry run: deployment would be scaled to zero", response.Message)
+			assert.True(t, response.Deployment.DryRun)
+			assert.Equal(t, int32(3), response.Deployment.PreviousReplicas)
+			assert.Equal(t, int32(0), response.Deployment.TargetReplicas)
+
+			mockClient.AssertExpectations(t)
+			mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestScaleToZero_InvalidDryRun(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Test
+	body := models.ScaleRequest{
+		Reason: "Preview",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero?dryRun=maybe", body)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestScaleUp_PolicyMaxReplicasExceeded(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationMaxReplicas: "2"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 50,
+		Reason:   "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Equal(t, []string{"requested 50 replicas exceeds maximum of 2 (deployment annotations)"}, response.Violations)
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleUp_PolicyInvalidAnnotation(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationMaxReplicas: "two"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 1, Reason: "Test"})
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "Scaling policy annotations of deployment test-ns/test-app are invalid", response.Message)
+	assert.Contains(t, response.Error, policy.AnnotationMaxReplicas)
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleToZero_PolicyProtected(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-to-zero", handler.ScaleToZero)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 1, 1)
+	status.Annotations = map[string]string{policy.AnnotationProtected: "true"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+
+	// Test
+	body := models.ScaleRequest{
+		Reason: "Test",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-to-zero", body)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Contains(t, response.Error, "protected")
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleUp_DryRunIncludesPolicyDecisions(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithPolicyEngine(policy.NewEngine(nil, policy.NewConfig())))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0)
+	status.Annotations = map[string]string{policy.AnnotationMaxReplicas: "2"}
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(status, nil)
+	mockClient.On("DryRunScaleDeployment", mock.Anything, "test-ns", "test-app", int32(1)).
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 0, 1), nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Preview",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up?dryRun=true", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, []string{"max replicas 2 (deployment annotations)"}, response.Deployment.PolicyDecisions)
+}
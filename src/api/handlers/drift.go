@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/drift"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// DriftHandler handles replica drift requests
+type DriftHandler struct {
+	detector *drift.Detector
+}
+
+// NewDriftHandler creates a new drift handler
+func NewDriftHandler(detector *drift.Detector) *DriftHandler {
+	return &DriftHandler{
+		detector: detector,
+	}
+}
+
+// ListDrift handles GET /api/v1/drift
+func (h *DriftHandler) ListDrift(c *gin.Context) {
+	if h.detector == nil {
+		c.JSON(http.StatusServiceUnavailable, models.DriftResponse{
+			Status:    models.StatusError,
+			Message:   "Drift detection not available",
+			Error:     "Kubernetes client not available",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	drifts, checkedAt := h.detector.List(c.Query("namespace"))
+
+	response := models.DriftResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Replica drift retrieved successfully",
+		Drifts:    make([]models.DeploymentDrift, 0, len(drifts)),
+		Timestamp: time.Now().UTC(),
+	}
+	if !checkedAt.IsZero() {
+		response.CheckedAt = &checkedAt
+	}
+	for _, d := range drifts {
+		response.Drifts = append(response.Drifts, models.DeploymentDrift{
+			Namespace:        d.Namespace,
+			Deployment:       d.Name,
+			RecordedReplicas: d.RecordedReplicas,
+			LiveReplicas:     d.LiveReplicas,
+			RecordedAt:       d.RecordedAt,
+			DetectedAt:       d.DetectedAt,
+			Mode:             d.Mode,
+			Corrected:        d.Corrected,
+			Error:            d.Error,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
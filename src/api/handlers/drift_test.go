@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/drift"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/metrics"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+)
+
+func TestListDrift_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	detector := drift.NewDetector(mockClient, lock.NewLocalLocker(), metrics.NewRegistry())
+	handler := NewDriftHandler(detector)
+	router := helpers.SetupTestRouter()
+	router.GET("/drift", handler.ListDrift)
+
+	drifted := mocks.MockDeploymentStatus("sample-app-a", "project-a", 2, 2)
+	drifted.Annotations = map[string]string{k8s.AnnotationRecordedReplicas: "0"}
+	other := mocks.MockDeploymentStatus("sample-app-b", "project-b", 1, 1)
+	other.Annotations = map[string]string{k8s.AnnotationRecordedReplicas: "3"}
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{drifted, other}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(drifted, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "sample-app-b").Return(other, nil)
+	mockClient.On("RecordDeploymentEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything, drift.ReasonDrift, mock.Anything).
+		Return(nil)
+	require.NoError(t, detector.Check(context.Background()))
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/drift?namespace=project-a", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DriftResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	assert.NotNil(t, response.CheckedAt)
+	require.Len(t, response.Drifts, 1)
+	assert.Equal(t, "sample-app-a", response.Drifts[0].Deployment)
+	assert.Equal(t, int32(0), response.Drifts[0].RecordedReplicas)
+	assert.Equal(t, int32(2), response.Drifts[0].LiveReplicas)
+	assert.Equal(t, drift.ModeReport, response.Drifts[0].Mode)
+	assert.False(t, response.Drifts[0].Corrected)
+}
+
+func TestListDrift_NoDetector(t *testing.T) {
+	// Setup
+	handler := NewDriftHandler(nil)
+	router := helpers.SetupTestRouter()
+	router.GET("/drift", handler.ListDrift)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/drift", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/group"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// GroupHandler handles scale group requests
+type GroupHandler struct {
+	k8sClient k8s.ClientInterface
+	groups    *group.Manager
+}
+
+// NewGroupHandler creates a new scale group handler
+func NewGroupHandler(k8sClient k8s.ClientInterface, groups *group.Manager) *GroupHandler {
+	return &GroupHandler{
+		k8sClient: k8sClient,
+		groups:    groups,
+	}
+}
+
+// ListGroups handles GET /api/v1/groups
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	names, err := h.groups.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.GroupListResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to list scale groups",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GroupListResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Scale groups retrieved successfully",
+		Groups:    names,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// GetGroup handles GET /api/v1/groups/{name}. Members are listed in scale-up
+// order with their current replicas.
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.available(c) {
+		return
+	}
+
+	g, err := h.groups.Get(c.Request.Context(), name)
+	if err != nil {
+		h.respondError(c, name, err)
+		return
+	}
+
+	info := &models.ScaleGroup{
+		Name:             name,
+		ReadinessTimeout: g.Timeout().String(),
+	}
+	for stage, members := range g.Stages() {
+		for _, member := range members {
+			m := models.GroupMember{
+				Namespace:    member.Namespace,
+				Name:         member.Name,
+				Stage:        stage,
+				Replicas:     member.Replicas,
+				MinAvailable: member.Available(),
+				DependsOn:    member.DependsOn,
+			}
+			status, err := h.k8sClient.GetDeploymentStatus(c.Request.Context(), member.Namespace, member.Name)
+			if err != nil {
+				m.Error = err.Error()
+			} else {
+				m.CurrentReplicas = &status.DesiredReplicas
+				m.AvailableReplicas = &status.AvailableReplicas
+			}
+			info.Members = append(info.Members, m)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.GroupResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Scale group retrieved successfully",
+		Group:     info,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// ScaleUp handles POST /api/v1/groups/{name}/scale-up
+func (h *GroupHandler) ScaleUp(c *gin.Context) {
+	h.start(c, group.DirectionScaleUp)
+}
+
+// ScaleToZero handles POST /api/v1/groups/{name}/scale-to-zero
+func (h *GroupHandler) ScaleToZero(c *gin.Context) {
+	h.start(c, group.DirectionScaleToZero)
+}
+
+// start begins a group operation and responds with 202 Accepted and the
+// operation to poll
+func (h *GroupHandler) start(c *gin.Context, direction string) {
+	name := c.Param("name")
+
+	if !h.available(c) {
+		return
+	}
+
+	var req models.GroupScaleRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.OperationResponse{
+				Status:    models.StatusError,
+				Message:   "Invalid request body",
+				Error:     err.Error(),
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+	}
+
+	op, err := h.groups.Start(c.Request.Context(), name, direction, req.Reason, middleware.Principal(c))
+	if err != nil {
+		h.respondError(c, name, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/operations/"+op.ID)
+	c.JSON(http.StatusAccepted, models.OperationResponse{
+		Status:    models.ResponseStatusPending,
+		Message:   fmt.Sprintf("Started %s of scale group %s", direction, name),
+		Operation: operationInfo(op),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// available responds with 503 when scale groups are not configured
+func (h *GroupHandler) available(c *gin.Context) bool {
+	if h.groups != nil {
+		return true
+	}
+	c.JSON(http.StatusServiceUnavailable, models.GroupResponse{
+		Status:    models.StatusError,
+		Message:   "Scale groups not available",
+		Error:     "Kubernetes client not available",
+		Timestamp: time.Now().UTC(),
+	})
+	return false
+}
+
+// respondError maps scale group errors to HTTP responses
+func (h *GroupHandler) respondError(c *gin.Context, name string, err error) {
+	statusCode := http.StatusInternalServerError
+	message := fmt.Sprintf("Failed to process scale group %s", name)
+	switch {
+	case errors.Is(err, group.ErrNotFound):
+		statusCode, message = http.StatusNotFound, fmt.Sprintf("Scale group %s not found", name)
+	case errors.Is(err, lock.ErrLocked):
+		statusCode, message = http.StatusConflict, fmt.Sprintf("Scale group %s is already being scaled", name)
+	}
+
+	c.JSON(statusCode, models.GroupResponse{
+		Status:    models.StatusError,
+		Message:   message,
+		Error:     err.Error(),
+		Timestamp: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/group"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// setupGroupRouter wires the scale group and operation routes with a group
+// "inference-b" of a model server and a frontend depending on it
+func setupGroupRouter(mockClient *mocks.MockK8sClient) *gin.Engine {
+	mockClient.On("GetClientset").Return(fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: group.DefaultConfigMapName, Namespace: group.DefaultConfigMapNamespace},
+		Data: map[string]string{"groups.yaml": `
+groups:
+  inference-b:
+    namespace: project-b
+    members:
+      - name: frontend
+        replicas: 2
+        dependsOn: [model-server]
+      - name: model-server
+        replicas: 1
+`},
+	}))
+
+	operations := operation.NewStore(operation.DefaultRetention)
+	config := group.NewConfig()
+	config.PollInterval = 10 * time.Millisecond
+	groups := group.NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()), nil, operations, config)
+
+	groupHandler := NewGroupHandler(mockClient, groups)
+	operationHandler := NewOperationHandler(operations)
+
+	router := helpers.SetupTestRouter()
+	router.GET("/groups", groupHandler.ListGroups)
+	router.GET("/groups/:name", groupHandler.GetGroup)
+	router.POST("/groups/:name/scale-up", groupHandler.ScaleUp)
+	router.GET("/operations/:id", operationHandler.GetOperation)
+	return router
+}
+
+func TestGetGroup(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupGroupRouter(mockClient)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "model-server").
+		Return(mocks.MockDeploymentStatus("model-server", "project-b", 1, 1), nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "frontend").
+		Return(mocks.MockDeploymentStatus("frontend", "project-b", 0, 0), nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/groups/inference-b", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.GroupResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Group)
+	require.Len(t, response.Group.Members, 2)
+	assert.Equal(t, "model-server", response.Group.Members[0].Name)
+	assert.Equal(t, 0, response.Group.Members[0].Stage)
+	assert.Equal(t, "frontend", response.Group.Members[1].Name)
+	assert.Equal(t, 1, response.Group.Members[1].Stage)
+	assert.Equal(t, int32(0), *response.Group.Members[1].CurrentReplicas)
+	assert.Equal(t, "5m0s", response.Group.ReadinessTimeout)
+}
+
+func TestGetGroup_NotFound(t *testing.T) {
+	// Setup
+	router := setupGroupRouter(mocks.NewMockK8sClient())
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/groups/missing", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGroupScaleUp_Accepted(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupGroupRouter(mockClient)
+
+	// Mock expectations
+	for _, name := range []string{"model-server", "frontend"} {
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).
+			Return(mocks.MockDeploymentStatus(name, "project-b", 0, 0), nil).Once()
+		mockClient.On("ScaleDeployment", mock.Anything, "project-b", name, mock.Anything).Return(nil)
+		mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", name).
+			Return(mocks.MockDeploymentStatus(name, "project-b", 2, 2), nil)
+	}
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/groups/inference-b/scale-up", models.GroupScaleRequest{Reason: "Demo"})
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var response models.OperationResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Operation)
+	assert.Equal(t, "/api/v1/operations/"+response.Operation.ID, w.Header().Get("Location"))
+	assert.Equal(t, "group-scale-up", response.Operation.Type)
+	assert.Equal(t, "Demo", response.Operation.Reason)
+
+	// The operation completes in the background
+	require.Eventually(t, func() bool {
+		w := helpers.MakeRequest(router, "GET", "/operations/"+response.Operation.ID, nil)
+		var polled models.OperationResponse
+		helpers.ParseJSONResponse(t, w, &polled)
+		return polled.Operation.Status == "succeeded"
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestGetOperation_NotFound(t *testing.T) {
+	// Setup
+	router := setupGroupRouter(mocks.NewMockK8sClient())
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/operations/missing", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -9,16 +9,36 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// DefaultReadinessNamespace is listed by the readiness check unless
+// WithReadinessNamespace is given
+const DefaultReadinessNamespace = "scale-system"
+
 // HealthHandler handles health check requests
 type HealthHandler struct {
-	k8sClient k8s.ClientInterface
+	k8sClient          k8s.ClientInterface
+	readinessNamespace string
+}
+
+// HealthHandlerOption configures a HealthHandler
+type HealthHandlerOption func(*HealthHandler)
+
+// WithReadinessNamespace sets the namespace listed by the readiness check
+func WithReadinessNamespace(namespace string) HealthHandlerOption {
+	return func(h *HealthHandler) {
+		h.readinessNamespace = namespace
+	}
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(k8sClient k8s.ClientInterface) *HealthHandler {
-	return &HealthHandler{
-		k8sClient: k8sClient,
+func NewHealthHandler(k8sClient k8s.ClientInterface, opts ...HealthHandlerOption) *HealthHandler {
+	h := &HealthHandler{
+		k8sClient:          k8sClient,
+		readinessNamespace: DefaultReadinessNamespace,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // Health handles GET /health - basic health check
@@ -52,9 +72,9 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 		return
 	}
 
-	// Try to list deployments in scale-system namespace as a connectivity check
+	// Try to list deployments in the readiness namespace as a connectivity check
 	// We use deployments because the service account has permissions for this resource
-	_, err := clientset.AppsV1().Deployments("scale-system").List(c.Request.Context(), metav1.ListOptions{Limit: 1})
+	_, err := clientset.AppsV1().Deployments(h.readinessNamespace).List(c.Request.Context(), metav1.ListOptions{Limit: 1})
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"status": "not ready",
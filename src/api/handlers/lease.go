@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// ExtendLease handles POST /api/v1/deployments/{namespace}/{name}/lease/extend
+func (h *DeploymentHandler) ExtendLease(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	var req models.LeaseExtendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	release, ok := h.acquireLock(c, namespace, name)
+	if !ok {
+		return
+	}
+	defer release()
+
+	current, ok := h.activeLease(c, namespace, name)
+	if !ok {
+		return
+	}
+
+	// Only the holder and admins may keep a deployment running longer
+	principal := middleware.Principal(c)
+	if principal != current.Holder && !isAdmin(h.configWatcher, principal) {
+		c.JSON(http.StatusForbidden, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   "Only the lease holder or an admin can extend the lease",
+			Error:     fmt.Sprintf("lease of deployment %s/%s is held by %s", namespace, name, current.Holder),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	expiresAt, err := lease.Extend(current.ExpiresAt, req.Duration, req.ExpiresAt, time.Now(), h.maxLeaseDuration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid lease extension",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	extended := lease.Lease{ExpiresAt: expiresAt, Holder: current.Holder}
+	if err := lease.Set(c.Request.Context(), h.k8sClient, namespace, name, extended); err != nil {
+		c.JSON(http.StatusInternalServerError, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   "Failed to extend lease",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LeaseResponse{
+		Status:    models.StatusSuccess,
+		Message:   fmt.Sprintf("Lease extended until %s", expiresAt.Format(time.RFC3339)),
+		Lease:     leaseInfo(namespace, name, &extended),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// ReleaseLease handles DELETE /api/v1/deployments/{namespace}/{name}/lease.
+// Releasing a lease ends it now: the deployment is scaled to zero.
+func (h *DeploymentHandler) ReleaseLease(c *gin.Context) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	if _, ok := h.activeLease(c, namespace, name); !ok {
+		return
+	}
+
+	h.scaleToZero(c, namespace, name, models.ScaleRequest{Reason: "Lease released"}, false, false)
+}
+
+// activeLease returns the lease of a deployment, responding with 404 if the
+// deployment does not exist or has no lease
+func (h *DeploymentHandler) activeLease(c *gin.Context, namespace, name string) (*lease.Lease, bool) {
+	status, err := h.k8sClient.GetDeploymentStatus(c.Request.Context(), namespace, name)
+	if err != nil {
+		statusCode, message := lookupError(namespace, name, err)
+		c.JSON(statusCode, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+
+	current, ok := lease.FromAnnotations(status.Annotations)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.LeaseResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Deployment %s/%s has no active lease", namespace, name),
+			Timestamp: time.Now().UTC(),
+		})
+		return nil, false
+	}
+	return current, true
+}
+
+// leaseInfo converts a lease to its API representation
+func leaseInfo(namespace, name string, l *lease.Lease) *models.LeaseInfo {
+	return &models.LeaseInfo{
+		Namespace:  namespace,
+		Deployment: name,
+		Holder:     l.Holder,
+		ExpiresAt:  l.ExpiresAt,
+	}
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+)
+
+// leasedDeployment returns a running deployment whose lease expires at expiresAt
+func leasedDeployment(expiresAt time.Time) *k8s.DeploymentStatus {
+	status := mocks.MockDeploymentStatus("test-app", "test-ns", 1, 1)
+	status.Labels = map[string]string{lease.LabelLeased: "true"}
+	status.Annotations = map[string]string{
+		lease.AnnotationExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		lease.AnnotationHolder:    "alice",
+	}
+	return status
+}
+
+// setsLease matches the annotations written by lease.Set
+func setsLease(annotations map[string]*string) bool {
+	return annotations[lease.AnnotationExpiresAt] != nil
+}
+
+// clearsLease matches the annotations written by lease.Clear
+func clearsLease(annotations map[string]*string) bool {
+	value, ok := annotations[lease.AnnotationExpiresAt]
+	return ok && value == nil
+}
+
+func TestScaleUp_WithLease(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app",
+		map[string]*string{lease.LabelLeased: ptrTo("true")}, mock.MatchedBy(setsLease)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(1)).Return(nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Experiment",
+		Duration: "2h",
+	}
+	before := time.Now()
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Deployment.LeaseExpiresAt)
+	assert.WithinDuration(t, before.Add(2*time.Hour), *response.Deployment.LeaseExpiresAt, 5*time.Second)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleUp_InvalidLease(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Experiment",
+		Duration: "forever",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockClient.AssertNotCalled(t, "GetDeploymentStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleUp_LeaseRemovedWhenScaleFails(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 0, 0), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(setsLease)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(1)).Return(errors.New("conflict"))
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(clearsLease)).Return(nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Experiment",
+		Duration: "1h",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleUp_WithoutLeaseEndsPreviousLease(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(leasedDeployment(time.Now().Add(time.Hour)), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app",
+		map[string]*string{lease.LabelLeased: nil}, mock.MatchedBy(clearsLease)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 2, Reason: "Keep running"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Nil(t, response.Deployment.LeaseExpiresAt)
+	mockClient.AssertExpectations(t)
+}
+
+func TestScaleUp_PreviousLeaseRestoredWhenScaleFails(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(leasedDeployment(time.Now().Add(time.Hour)), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(clearsLease)).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(2)).Return(errors.New("conflict"))
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(setsLease)).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/scale-up", models.ScaleUpRequest{Replicas: 2, Reason: "Keep running"})
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockClient.AssertExpectations(t)
+}
+
+// setupExtendLeaseRouter serves lease/extend with the caller taken from the
+// X-Principal header and bob listed in auth.admins
+func setupExtendLeaseRouter(t *testing.T, mockClient *mocks.MockK8sClient) *gin.Engine {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("auth:\n  apiKeys:\n    bob: bob-key\n  admins: [bob]\n"), 0o600))
+	watcher, err := config.NewWatcher(path)
+	require.NoError(t, err)
+
+	handler := NewDeploymentHandler(mockClient, WithConfigWatcher(watcher))
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(middleware.PrincipalKey, c.GetHeader("X-Principal"))
+	})
+	router.POST("/deployments/:namespace/:name/lease/extend", handler.ExtendLease)
+	return router
+}
+
+func TestExtendLease_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupExtendLeaseRouter(t, mockClient)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(leasedDeployment(expiresAt), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(setsLease)).Return(nil)
+
+	// Test
+	w := requestAs(router, "alice", "POST", "/deployments/test-ns/test-app/lease/extend", models.LeaseExtendRequest{Duration: "30m"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.LeaseResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.True(t, expiresAt.Add(30*time.Minute).Equal(response.Lease.ExpiresAt))
+	assert.Equal(t, "alice", response.Lease.Holder)
+	mockClient.AssertExpectations(t)
+}
+
+func TestExtendLease_ByAdmin(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupExtendLeaseRouter(t, mockClient)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(leasedDeployment(time.Now().Add(time.Hour)), nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(setsLease)).Return(nil)
+
+	// Test
+	w := requestAs(router, "bob", "POST", "/deployments/test-ns/test-app/lease/extend", models.LeaseExtendRequest{Duration: "30m"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.LeaseResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "alice", response.Lease.Holder)
+	mockClient.AssertExpectations(t)
+}
+
+func TestExtendLease_NotHolder(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupExtendLeaseRouter(t, mockClient)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(leasedDeployment(time.Now().Add(time.Hour)), nil)
+
+	// Test
+	w := requestAs(router, "carol", "POST", "/deployments/test-ns/test-app/lease/extend", models.LeaseExtendRequest{Duration: "30m"})
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExtendLease_NoLease(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/lease/extend", handler.ExtendLease)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").
+		Return(mocks.MockDeploymentStatus("test-app", "test-ns", 1, 1), nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/test-app/lease/extend", models.LeaseExtendRequest{Duration: "30m"})
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReleaseLease_ScalesToZero(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.DELETE("/deployments/:namespace/:name/lease", handler.ReleaseLease)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(leasedDeployment(time.Now().Add(time.Hour)), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "test-app", int32(0)).Return(nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "test-app", mock.Anything, mock.MatchedBy(clearsLease)).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "DELETE", "/deployments/test-ns/test-app/lease", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, "scaled-to-zero", response.Deployment.TargetStatus)
+	assert.Equal(t, "Lease released", response.Deployment.ScalingReason)
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetStatus_IncludesLease(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments/:namespace/:name/status", handler.GetStatus)
+
+	expiresAt := time.Date(2025, 7, 14, 11, 0, 0, 0, time.UTC)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "test-app").Return(leasedDeployment(expiresAt), nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments/test-ns/test-app/status", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.DeploymentStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Deployment.Lease)
+	assert.Equal(t, expiresAt, response.Deployment.Lease.ExpiresAt)
+	assert.Equal(t, "alice", response.Deployment.Lease.Holder)
+}
+
+func ptrTo(s string) *string {
+	return &s
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/utils"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Deployment lists return this many entries per page unless ?perPage is set
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// ListDeployments handles GET /api/v1/deployments and
+// GET /api/v1/namespaces/{namespace}/deployments. Deployments are sorted by
+// namespace and name and can be filtered by ?status, ?labelSelector and
+// ?nodePool before paging with ?page and ?perPage.
+func (h *DeploymentHandler) ListDeployments(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	status := c.Query("status")
+	switch status {
+	case "", models.StatusActive, models.StatusInactive, models.StatusScaling, models.StatusDraining:
+	default:
+		utils.BadRequest(c, "Invalid status value", fmt.Errorf("status must be %q, %q, %q or %q",
+			models.StatusActive, models.StatusInactive, models.StatusScaling, models.StatusDraining))
+		return
+	}
+
+	selector := c.Query("labelSelector")
+	if _, err := labels.Parse(selector); err != nil {
+		utils.BadRequest(c, "Invalid label selector", err)
+		return
+	}
+	nodePool := c.Query("nodePool")
+
+	page, err := queryInt(c, "page", 1, 1, 0)
+	if err != nil {
+		utils.BadRequest(c, "Invalid page value", err)
+		return
+	}
+	perPage, err := queryInt(c, "perPage", DefaultPerPage, 1, MaxPerPage)
+	if err != nil {
+		utils.BadRequest(c, "Invalid perPage value", err)
+		return
+	}
+
+	// Listing leaves out unmanaged deployments, so a namespace outside the
+	// managed scope is refused rather than listed as empty
+	if namespace != "" {
+		if err := h.k8sClient.CheckNamespace(namespace); err != nil {
+			utils.SendError(c, http.StatusForbidden, fmt.Sprintf("Namespace %s is not managed by the Scale API", namespace), err)
+			return
+		}
+	}
+
+	deployments, err := h.k8sClient.ListDeployments(c.Request.Context(), namespace, selector)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to list deployments", err)
+		return
+	}
+
+	items := make([]*models.DeploymentStatus, 0, len(deployments))
+	for _, d := range deployments {
+		if nodePool != "" && d.NodePool != nodePool {
+			continue
+		}
+		info := deploymentInfo(d)
+		if status != "" && info.Status != status {
+			continue
+		}
+		items = append(items, info)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	total := len(items)
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+	utils.SendPaginated(c, items[start:end], total, page, perPage)
+}
+
+// queryInt parses an integer query parameter, returning def when it is
+// absent. A max of 0 means no upper bound.
+func queryInt(c *gin.Context, key string, def, minValue, maxValue int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue || (maxValue > 0 && n > maxValue) {
+		if maxValue > 0 {
+			return 0, fmt.Errorf("%s must be between %d and %d", key, minValue, maxValue)
+		}
+		return 0, fmt.Errorf("%s must be at least %d", key, minValue)
+	}
+	return n, nil
+}
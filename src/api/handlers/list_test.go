@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/utils"
+)
+
+func setupListRouter(mockClient *mocks.MockK8sClient) *gin.Engine {
+	handler := NewDeploymentHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/deployments", handler.ListDeployments)
+	router.GET("/namespaces/:namespace/deployments", handler.ListDeployments)
+	return router
+}
+
+// parseDeploymentPage decodes a paginated deployment list response
+func parseDeploymentPage(t *testing.T, body []byte) (utils.PaginatedResponse, []models.DeploymentStatus) {
+	t.Helper()
+	var response struct {
+		utils.Response
+		Data struct {
+			utils.PaginatedResponse
+			Items []models.DeploymentStatus `json:"items"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &response))
+	require.True(t, response.Success)
+	return response.Data.PaginatedResponse, response.Data.Items
+}
+
+func TestListDeployments_FilterAndPage(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupListRouter(mockClient)
+
+	gpuApp := mocks.MockDeploymentStatus("app-c", "project-b", 1, 1)
+	gpuApp.NodePool = "gpupool"
+	scaling := mocks.MockDeploymentStatus("app-b", "project-b", 1, 3)
+	scaling.NodePool = "gpupool"
+	inactive := mocks.MockDeploymentStatus("app-a", "project-a", 0, 0)
+	inactive.NodePool = "gpupool"
+	other := mocks.MockDeploymentStatus("web", "project-a", 2, 2)
+	other.NodePool = "system"
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "tier=backend").
+		Return([]*k8s.DeploymentStatus{gpuApp, scaling, inactive, other}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments?labelSelector=tier%3Dbackend&nodePool=gpupool&perPage=2&page=1", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	page, items := parseDeploymentPage(t, w.Body.Bytes())
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, 2, page.TotalPages)
+	assert.Equal(t, 2, page.PerPage)
+	require.Len(t, items, 2)
+	assert.Equal(t, "project-a", items[0].Namespace)
+	assert.Equal(t, models.StatusInactive, items[0].Status)
+	assert.Equal(t, "app-b", items[1].Name)
+	assert.Equal(t, models.StatusScaling, items[1].Status)
+
+	// Last page
+	w = helpers.MakeRequest(router, "GET", "/deployments?labelSelector=tier%3Dbackend&nodePool=gpupool&perPage=2&page=2", nil)
+	_, items = parseDeploymentPage(t, w.Body.Bytes())
+	require.Len(t, items, 1)
+	assert.Equal(t, "app-c", items[0].Name)
+}
+
+func TestListDeployments_NamespaceStatusFilter(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupListRouter(mockClient)
+
+	// Mock expectations
+	mockClient.On("CheckNamespace", "project-b").Return(nil)
+	mockClient.On("ListDeployments", mock.Anything, "project-b", "").Return([]*k8s.DeploymentStatus{
+		mocks.MockDeploymentStatus("app-b", "project-b", 2, 2),
+		mocks.MockDeploymentStatus("app-c", "project-b", 0, 0),
+	}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/project-b/deployments?status=active", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	page, items := parseDeploymentPage(t, w.Body.Bytes())
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, DefaultPerPage, page.PerPage)
+	require.Len(t, items, 1)
+	assert.Equal(t, "app-b", items[0].Name)
+	mockClient.AssertExpectations(t)
+}
+
+func TestListDeployments_NamespaceNotManaged(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupListRouter(mockClient)
+
+	// Mock expectations
+	mockClient.On("CheckNamespace", "kube-system").Return(k8s.ErrNotManaged)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/kube-system/deployments", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockClient.AssertNotCalled(t, "ListDeployments", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListDeployments_DrainingFilter(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupListRouter(mockClient)
+
+	draining := mocks.MockDeploymentStatus("app-b", "project-b", 1, 1)
+	draining.Annotations = map[string]string{drain.AnnotationDrainingSince: "2025-06-02T18:00:00Z"}
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").Return([]*k8s.DeploymentStatus{
+		draining,
+		mocks.MockDeploymentStatus("app-c", "project-b", 1, 1),
+	}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments?status=draining", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, items := parseDeploymentPage(t, w.Body.Bytes())
+	require.Len(t, items, 1)
+	assert.Equal(t, "app-b", items[0].Name)
+	assert.Equal(t, models.StatusDraining, items[0].Status)
+}
+
+func TestListDeployments_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "unknown status", query: "status=running"},
+		{name: "invalid selector", query: "labelSelector=a%3D%3D%3Db"},
+		{name: "page zero", query: "page=0"},
+		{name: "perPage too large", query: "perPage=1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			router := setupListRouter(mocks.NewMockK8sClient())
+
+			// Test
+			w := helpers.MakeRequest(router, "GET", "/deployments?"+tt.query, nil)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestListDeployments_ListError(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupListRouter(mockClient)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").Return(nil, errors.New("forbidden"))
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/deployments", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/hibernate"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// NamespaceHandler handles namespace hibernation requests
+type NamespaceHandler struct {
+	hibernation *hibernate.Manager
+}
+
+// NewNamespaceHandler creates a new namespace handler
+func NewNamespaceHandler(hibernation *hibernate.Manager) *NamespaceHandler {
+	return &NamespaceHandler{
+		hibernation: hibernation,
+	}
+}
+
+// Hibernate handles POST /api/v1/namespaces/{namespace}/hibernate
+func (h *NamespaceHandler) Hibernate(c *gin.Context) {
+	h.run(c, "hibernated", h.hibernation.Hibernate)
+}
+
+// Wake handles POST /api/v1/namespaces/{namespace}/wake
+func (h *NamespaceHandler) Wake(c *gin.Context) {
+	h.run(c, "woken", h.hibernation.Wake)
+}
+
+// run executes a namespace-wide operation and writes the per-workload results.
+// As with bulk scaling, 207 Multi-Status is returned if any workload failed.
+func (h *NamespaceHandler) run(c *gin.Context, done string, operation func(context.Context, string, string) ([]hibernate.Result, error)) {
+	namespace := c.Param("namespace")
+
+	if !h.available(c) {
+		return
+	}
+
+	results, err := operation(c.Request.Context(), namespace, middleware.Principal(c))
+	if err != nil {
+		statusCode, message := http.StatusInternalServerError, fmt.Sprintf("Failed to list workloads of namespace %s", namespace)
+		switch {
+		case errors.Is(err, lock.ErrLocked):
+			statusCode, message = http.StatusConflict, fmt.Sprintf("Namespace %s is being hibernated or woken by another request", namespace)
+		case errors.Is(err, k8s.ErrNotManaged):
+			statusCode, message = http.StatusForbidden, fmt.Sprintf("Namespace %s is not managed by the Scale API", namespace)
+		}
+		c.JSON(statusCode, models.HibernationResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Namespace: namespace,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	succeeded, failed := 0, 0
+	workloads := make([]models.WorkloadResult, 0, len(results))
+	for _, r := range results {
+		result := models.WorkloadResult{
+			Kind:             r.Kind,
+			Name:             r.Name,
+			Status:           r.Status,
+			PreviousReplicas: r.PreviousReplicas,
+			TargetReplicas:   r.TargetReplicas,
+			Message:          r.Message,
+			Violations:       r.Violations,
+		}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+		}
+		if r.Operation != nil {
+			result.Operation = operationInfo(*r.Operation)
+		}
+		switch r.Status {
+		case hibernate.ResultSuccess:
+			succeeded++
+		case hibernate.ResultError:
+			failed++
+		}
+		workloads = append(workloads, result)
+	}
+
+	statusCode, status := http.StatusOK, models.StatusSuccess
+	if failed > 0 {
+		statusCode, status = http.StatusMultiStatus, models.ResponseStatusPartial
+		if succeeded == 0 {
+			status = models.StatusError
+		}
+	}
+
+	c.JSON(statusCode, models.HibernationResponse{
+		Status:    status,
+		Message:   fmt.Sprintf("Namespace %s %s: %d workloads changed, %d failed", namespace, done, succeeded, failed),
+		Namespace: namespace,
+		Results:   workloads,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// GetStatus handles GET /api/v1/namespaces/{namespace}/status
+func (h *NamespaceHandler) GetStatus(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	if !h.available(c) {
+		return
+	}
+
+	status, err := h.hibernation.Status(c.Request.Context(), namespace)
+	if err != nil {
+		statusCode, message := http.StatusInternalServerError, fmt.Sprintf("Failed to get status of namespace %s", namespace)
+		if errors.Is(err, k8s.ErrNotManaged) {
+			statusCode, message = http.StatusForbidden, fmt.Sprintf("Namespace %s is not managed by the Scale API", namespace)
+		}
+		c.JSON(statusCode, models.NamespaceStatusResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	workloads := make([]models.WorkloadState, 0, len(status.Workloads))
+	for _, w := range status.Workloads {
+		state := models.WorkloadState{
+			Kind:          w.Kind,
+			Name:          w.Name,
+			Replicas:      w.Replicas,
+			ReadyReplicas: w.ReadyReplicas,
+			Hibernated:    w.Hibernated,
+		}
+		if w.Hibernated {
+			restore := w.RestoreReplicas
+			state.RestoreReplicas = &restore
+			if !w.HibernatedAt.IsZero() {
+				hibernatedAt := w.HibernatedAt
+				state.HibernatedAt = &hibernatedAt
+			}
+		}
+		workloads = append(workloads, state)
+	}
+
+	c.JSON(http.StatusOK, models.NamespaceStatusResponse{
+		Status:  models.StatusSuccess,
+		Message: "Namespace status retrieved successfully",
+		Namespace: &models.NamespaceStatus{
+			Namespace: namespace,
+			State:     status.State,
+			Workloads: workloads,
+		},
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// available responds with 503 when hibernation is not configured
+func (h *NamespaceHandler) available(c *gin.Context) bool {
+	if h.hibernation != nil {
+		return true
+	}
+	c.JSON(http.StatusServiceUnavailable, models.HibernationResponse{
+		Status:    models.StatusError,
+		Message:   "Namespace hibernation not available",
+		Namespace: c.Param("namespace"),
+		Error:     "Kubernetes client not available",
+		Timestamp: time.Now().UTC(),
+	})
+	return false
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/hibernate"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+)
+
+func newTestNamespaceHandler(mockClient *mocks.MockK8sClient) *NamespaceHandler {
+	return NewNamespaceHandler(hibernate.NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()), nil))
+}
+
+func TestHibernateNamespace_PartialFailure(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestNamespaceHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/hibernate", handler.Hibernate)
+
+	// Mock expectations
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "web", Namespace: "project-b", DesiredReplicas: 2},
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", DesiredReplicas: 1},
+	}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "web").
+		Return(mocks.MockDeploymentStatus("web", "project-b", 2, 2), nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, mock.Anything, "project-b", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindDeployment, "project-b", "web", int32(0)).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindStatefulSet, "project-b", "db", int32(0)).Return(errors.New("forbidden"))
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/hibernate", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response models.HibernationResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.ResponseStatusPartial, response.Status)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "success", response.Results[0].Status)
+	assert.Equal(t, int32(2), response.Results[0].PreviousReplicas)
+	assert.Equal(t, "error", response.Results[1].Status)
+	assert.Equal(t, "forbidden", response.Results[1].Error)
+}
+
+func TestWakeNamespace_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestNamespaceHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/wake", handler.Wake)
+
+	// Mock expectations
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", Annotations: map[string]string{hibernate.AnnotationReplicas: "3"}},
+	}, nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindStatefulSet, "project-b", "db", int32(3)).Return(nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindStatefulSet, "project-b", "db", mock.Anything).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/wake", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.HibernationResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, int32(3), response.Results[0].TargetReplicas)
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetNamespaceStatus(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := newTestNamespaceHandler(mockClient)
+	router := helpers.SetupTestRouter()
+	router.GET("/namespaces/:namespace/status", handler.GetStatus)
+
+	// Mock expectations
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "web", Namespace: "project-b", Annotations: map[string]string{
+			hibernate.AnnotationReplicas:     "2",
+			hibernate.AnnotationHibernatedAt: "2025-07-14T18:00:00Z",
+		}},
+	}, nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/project-b/status", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.NamespaceStatusResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	require.NotNil(t, response.Namespace)
+	assert.Equal(t, hibernate.StateHibernated, response.Namespace.State)
+	require.Len(t, response.Namespace.Workloads, 1)
+	require.NotNil(t, response.Namespace.Workloads[0].RestoreReplicas)
+	assert.Equal(t, int32(2), *response.Namespace.Workloads[0].RestoreReplicas)
+	assert.NotNil(t, response.Namespace.Workloads[0].HibernatedAt)
+}
+
+func TestHibernateNamespace_Unavailable(t *testing.T) {
+	// Setup
+	handler := NewNamespaceHandler(nil)
+	router := helpers.SetupTestRouter()
+	router.POST("/namespaces/:namespace/hibernate", handler.Hibernate)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/namespaces/project-b/hibernate", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
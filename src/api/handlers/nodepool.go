@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/azure"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+)
+
+// NodePoolHandler handles AKS node pool requests
+type NodePoolHandler struct {
+	agentPools    azure.AgentPoolsClient
+	locker        lock.Locker
+	configWatcher *config.Watcher
+}
+
+// NewNodePoolHandler creates a new node pool handler. Scaling a node pool
+// affects every workload on it, so only the principals in auth.admins of the
+// watched configuration may do it.
+func NewNodePoolHandler(agentPools azure.AgentPoolsClient, locker lock.Locker, watcher *config.Watcher) *NodePoolHandler {
+	return &NodePoolHandler{
+		agentPools:    agentPools,
+		locker:        locker,
+		configWatcher: watcher,
+	}
+}
+
+// Scale handles POST /api/v1/nodepools/{name}/scale
+func (h *NodePoolHandler) Scale(c *gin.Context) {
+	name := c.Param("name")
+
+	if h.agentPools == nil {
+		c.JSON(http.StatusServiceUnavailable, models.NodePoolScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Node pool scaling not available",
+			Error:     "azure cluster is not configured",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	var req models.NodePoolScaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NodePoolScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Invalid request body",
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	principal := middleware.Principal(c)
+	if !isAdmin(h.configWatcher, principal) {
+		c.JSON(http.StatusForbidden, models.NodePoolScaleResponse{
+			Status:    models.StatusError,
+			Message:   "Scaling a node pool requires an admin",
+			Error:     principal + " is not listed in auth.admins",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// Concurrent updates of one pool would overwrite each other's counts
+	release, err := h.locker.Acquire(c.Request.Context(), lock.NodePoolKey(name), false)
+	if err != nil {
+		statusCode, message := http.StatusInternalServerError, fmt.Sprintf("Failed to lock node pool %s", name)
+		if errors.Is(err, lock.ErrLocked) {
+			statusCode, message = http.StatusConflict, fmt.Sprintf("Node pool %s is being scaled by another request", name)
+		}
+		c.JSON(statusCode, models.NodePoolScaleResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	defer release()
+
+	pool, err := h.agentPools.Scale(c.Request.Context(), name, azure.ScaleRequest{Count: req.Count, MinCount: req.MinCount})
+	if err != nil {
+		statusCode, message := nodePoolErrorStatus(err), fmt.Sprintf("Failed to scale node pool %s", name)
+		c.JSON(statusCode, models.NodePoolScaleResponse{
+			Status:    models.StatusError,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	log.Printf("Node pool %s scaled to count %d (min %s) by %s: %s",
+		name, pool.Count, formatCount(pool.MinCount), principal, req.Reason)
+
+	c.JSON(http.StatusOK, models.NodePoolScaleResponse{
+		Status:  models.StatusSuccess,
+		Message: fmt.Sprintf("Node pool %s is scaling", name),
+		NodePool: &models.NodePool{
+			Name:              pool.Name,
+			Mode:              pool.Mode,
+			VMSize:            pool.VMSize,
+			Count:             pool.Count,
+			MinCount:          pool.MinCount,
+			MaxCount:          pool.MaxCount,
+			AutoScaling:       pool.EnableAutoScaling,
+			ProvisioningState: pool.ProvisioningState,
+		},
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// nodePoolErrorStatus maps an agent pool error to an HTTP status. Errors
+// from Azure Resource Manager keep client errors and are otherwise reported
+// as a bad gateway.
+func nodePoolErrorStatus(err error) int {
+	var responseErr *azure.ResponseError
+	switch {
+	case errors.Is(err, azure.ErrInvalidScale):
+		return http.StatusBadRequest
+	case errors.Is(err, azure.ErrNodePoolNotAllowed):
+		return http.StatusForbidden
+	case errors.As(err, &responseErr):
+		switch responseErr.StatusCode {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests:
+			return responseErr.StatusCode
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// formatCount formats an optional node count
+func formatCount(count *int32) string {
+	if count == nil {
+		return "-"
+	}
+	return fmt.Sprint(*count)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/azure"
+	apiconfig "github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"k8s.io/utils/ptr"
+)
+
+func setupNodePoolRouter(t *testing.T, principal string) (*gin.Engine, *mocks.ARMServer, lock.Locker) {
+	arm := mocks.NewARMServer(map[string]map[string]any{
+		"projectb": {
+			"mode":              "User",
+			"vmSize":            "Standard_NC4as_T4_v3",
+			"count":             0,
+			"minCount":          0,
+			"maxCount":          5,
+			"enableAutoScaling": true,
+			"provisioningState": "Succeeded",
+		},
+	})
+	t.Cleanup(arm.Close)
+
+	config := arm.Config()
+	config.NodePools = []string{"projecta", "projectb"}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("auth:\n  apiKeys:\n    alice: alice-key\n  admins: [alice]\n"), 0o600))
+	watcher, err := apiconfig.NewWatcher(path)
+	require.NoError(t, err)
+
+	locker := lock.NewLocalLocker()
+	handler := NewNodePoolHandler(azure.NewClient(config, mocks.StaticToken("test-token")), locker, watcher)
+	router := helpers.SetupTestRouter()
+	router.Use(func(c *gin.Context) { c.Set(middleware.PrincipalKey, principal) })
+	router.POST("/nodepools/:name/scale", handler.Scale)
+	return router, arm, locker
+}
+
+func TestScaleNodePool_Success(t *testing.T) {
+	// Setup
+	router, arm, _ := setupNodePoolRouter(t, "alice")
+
+	// Test
+	body := models.NodePoolScaleRequest{MinCount: ptr.To[int32](1), Reason: "Warm GPU node for the demo"}
+	w := helpers.MakeRequest(router, "POST", "/nodepools/projectb/scale", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.NodePoolScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	require.NotNil(t, response.NodePool)
+	assert.Equal(t, int32(1), response.NodePool.Count)
+	assert.Equal(t, int32(1), *response.NodePool.MinCount)
+	assert.Equal(t, "Scaling", response.NodePool.ProvisioningState)
+
+	require.Len(t, arm.Updates["projectb"], 1)
+	assert.Equal(t, float64(1), arm.Pool("projectb")["minCount"])
+}
+
+func TestScaleNodePool_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		pool       string
+		body       any
+		wantStatus int
+	}{
+		{name: "missing reason", pool: "projectb", body: map[string]int{"count": 1}, wantStatus: http.StatusBadRequest},
+		{name: "no counts", pool: "projectb", body: models.NodePoolScaleRequest{Reason: "test"}, wantStatus: http.StatusBadRequest},
+		{name: "above max", pool: "projectb", body: models.NodePoolScaleRequest{Count: ptr.To[int32](9), Reason: "test"}, wantStatus: http.StatusBadRequest},
+		{name: "not allowed", pool: "system", body: models.NodePoolScaleRequest{Count: ptr.To[int32](1), Reason: "test"}, wantStatus: http.StatusForbidden},
+		{name: "not found", pool: "projecta", body: models.NodePoolScaleRequest{Count: ptr.To[int32](1), Reason: "test"}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, arm, _ := setupNodePoolRouter(t, "alice")
+
+			w := helpers.MakeRequest(router, "POST", "/nodepools/"+tt.pool+"/scale", tt.body)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Empty(t, arm.Updates)
+		})
+	}
+}
+
+func TestScaleNodePool_NotAdmin(t *testing.T) {
+	// Setup
+	router, arm, _ := setupNodePoolRouter(t, "bob")
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/nodepools/projectb/scale", models.NodePoolScaleRequest{Count: ptr.To[int32](1), Reason: "test"})
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "requires an admin")
+	assert.Empty(t, arm.Updates)
+}
+
+func TestScaleNodePool_Locked(t *testing.T) {
+	// Setup
+	router, arm, locker := setupNodePoolRouter(t, "alice")
+	release, err := locker.Acquire(context.Background(), lock.NodePoolKey("projectb"), false)
+	require.NoError(t, err)
+	defer release()
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/nodepools/projectb/scale", models.NodePoolScaleRequest{Count: ptr.To[int32](1), Reason: "test"})
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, arm.Updates)
+}
+
+func TestScaleNodePool_NotConfigured(t *testing.T) {
+	// Setup
+	handler := NewNodePoolHandler(nil, lock.NewLocalLocker(), nil)
+	router := helpers.SetupTestRouter()
+	router.POST("/nodepools/:name/scale", handler.Scale)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/nodepools/projectb/scale", models.NodePoolScaleRequest{Count: ptr.To[int32](1), Reason: "test"})
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
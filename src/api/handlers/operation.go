@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+)
+
+// OperationHandler handles background operation requests
+type OperationHandler struct {
+	operations *operation.Store
+}
+
+// NewOperationHandler creates a new operation handler
+func NewOperationHandler(operations *operation.Store) *OperationHandler {
+	return &OperationHandler{
+		operations: operations,
+	}
+}
+
+// GetOperation handles GET /api/v1/operations/{id}
+func (h *OperationHandler) GetOperation(c *gin.Context) {
+	op, ok := h.operations.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.OperationResponse{
+			Status:    models.StatusError,
+			Message:   "Operation not found",
+			Error:     "operations are kept in memory by the API replica that runs them",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OperationResponse{
+		Status:    models.StatusSuccess,
+		Message:   "Operation retrieved successfully",
+		Operation: operationInfo(op),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// operationInfo converts an operation to its API representation
+func operationInfo(op operation.Operation) *models.OperationInfo {
+	info := &models.OperationInfo{
+		ID:        op.ID,
+		Type:      op.Type,
+		Target:    op.Target,
+		Reason:    op.Reason,
+		StartedBy: op.StartedBy,
+		Status:    string(op.Status),
+		Steps:     make([]models.OperationStep, 0, len(op.Steps)),
+		Error:     op.Error,
+		StartedAt: op.StartedAt,
+	}
+	for _, step := range op.Steps {
+		info.Steps = append(info.Steps, models.OperationStep{
+			Stage:     step.Stage,
+			Namespace: step.Namespace,
+			Name:      step.Name,
+			Replicas:  step.Replicas,
+			Status:    string(step.Status),
+			Message:   step.Message,
+		})
+	}
+	if !op.FinishedAt.IsZero() {
+		finishedAt := op.FinishedAt
+		info.FinishedAt = &finishedAt
+	}
+	return info
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+)
+
+// QuotaHandler handles namespace quota requests
+type QuotaHandler struct {
+	k8sClient   k8s.ClientInterface
+	quotaEngine *quota.Engine
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(k8sClient k8s.ClientInterface, quotaEngine *quota.Engine) *QuotaHandler {
+	return &QuotaHandler{
+		k8sClient:   k8sClient,
+		quotaEngine: quotaEngine,
+	}
+}
+
+// GetQuota handles GET /api/v1/namespaces/{namespace}/quota
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	if h.quotaEngine == nil || h.k8sClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.QuotaResponse{
+			Status:    models.StatusError,
+			Message:   "Quota engine not available",
+			Error:     "Kubernetes client not available",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// The engine reads the namespace directly, so namespaces outside the
+	// managed scope are refused here
+	if err := h.k8sClient.CheckNamespace(namespace); err != nil {
+		c.JSON(http.StatusForbidden, models.QuotaResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Namespace %s is not managed by the Scale API", namespace),
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	usage, err := h.quotaEngine.Usage(c.Request.Context(), namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.QuotaResponse{
+			Status:    models.StatusError,
+			Message:   fmt.Sprintf("Failed to get quota usage for namespace %s", namespace),
+			Error:     err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	deployments := make([]models.DeploymentUsage, 0, len(usage.Deployments))
+	for _, d := range usage.Deployments {
+		deployments = append(deployments, models.DeploymentUsage{
+			Name:       d.Name,
+			Replicas:   d.Replicas,
+			GPUsPerPod: d.GPUsPerPod,
+			GPUs:       d.GPUs,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.QuotaResponse{
+		Status:  models.StatusSuccess,
+		Message: "Namespace quota retrieved successfully",
+		Quota: &models.NamespaceQuota{
+			Namespace:    namespace,
+			MaxReplicas:  usage.Quota.MaxReplicas,
+			MaxGPUs:      usage.Quota.MaxGPUs,
+			UsedReplicas: usage.UsedReplicas,
+			UsedGPUs:     usage.UsedGPUs,
+			Deployments:  deployments,
+		},
+		Timestamp: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/models"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+// newTestQuotaEngine returns a quota engine limiting test-ns to 2 GPUs, with
+// gpu-app (1 GPU per pod) running 1 replica and batch-app (2 GPUs per pod) idle
+func newTestQuotaEngine() *quota.Engine {
+	gpuDeployment := func(name string, replicas int32, gpus int64) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To(replicas),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: name,
+					Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						"nvidia.com/gpu": *resource.NewQuantity(gpus, resource.DecimalSI),
+					}},
+				}}}},
+			},
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: quota.DefaultConfigMapName, Namespace: quota.DefaultConfigMapNamespace},
+			Data:       map[string]string{"quotas.yaml": "namespaces:\n  test-ns:\n    maxGPUs: 2\n"},
+		},
+		gpuDeployment("gpu-app", 1, 1),
+		gpuDeployment("batch-app", 0, 2),
+	)
+	return quota.NewEngine(clientset, quota.NewConfig())
+}
+
+func TestGetQuota_Success(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewQuotaHandler(mockClient, newTestQuotaEngine())
+	router := helpers.SetupTestRouter()
+	router.GET("/namespaces/:namespace/quota", handler.GetQuota)
+
+	// Mock expectations
+	mockClient.On("CheckNamespace", "test-ns").Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/test-ns/quota", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.QuotaResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusSuccess, response.Status)
+	assert.Equal(t, "test-ns", response.Quota.Namespace)
+	assert.Equal(t, int64(2), *response.Quota.MaxGPUs)
+	assert.Nil(t, response.Quota.MaxReplicas)
+	assert.Equal(t, int32(1), response.Quota.UsedReplicas)
+	assert.Equal(t, int64(1), response.Quota.UsedGPUs)
+	assert.Equal(t, []models.DeploymentUsage{{Name: "gpu-app", Replicas: 1, GPUsPerPod: 1, GPUs: 1}}, response.Quota.Deployments)
+}
+
+func TestGetQuota_NotManaged(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewQuotaHandler(mockClient, newTestQuotaEngine())
+	router := helpers.SetupTestRouter()
+	router.GET("/namespaces/:namespace/quota", handler.GetQuota)
+
+	// Mock expectations
+	mockClient.On("CheckNamespace", "kube-system").Return(fmt.Errorf("%w: namespace kube-system is denied", k8s.ErrNotManaged))
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/kube-system/quota", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "used_replicas")
+}
+
+func TestGetQuota_NoEngine(t *testing.T) {
+	// Setup
+	handler := NewQuotaHandler(mocks.NewMockK8sClient(), nil)
+	router := helpers.SetupTestRouter()
+	router.GET("/namespaces/:namespace/quota", handler.GetQuota)
+
+	// Test
+	w := helpers.MakeRequest(router, "GET", "/namespaces/test-ns/quota", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestScaleUp_QuotaExceeded(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithQuotaEngine(newTestQuotaEngine()))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "batch-app").
+		Return(mocks.MockDeploymentStatus("batch-app", "test-ns", 0, 0), nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 1,
+		Reason:   "Nightly batch",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/batch-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.ScaleResponse
+	helpers.ParseJSONResponse(t, w, &response)
+	assert.Equal(t, models.StatusError, response.Status)
+	assert.Equal(t, []string{"namespace test-ns would use 3 GPUs, exceeding its quota of 2 (currently 1)"}, response.Violations)
+
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestScaleUp_WithinQuota(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	handler := NewDeploymentHandler(mockClient, WithQuotaEngine(newTestQuotaEngine()))
+	router := helpers.SetupTestRouter()
+	router.POST("/deployments/:namespace/:name/scale-up", handler.ScaleUp)
+
+	// Mock expectations
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "gpu-app").
+		Return(mocks.MockDeploymentStatus("gpu-app", "test-ns", 1, 1), nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "gpu-app", int32(2)).Return(nil)
+
+	// Test
+	body := models.ScaleUpRequest{
+		Replicas: 2,
+		Reason:   "Peak traffic",
+	}
+	w := helpers.MakeRequest(router, "POST", "/deployments/test-ns/gpu-app/scale-up", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/utils"
+	"github.com/torumakabe/aks-scale-to-zero/api/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// WebhookHandler handles admission reviews from the Kubernetes API server
+type WebhookHandler struct {
+	validator *webhook.Validator
+}
+
+// NewWebhookHandler creates a new admission webhook handler
+func NewWebhookHandler(validator *webhook.Validator) *WebhookHandler {
+	return &WebhookHandler{
+		validator: validator,
+	}
+}
+
+// ValidateDeployment handles POST /validate/deployments. The AdmissionReview
+// is answered with the same apiVersion and kind it was sent with.
+func (h *WebhookHandler) ValidateDeployment(c *gin.Context) {
+	var review admissionv1.AdmissionReview
+	if err := c.ShouldBindJSON(&review); err != nil {
+		utils.BadRequest(c, "Invalid admission review", err)
+		return
+	}
+	if review.Request == nil {
+		utils.BadRequest(c, "Invalid admission review", fmt.Errorf("request is required"))
+		return
+	}
+
+	review.Response = h.validator.Review(c.Request.Context(), review.Request)
+	review.Request = nil
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/helpers"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"github.com/torumakabe/aks-scale-to-zero/api/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func setupWebhookRouter(mockClient *mocks.MockK8sClient) *gin.Engine {
+	handler := NewWebhookHandler(webhook.NewValidator(mockClient, webhook.ModeEnforce, []string{webhook.DefaultServiceAccount}))
+	router := helpers.SetupTestRouter()
+	router.POST("/validate/deployments", handler.ValidateDeployment)
+	return router
+}
+
+func TestValidateDeployment_Denied(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	router := setupWebhookRouter(mockClient)
+
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:         "705ab4f5-6393-11e8-b7cc-42010a800002",
+			Namespace:   "project-a",
+			Name:        "sample-app-a",
+			SubResource: "scale",
+			Operation:   admissionv1.Update,
+			UserInfo:    authenticationv1.UserInfo{Username: "alice"},
+			OldObject:   runtime.RawExtension{Raw: []byte(`{"spec":{"replicas":0}}`)},
+			Object:      runtime.RawExtension{Raw: []byte(`{"spec":{"replicas":3}}`)},
+		},
+	}
+
+	// Mock expectations
+	mockClient.On("CheckDeployment", mock.Anything, "project-a", "sample-app-a", map[string]string(nil)).Return(nil)
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/validate/deployments", review)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response admissionv1.AdmissionReview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "admission.k8s.io/v1", response.APIVersion)
+	assert.Equal(t, "AdmissionReview", response.Kind)
+	assert.Nil(t, response.Request)
+	require.NotNil(t, response.Response)
+	assert.Equal(t, review.Request.UID, response.Response.UID)
+	assert.False(t, response.Response.Allowed)
+	assert.Equal(t, int32(http.StatusForbidden), response.Response.Result.Code)
+	assert.Contains(t, response.Response.Result.Message, "through the Scale API")
+}
+
+func TestValidateDeployment_InvalidReview(t *testing.T) {
+	// Setup
+	router := setupWebhookRouter(mocks.NewMockK8sClient())
+
+	// Test
+	w := helpers.MakeRequest(router, "POST", "/validate/deployments", map[string]string{"kind": "AdmissionReview"})
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
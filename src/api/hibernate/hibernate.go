@@ -0,0 +1,484 @@
+package hibernate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+)
+
+// Workload annotations recording a hibernation
+const (
+	// AnnotationReplicas is the replica count restored when the namespace wakes
+	AnnotationReplicas = "scale-to-zero.io/hibernated-replicas"
+	// AnnotationHibernatedAt is when the workload was hibernated (RFC 3339)
+	AnnotationHibernatedAt = "scale-to-zero.io/hibernated-at"
+)
+
+// Namespace hibernation states
+const (
+	StateActive              = "active"
+	StateHibernated          = "hibernated"
+	StatePartiallyHibernated = "partially-hibernated"
+)
+
+// Result statuses
+const (
+	ResultSuccess = "success"
+	ResultSkipped = "skipped"
+	ResultError   = "error"
+)
+
+// Result is the outcome of hibernating or waking one workload
+type Result struct {
+	Kind             string
+	Name             string
+	Status           string
+	PreviousReplicas int32
+	TargetReplicas   int32
+	Message          string
+	Violations       []string
+	Err              error
+	// Operation is the drain of a Deployment that declares one
+	Operation *operation.Operation
+}
+
+// WorkloadState describes a workload and what waking the namespace restores
+type WorkloadState struct {
+	Kind          string
+	Name          string
+	Replicas      int32
+	ReadyReplicas int32
+	Hibernated    bool
+	// RestoreReplicas and HibernatedAt are set for hibernated workloads
+	RestoreReplicas int32
+	HibernatedAt    time.Time
+}
+
+// Status is the hibernation state of a namespace
+type Status struct {
+	Namespace string
+	State     string
+	Workloads []WorkloadState
+}
+
+// Manager hibernates and wakes all Deployments, StatefulSets and CronJobs of a
+// namespace. CronJobs are suspended rather than scaled.
+type Manager struct {
+	k8sClient    k8s.ClientInterface
+	locker       lock.Locker
+	policyEngine *policy.Engine
+	quotaEngine  *quota.Engine
+	notifier     *notify.Notifier
+	drains       *drain.Manager
+	now          func() time.Time
+}
+
+// NewManager creates a new hibernation manager. Deployments are scaled under
+// their deployment lock. Every workload is checked against scaling policies,
+// and Deployments against namespace quotas when waking; quotaEngine may be nil.
+func NewManager(k8sClient k8s.ClientInterface, locker lock.Locker, policyEngine *policy.Engine, quotaEngine *quota.Engine) *Manager {
+	return &Manager{
+		k8sClient:    k8sClient,
+		locker:       locker,
+		policyEngine: policyEngine,
+		quotaEngine:  quotaEngine,
+		now:          time.Now,
+	}
+}
+
+// SetNotifier sets the notifier told when a workload was hibernated or woken,
+// or failed to be
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// SetDrainManager sets the drain manager used to drain Deployments that
+// declare a drain before they are scaled to zero
+func (m *Manager) SetDrainManager(drains *drain.Manager) {
+	m.drains = drains
+}
+
+// Hibernate scales every running workload in the namespace to zero, recording
+// its replica count so that Wake can restore it. Workloads already hibernated
+// or at zero are skipped, as are workloads a policy protects. Deployments that
+// declare a drain are drained in the background first. It returns
+// lock.ErrLocked if the namespace is being hibernated or woken by another request.
+func (m *Manager) Hibernate(ctx context.Context, namespace, principal string) ([]Result, error) {
+	release, err := m.locker.Acquire(ctx, lock.NamespaceKey(namespace), false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	workloads, err := m.k8sClient.ListWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(workloads))
+	for _, w := range workloads {
+		results = append(results, m.hibernate(ctx, w, principal))
+	}
+	return results, nil
+}
+
+// hibernate scales one workload to zero. The replica count is recorded before
+// scaling so it is not lost if the API stops in between.
+func (m *Manager) hibernate(ctx context.Context, w *k8s.Workload, principal string) Result {
+	result := Result{Kind: w.Kind, Name: w.Name, PreviousReplicas: w.DesiredReplicas}
+
+	if _, ok := w.Annotations[AnnotationReplicas]; ok {
+		return skipped(result, "already hibernated")
+	}
+
+	// A drain takes the deployment lock over until it is scaled to zero
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	var status *k8s.DeploymentStatus
+	leased := w.Labels[lease.LabelLeased] == "true"
+	if w.Kind == k8s.KindDeployment {
+		var err error
+		release, err = m.locker.Acquire(ctx, lock.Key(w.Namespace, w.Name), false)
+		if err != nil {
+			return failed(result, err)
+		}
+
+		// Re-read under the lock in case a scale operation just finished
+		status, err = m.k8sClient.GetDeploymentStatus(ctx, w.Namespace, w.Name)
+		if err != nil {
+			return failed(result, err)
+		}
+		result.PreviousReplicas = status.DesiredReplicas
+		leased = status.Labels[lease.LabelLeased] == "true"
+
+		if status.DesiredReplicas > 0 {
+			decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+				Namespace:   w.Namespace,
+				Name:        w.Name,
+				Annotations: status.Annotations,
+				Operation:   policy.OperationScaleToZero,
+			})
+			if err != nil {
+				return failed(result, err)
+			}
+			if !decision.Allowed {
+				result.Violations = decision.Violations
+				return skipped(result, "scaling policy does not allow scaling to zero")
+			}
+		}
+	} else if w.Kind == k8s.KindCronJob {
+		var err error
+		release, err = m.locker.Acquire(ctx, lock.CronJobKey(w.Namespace, w.Name), false)
+		if err != nil {
+			return failed(result, err)
+		}
+
+		if w.DesiredReplicas > 0 {
+			violations, err := m.checkWorkload(ctx, w, policy.OperationScaleToZero, 0)
+			if err != nil {
+				return failed(result, err)
+			}
+			if len(violations) > 0 {
+				result.Violations = violations
+				return skipped(result, "scaling policy does not allow suspending")
+			}
+		}
+	} else if w.DesiredReplicas > 0 {
+		violations, err := m.checkWorkload(ctx, w, policy.OperationScaleToZero, 0)
+		if err != nil {
+			return failed(result, err)
+		}
+		if len(violations) > 0 {
+			result.Violations = violations
+			return skipped(result, "scaling policy does not allow scaling to zero")
+		}
+	}
+
+	if result.PreviousReplicas == 0 {
+		return skipped(result, "already scaled to zero")
+	}
+
+	replicas := strconv.Itoa(int(result.PreviousReplicas))
+	hibernatedAt := m.now().UTC().Format(time.RFC3339)
+	err := m.k8sClient.PatchWorkloadAnnotations(ctx, w.Kind, w.Namespace, w.Name, map[string]*string{
+		AnnotationReplicas:     &replicas,
+		AnnotationHibernatedAt: &hibernatedAt,
+	})
+	if err != nil {
+		return failed(result, err)
+	}
+
+	var op *operation.Operation
+	if status != nil {
+		// The drain scales the deployment to zero and notifies it
+		op, err = m.drains.StartDeclared(ctx, status, "namespace hibernated", principal, release)
+		if err != nil {
+			m.clear(ctx, w)
+			return failed(result, err)
+		}
+	}
+	if op != nil {
+		release = nil
+	} else {
+		err = m.k8sClient.ScaleWorkload(ctx, w.Kind, w.Namespace, w.Name, 0)
+		m.notifier.NotifyScale(w.Namespace, w.Name, result.PreviousReplicas, 0, principal, "namespace hibernated", err)
+		if err != nil {
+			m.clear(ctx, w)
+			return failed(result, err)
+		}
+	}
+
+	// A hibernated deployment has nothing left for its scale-up lease to revert
+	if leased {
+		if err := lease.Clear(ctx, m.k8sClient, w.Namespace, w.Name); err != nil {
+			log.Printf("Failed to release lease of deployment %s/%s: %v", w.Namespace, w.Name, err)
+		}
+	}
+
+	result.Status = ResultSuccess
+	result.Message = fmt.Sprintf("%s scaled to zero, %d replicas recorded", w.Kind, result.PreviousReplicas)
+	if op != nil {
+		result.Operation = op
+		result.Message = fmt.Sprintf("%s draining before scaling to zero, %d replicas recorded", w.Kind, result.PreviousReplicas)
+	}
+	if w.Kind == k8s.KindCronJob {
+		result.Message = "CronJob suspended"
+	}
+	return result
+}
+
+// Wake restores every hibernated workload in the namespace to its recorded
+// replica count. Workloads are checked against scaling policies, and
+// Deployments against the namespace quota; a workload that cannot be restored
+// stays hibernated.
+// Approval thresholds do not apply, since the replicas were running before.
+func (m *Manager) Wake(ctx context.Context, namespace, principal string) ([]Result, error) {
+	release, err := m.locker.Acquire(ctx, lock.NamespaceKey(namespace), false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	workloads, err := m.k8sClient.ListWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(workloads))
+	for _, w := range workloads {
+		results = append(results, m.wake(ctx, w, principal))
+	}
+	return results, nil
+}
+
+// wake restores one workload
+func (m *Manager) wake(ctx context.Context, w *k8s.Workload, principal string) Result {
+	result := Result{Kind: w.Kind, Name: w.Name, PreviousReplicas: w.DesiredReplicas}
+
+	value, ok := w.Annotations[AnnotationReplicas]
+	if !ok {
+		return skipped(result, "not hibernated")
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 1 {
+		return failed(result, fmt.Errorf("invalid %s annotation %q", AnnotationReplicas, value))
+	}
+	result.TargetReplicas = int32(replicas)
+
+	if w.Kind == k8s.KindDeployment {
+		release, err := m.locker.Acquire(ctx, lock.Key(w.Namespace, w.Name), false)
+		if err != nil {
+			return failed(result, err)
+		}
+		defer release()
+
+		status, err := m.k8sClient.GetDeploymentStatus(ctx, w.Namespace, w.Name)
+		if err != nil {
+			return failed(result, err)
+		}
+		result.PreviousReplicas = status.DesiredReplicas
+		if status.DesiredReplicas == 0 {
+			if violations, err := m.checkScaleUp(ctx, status, result.TargetReplicas); err != nil {
+				return failed(result, err)
+			} else if len(violations) > 0 {
+				result.Violations = violations
+				return failed(result, fmt.Errorf("scale-up to %d replicas not allowed", result.TargetReplicas))
+			}
+		}
+	} else if w.Kind == k8s.KindCronJob {
+		release, err := m.locker.Acquire(ctx, lock.CronJobKey(w.Namespace, w.Name), false)
+		if err != nil {
+			return failed(result, err)
+		}
+		defer release()
+
+		if w.DesiredReplicas == 0 {
+			violations, err := m.checkWorkload(ctx, w, policy.OperationResume, 0)
+			if err != nil {
+				return failed(result, err)
+			}
+			if len(violations) > 0 {
+				result.Violations = violations
+				return failed(result, fmt.Errorf("resume not allowed"))
+			}
+		}
+	} else if w.DesiredReplicas == 0 {
+		violations, err := m.checkWorkload(ctx, w, policy.OperationScaleUp, result.TargetReplicas)
+		if err != nil {
+			return failed(result, err)
+		}
+		if len(violations) > 0 {
+			result.Violations = violations
+			return failed(result, fmt.Errorf("scale-up to %d replicas not allowed", result.TargetReplicas))
+		}
+	}
+
+	// Scaled up by hand since hibernation: just forget the recorded count
+	if result.PreviousReplicas > 0 {
+		if err := m.clear(ctx, w); err != nil {
+			return failed(result, err)
+		}
+		return skipped(result, "already running")
+	}
+
+	err = m.k8sClient.ScaleWorkload(ctx, w.Kind, w.Namespace, w.Name, result.TargetReplicas)
+	m.notifier.NotifyScale(w.Namespace, w.Name, result.PreviousReplicas, result.TargetReplicas, principal, "namespace woken", err)
+	if err != nil {
+		return failed(result, err)
+	}
+	if err := m.clear(ctx, w); err != nil {
+		return failed(result, fmt.Errorf("restored but failed to remove hibernation record: %w", err))
+	}
+
+	result.Status = ResultSuccess
+	result.Message = fmt.Sprintf("%s restored to %d replicas", w.Kind, result.TargetReplicas)
+	if w.Kind == k8s.KindCronJob {
+		result.Message = "CronJob resumed"
+	}
+	return result
+}
+
+// checkScaleUp returns the policy and quota violations of restoring a deployment
+func (m *Manager) checkScaleUp(ctx context.Context, status *k8s.DeploymentStatus, replicas int32) ([]string, error) {
+	decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+		Namespace:   status.Namespace,
+		Name:        status.Name,
+		Annotations: status.Annotations,
+		Operation:   policy.OperationScaleUp,
+		Replicas:    replicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Allowed {
+		return decision.Violations, nil
+	}
+
+	if m.quotaEngine == nil {
+		return nil, nil
+	}
+	quotaDecision, err := m.quotaEngine.Check(ctx, status.Namespace, status.Name, replicas)
+	if err != nil {
+		return nil, err
+	}
+	return quotaDecision.Violations, nil
+}
+
+// checkWorkload returns the policy violations of scaling a StatefulSet or
+// suspending or resuming a CronJob
+func (m *Manager) checkWorkload(ctx context.Context, w *k8s.Workload, op policy.Operation, replicas int32) ([]string, error) {
+	decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+		Namespace:   w.Namespace,
+		Name:        w.Name,
+		Annotations: w.Annotations,
+		Operation:   op,
+		Replicas:    replicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decision.Violations, nil
+}
+
+// Status reports which workloads of the namespace are hibernated and the
+// replicas waking it would restore
+func (m *Manager) Status(ctx context.Context, namespace string) (*Status, error) {
+	workloads, err := m.k8sClient.ListWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Namespace: namespace, Workloads: make([]WorkloadState, 0, len(workloads))}
+	hibernated, running := 0, 0
+	for _, w := range workloads {
+		state := WorkloadState{
+			Kind:          w.Kind,
+			Name:          w.Name,
+			Replicas:      w.DesiredReplicas,
+			ReadyReplicas: w.ReadyReplicas,
+		}
+		if value, ok := w.Annotations[AnnotationReplicas]; ok {
+			state.Hibernated = true
+			hibernated++
+			if replicas, err := strconv.ParseInt(value, 10, 32); err == nil {
+				state.RestoreReplicas = int32(replicas)
+			}
+			if at, err := time.Parse(time.RFC3339, w.Annotations[AnnotationHibernatedAt]); err == nil {
+				state.HibernatedAt = at
+			}
+		} else if w.DesiredReplicas > 0 {
+			running++
+		}
+		status.Workloads = append(status.Workloads, state)
+	}
+
+	switch {
+	case hibernated == 0:
+		status.State = StateActive
+	case running == 0:
+		status.State = StateHibernated
+	default:
+		status.State = StatePartiallyHibernated
+	}
+	return status, nil
+}
+
+// clear removes the hibernation record from a workload
+func (m *Manager) clear(ctx context.Context, w *k8s.Workload) error {
+	err := m.k8sClient.PatchWorkloadAnnotations(ctx, w.Kind, w.Namespace, w.Name, map[string]*string{
+		AnnotationReplicas:     nil,
+		AnnotationHibernatedAt: nil,
+	})
+	if err != nil {
+		log.Printf("Failed to remove hibernation record of %s %s/%s: %v", w.Kind, w.Namespace, w.Name, err)
+	}
+	return err
+}
+
+func skipped(result Result, message string) Result {
+	result.Status = ResultSkipped
+	result.Message = message
+	return result
+}
+
+func failed(result Result, err error) Result {
+	result.Status = ResultError
+	result.Err = err
+	return result
+}
@@ -0,0 +1,298 @@
+package hibernate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestManager(mockClient *mocks.MockK8sClient) *Manager {
+	m := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()), nil)
+	m.now = func() time.Time { return time.Date(2025, 7, 14, 18, 0, 0, 0, time.UTC) }
+	return m
+}
+
+// records matches an annotation patch recording the given replica count
+func records(replicas string) interface{} {
+	return mock.MatchedBy(func(annotations map[string]*string) bool {
+		value := annotations[AnnotationReplicas]
+		return value != nil && *value == replicas
+	})
+}
+
+// clears matches an annotation patch removing the hibernation record
+func clears() interface{} {
+	return mock.MatchedBy(func(annotations map[string]*string) bool {
+		value, ok := annotations[AnnotationReplicas]
+		return ok && value == nil
+	})
+}
+
+func TestHibernate(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	web := mocks.MockDeploymentStatus("web", "project-b", 2, 2)
+	protected := mocks.MockDeploymentStatus("gateway", "project-b", 1, 1)
+	protected.Annotations = map[string]string{policy.AnnotationProtected: "true"}
+
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "gateway", Namespace: "project-b", DesiredReplicas: 1, Annotations: protected.Annotations},
+		{Kind: k8s.KindDeployment, Name: "idle", Namespace: "project-b"},
+		{Kind: k8s.KindDeployment, Name: "web", Namespace: "project-b", DesiredReplicas: 2},
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", DesiredReplicas: 3},
+	}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "gateway").Return(protected, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "idle").Return(mocks.MockDeploymentStatus("idle", "project-b", 0, 0), nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "web").Return(web, nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindDeployment, "project-b", "web", records("2")).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindDeployment, "project-b", "web", int32(0)).Return(nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindStatefulSet, "project-b", "db", records("3")).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindStatefulSet, "project-b", "db", int32(0)).Return(nil)
+
+	results, err := newTestManager(mockClient).Hibernate(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, ResultSkipped, results[0].Status)
+	assert.NotEmpty(t, results[0].Violations)
+	assert.Equal(t, ResultSkipped, results[1].Status)
+	assert.Equal(t, ResultSuccess, results[2].Status)
+	assert.Equal(t, int32(2), results[2].PreviousReplicas)
+	assert.Equal(t, ResultSuccess, results[3].Status)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "ScaleWorkload", mock.Anything, mock.Anything, "project-b", "gateway", mock.Anything)
+}
+
+func TestHibernate_CronJobs(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	protected := map[string]string{policy.AnnotationProtected: "true"}
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindCronJob, Name: "nightly-eval", Namespace: "project-b", DesiredReplicas: 1},
+		{Kind: k8s.KindCronJob, Name: "report", Namespace: "project-b", DesiredReplicas: 1, Annotations: protected},
+	}, nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindCronJob, "project-b", "nightly-eval", records("1")).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindCronJob, "project-b", "nightly-eval", int32(0)).Return(nil)
+
+	results, err := newTestManager(mockClient).Hibernate(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ResultSuccess, results[0].Status)
+	assert.Equal(t, "CronJob suspended", results[0].Message)
+	assert.Equal(t, ResultSkipped, results[1].Status)
+	assert.NotEmpty(t, results[1].Violations)
+	mockClient.AssertExpectations(t)
+}
+
+func TestHibernate_StatefulSetProtected(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", DesiredReplicas: 3,
+			Annotations: map[string]string{policy.AnnotationProtected: "true"}},
+	}, nil)
+
+	results, err := newTestManager(mockClient).Hibernate(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ResultSkipped, results[0].Status)
+	assert.NotEmpty(t, results[0].Violations)
+	mockClient.AssertNotCalled(t, "PatchWorkloadAnnotations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "ScaleWorkload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHibernate_Drain(t *testing.T) {
+	// Setup: the deployment has no running pods, so it is idle right away
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "model-server", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "model-server"}}},
+	}))
+	operations := operation.NewStore(operation.DefaultRetention)
+	m := newTestManager(mockClient)
+	m.SetDrainManager(drain.NewManager(mockClient, operations, &drain.Config{PollInterval: time.Millisecond}))
+
+	status := mocks.MockDeploymentStatus("model-server", "project-b", 2, 2)
+	status.Annotations = map[string]string{drain.AnnotationDrain: `{"type":"metric","port":8002,"metric":"nv_inference_pending_request_count"}`}
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "model-server", Namespace: "project-b", DesiredReplicas: 2, Annotations: status.Annotations},
+	}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "model-server").Return(status, nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindDeployment, "project-b", "model-server", records("2")).Return(nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-b", "model-server", mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-b", "model-server", int32(0)).Return(nil)
+
+	results, err := m.Hibernate(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ResultSuccess, results[0].Status)
+	if assert.NotNil(t, results[0].Operation) {
+		assert.Equal(t, drain.OperationType, results[0].Operation.Type)
+		assert.Eventually(t, func() bool {
+			op, _ := operations.Get(results[0].Operation.ID)
+			return op.Status == operation.StatusSucceeded
+		}, 5*time.Second, time.Millisecond)
+	}
+	mockClient.AssertCalled(t, "ScaleDeployment", mock.Anything, "project-b", "model-server", int32(0))
+	mockClient.AssertNotCalled(t, "ScaleWorkload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHibernate_ScaleFailureRemovesRecord(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", DesiredReplicas: 3},
+	}, nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindStatefulSet, "project-b", "db", records("3")).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindStatefulSet, "project-b", "db", int32(0)).Return(errors.New("conflict"))
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindStatefulSet, "project-b", "db", clears()).Return(nil)
+
+	results, err := newTestManager(mockClient).Hibernate(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ResultError, results[0].Status)
+	assert.EqualError(t, results[0].Err, "conflict")
+	mockClient.AssertExpectations(t)
+}
+
+func TestHibernate_NamespaceLocked(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	m := newTestManager(mockClient)
+
+	release, err := m.locker.Acquire(context.Background(), lock.NamespaceKey("project-b"), false)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = m.Hibernate(context.Background(), "project-b", "alice")
+	assert.ErrorIs(t, err, lock.ErrLocked)
+}
+
+func TestWake(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	hibernated := map[string]string{AnnotationReplicas: "2", AnnotationHibernatedAt: "2025-07-14T18:00:00Z"}
+	web := mocks.MockDeploymentStatus("web", "project-b", 0, 0)
+	web.Annotations = hibernated
+	manual := mocks.MockDeploymentStatus("manual", "project-b", 1, 1)
+	manual.Annotations = hibernated
+
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "manual", Namespace: "project-b", DesiredReplicas: 1, Annotations: hibernated},
+		{Kind: k8s.KindDeployment, Name: "other", Namespace: "project-b", DesiredReplicas: 1},
+		{Kind: k8s.KindDeployment, Name: "web", Namespace: "project-b", Annotations: hibernated},
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b", Annotations: map[string]string{AnnotationReplicas: "3"}},
+	}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "manual").Return(manual, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "web").Return(web, nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindDeployment, "project-b", "manual", clears()).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindDeployment, "project-b", "web", int32(2)).Return(nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindDeployment, "project-b", "web", clears()).Return(nil)
+	mockClient.On("ScaleWorkload", mock.Anything, k8s.KindStatefulSet, "project-b", "db", int32(3)).Return(nil)
+	mockClient.On("PatchWorkloadAnnotations", mock.Anything, k8s.KindStatefulSet, "project-b", "db", clears()).Return(nil)
+
+	results, err := newTestManager(mockClient).Wake(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, ResultSkipped, results[0].Status)
+	assert.Equal(t, "already running", results[0].Message)
+	assert.Equal(t, ResultSkipped, results[1].Status)
+	assert.Equal(t, ResultSuccess, results[2].Status)
+	assert.Equal(t, int32(2), results[2].TargetReplicas)
+	assert.Equal(t, ResultSuccess, results[3].Status)
+	mockClient.AssertExpectations(t)
+}
+
+func TestWake_PolicyDenied(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	annotations := map[string]string{AnnotationReplicas: "5", policy.AnnotationMaxReplicas: "3"}
+	web := mocks.MockDeploymentStatus("web", "project-b", 0, 0)
+	web.Annotations = annotations
+
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindDeployment, Name: "web", Namespace: "project-b", Annotations: annotations},
+	}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-b", "web").Return(web, nil)
+
+	results, err := newTestManager(mockClient).Wake(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ResultError, results[0].Status)
+	assert.NotEmpty(t, results[0].Violations)
+	mockClient.AssertNotCalled(t, "ScaleWorkload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWake_StatefulSetPolicyDenied(t *testing.T) {
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("ListWorkloads", mock.Anything, "project-b").Return([]*k8s.Workload{
+		{Kind: k8s.KindStatefulSet, Name: "db", Namespace: "project-b",
+			Annotations: map[string]string{AnnotationReplicas: "5", policy.AnnotationMaxReplicas: "3"}},
+	}, nil)
+
+	results, err := newTestManager(mockClient).Wake(context.Background(), "project-b", "alice")
+
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ResultError, results[0].Status)
+	assert.NotEmpty(t, results[0].Violations)
+	mockClient.AssertNotCalled(t, "ScaleWorkload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		workloads []*k8s.Workload
+		want      string
+	}{
+		{
+			name: "active",
+			workloads: []*k8s.Workload{
+				{Kind: k8s.KindDeployment, Name: "web", DesiredReplicas: 2},
+			},
+			want: StateActive,
+		},
+		{
+			name: "hibernated",
+			workloads: []*k8s.Workload{
+				{Kind: k8s.KindDeployment, Name: "idle"},
+				{Kind: k8s.KindDeployment, Name: "web", Annotations: map[string]string{AnnotationReplicas: "2"}},
+			},
+			want: StateHibernated,
+		},
+		{
+			name: "partially hibernated",
+			workloads: []*k8s.Workload{
+				{Kind: k8s.KindDeployment, Name: "gateway", DesiredReplicas: 1},
+				{Kind: k8s.KindDeployment, Name: "web", Annotations: map[string]string{AnnotationReplicas: "2"}},
+			},
+			want: StatePartiallyHibernated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := mocks.NewMockK8sClient()
+			mockClient.On("ListWorkloads", mock.Anything, "project-b").Return(tt.workloads, nil)
+
+			status, err := newTestManager(mockClient).Status(context.Background(), "project-b")
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, status.State)
+			assert.Len(t, status.Workloads, len(tt.workloads))
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Autoscaler kinds that can be attached to a deployment
+const (
+	KindHorizontalPodAutoscaler = "HorizontalPodAutoscaler"
+	KindScaledObject            = "ScaledObject"
+)
+
+// Annotations used to pause autoscalers while a deployment is scaled to zero
+const (
+	// AnnotationPausedHPAs stashes, as JSON, the HorizontalPodAutoscalers
+	// removed from a deployment when it was scaled to zero. They are recreated
+	// when the deployment is scaled up.
+	AnnotationPausedHPAs = "scale-to-zero.io/paused-hpas"
+	// AnnotationKEDAPausedReplicas makes KEDA hold a ScaledObject's target at
+	// the given replica count
+	AnnotationKEDAPausedReplicas = "autoscaling.keda.sh/paused-replicas"
+	// AnnotationPausedBy marks ScaledObjects paused by the client, so pauses
+	// set by anyone else are left in place
+	AnnotationPausedBy = "scale-to-zero.io/paused-by"
+)
+
+// KEDA defaults for ScaledObjects without explicit replica bounds
+const (
+	kedaDefaultMinReplicas = 0
+	kedaDefaultMaxReplicas = 100
+)
+
+// ScaledObjectResource identifies KEDA ScaledObjects for the dynamic client
+var ScaledObjectResource = schema.GroupVersionResource{Group: "keda.sh", Version: "v1alpha1", Resource: "scaledobjects"}
+
+// Autoscaler is an HPA or KEDA ScaledObject targeting a deployment
+type Autoscaler struct {
+	Kind        string
+	Name        string
+	MinReplicas int32
+	MaxReplicas int32
+	// Paused is set while the autoscaler is paused or, for HPAs, stashed
+	Paused bool
+}
+
+// stashedHPA is the part of a HorizontalPodAutoscaler kept in
+// AnnotationPausedHPAs
+type stashedHPA struct {
+	Name        string                                    `json:"name"`
+	Labels      map[string]string                         `json:"labels,omitempty"`
+	Annotations map[string]string                         `json:"annotations,omitempty"`
+	Spec        autoscalingv2.HorizontalPodAutoscalerSpec `json:"spec"`
+}
+
+// pauseAutoscalers stops the deployment's autoscalers from undoing a scale to
+// zero. ScaledObjects are paused at zero replicas. HPAs cannot go below one
+// replica, so they are stashed in the deployment's annotations, which the
+// caller must persist before deleting the returned HPAs. undo resumes the
+// ScaledObjects if the deployment could not be updated.
+func (c *Client) pauseAutoscalers(ctx context.Context, deployment *appsv1.Deployment) (hpaNames []string, undo func(context.Context) error, err error) {
+	paused, err := c.setScaledObjectsPaused(ctx, deployment.Namespace, deployment.Name, true)
+	undo = func(ctx context.Context) error {
+		return c.patchScaledObjects(ctx, deployment.Namespace, paused, false)
+	}
+	defer func() {
+		if err != nil {
+			err = withUndo(ctx, err, undo)
+		}
+	}()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hpas, err := c.attachedHPAs(ctx, deployment.Namespace, deployment.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(hpas) == 0 {
+		return nil, undo, nil
+	}
+
+	stash, err := pausedHPAs(deployment.Annotations)
+	if err != nil {
+		return nil, nil, err
+	}
+	hpaNames = make([]string, 0, len(hpas))
+	for _, hpa := range hpas {
+		stash = append(stash, stashedHPA{
+			Name:        hpa.Name,
+			Labels:      hpa.Labels,
+			Annotations: hpa.Annotations,
+			Spec:        hpa.Spec,
+		})
+		hpaNames = append(hpaNames, hpa.Name)
+	}
+	value, err := json.Marshal(stash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode paused HPAs: %w", err)
+	}
+	deployment.Annotations[AnnotationPausedHPAs] = string(value)
+	return hpaNames, undo, nil
+}
+
+// deleteHPAs deletes HPAs stashed by pauseAutoscalers
+func (c *Client) deleteHPAs(ctx context.Context, namespace string, names []string) error {
+	for _, name := range names {
+		err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete HPA %s/%s: %w", namespace, name, err)
+		}
+	}
+	return nil
+}
+
+// resumeAutoscalers undoes pauseAutoscalers before a scale-up. Stashed HPAs
+// are recreated and removed from the deployment's annotations, which the
+// caller must persist. undo pauses the ScaledObjects and deletes the
+// recreated HPAs again if the deployment could not be updated; the stash is
+// still in the stored deployment then.
+func (c *Client) resumeAutoscalers(ctx context.Context, deployment *appsv1.Deployment) (undo func(context.Context) error, err error) {
+	namespace := deployment.Namespace
+	resumed, err := c.setScaledObjectsPaused(ctx, namespace, deployment.Name, false)
+	var created []string
+	undo = func(ctx context.Context) error {
+		return errors.Join(
+			c.patchScaledObjects(ctx, namespace, resumed, true),
+			c.deleteHPAs(ctx, namespace, created),
+		)
+	}
+	defer func() {
+		if err != nil {
+			err = withUndo(ctx, err, undo)
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	stash, err := pausedHPAs(deployment.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stash {
+		hpa := &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        s.Name,
+				Namespace:   namespace,
+				Labels:      s.Labels,
+				Annotations: s.Annotations,
+			},
+			Spec: s.Spec,
+		}
+		_, err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Create(ctx, hpa, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore HPA %s/%s: %w", namespace, s.Name, err)
+		}
+		created = append(created, s.Name)
+	}
+	delete(deployment.Annotations, AnnotationPausedHPAs)
+	return undo, nil
+}
+
+// withUndo rolls back autoscaler changes after err and reports a failed
+// rollback along with err. The rollback runs even if ctx was cancelled.
+func withUndo(ctx context.Context, err error, undo func(context.Context) error) error {
+	if undoErr := undo(context.WithoutCancel(ctx)); undoErr != nil {
+		return fmt.Errorf("%w (failed to restore autoscalers: %v)", err, undoErr)
+	}
+	return err
+}
+
+// setScaledObjectsPaused pauses the deployment's ScaledObjects at zero
+// replicas, or resumes those the client paused. It returns the ScaledObjects
+// changed, also when it fails part way.
+func (c *Client) setScaledObjectsPaused(ctx context.Context, namespace, name string, paused bool) ([]string, error) {
+	scaledObjects, err := c.attachedScaledObjects(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, so := range scaledObjects {
+		annotations := so.GetAnnotations()
+		switch {
+		case paused && annotations[AnnotationKEDAPausedReplicas] == "":
+		case !paused && annotations[AnnotationPausedBy] != "":
+		default:
+			continue
+		}
+		if err := c.patchScaledObjects(ctx, namespace, []string{so.GetName()}, paused); err != nil {
+			return changed, err
+		}
+		changed = append(changed, so.GetName())
+	}
+	return changed, nil
+}
+
+// patchScaledObjects pauses ScaledObjects at zero replicas on behalf of the
+// client, or removes such pauses
+func (c *Client) patchScaledObjects(ctx context.Context, namespace string, names []string, paused bool) error {
+	patch := map[string]*string{AnnotationKEDAPausedReplicas: nil, AnnotationPausedBy: nil}
+	if paused {
+		zero, by := "0", eventSource
+		patch = map[string]*string{AnnotationKEDAPausedReplicas: &zero, AnnotationPausedBy: &by}
+	}
+	data, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": patch}})
+	if err != nil {
+		return fmt.Errorf("failed to encode ScaledObject patch: %w", err)
+	}
+
+	for _, name := range names {
+		_, err = c.dynamicClient.Resource(ScaledObjectResource).Namespace(namespace).Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to patch ScaledObject %s/%s: %w", namespace, name, err)
+		}
+	}
+	return nil
+}
+
+// attachedHPAs lists the HPAs targeting a deployment. HPAs that KEDA manages
+// for a ScaledObject are left to KEDA.
+func (c *Client) attachedHPAs(ctx context.Context, namespace, name string) ([]autoscalingv2.HorizontalPodAutoscaler, error) {
+	list, err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list HPAs: %w", err)
+	}
+
+	var hpas []autoscalingv2.HorizontalPodAutoscaler
+	for _, hpa := range list.Items {
+		if hpa.Spec.ScaleTargetRef.Kind != KindDeployment || hpa.Spec.ScaleTargetRef.Name != name {
+			continue
+		}
+		if ownedByScaledObject(hpa.OwnerReferences) {
+			continue
+		}
+		hpas = append(hpas, hpa)
+	}
+	return hpas, nil
+}
+
+// attachedScaledObjects lists the KEDA ScaledObjects targeting a deployment.
+// It returns nil when KEDA is not installed.
+func (c *Client) attachedScaledObjects(ctx context.Context, namespace, name string) ([]unstructured.Unstructured, error) {
+	if c.dynamicClient == nil {
+		return nil, nil
+	}
+	list, err := c.dynamicClient.Resource(ScaledObjectResource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ScaledObjects: %w", err)
+	}
+
+	var scaledObjects []unstructured.Unstructured
+	for _, so := range list.Items {
+		kind, _, _ := unstructured.NestedString(so.Object, "spec", "scaleTargetRef", "kind")
+		target, _, _ := unstructured.NestedString(so.Object, "spec", "scaleTargetRef", "name")
+		// The target kind defaults to Deployment
+		if (kind == "" || kind == KindDeployment) && target == name {
+			scaledObjects = append(scaledObjects, so)
+		}
+	}
+	return scaledObjects, nil
+}
+
+// autoscalers reports the autoscalers attached to a deployment, including
+// HPAs stashed while it is scaled to zero
+func (c *Client) autoscalers(ctx context.Context, deployment *appsv1.Deployment) ([]Autoscaler, error) {
+	var autoscalers []Autoscaler
+
+	hpas, err := c.attachedHPAs(ctx, deployment.Namespace, deployment.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, hpa := range hpas {
+		autoscalers = append(autoscalers, hpaAutoscaler(hpa.Name, hpa.Spec, false))
+	}
+	stash, err := pausedHPAs(deployment.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stash {
+		autoscalers = append(autoscalers, hpaAutoscaler(s.Name, s.Spec, true))
+	}
+
+	scaledObjects, err := c.attachedScaledObjects(ctx, deployment.Namespace, deployment.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, so := range scaledObjects {
+		a := Autoscaler{
+			Kind:        KindScaledObject,
+			Name:        so.GetName(),
+			MinReplicas: kedaDefaultMinReplicas,
+			MaxReplicas: kedaDefaultMaxReplicas,
+			Paused:      so.GetAnnotations()[AnnotationKEDAPausedReplicas] != "",
+		}
+		if v, ok, _ := unstructured.NestedInt64(so.Object, "spec", "minReplicaCount"); ok {
+			a.MinReplicas = int32(v)
+		}
+		if v, ok, _ := unstructured.NestedInt64(so.Object, "spec", "maxReplicaCount"); ok {
+			a.MaxReplicas = int32(v)
+		}
+		autoscalers = append(autoscalers, a)
+	}
+
+	sort.SliceStable(autoscalers, func(i, j int) bool {
+		if autoscalers[i].Kind != autoscalers[j].Kind {
+			return autoscalers[i].Kind < autoscalers[j].Kind
+		}
+		return autoscalers[i].Name < autoscalers[j].Name
+	})
+	return autoscalers, nil
+}
+
+// hpaAutoscaler converts an HPA spec into an Autoscaler
+func hpaAutoscaler(name string, spec autoscalingv2.HorizontalPodAutoscalerSpec, paused bool) Autoscaler {
+	minReplicas := int32(1)
+	if spec.MinReplicas != nil {
+		minReplicas = *spec.MinReplicas
+	}
+	return Autoscaler{
+		Kind:        KindHorizontalPodAutoscaler,
+		Name:        name,
+		MinReplicas: minReplicas,
+		MaxReplicas: spec.MaxReplicas,
+		Paused:      paused,
+	}
+}
+
+// pausedHPAs parses AnnotationPausedHPAs
+func pausedHPAs(annotations map[string]string) ([]stashedHPA, error) {
+	value := annotations[AnnotationPausedHPAs]
+	if value == "" {
+		return nil, nil
+	}
+	var stash []stashedHPA
+	if err := json.Unmarshal([]byte(value), &stash); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationPausedHPAs, err)
+	}
+	return stash, nil
+}
+
+// ownedByScaledObject reports whether an HPA was created by KEDA
+func ownedByScaledObject(owners []metav1.OwnerReference) bool {
+	for _, owner := range owners {
+		if owner.Kind == KindScaledObject {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Batch workload kinds
+const (
+	KindCronJob = "CronJob"
+	KindJob     = "Job"
+)
+
+// CronJob is a managed CronJob
+type CronJob struct {
+	Name             string
+	Namespace        string
+	Schedule         string
+	Suspended        bool
+	ActiveJobs       int32
+	LastScheduleTime *time.Time
+	Labels           map[string]string
+	Annotations      map[string]string
+}
+
+// Job is an unfinished Job and the node pool its pods run on
+type Job struct {
+	Name      string
+	Namespace string
+	// CronJob is the CronJob that created the Job, if any
+	CronJob     string
+	NodePool    string
+	ActivePods  int32
+	StartTime   *time.Time
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// GetCronJob retrieves a managed CronJob
+func (c *Client) GetCronJob(ctx context.Context, namespace, name string) (*CronJob, error) {
+	cronJob, err := c.getCronJob(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return cronJobFrom(cronJob), nil
+}
+
+// SuspendCronJob suspends or resumes a CronJob. Suspending stops new Jobs from
+// being created; Jobs already running are left alone.
+func (c *Client) SuspendCronJob(ctx context.Context, namespace, name string, suspend bool) error {
+	cronJob, err := c.getCronJob(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	cronJob.Spec.Suspend = &suspend
+	_, err = c.clientset.BatchV1().CronJobs(namespace).Update(ctx, cronJob, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update cronjob %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// ListJobs lists the managed Jobs that have not finished, sorted by namespace
+// and name. An empty namespace lists Jobs in all namespaces and an empty
+// nodePool lists Jobs on any node pool.
+func (c *Client) ListJobs(ctx context.Context, namespace, nodePool string) ([]*Job, error) {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	list, err := c.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var jobs []*Job
+	for i := range list.Items {
+		job := &list.Items[i]
+		if jobFinished(job) || !c.scope.Allows(job.Namespace, job.Labels) {
+			continue
+		}
+		pool := c.jobNodePool(ctx, job)
+		if nodePool != "" && pool != nodePool {
+			continue
+		}
+
+		j := &Job{
+			Name:        job.Name,
+			Namespace:   job.Namespace,
+			NodePool:    pool,
+			ActivePods:  job.Status.Active,
+			Labels:      job.Labels,
+			Annotations: job.Annotations,
+		}
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == KindCronJob {
+				j.CronJob = owner.Name
+			}
+		}
+		if job.Status.StartTime != nil {
+			startTime := job.Status.StartTime.Time
+			j.StartTime = &startTime
+		}
+		jobs = append(jobs, j)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Namespace != jobs[j].Namespace {
+			return jobs[i].Namespace < jobs[j].Namespace
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs, nil
+}
+
+// DeleteJob terminates a managed Job by deleting it together with its pods
+func (c *Client) DeleteJob(ctx context.Context, namespace, name string) error {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return err
+	}
+
+	job, err := c.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get job %s/%s: %w", namespace, name, err)
+	}
+	if err := c.scope.check(KindJob, namespace, name, job.Labels); err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err = c.clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return fmt.Errorf("failed to delete job %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// getCronJob retrieves a cronjob, returning ErrNotManaged when it is outside
+// the client's scope
+func (c *Client) getCronJob(ctx context.Context, namespace, name string) (*batchv1.CronJob, error) {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	cronJob, err := c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cronjob %s/%s: %w", namespace, name, err)
+	}
+
+	if err := c.scope.check(KindCronJob, namespace, name, cronJob.Labels); err != nil {
+		return nil, err
+	}
+	return cronJob, nil
+}
+
+// jobNodePool resolves the node pool of a Job from its pod template and, when
+// the template does not pin one, from the nodes its pods were scheduled to
+func (c *Client) jobNodePool(ctx context.Context, job *batchv1.Job) string {
+	if pool := c.resolveNodePool(ctx, job.Annotations, &job.Spec.Template.Spec); pool != "" {
+		return pool
+	}
+	if job.Spec.Selector == nil {
+		return ""
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return ""
+	}
+	pods, err := c.clientset.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return ""
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		node, err := c.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err == nil && node.Labels[AgentPoolLabel] != "" {
+			return node.Labels[AgentPoolLabel]
+		}
+	}
+	return ""
+}
+
+// cronJobFrom converts a CronJob into its client representation
+func cronJobFrom(cronJob *batchv1.CronJob) *CronJob {
+	cj := &CronJob{
+		Name:        cronJob.Name,
+		Namespace:   cronJob.Namespace,
+		Schedule:    cronJob.Spec.Schedule,
+		Suspended:   cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend,
+		ActiveJobs:  int32(len(cronJob.Status.Active)),
+		Labels:      cronJob.Labels,
+		Annotations: cronJob.Annotations,
+	}
+	if cronJob.Status.LastScheduleTime != nil {
+		last := cronJob.Status.LastScheduleTime.Time
+		cj.LastScheduleTime = &last
+	}
+	return cj
+}
+
+// jobFinished reports whether a Job has completed or failed
+func jobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,19 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -19,17 +26,70 @@ type ClientInterface interface {
 	GetClientset() kubernetes.Interface
 	ScaleDeployment(ctx context.Context, namespace, name string, replicas int32) error
 	GetDeploymentStatus(ctx context.Context, namespace, name string) (*DeploymentStatus, error)
+	DryRunScaleDeployment(ctx context.Context, namespace, name string, replicas int32) (*DeploymentStatus, error)
+	ListDeployments(ctx context.Context, namespace, labelSelector string) ([]*DeploymentStatus, error)
+	PatchDeploymentMetadata(ctx context.Context, namespace, name string, labels, annotations map[string]*string) error
+	ListWorkloads(ctx context.Context, namespace string) ([]*Workload, error)
+	ScaleWorkload(ctx context.Context, kind, namespace, name string, replicas int32) error
+	PatchWorkloadAnnotations(ctx context.Context, kind, namespace, name string, annotations map[string]*string) error
+	RecordDeploymentEvent(ctx context.Context, namespace, name, eventType, reason, message string) error
+	GetCronJob(ctx context.Context, namespace, name string) (*CronJob, error)
+	SuspendCronJob(ctx context.Context, namespace, name string, suspend bool) error
+	ListJobs(ctx context.Context, namespace, nodePool string) ([]*Job, error)
+	DeleteJob(ctx context.Context, namespace, name string) error
+	CheckNamespace(namespace string) error
+	CheckDeployment(ctx context.Context, namespace, name string, deploymentLabels map[string]string) error
 }
 
+// Node pool resolution
+const (
+	// NodePoolAnnotation explicitly names the node pool a workload runs on
+	NodePoolAnnotation = "scale-to-zero.io/node-pool"
+	// AgentPoolLabel is the label AKS sets on every node with its agent pool name
+	AgentPoolLabel = "kubernetes.azure.com/agentpool"
+	// legacyAgentPoolLabel is the older agent pool label still set by AKS
+	legacyAgentPoolLabel = "agentpool"
+)
+
+// Deployment annotations recording the last replica count set through the
+// client, so changes made around the API can be detected
+const (
+	AnnotationRecordedReplicas = "scale-to-zero.io/recorded-replicas"
+	// AnnotationRecordedAt is when the replica count was recorded (RFC 3339)
+	AnnotationRecordedAt = "scale-to-zero.io/recorded-at"
+)
+
+// eventSource is the component name on Events the client records
+const eventSource = "scale-api"
+
+// GPUResourceName is the extended resource exposed by the NVIDIA device plugin
+const GPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
 // Client wraps the Kubernetes clientset
 type Client struct {
 	clientset kubernetes.Interface
+	// dynamicClient reaches KEDA ScaledObjects; nil disables KEDA support
+	dynamicClient dynamic.Interface
+	// scope limits the workloads the client acts on
+	scope *Scope
+	// throttle limits how often each deployment is scaled; nil disables it
+	throttle Throttle
+}
+
+// Throttle limits how often a deployment is scaled. Reserve either rejects the
+// operation or records it and returns a function that undoes the record when
+// the operation fails. ReserveKind does the same for other workload kinds,
+// which are limited apart from deployments of the same name.
+type Throttle interface {
+	Reserve(namespace, name string, replicas int32) (func(), error)
+	ReserveKind(kind, namespace, name string, replicas int32) (func(), error)
 }
 
 // NewClient creates a new Kubernetes client
 // It automatically detects if running inside a cluster (InClusterConfig)
-// or outside (using kubeconfig file)
-func NewClient() (*Client, error) {
+// or outside (using kubeconfig file). The client only acts on workloads in
+// scope; a nil scope allows every workload.
+func NewClient(scope *Scope) (*Client, error) {
 	config, err := getConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
@@ -40,11 +100,33 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	return &Client{
-		clientset: clientset,
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		scope:         scope,
 	}, nil
 }
 
+// NewDynamicClient creates a dynamic client for custom resources using the
+// same configuration as NewClient
+func NewDynamicClient() (dynamic.Interface, error) {
+	config, err := getConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return dynamicClient, nil
+}
+
 // getConfig returns the appropriate Kubernetes configuration
 func getConfig() (*rest.Config, error) {
 	// Try in-cluster config first (when running inside a pod)
@@ -69,42 +151,219 @@ func getConfig() (*rest.Config, error) {
 	return config, nil
 }
 
+// SetThrottle sets the throttle every replica change goes through, so the
+// limits apply to all callers of ScaleDeployment
+func (c *Client) SetThrottle(throttle Throttle) {
+	c.throttle = throttle
+}
+
 // GetClientset returns the underlying Kubernetes clientset
 func (c *Client) GetClientset() kubernetes.Interface {
 	return c.clientset
 }
 
-// ScaleDeployment scales a deployment to the specified number of replicas
+// ScaleDeployment scales a deployment to the specified number of replicas and
+// records the replica count in its annotations. Attached HPAs and KEDA
+// ScaledObjects are paused when scaling to zero and resumed when scaling up.
 func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, replicas int32) error {
 	deploymentsClient := c.clientset.AppsV1().Deployments(namespace)
 
 	// Get the deployment
-	deployment, err := deploymentsClient.Get(ctx, name, metav1.GetOptions{})
+	deployment, err := c.getDeployment(ctx, namespace, name)
 	if err != nil {
-		return fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+		return err
+	}
+
+	// The throttle is checked and recorded in one step, and given back if
+	// the replicas could not be changed
+	updated := false
+	if c.throttle != nil {
+		undo, err := c.throttle.Reserve(namespace, name, replicas)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if !updated {
+				undo()
+			}
+		}()
 	}
 
-	// Update the replica count
+	if deployment.Annotations == nil {
+		deployment.Annotations = map[string]string{}
+	}
+
+	// Autoscalers would fight the change, so they are paused or resumed first.
+	// Paused HPAs are stashed in the same update and deleted once it succeeds.
+	// If the update fails, the autoscalers are put back as they were.
+	var pausedHPAs []string
+	var undoAutoscalers func(context.Context) error
+	if replicas == 0 {
+		if pausedHPAs, undoAutoscalers, err = c.pauseAutoscalers(ctx, deployment); err != nil {
+			return fmt.Errorf("failed to pause autoscalers of deployment %s/%s: %w", namespace, name, err)
+		}
+	} else if undoAutoscalers, err = c.resumeAutoscalers(ctx, deployment); err != nil {
+		return fmt.Errorf("failed to resume autoscalers of deployment %s/%s: %w", namespace, name, err)
+	}
+
+	// Update the replica count, recording it in the same update
 	deployment.Spec.Replicas = &replicas
+	deployment.Annotations[AnnotationRecordedReplicas] = strconv.Itoa(int(replicas))
+	deployment.Annotations[AnnotationRecordedAt] = time.Now().UTC().Format(time.RFC3339)
 
 	// Update the deployment
 	_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to update deployment %s/%s: %w", namespace, name, err)
+		return fmt.Errorf("failed to update deployment %s/%s: %w", namespace, name, withUndo(ctx, err, undoAutoscalers))
 	}
+	updated = true
 
-	return nil
+	return c.deleteHPAs(ctx, namespace, pausedHPAs)
 }
 
-// GetDeploymentStatus retrieves the current status of a deployment
-func (c *Client) GetDeploymentStatus(ctx context.Context, namespace, name string) (*DeploymentStatus, error) {
+// DryRunScaleDeployment submits the replica change with server-side dry run
+// (DryRun: All) and returns the deployment status as it would be after scaling.
+// The cluster state is not changed.
+func (c *Client) DryRunScaleDeployment(ctx context.Context, namespace, name string, replicas int32) (*DeploymentStatus, error) {
 	deploymentsClient := c.clientset.AppsV1().Deployments(namespace)
 
-	deployment, err := deploymentsClient.Get(ctx, name, metav1.GetOptions{})
+	deployment, err := c.getDeployment(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment.Spec.Replicas = &replicas
+
+	result, err := deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dry-run update of deployment %s/%s: %w", namespace, name, err)
+	}
+
+	status := c.statusFromDeployment(ctx, result)
+	// Not every API server echoes the requested spec on dry run
+	status.DesiredReplicas = replicas
+	return status, nil
+}
+
+// GetDeploymentStatus retrieves the current status of a deployment, including
+// its autoscalers
+func (c *Client) GetDeploymentStatus(ctx context.Context, namespace, name string) (*DeploymentStatus, error) {
+	deployment, err := c.getDeployment(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	status := c.statusFromDeployment(ctx, deployment)
+	// Autoscalers are informational, so this is best effort
+	status.Autoscalers, _ = c.autoscalers(ctx, deployment)
+	return status, nil
+}
+
+// RecordDeploymentEvent records a Kubernetes Event on a deployment
+func (c *Client) RecordDeploymentEvent(ctx context.Context, namespace, name, eventType, reason, message string) error {
+	deployment, err := c.getDeployment(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "apps/v1",
+			Kind:            KindDeployment,
+			Namespace:       namespace,
+			Name:            name,
+			UID:             deployment.UID,
+			ResourceVersion: deployment.ResourceVersion,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := c.clientset.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to record event on deployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// getDeployment retrieves a deployment, returning ErrNotManaged when it is
+// outside the client's scope
+func (c *Client) getDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
 	}
 
+	if err := c.scope.check(KindDeployment, namespace, name, deployment.Labels); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// ListDeployments lists the managed deployments matching labelSelector. An
+// empty namespace lists deployments in all namespaces.
+func (c *Client) ListDeployments(ctx context.Context, namespace, labelSelector string) ([]*DeploymentStatus, error) {
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	statuses := make([]*DeploymentStatus, 0, len(deployments.Items))
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !c.scope.Allows(deployment.Namespace, deployment.Labels) {
+			continue
+		}
+		statuses = append(statuses, c.statusFromDeployment(ctx, deployment))
+	}
+	return statuses, nil
+}
+
+// PatchDeploymentMetadata sets labels and annotations on a deployment with a
+// JSON merge patch. A nil value removes the key.
+func (c *Client) PatchDeploymentMetadata(ctx context.Context, namespace, name string, labels, annotations map[string]*string) error {
+	if _, err := c.getDeployment(ctx, namespace, name); err != nil {
+		return err
+	}
+
+	metadata := map[string]interface{}{}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata patch: %w", err)
+	}
+
+	_, err = c.clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch deployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// statusFromDeployment converts a Deployment into a DeploymentStatus
+func (c *Client) statusFromDeployment(ctx context.Context, deployment *appsv1.Deployment) *DeploymentStatus {
 	desiredReplicas := int32(0)
 	if deployment.Spec.Replicas != nil {
 		desiredReplicas = *deployment.Spec.Replicas
@@ -118,7 +377,67 @@ func (c *Client) GetDeploymentStatus(ctx context.Context, namespace, name string
 		AvailableReplicas: deployment.Status.AvailableReplicas,
 		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
 		CreationTime:      deployment.CreationTimestamp.Time,
-	}, nil
+		GPUsPerPod:        GPUsPerPod(&deployment.Spec.Template.Spec),
+		NodePool:          c.resolveNodePool(ctx, deployment.Annotations, &deployment.Spec.Template.Spec),
+		Labels:            deployment.Labels,
+		Annotations:       deployment.Annotations,
+	}
+}
+
+// resolveNodePool determines which AKS node pool a workload's pods run on.
+// An explicit annotation wins, then an agent pool nodeSelector, and finally the
+// pool of an existing node matching the pod template's nodeSelector.
+func (c *Client) resolveNodePool(ctx context.Context, annotations map[string]string, spec *corev1.PodSpec) string {
+	if pool := annotations[NodePoolAnnotation]; pool != "" {
+		return pool
+	}
+
+	nodeSelector := spec.NodeSelector
+	for _, key := range []string{AgentPoolLabel, legacyAgentPoolLabel} {
+		if pool := nodeSelector[key]; pool != "" {
+			return pool
+		}
+	}
+	if len(nodeSelector) == 0 {
+		return ""
+	}
+
+	// Nodes may not exist while the pool is scaled to zero, so this is best effort
+	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(nodeSelector).String(),
+		Limit:         1,
+	})
+	if err != nil || len(nodes.Items) == 0 {
+		return ""
+	}
+	return nodes.Items[0].Labels[AgentPoolLabel]
+}
+
+// GPUsPerPod returns the number of GPUs a pod built from spec requests. Like
+// the scheduler, it takes the larger of the sum over app containers and the
+// largest init container. Limits are used for containers without requests,
+// since extended resources default their request to the limit.
+func GPUsPerPod(spec *corev1.PodSpec) int64 {
+	gpus := func(container corev1.Container) int64 {
+		if quantity, ok := container.Resources.Requests[GPUResourceName]; ok {
+			return quantity.Value()
+		}
+		if quantity, ok := container.Resources.Limits[GPUResourceName]; ok {
+			return quantity.Value()
+		}
+		return 0
+	}
+
+	var total int64
+	for _, container := range spec.Containers {
+		total += gpus(container)
+	}
+	for _, container := range spec.InitContainers {
+		if g := gpus(container); g > total {
+			total = g
+		}
+	}
+	return total
 }
 
 // DeploymentStatus represents the status of a deployment
@@ -129,5 +448,11 @@ type DeploymentStatus struct {
 	CurrentReplicas   int32
 	AvailableReplicas int32
 	UpdatedReplicas   int32
+	GPUsPerPod        int64
 	CreationTime      time.Time
+	NodePool          string
+	Labels            map[string]string
+	Annotations       map[string]string
+	// Autoscalers is only set by GetDeploymentStatus
+	Autoscalers []Autoscaler
 }
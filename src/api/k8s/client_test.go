@@ -8,11 +8,19 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/utils/ptr"
@@ -62,7 +70,7 @@ users:
 	t.Setenv("KUBECONFIG", kubeconfigPath)
 
 	// Test
-	client, err := NewClient()
+	client, err := NewClient(nil)
 
 	// Assert - should create client successfully
 	assert.NoError(t, err)
@@ -100,6 +108,75 @@ func TestScaleDeployment_Success(t *testing.T) {
 	updated, err := fakeClientset.AppsV1().Deployments("test-ns").Get(context.Background(), "test-app", metav1.GetOptions{})
 	assert.NoError(t, err)
 	assert.Equal(t, int32(0), *updated.Spec.Replicas)
+	assert.Equal(t, "0", updated.Annotations[AnnotationRecordedReplicas])
+	assert.NotEmpty(t, updated.Annotations[AnnotationRecordedAt])
+}
+
+func TestScaleDeployment_Throttled(t *testing.T) {
+	// Setup
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(0))},
+	}
+	fakeClientset := fake.NewSimpleClientset(deployment)
+	client := &Client{clientset: fakeClientset}
+	client.SetThrottle(throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute}))
+
+	// Test
+	require.NoError(t, client.ScaleDeployment(context.Background(), "test-ns", "test-app", 2))
+	err := client.ScaleDeployment(context.Background(), "test-ns", "test-app", 0)
+
+	// Assert
+	var throttled *throttle.Error
+	require.ErrorAs(t, err, &throttled)
+	updated, err := fakeClientset.AppsV1().Deployments("test-ns").Get(context.Background(), "test-app", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), *updated.Spec.Replicas)
+}
+
+func TestScaleDeployment_FailedUpdateIsNotThrottled(t *testing.T) {
+	// Setup
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(0))},
+	}
+	fakeClientset := fake.NewSimpleClientset(deployment)
+	client := &Client{clientset: fakeClientset}
+	client.SetThrottle(throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute}))
+	failing := true
+	fakeClientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return failing, nil, errors.NewInternalError(assert.AnError)
+	})
+
+	// Test
+	require.Error(t, client.ScaleDeployment(context.Background(), "test-ns", "test-app", 2))
+	failing = false
+	err := client.ScaleDeployment(context.Background(), "test-ns", "test-app", 0)
+
+	// Assert: the failed scale-up did not start the cooldown
+	assert.NoError(t, err)
+}
+
+func TestRecordDeploymentEvent(t *testing.T) {
+	// Setup
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test-ns", UID: "uid-1"},
+	}
+	fakeClientset := fake.NewSimpleClientset(deployment)
+	client := &Client{clientset: fakeClientset}
+
+	// Test
+	err := client.RecordDeploymentEvent(context.Background(), "test-ns", "test-app", v1.EventTypeWarning, "ReplicaDrift", "replicas changed")
+
+	// Assert
+	require.NoError(t, err)
+	events, err := fakeClientset.CoreV1().Events("test-ns").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "ReplicaDrift", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+	assert.Equal(t, "test-app", events.Items[0].InvolvedObject.Name)
+	assert.Equal(t, "uid-1", string(events.Items[0].InvolvedObject.UID))
 }
 
 func TestScaleDeployment_NotFound(t *testing.T) {
@@ -261,3 +338,614 @@ func TestGetClientset(t *testing.T) {
 	assert.NotNil(t, result)
 	assert.Equal(t, fakeClientset, result)
 }
+
+func TestDryRunScaleDeployment(t *testing.T) {
+	// Setup
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "test-ns",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(0)),
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					NodeSelector: map[string]string{AgentPoolLabel: "projectb"},
+				},
+			},
+		},
+	}
+
+	fakeClientset := fake.NewSimpleClientset(deployment)
+	client := &Client{clientset: fakeClientset}
+
+	// The fake clientset does not implement dry run, so answer the update
+	// without persisting it and record the options that were sent
+	var dryRun []string
+	fakeClientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		update := action.(k8stesting.UpdateActionImpl)
+		dryRun = update.UpdateOptions.DryRun
+		return true, update.Object, nil
+	})
+
+	// Test
+	status, err := client.DryRunScaleDeployment(context.Background(), "test-ns", "test-app", 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{metav1.DryRunAll}, dryRun)
+	assert.Equal(t, int32(2), status.DesiredReplicas)
+	assert.Equal(t, "projectb", status.NodePool)
+
+	stored, err := fakeClientset.AppsV1().Deployments("test-ns").Get(context.Background(), "test-app", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *stored.Spec.Replicas)
+}
+
+func TestDryRunScaleDeployment_NotFound(t *testing.T) {
+	// Setup
+	client := &Client{clientset: fake.NewSimpleClientset()}
+
+	// Test
+	status, err := client.DryRunScaleDeployment(context.Background(), "test-ns", "nonexistent", 1)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, status)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestGetDeploymentStatus_NodePool(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		nodeSelector map[string]string
+		nodes        []runtime.Object
+		expected     string
+	}{
+		{
+			name:         "annotation takes precedence",
+			annotations:  map[string]string{NodePoolAnnotation: "projectb"},
+			nodeSelector: map[string]string{AgentPoolLabel: "other"},
+			expected:     "projectb",
+		},
+		{
+			name:         "agent pool node selector",
+			nodeSelector: map[string]string{"agentpool": "projecta"},
+			expected:     "projecta",
+		},
+		{
+			name:         "matching node labels",
+			nodeSelector: map[string]string{"project": "b"},
+			nodes: []runtime.Object{
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   "aks-projecta-0",
+					Labels: map[string]string{"project": "a", AgentPoolLabel: "projecta"},
+				}},
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   "aks-projectb-0",
+					Labels: map[string]string{"project": "b", AgentPoolLabel: "projectb"},
+				}},
+			},
+			expected: "projectb",
+		},
+		{
+			name:         "no matching nodes",
+			nodeSelector: map[string]string{"project": "b"},
+			expected:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-app",
+					Namespace:   "test-ns",
+					Annotations: tt.annotations,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{NodeSelector: tt.nodeSelector},
+					},
+				},
+			}
+
+			client := &Client{clientset: fake.NewSimpleClientset(append(tt.nodes, deployment)...)}
+
+			status, err := client.GetDeploymentStatus(context.Background(), "test-ns", "test-app")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, status.NodePool)
+		})
+	}
+}
+
+func TestGPUsPerPod(t *testing.T) {
+	gpu := func(n int64) v1.ResourceList {
+		return v1.ResourceList{GPUResourceName: *resource.NewQuantity(n, resource.DecimalSI)}
+	}
+
+	tests := []struct {
+		name string
+		spec v1.PodSpec
+		want int64
+	}{
+		{
+			name: "no gpu",
+			spec: v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+			want: 0,
+		},
+		{
+			name: "requests summed across containers",
+			spec: v1.PodSpec{Containers: []v1.Container{
+				{Name: "app", Resources: v1.ResourceRequirements{Requests: gpu(1), Limits: gpu(1)}},
+				{Name: "sidecar", Resources: v1.ResourceRequirements{Requests: gpu(2)}},
+			}},
+			want: 3,
+		},
+		{
+			name: "limit only",
+			spec: v1.PodSpec{Containers: []v1.Container{
+				{Name: "app", Resources: v1.ResourceRequirements{Limits: gpu(2)}},
+			}},
+			want: 2,
+		},
+		{
+			name: "init container larger than app containers",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{{Name: "warmup", Resources: v1.ResourceRequirements{Limits: gpu(4)}}},
+				Containers:     []v1.Container{{Name: "app", Resources: v1.ResourceRequirements{Limits: gpu(1)}}},
+			},
+			want: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GPUsPerPod(&tt.spec))
+		})
+	}
+}
+
+func TestListDeployments(t *testing.T) {
+	// Setup
+	fakeClientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app-a", Namespace: "ns-a", Labels: map[string]string{"tier": "gpu"}}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "app-b", Namespace: "ns-b", Labels: map[string]string{"tier": "gpu"}}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns-a"}},
+	)
+	client := &Client{clientset: fakeClientset}
+
+	// Test - all namespaces
+	statuses, err := client.ListDeployments(context.Background(), "", "tier=gpu")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+
+	// Test - single namespace
+	statuses, err = client.ListDeployments(context.Background(), "ns-a", "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+}
+
+func TestPatchDeploymentMetadata(t *testing.T) {
+	// Setup
+	fakeClientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-app",
+			Namespace:   "test-ns",
+			Labels:      map[string]string{"app": "test-app", "stale": "true"},
+			Annotations: map[string]string{"keep": "me"},
+		},
+	})
+	client := &Client{clientset: fakeClientset}
+
+	// Test
+	err := client.PatchDeploymentMetadata(context.Background(), "test-ns", "test-app",
+		map[string]*string{"stale": nil, "new": ptr.To("label")},
+		map[string]*string{"added": ptr.To("value")},
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	updated, err := fakeClientset.AppsV1().Deployments("test-ns").Get(context.Background(), "test-app", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "test-app", "new": "label"}, updated.Labels)
+	assert.Equal(t, map[string]string{"keep": "me", "added": "value"}, updated.Annotations)
+}
+
+func TestListWorkloads(t *testing.T) {
+	// Setup
+	fakeClientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns-a"},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns-a"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(3))},
+		},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns-b"}},
+	)
+	client := &Client{clientset: fakeClientset}
+
+	// Test
+	workloads, err := client.ListWorkloads(context.Background(), "ns-a")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 2)
+	assert.Equal(t, KindDeployment, workloads[0].Kind)
+	assert.Equal(t, int32(2), workloads[0].DesiredReplicas)
+	assert.Equal(t, KindStatefulSet, workloads[1].Kind)
+	assert.Equal(t, "db", workloads[1].Name)
+	assert.Equal(t, int32(3), workloads[1].DesiredReplicas)
+}
+
+func TestScaleWorkload_StatefulSet(t *testing.T) {
+	// Setup
+	fakeClientset := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns-a"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(3))},
+	})
+	client := &Client{clientset: fakeClientset}
+
+	// Test
+	err := client.ScaleWorkload(context.Background(), KindStatefulSet, "ns-a", "db", 0)
+	assert.NoError(t, err)
+	err = client.PatchWorkloadAnnotations(context.Background(), KindStatefulSet, "ns-a", "db", map[string]*string{"note": ptr.To("value")})
+	assert.NoError(t, err)
+
+	// Assert
+	updated, err := fakeClientset.AppsV1().StatefulSets("ns-a").Get(context.Background(), "db", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *updated.Spec.Replicas)
+	assert.Equal(t, "value", updated.Annotations["note"])
+
+	// Unknown kinds are refused
+	err = client.ScaleWorkload(context.Background(), "DaemonSet", "ns-a", "agent", 0)
+	assert.Error(t, err)
+}
+
+func TestScaleWorkload_StatefulSetThrottled(t *testing.T) {
+	// Setup: a deployment and a StatefulSet share a name
+	fakeClientset := fake.NewSimpleClientset(
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns-a"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(3))},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns-a"},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(0))},
+		},
+	)
+	client := &Client{clientset: fakeClientset}
+	client.SetThrottle(throttle.NewLimiter(&throttle.Config{DirectionCooldown: time.Minute}))
+
+	// Test
+	require.NoError(t, client.ScaleWorkload(context.Background(), KindStatefulSet, "ns-a", "db", 0))
+	err := client.ScaleWorkload(context.Background(), KindStatefulSet, "ns-a", "db", 3)
+
+	// Assert: the StatefulSet is in its cooldown, the deployment is not
+	var throttled *throttle.Error
+	require.ErrorAs(t, err, &throttled)
+	updated, err := fakeClientset.AppsV1().StatefulSets("ns-a").Get(context.Background(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *updated.Spec.Replicas)
+	assert.NoError(t, client.ScaleDeployment(context.Background(), "ns-a", "db", 2))
+}
+
+func TestScope(t *testing.T) {
+	// Setup
+	managed := map[string]string{"scale-to-zero.io/managed": "true"}
+	fakeClientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns-a", Labels: managed},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "batch", Namespace: "ns-a"},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns-a"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To(int32(1))},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system", Labels: managed},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+		},
+	)
+	scope, err := NewScope(DefaultManagedSelector, DefaultDeniedNamespaces)
+	assert.NoError(t, err)
+	client := &Client{clientset: fakeClientset, scope: scope}
+	ctx := context.Background()
+
+	// Managed deployments are handled as usual
+	_, err = client.GetDeploymentStatus(ctx, "ns-a", "web")
+	assert.NoError(t, err)
+	assert.NoError(t, client.ScaleDeployment(ctx, "ns-a", "web", 0))
+
+	// Unlabelled workloads and denied namespaces are rejected
+	_, err = client.GetDeploymentStatus(ctx, "ns-a", "batch")
+	assert.ErrorIs(t, err, ErrNotManaged)
+	assert.ErrorIs(t, client.ScaleDeployment(ctx, "kube-system", "coredns", 0), ErrNotManaged)
+	assert.ErrorIs(t, client.PatchDeploymentMetadata(ctx, "ns-a", "batch", nil, map[string]*string{"note": ptr.To("value")}), ErrNotManaged)
+	assert.ErrorIs(t, client.ScaleWorkload(ctx, KindStatefulSet, "ns-a", "db", 0), ErrNotManaged)
+	_, err = client.ListWorkloads(ctx, "kube-system")
+	assert.ErrorIs(t, err, ErrNotManaged)
+	assert.ErrorIs(t, client.CheckNamespace("kube-system"), ErrNotManaged)
+	assert.NoError(t, client.CheckNamespace("ns-a"))
+	assert.NoError(t, client.CheckDeployment(ctx, "ns-a", "web", nil))
+	assert.ErrorIs(t, client.CheckDeployment(ctx, "ns-a", "batch", nil), ErrNotManaged)
+	assert.ErrorIs(t, client.CheckDeployment(ctx, "ns-a", "web", map[string]string{}), ErrNotManaged)
+	assert.ErrorIs(t, client.CheckDeployment(ctx, "kube-system", "coredns", managed), ErrNotManaged)
+
+	// Lists leave them out
+	deployments, err := client.ListDeployments(ctx, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, deployments, 1)
+	assert.Equal(t, "web", deployments[0].Name)
+	workloads, err := client.ListWorkloads(ctx, "ns-a")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1)
+
+	// Rejected workloads are left unchanged
+	coredns, err := fakeClientset.AppsV1().Deployments("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *coredns.Spec.Replicas)
+}
+
+// scaledObject returns a KEDA ScaledObject targeting a deployment
+func scaledObject(name, target string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "keda.sh/v1alpha1",
+		"kind":       KindScaledObject,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "project-b"},
+		"spec": map[string]interface{}{
+			"scaleTargetRef":  map[string]interface{}{"name": target},
+			"maxReplicaCount": int64(4),
+		},
+	}}
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func TestScaleDeployment_Autoscalers(t *testing.T) {
+	// Setup: an HPA, a ScaledObject and the HPA KEDA manages for it
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "triton", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "triton", Namespace: "project-b", Labels: map[string]string{"team": "b"}},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: KindDeployment, Name: "triton"},
+			MinReplicas:    ptr.To(int32(1)),
+			MaxReplicas:    4,
+		},
+	}
+	kedaHPA := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "keda-hpa-triton", Namespace: "project-b",
+			OwnerReferences: []metav1.OwnerReference{{Kind: KindScaledObject, Name: "triton"}},
+		},
+		Spec: hpa.Spec,
+	}
+	fakeClientset := fake.NewSimpleClientset(deployment, hpa, kedaHPA)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ScaledObjectResource: "ScaledObjectList"},
+		scaledObject("triton", "triton", nil), scaledObject("other", "other", nil))
+	client := &Client{clientset: fakeClientset, dynamicClient: dynamicClient}
+	ctx := context.Background()
+
+	// Test: scale to zero
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 0))
+
+	// Assert: the HPA is stashed and the ScaledObject paused
+	_, err := fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+	_, err = fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "keda-hpa-triton", metav1.GetOptions{})
+	assert.NoError(t, err)
+	so, err := dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "0", so.GetAnnotations()[AnnotationKEDAPausedReplicas])
+	other, err := dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "other", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, other.GetAnnotations())
+
+	status, err := client.GetDeploymentStatus(ctx, "project-b", "triton")
+	require.NoError(t, err)
+	assert.Equal(t, []Autoscaler{
+		{Kind: KindHorizontalPodAutoscaler, Name: "triton", MinReplicas: 1, MaxReplicas: 4, Paused: true},
+		{Kind: KindScaledObject, Name: "triton", MinReplicas: 0, MaxReplicas: 4, Paused: true},
+	}, status.Autoscalers)
+
+	// Test: scale up
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 2))
+
+	// Assert: the HPA is restored and the ScaledObject resumed
+	restored, err := fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, hpa.Spec, restored.Spec)
+	assert.Equal(t, "b", restored.Labels["team"])
+	so, err = dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, so.GetAnnotations())
+
+	status, err = client.GetDeploymentStatus(ctx, "project-b", "triton")
+	require.NoError(t, err)
+	assert.NotContains(t, status.Annotations, AnnotationPausedHPAs)
+	require.Len(t, status.Autoscalers, 2)
+	assert.False(t, status.Autoscalers[0].Paused)
+	assert.False(t, status.Autoscalers[1].Paused)
+}
+
+func TestScaleDeployment_AutoscalersRestoredOnFailure(t *testing.T) {
+	// Setup: an HPA and a ScaledObject, and deployment updates that fail
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "triton", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "triton", Namespace: "project-b"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: KindDeployment, Name: "triton"},
+			MaxReplicas:    4,
+		},
+	}
+	fakeClientset := fake.NewSimpleClientset(deployment, hpa)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ScaledObjectResource: "ScaledObjectList"},
+		scaledObject("triton", "triton", nil))
+	client := &Client{clientset: fakeClientset, dynamicClient: dynamicClient}
+	ctx := context.Background()
+	failing := true
+	fakeClientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return failing, nil, errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "triton", assert.AnError)
+	})
+
+	// Test: a failed scale to zero
+	err := client.ScaleDeployment(ctx, "project-b", "triton", 0)
+
+	// Assert: the ScaledObject is resumed and the HPA kept
+	assert.True(t, errors.IsConflict(err))
+	so, err := dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, so.GetAnnotations())
+	_, err = fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Test: a failed scale-up after a scale to zero
+	failing = false
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 0))
+	failing = true
+	err = client.ScaleDeployment(ctx, "project-b", "triton", 2)
+
+	// Assert: the ScaledObject is paused again and the restored HPA deleted
+	assert.True(t, errors.IsConflict(err))
+	so, err = dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "0", so.GetAnnotations()[AnnotationKEDAPausedReplicas])
+	_, err = fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+
+	// The stash is kept, so the next scale-up restores the HPA
+	failing = false
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 2))
+	_, err = fakeClientset.AutoscalingV2().HorizontalPodAutoscalers("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestScaleDeployment_ScaledObjectPausedByUser(t *testing.T) {
+	// Setup: a ScaledObject someone else paused
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "triton", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(0))},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ScaledObjectResource: "ScaledObjectList"},
+		scaledObject("triton", "triton", map[string]string{AnnotationKEDAPausedReplicas: "1"}))
+	client := &Client{clientset: fake.NewSimpleClientset(deployment), dynamicClient: dynamicClient}
+	ctx := context.Background()
+
+	// Test
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 0))
+	require.NoError(t, client.ScaleDeployment(ctx, "project-b", "triton", 1))
+
+	// Assert: the pause is left in place
+	so, err := dynamicClient.Resource(ScaledObjectResource).Namespace("project-b").Get(ctx, "triton", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{AnnotationKEDAPausedReplicas: "1"}, so.GetAnnotations())
+}
+
+func TestCronJobWorkload(t *testing.T) {
+	// Setup
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly-eval", Namespace: "project-b"},
+		Spec:       batchv1.CronJobSpec{Schedule: "0 2 * * *"},
+		Status:     batchv1.CronJobStatus{Active: []v1.ObjectReference{{Name: "nightly-eval-29000000"}}},
+	}
+	fakeClientset := fake.NewSimpleClientset(cronJob)
+	client := &Client{clientset: fakeClientset}
+	ctx := context.Background()
+
+	// A running CronJob counts as one replica with its active Jobs ready
+	workloads, err := client.ListWorkloads(ctx, "project-b")
+	require.NoError(t, err)
+	require.Len(t, workloads, 1)
+	assert.Equal(t, KindCronJob, workloads[0].Kind)
+	assert.Equal(t, int32(1), workloads[0].DesiredReplicas)
+	assert.Equal(t, int32(1), workloads[0].ReadyReplicas)
+
+	// Scaling to zero suspends it
+	require.NoError(t, client.ScaleWorkload(ctx, KindCronJob, "project-b", "nightly-eval", 0))
+	got, err := client.GetCronJob(ctx, "project-b", "nightly-eval")
+	require.NoError(t, err)
+	assert.True(t, got.Suspended)
+	assert.Equal(t, "0 2 * * *", got.Schedule)
+
+	require.NoError(t, client.PatchWorkloadAnnotations(ctx, KindCronJob, "project-b", "nightly-eval", map[string]*string{"note": ptr.To("value")}))
+	workloads, err = client.ListWorkloads(ctx, "project-b")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), workloads[0].DesiredReplicas)
+	assert.Equal(t, "value", workloads[0].Annotations["note"])
+
+	require.NoError(t, client.SuspendCronJob(ctx, "project-b", "nightly-eval", false))
+	got, err = client.GetCronJob(ctx, "project-b", "nightly-eval")
+	require.NoError(t, err)
+	assert.False(t, got.Suspended)
+}
+
+func TestListJobs(t *testing.T) {
+	// Setup
+	job := func(name string, nodeSelector map[string]string, conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "project-b",
+				OwnerReferences: []metav1.OwnerReference{{Kind: KindCronJob, Name: "nightly-eval"}},
+			},
+			Spec: batchv1.JobSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": name}},
+				Template: v1.PodTemplateSpec{Spec: v1.PodSpec{NodeSelector: nodeSelector}},
+			},
+			Status: batchv1.JobStatus{Active: 1, Conditions: conditions},
+		}
+	}
+	gpu := map[string]string{AgentPoolLabel: "projectb"}
+	complete := batchv1.JobCondition{Type: batchv1.JobComplete, Status: v1.ConditionTrue}
+	fakeClientset := fake.NewSimpleClientset(
+		job("eval-1", gpu),
+		job("eval-0", gpu, complete),
+		job("cpu", map[string]string{AgentPoolLabel: "system"}),
+		// Unpinned Job whose pod runs on a projectb node
+		job("unpinned", nil),
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "unpinned-abcde", Namespace: "project-b", Labels: map[string]string{"job-name": "unpinned"}},
+			Spec:       v1.PodSpec{NodeName: "aks-projectb-0"},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "aks-projectb-0", Labels: gpu}},
+	)
+	client := &Client{clientset: fakeClientset}
+	ctx := context.Background()
+
+	// Test
+	jobs, err := client.ListJobs(ctx, "", "projectb")
+
+	// Assert: finished Jobs and other pools are left out
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "eval-1", jobs[0].Name)
+	assert.Equal(t, "nightly-eval", jobs[0].CronJob)
+	assert.Equal(t, "unpinned", jobs[1].Name)
+	assert.Equal(t, "projectb", jobs[1].NodePool)
+
+	// Deleting terminates the Job
+	require.NoError(t, client.DeleteJob(ctx, "project-b", "eval-1"))
+	_, err = fakeClientset.BatchV1().Jobs("project-b").Get(ctx, "eval-1", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
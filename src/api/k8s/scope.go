@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultManagedSelector is the opt-in label workloads must carry before the
+// API acts on them
+const DefaultManagedSelector = "scale-to-zero.io/managed=true"
+
+// DefaultDeniedNamespaces are never managed, whatever their workloads' labels
+var DefaultDeniedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// ErrNotManaged is returned for workloads outside the client's scope
+var ErrNotManaged = errors.New("workload is not managed by the scale API")
+
+// Scope limits the workloads the client reads and changes to those that
+// opted in. A nil Scope allows every workload.
+type Scope struct {
+	// Selector matches the labels of managed workloads. Nil matches all.
+	Selector labels.Selector
+	// DeniedNamespaces are never managed
+	DeniedNamespaces map[string]bool
+}
+
+// NewScope builds a scope from a label selector and a list of denied
+// namespaces. An empty selector manages every workload outside the denied
+// namespaces.
+func NewScope(selector string, deniedNamespaces []string) (*Scope, error) {
+	scope := &Scope{DeniedNamespaces: map[string]bool{}}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid managed selector %q: %w", selector, err)
+		}
+		scope.Selector = parsed
+	}
+	for _, namespace := range deniedNamespaces {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			scope.DeniedNamespaces[namespace] = true
+		}
+	}
+	return scope, nil
+}
+
+// Allows reports whether a workload in namespace with the given labels is managed
+func (s *Scope) Allows(namespace string, workloadLabels map[string]string) bool {
+	return s.checkNamespace(namespace) == nil &&
+		(s == nil || s.Selector == nil || s.Selector.Matches(labels.Set(workloadLabels)))
+}
+
+// checkNamespace returns ErrNotManaged for denied namespaces
+func (s *Scope) checkNamespace(namespace string) error {
+	if s != nil && s.DeniedNamespaces[namespace] {
+		return fmt.Errorf("%w: namespace %s is denied", ErrNotManaged, namespace)
+	}
+	return nil
+}
+
+// check returns ErrNotManaged unless the workload is managed
+func (s *Scope) check(kind, namespace, name string, workloadLabels map[string]string) error {
+	if err := s.checkNamespace(namespace); err != nil {
+		return err
+	}
+	if !s.Allows(namespace, workloadLabels) {
+		return fmt.Errorf("%w: %s %s/%s does not match %s", ErrNotManaged, strings.ToLower(kind), namespace, name, s.Selector)
+	}
+	return nil
+}
+
+// CheckNamespace returns ErrNotManaged when namespace is denied, so handlers
+// reading namespace-wide data can refuse namespaces outside the client's scope
+func (c *Client) CheckNamespace(namespace string) error {
+	return c.scope.checkNamespace(namespace)
+}
+
+// CheckDeployment returns ErrNotManaged unless the deployment is managed. It
+// decides from the deployment's labels when the caller has them, and
+// otherwise reads only the deployment, so admission reviews stay cheap.
+func (c *Client) CheckDeployment(ctx context.Context, namespace, name string, deploymentLabels map[string]string) error {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return err
+	}
+	if deploymentLabels == nil {
+		_, err := c.getDeployment(ctx, namespace, name)
+		return err
+	}
+	return c.scope.check(KindDeployment, namespace, name, deploymentLabels)
+}
@@ -0,0 +1,224 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Workload kinds handled by namespace-wide operations
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+)
+
+// Workload is a scalable workload of any supported kind. A CronJob counts as
+// one replica while it is not suspended, and its active Jobs as ready replicas.
+type Workload struct {
+	Kind            string
+	Name            string
+	Namespace       string
+	DesiredReplicas int32
+	ReadyReplicas   int32
+	Labels          map[string]string
+	Annotations     map[string]string
+}
+
+// ListWorkloads lists the managed CronJobs, Deployments and StatefulSets in a
+// namespace, sorted by kind and name
+func (c *Client) ListWorkloads(ctx context.Context, namespace string) ([]*Workload, error) {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	statefulSets, err := c.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	cronJobs, err := c.clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+
+	workloads := make([]*Workload, 0, len(deployments.Items)+len(statefulSets.Items)+len(cronJobs.Items))
+	for _, d := range deployments.Items {
+		if !c.scope.Allows(d.Namespace, d.Labels) {
+			continue
+		}
+		workloads = append(workloads, &Workload{
+			Kind:            KindDeployment,
+			Name:            d.Name,
+			Namespace:       d.Namespace,
+			DesiredReplicas: desiredReplicas(d.Spec.Replicas),
+			ReadyReplicas:   d.Status.ReadyReplicas,
+			Labels:          d.Labels,
+			Annotations:     d.Annotations,
+		})
+	}
+	for _, s := range statefulSets.Items {
+		if !c.scope.Allows(s.Namespace, s.Labels) {
+			continue
+		}
+		workloads = append(workloads, &Workload{
+			Kind:            KindStatefulSet,
+			Name:            s.Name,
+			Namespace:       s.Namespace,
+			DesiredReplicas: desiredReplicas(s.Spec.Replicas),
+			ReadyReplicas:   s.Status.ReadyReplicas,
+			Labels:          s.Labels,
+			Annotations:     s.Annotations,
+		})
+	}
+	for i := range cronJobs.Items {
+		cj := cronJobFrom(&cronJobs.Items[i])
+		if !c.scope.Allows(cj.Namespace, cj.Labels) {
+			continue
+		}
+		w := &Workload{
+			Kind:          KindCronJob,
+			Name:          cj.Name,
+			Namespace:     cj.Namespace,
+			ReadyReplicas: cj.ActiveJobs,
+			Labels:        cj.Labels,
+			Annotations:   cj.Annotations,
+		}
+		if !cj.Suspended {
+			w.DesiredReplicas = 1
+		}
+		workloads = append(workloads, w)
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Kind != workloads[j].Kind {
+			return workloads[i].Kind < workloads[j].Kind
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+	return workloads, nil
+}
+
+// ScaleWorkload scales a Deployment or StatefulSet to the specified number of
+// replicas. A CronJob is suspended at zero replicas and resumed otherwise.
+// StatefulSets and CronJobs are throttled like deployments, in buckets of
+// their own kind.
+func (c *Client) ScaleWorkload(ctx context.Context, kind, namespace, name string, replicas int32) error {
+	switch kind {
+	case KindDeployment:
+		return c.ScaleDeployment(ctx, namespace, name, replicas)
+	case KindStatefulSet:
+		statefulSetsClient := c.clientset.AppsV1().StatefulSets(namespace)
+
+		statefulSet, err := c.getStatefulSet(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+
+		undo, err := c.reserve(kind, namespace, name, replicas)
+		if err != nil {
+			return err
+		}
+
+		statefulSet.Spec.Replicas = &replicas
+
+		_, err = statefulSetsClient.Update(ctx, statefulSet, metav1.UpdateOptions{})
+		if err != nil {
+			undo()
+			return fmt.Errorf("failed to update statefulset %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	case KindCronJob:
+		undo, err := c.reserve(kind, namespace, name, replicas)
+		if err != nil {
+			return err
+		}
+		if err := c.SuspendCronJob(ctx, namespace, name, replicas == 0); err != nil {
+			undo()
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported workload kind %q", kind)
+	}
+}
+
+// reserve reserves a throttle slot for a workload that is not a deployment
+func (c *Client) reserve(kind, namespace, name string, replicas int32) (func(), error) {
+	if c.throttle == nil {
+		return func() {}, nil
+	}
+	return c.throttle.ReserveKind(kind, namespace, name, replicas)
+}
+
+// PatchWorkloadAnnotations sets annotations on a Deployment, StatefulSet or
+// CronJob with a JSON merge patch. A nil value removes the key.
+func (c *Client) PatchWorkloadAnnotations(ctx context.Context, kind, namespace, name string, annotations map[string]*string) error {
+	if kind == KindDeployment {
+		return c.PatchDeploymentMetadata(ctx, namespace, name, nil, annotations)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata patch: %w", err)
+	}
+
+	switch kind {
+	case KindStatefulSet:
+		if _, err := c.getStatefulSet(ctx, namespace, name); err != nil {
+			return err
+		}
+		_, err = c.clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to patch statefulset %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	case KindCronJob:
+		if _, err := c.getCronJob(ctx, namespace, name); err != nil {
+			return err
+		}
+		_, err = c.clientset.BatchV1().CronJobs(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to patch cronjob %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported workload kind %q", kind)
+	}
+}
+
+// getStatefulSet retrieves a statefulset, returning ErrNotManaged when it is
+// outside the client's scope
+func (c *Client) getStatefulSet(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error) {
+	if err := c.scope.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	statefulSet, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset %s/%s: %w", namespace, name, err)
+	}
+
+	if err := c.scope.check(KindStatefulSet, namespace, name, statefulSet.Labels); err != nil {
+		return nil, err
+	}
+	return statefulSet, nil
+}
+
+// desiredReplicas returns the replica count of a workload spec, which
+// defaults to 1 when unset
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
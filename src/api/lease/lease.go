@@ -0,0 +1,314 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+)
+
+// Deployment metadata recording a scale-up lease
+const (
+	// LabelLeased marks deployments with an active lease so they can be listed
+	LabelLeased = "scale-to-zero.io/leased"
+	// AnnotationExpiresAt is when the deployment is scaled back to zero (RFC 3339)
+	AnnotationExpiresAt = "scale-to-zero.io/lease-expires-at"
+	// AnnotationHolder is the principal that took the lease
+	AnnotationHolder = "scale-to-zero.io/lease-holder"
+	// AnnotationExpiryNotified is the expiry a lease_expiring notification was
+	// sent for, so each lease is announced once across API replicas
+	AnnotationExpiryNotified = "scale-to-zero.io/lease-expiry-notified"
+)
+
+// Default lease values
+const (
+	DefaultMaxDuration = 7 * 24 * time.Hour
+	DefaultInterval    = 30 * time.Second
+	// DefaultExpiryWarning is how long before expiry lease holders are notified
+	DefaultExpiryWarning = 15 * time.Minute
+)
+
+// Lease is a time box on a scale-up after which the deployment is scaled back to zero
+type Lease struct {
+	ExpiresAt time.Time
+	Holder    string
+}
+
+// Resolve returns the lease expiry requested with either a duration (e.g. "2h")
+// or an absolute expiry time. It returns the zero time if neither is set.
+func Resolve(duration string, expiresAt *time.Time, now time.Time, maxDuration time.Duration) (time.Time, error) {
+	var expiry time.Time
+	switch {
+	case duration != "" && expiresAt != nil:
+		return time.Time{}, fmt.Errorf("only one of duration and expires_at may be set")
+	case duration != "":
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q: %w", duration, err)
+		}
+		expiry = now.Add(d)
+	case expiresAt != nil:
+		expiry = *expiresAt
+	default:
+		return time.Time{}, nil
+	}
+
+	if !expiry.After(now) {
+		return time.Time{}, fmt.Errorf("lease must expire in the future")
+	}
+	if maxDuration > 0 && expiry.Sub(now) > maxDuration {
+		return time.Time{}, fmt.Errorf("lease may not be longer than %s", maxDuration)
+	}
+	return expiry.UTC().Truncate(time.Second), nil
+}
+
+// Extend returns the new expiry of a lease. A duration is added to the current
+// expiry, or to now if the lease has already run out; expiresAt replaces it.
+func Extend(current time.Time, duration string, expiresAt *time.Time, now time.Time, maxDuration time.Duration) (time.Time, error) {
+	switch {
+	case duration == "" && expiresAt == nil:
+		return time.Time{}, fmt.Errorf("one of duration and expires_at is required")
+	case duration != "" && expiresAt != nil:
+		return time.Time{}, fmt.Errorf("only one of duration and expires_at may be set")
+	case duration != "":
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q: %w", duration, err)
+		}
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("duration must be positive")
+		}
+		base := current
+		if base.Before(now) {
+			base = now
+		}
+		expiry := base.Add(d)
+		expiresAt = &expiry
+	}
+	return Resolve("", expiresAt, now, maxDuration)
+}
+
+// FromAnnotations returns the lease recorded on a deployment, if any
+func FromAnnotations(annotations map[string]string) (*Lease, bool) {
+	value, ok := annotations[AnnotationExpiresAt]
+	if !ok {
+		return nil, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, false
+	}
+	return &Lease{ExpiresAt: expiresAt, Holder: annotations[AnnotationHolder]}, true
+}
+
+// Set records a lease on a deployment
+func Set(ctx context.Context, k8sClient k8s.ClientInterface, namespace, name string, l Lease) error {
+	expiresAt := l.ExpiresAt.UTC().Format(time.RFC3339)
+	leased := "true"
+	holder := l.Holder
+	return k8sClient.PatchDeploymentMetadata(ctx, namespace, name,
+		map[string]*string{LabelLeased: &leased},
+		map[string]*string{AnnotationExpiresAt: &expiresAt, AnnotationHolder: &holder},
+	)
+}
+
+// Clear removes the lease from a deployment
+func Clear(ctx context.Context, k8sClient k8s.ClientInterface, namespace, name string) error {
+	return k8sClient.PatchDeploymentMetadata(ctx, namespace, name,
+		map[string]*string{LabelLeased: nil},
+		map[string]*string{AnnotationExpiresAt: nil, AnnotationHolder: nil, AnnotationExpiryNotified: nil},
+	)
+}
+
+// Drainer drains a deployment that declares a drain before it is scaled to
+// zero. It returns a nil operation when the deployment declares none, and
+// otherwise takes over release. drain.Manager implements it.
+type Drainer interface {
+	StartDeclared(ctx context.Context, status *k8s.DeploymentStatus, reason, startedBy string, release func()) (*operation.Operation, error)
+}
+
+// Manager scales deployments back to zero when their lease expires
+type Manager struct {
+	k8sClient     k8s.ClientInterface
+	locker        lock.Locker
+	policyEngine  *policy.Engine
+	drainer       Drainer
+	notifier      *notify.Notifier
+	expiryWarning time.Duration
+	now           func() time.Time
+}
+
+// NewManager creates a new lease manager. The locker serializes reverts with
+// scale operations from the API, and expired leases of deployments a policy
+// protects from scaling to zero are released without scaling.
+func NewManager(k8sClient k8s.ClientInterface, locker lock.Locker, policyEngine *policy.Engine) *Manager {
+	return &Manager{
+		k8sClient:     k8sClient,
+		locker:        locker,
+		policyEngine:  policyEngine,
+		expiryWarning: DefaultExpiryWarning,
+		now:           time.Now,
+	}
+}
+
+// SetDrainer sets the drainer that drains deployments declaring a drain
+// before their expired lease scales them to zero
+func (m *Manager) SetDrainer(drainer Drainer) {
+	m.drainer = drainer
+}
+
+// SetNotifier sets the notifier told about leases that are about to expire
+// and deployments scaled to zero because their lease expired
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// Run reverts expired leases every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.RevertExpired(ctx); err != nil {
+				log.Printf("Failed to revert expired scale-up leases: %v", err)
+			}
+		}
+	}
+}
+
+// RevertExpired scales every deployment whose lease has expired back to zero
+// and removes the lease. Deployments locked by an ongoing operation, such as
+// the drain of an expired lease, are retried on the next run. Leases expiring within the warning period are
+// announced to the notifier.
+func (m *Manager) RevertExpired(ctx context.Context) error {
+	deployments, err := m.k8sClient.ListDeployments(ctx, "", LabelLeased+"=true")
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deployments {
+		l, ok := FromAnnotations(d.Annotations)
+		if !ok {
+			log.Printf("Ignoring deployment %s/%s with invalid %s annotation", d.Namespace, d.Name, AnnotationExpiresAt)
+			continue
+		}
+		if m.now().Before(l.ExpiresAt) {
+			if err := m.warn(ctx, d, l); err != nil {
+				log.Printf("Failed to announce expiring lease of deployment %s/%s: %v", d.Namespace, d.Name, err)
+			}
+			continue
+		}
+		if err := m.revert(ctx, d.Namespace, d.Name); err != nil && !errors.Is(err, lock.ErrLocked) {
+			log.Printf("Failed to revert lease of deployment %s/%s: %v", d.Namespace, d.Name, err)
+		}
+	}
+	return nil
+}
+
+// revert scales one deployment to zero under its lock, re-checking the lease
+// in case it was extended or released meanwhile. A deployment that declares
+// a drain is drained first; the drain releases the lease once it is done.
+func (m *Manager) revert(ctx context.Context, namespace, name string) error {
+	release, err := m.locker.Acquire(ctx, lock.Key(namespace, name), false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	status, err := m.k8sClient.GetDeploymentStatus(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	if status.Labels[LabelLeased] != "true" {
+		return nil
+	}
+
+	l, ok := FromAnnotations(status.Annotations)
+	if !ok || m.now().Before(l.ExpiresAt) {
+		return nil
+	}
+
+	if status.DesiredReplicas > 0 {
+		decision, err := m.policyEngine.Evaluate(ctx, policy.Request{
+			Namespace:   namespace,
+			Name:        name,
+			Annotations: status.Annotations,
+			Operation:   policy.OperationScaleToZero,
+		})
+		if err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			log.Printf("Lease of deployment %s/%s held by %s expired, not scaled to zero: %s",
+				namespace, name, l.Holder, strings.Join(decision.Violations, "; "))
+			return Clear(ctx, m.k8sClient, namespace, name)
+		}
+
+		reason := fmt.Sprintf("lease held by %s expired", l.Holder)
+		if m.drainer != nil {
+			op, err := m.drainer.StartDeclared(ctx, status, reason, l.Holder, release)
+			if err != nil {
+				return err
+			}
+			if op != nil {
+				release = nil
+				log.Printf("Lease of deployment %s/%s held by %s expired at %s, draining (operation %s)",
+					namespace, name, l.Holder, l.ExpiresAt.Format(time.RFC3339), op.ID)
+				return nil
+			}
+		}
+
+		err = m.k8sClient.ScaleDeployment(ctx, namespace, name, 0)
+		m.notifier.NotifyScale(namespace, name, status.DesiredReplicas, 0, l.Holder, reason, err)
+		if err != nil {
+			return err
+		}
+		log.Printf("Lease of deployment %s/%s held by %s expired at %s, scaled to zero",
+			namespace, name, l.Holder, l.ExpiresAt.Format(time.RFC3339))
+	}
+	return Clear(ctx, m.k8sClient, namespace, name)
+}
+
+// warn notifies the lease holder once the lease is within the warning period
+// and records that it did, so an extended lease is announced again
+func (m *Manager) warn(ctx context.Context, d *k8s.DeploymentStatus, l *Lease) error {
+	if m.notifier == nil || l.ExpiresAt.Sub(m.now()) > m.expiryWarning {
+		return nil
+	}
+	expiresAt := l.ExpiresAt.UTC().Format(time.RFC3339)
+	if d.Annotations[AnnotationExpiryNotified] == expiresAt {
+		return nil
+	}
+
+	// Record first: a missed warning is better than one sent on every run
+	err := m.k8sClient.PatchDeploymentMetadata(ctx, d.Namespace, d.Name, nil, map[string]*string{AnnotationExpiryNotified: &expiresAt})
+	if err != nil {
+		return err
+	}
+	m.notifier.Notify(notify.Event{
+		Type:             notify.EventLeaseExpiring,
+		Namespace:        d.Namespace,
+		Name:             d.Name,
+		PreviousReplicas: d.DesiredReplicas,
+		Principal:        l.Holder,
+		Reason:           "scale-up lease is about to expire",
+		LeaseExpiresAt:   &l.ExpiresAt,
+	})
+	return nil
+}
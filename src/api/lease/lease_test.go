@@ -0,0 +1,274 @@
+package lease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	future := now.Add(3 * time.Hour)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		duration  string
+		expiresAt *time.Time
+		want      time.Time
+		wantErr   string
+	}{
+		{name: "no lease"},
+		{name: "duration", duration: "2h", want: now.Add(2 * time.Hour)},
+		{name: "expires at", expiresAt: &future, want: future},
+		{name: "both", duration: "2h", expiresAt: &future, wantErr: "only one of"},
+		{name: "invalid duration", duration: "two hours", wantErr: "invalid duration"},
+		{name: "in the past", expiresAt: &past, wantErr: "in the future"},
+		{name: "negative duration", duration: "-1h", wantErr: "in the future"},
+		{name: "too long", duration: "200h", wantErr: "may not be longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.duration, tt.expiresAt, now, DefaultMaxDuration)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromAnnotations(t *testing.T) {
+	l, ok := FromAnnotations(map[string]string{
+		AnnotationExpiresAt: "2025-07-14T11:00:00Z",
+		AnnotationHolder:    "alice",
+	})
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, 7, 14, 11, 0, 0, 0, time.UTC), l.ExpiresAt)
+	assert.Equal(t, "alice", l.Holder)
+
+	_, ok = FromAnnotations(map[string]string{AnnotationExpiresAt: "tomorrow"})
+	assert.False(t, ok)
+
+	_, ok = FromAnnotations(nil)
+	assert.False(t, ok)
+}
+
+func leasedStatus(name string, replicas int32, expiresAt string) *k8s.DeploymentStatus {
+	status := mocks.MockDeploymentStatus(name, "test-ns", replicas, replicas)
+	status.Labels = map[string]string{LabelLeased: "true"}
+	status.Annotations = map[string]string{AnnotationExpiresAt: expiresAt, AnnotationHolder: "alice"}
+	return status
+}
+
+func TestRevertExpired(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	manager := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	expired := leasedStatus("expired-app", 2, "2025-07-14T11:00:00Z")
+	active := leasedStatus("active-app", 1, "2025-07-14T13:00:00Z")
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{expired, active}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "expired-app").Return(expired, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "test-ns", "expired-app", int32(0)).Return(nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "expired-app",
+		map[string]*string{LabelLeased: nil},
+		map[string]*string{AnnotationExpiresAt: nil, AnnotationHolder: nil, AnnotationExpiryNotified: nil},
+	).Return(nil)
+
+	// Test
+	err := manager.RevertExpired(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, "test-ns", "active-app", mock.Anything)
+}
+
+func TestRevertExpired_ExtendedMeanwhile(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	manager := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	// Mock expectations - the lease was extended after the list
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{leasedStatus("app", 1, "2025-07-14T11:00:00Z")}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "app").
+		Return(leasedStatus("app", 1, "2025-07-14T14:00:00Z"), nil)
+
+	// Test
+	err := manager.RevertExpired(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevertExpired_SkipsLockedDeployment(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	manager := NewManager(mockClient, locker, policy.NewEngine(nil, policy.NewConfig()))
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	release, err := locker.Acquire(context.Background(), lock.Key("test-ns", "app"), false)
+	require.NoError(t, err)
+	defer release()
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{leasedStatus("app", 1, "2025-07-14T11:00:00Z")}, nil)
+
+	// Test
+	err = manager.RevertExpired(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	mockClient.AssertNotCalled(t, "GetDeploymentStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevertExpired_Protected(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	manager := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	protected := leasedStatus("app", 2, "2025-07-14T11:00:00Z")
+	protected.Annotations[policy.AnnotationProtected] = "true"
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{protected}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "app").Return(protected, nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "app",
+		map[string]*string{LabelLeased: nil},
+		map[string]*string{AnnotationExpiresAt: nil, AnnotationHolder: nil, AnnotationExpiryNotified: nil},
+	).Return(nil)
+
+	// Test
+	err := manager.RevertExpired(context.Background())
+
+	// Assert: the lease is released but the deployment keeps running
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// fakeDrainer drains every deployment, holding the lock until done is called
+type fakeDrainer struct {
+	started []string
+	done    func()
+}
+
+func (d *fakeDrainer) StartDeclared(ctx context.Context, status *k8s.DeploymentStatus, reason, startedBy string, release func()) (*operation.Operation, error) {
+	d.started = append(d.started, startedBy+": "+reason)
+	d.done = release
+	return &operation.Operation{ID: "op-1"}, nil
+}
+
+func TestRevertExpired_Drained(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	locker := lock.NewLocalLocker()
+	manager := NewManager(mockClient, locker, policy.NewEngine(nil, policy.NewConfig()))
+	drainer := &fakeDrainer{}
+	manager.SetDrainer(drainer)
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	expired := leasedStatus("app", 2, "2025-07-14T11:00:00Z")
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{expired}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "test-ns", "app").Return(expired, nil)
+
+	// Test
+	err := manager.RevertExpired(context.Background())
+
+	// Assert: the drain owns the lock and scales to zero itself
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice: lease held by alice expired"}, drainer.started)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	_, err = locker.Acquire(context.Background(), lock.Key("test-ns", "app"), false)
+	assert.ErrorIs(t, err, lock.ErrLocked)
+	drainer.done()
+}
+
+func TestRevertExpired_WarnsBeforeExpiry(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	manager := NewManager(mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()))
+	manager.SetNotifier(notify.NewNotifier(fake.NewSimpleClientset(), notify.NewConfig()))
+	manager.now = func() time.Time { return time.Date(2025, 7, 14, 12, 0, 0, 0, time.UTC) }
+
+	expiring := leasedStatus("expiring-app", 1, "2025-07-14T12:10:00Z")
+	notified := leasedStatus("notified-app", 1, "2025-07-14T12:05:00Z")
+	notified.Annotations[AnnotationExpiryNotified] = "2025-07-14T12:05:00Z"
+	later := leasedStatus("later-app", 1, "2025-07-14T13:00:00Z")
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", LabelLeased+"=true").
+		Return([]*k8s.DeploymentStatus{expiring, notified, later}, nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "test-ns", "expiring-app", map[string]*string(nil),
+		mock.MatchedBy(func(annotations map[string]*string) bool {
+			return *annotations[AnnotationExpiryNotified] == "2025-07-14T12:10:00Z"
+		}),
+	).Return(nil)
+
+	// Test
+	err := manager.RevertExpired(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "PatchDeploymentMetadata", 1)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExtend(t *testing.T) {
+	now := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	current := now.Add(time.Hour)
+	later := now.Add(5 * time.Hour)
+
+	got, err := Extend(current, "2h", nil, now, DefaultMaxDuration)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(3*time.Hour), got)
+
+	got, err = Extend(current, "", &later, now, DefaultMaxDuration)
+	assert.NoError(t, err)
+	assert.Equal(t, later, got)
+
+	// An expired lease that has not been reverted yet is extended from now
+	got, err = Extend(now.Add(-time.Hour), "30m", nil, now, DefaultMaxDuration)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Minute), got)
+
+	_, err = Extend(current, "", nil, now, DefaultMaxDuration)
+	assert.ErrorContains(t, err, "is required")
+
+	_, err = Extend(current, "-1h", nil, now, DefaultMaxDuration)
+	assert.ErrorContains(t, err, "must be positive")
+
+	_, err = Extend(current, "168h", nil, now, DefaultMaxDuration)
+	assert.ErrorContains(t, err, "may not be longer than")
+}
@@ -0,0 +1,253 @@
+package lock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// Default lease configuration values
+const (
+	DefaultLeaseNamespace = "scale-system"
+	DefaultLeaseDuration  = 30 * time.Second
+	DefaultRetryInterval  = 500 * time.Millisecond
+
+	leaseNamePrefix         = "scale-lock-"
+	groupLeaseNamePrefix    = "scale-group-lock-"
+	nodePoolLeaseNamePrefix = "scale-nodepool-lock-"
+	cronJobLeaseNamePrefix  = "scale-cronjob-lock-"
+	leaseTargetAnnotation   = "scale-to-zero.io/lock-target"
+	maxLeaseNameLength      = 253
+)
+
+// LeaseConfig holds configuration for the cluster-wide lease locker
+type LeaseConfig struct {
+	// Namespace where Lease objects are created
+	Namespace string
+	// Identity of this replica, recorded as the lease holder
+	Identity string
+	// LeaseDuration after which an unrenewed lease may be taken over
+	LeaseDuration time.Duration
+	// RetryInterval between attempts when waiting for a held lease
+	RetryInterval time.Duration
+}
+
+// NewLeaseConfig returns the default lease configuration. The caller sets
+// the namespace and identity of the pod from the application configuration.
+func NewLeaseConfig() *LeaseConfig {
+	identity, _ := os.Hostname()
+	return &LeaseConfig{
+		Namespace:     DefaultLeaseNamespace,
+		Identity:      identity,
+		LeaseDuration: DefaultLeaseDuration,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// LeaseLocker is a cluster-wide Locker backed by coordination.k8s.io Leases,
+// used when several replicas of the API are running
+type LeaseLocker struct {
+	clientset kubernetes.Interface
+	config    *LeaseConfig
+	now       func() time.Time
+}
+
+// NewLeaseLocker creates a new lease-based locker
+func NewLeaseLocker(clientset kubernetes.Interface, config *LeaseConfig) *LeaseLocker {
+	return &LeaseLocker{
+		clientset: clientset,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// Acquire implements Locker
+func (l *LeaseLocker) Acquire(ctx context.Context, key string, wait bool) (func(), error) {
+	name := leaseName(key)
+
+	for {
+		acquired, err := l.tryAcquire(ctx, name, key)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		if !wait {
+			return nil, ErrLocked
+		}
+
+		select {
+		case <-time.After(l.config.RetryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go l.renew(name, stop, done)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			l.release(name)
+		})
+	}, nil
+}
+
+// tryAcquire creates the lease or takes over an expired one
+func (l *LeaseLocker) tryAcquire(ctx context.Context, name, key string) (bool, error) {
+	leases := l.clientset.CoordinationV1().Leases(l.config.Namespace)
+	now := metav1.NewMicroTime(l.now())
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   l.config.Namespace,
+				Annotations: map[string]string{leaseTargetAnnotation: key},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(l.config.Identity),
+				LeaseDurationSeconds: ptr.To(int32(l.config.LeaseDuration.Seconds())),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to create lease %s: %w", name, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get lease %s: %w", name, err)
+	}
+
+	if l.isHeld(lease) {
+		return false, nil
+	}
+
+	lease.Spec.HolderIdentity = ptr.To(l.config.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(l.config.LeaseDuration.Seconds()))
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+
+	// The update carries the resourceVersion we read, so a concurrent
+	// takeover by another replica results in a conflict
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update lease %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// isHeld reports whether the lease is held by another replica and not yet expired
+func (l *LeaseLocker) isHeld(lease *coordinationv1.Lease) bool {
+	holder := ptr.Deref(lease.Spec.HolderIdentity, "")
+	if holder == "" || holder == l.config.Identity {
+		return false
+	}
+	if lease.Spec.RenewTime == nil {
+		return false
+	}
+
+	duration := time.Duration(ptr.Deref(lease.Spec.LeaseDurationSeconds, 0)) * time.Second
+	return l.now().Before(lease.Spec.RenewTime.Add(duration))
+}
+
+// renew keeps the lease alive until stop is closed
+func (l *LeaseLocker) renew(name string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.config.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.config.LeaseDuration/3)
+			leases := l.clientset.CoordinationV1().Leases(l.config.Namespace)
+			lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+			if err == nil && ptr.Deref(lease.Spec.HolderIdentity, "") == l.config.Identity {
+				lease.Spec.RenewTime = ptr.To(metav1.NewMicroTime(l.now()))
+				_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+			}
+			cancel()
+			if err != nil {
+				log.Printf("Warning: failed to renew lease %s/%s: %v", l.config.Namespace, name, err)
+			}
+		}
+	}
+}
+
+// release deletes the lease if this replica still holds it
+func (l *LeaseLocker) release(name string) {
+	// The request context may already be cancelled, so use a fresh one
+	ctx, cancel := context.WithTimeout(context.Background(), l.config.LeaseDuration)
+	defer cancel()
+
+	leases := l.clientset.CoordinationV1().Leases(l.config.Namespace)
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			log.Printf("Warning: failed to get lease %s/%s for release: %v", l.config.Namespace, name, err)
+		}
+		return
+	}
+	if ptr.Deref(lease.Spec.HolderIdentity, "") != l.config.Identity {
+		return
+	}
+
+	err = leases.Delete(ctx, name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Printf("Warning: failed to release lease %s/%s: %v", l.config.Namespace, name, err)
+	}
+}
+
+// leaseName returns a valid Lease name for the lock key. Group, node pool and
+// CronJob keys get their own prefixes so they cannot share a Lease with a
+// deployment.
+func leaseName(key string) string {
+	prefix, name := leaseNamePrefix, key
+	if group, ok := strings.CutPrefix(key, groupKeyPrefix); ok {
+		prefix, name = groupLeaseNamePrefix, group
+	} else if pool, ok := strings.CutPrefix(key, nodePoolKeyPrefix); ok {
+		prefix, name = nodePoolLeaseNamePrefix, pool
+	} else if cronJob, ok := strings.CutPrefix(key, cronJobKeyPrefix); ok {
+		prefix, name = cronJobLeaseNamePrefix, cronJob
+	}
+	if name := prefix + strings.ReplaceAll(name, "/", "."); len(name) <= maxLeaseNameLength && validation.IsDNS1123Subdomain(name) == nil {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return prefix + hex.EncodeToString(sum[:16])
+}
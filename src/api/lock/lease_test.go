@@ -0,0 +1,125 @@
+package lock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func testLeaseConfig(identity string) *LeaseConfig {
+	return &LeaseConfig{
+		Namespace:     "scale-system",
+		Identity:      identity,
+		LeaseDuration: 30 * time.Second,
+		RetryInterval: 10 * time.Millisecond,
+	}
+}
+
+func existingLease(holder string, renewed time.Time) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseName("test-ns/test-app"),
+			Namespace: "scale-system",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(holder),
+			LeaseDurationSeconds: ptr.To(int32(30)),
+			RenewTime:            ptr.To(metav1.NewMicroTime(renewed)),
+		},
+	}
+}
+
+func TestLeaseLocker_AcquireAndRelease(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	locker := NewLeaseLocker(clientset, testLeaseConfig("replica-1"))
+
+	release, err := locker.Acquire(context.Background(), "test-ns/test-app", false)
+	require.NoError(t, err)
+
+	lease, err := clientset.CoordinationV1().Leases("scale-system").Get(context.Background(), "scale-lock-test-ns.test-app", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+	assert.Equal(t, "test-ns/test-app", lease.Annotations[leaseTargetAnnotation])
+
+	release()
+
+	_, err = clientset.CoordinationV1().Leases("scale-system").Get(context.Background(), "scale-lock-test-ns.test-app", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestLeaseLocker_HeldByOtherReplica(t *testing.T) {
+	clientset := fake.NewSimpleClientset(existingLease("replica-2", time.Now()))
+	locker := NewLeaseLocker(clientset, testLeaseConfig("replica-1"))
+
+	_, err := locker.Acquire(context.Background(), "test-ns/test-app", false)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = locker.Acquire(ctx, "test-ns/test-app", true)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLeaseLocker_TakeOverExpiredLease(t *testing.T) {
+	clientset := fake.NewSimpleClientset(existingLease("replica-2", time.Now().Add(-time.Minute)))
+	locker := NewLeaseLocker(clientset, testLeaseConfig("replica-1"))
+
+	release, err := locker.Acquire(context.Background(), "test-ns/test-app", false)
+	require.NoError(t, err)
+	defer release()
+
+	lease, err := clientset.CoordinationV1().Leases("scale-system").Get(context.Background(), leaseName("test-ns/test-app"), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+}
+
+func TestLeaseLocker_ReleaseKeepsLeaseOfOtherHolder(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	locker := NewLeaseLocker(clientset, testLeaseConfig("replica-1"))
+
+	release, err := locker.Acquire(context.Background(), "test-ns/test-app", false)
+	require.NoError(t, err)
+
+	// Simulate another replica taking over after our lease expired
+	leases := clientset.CoordinationV1().Leases("scale-system")
+	lease, err := leases.Get(context.Background(), leaseName("test-ns/test-app"), metav1.GetOptions{})
+	require.NoError(t, err)
+	lease.Spec.HolderIdentity = ptr.To("replica-2")
+	_, err = leases.Update(context.Background(), lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	release()
+
+	lease, err = leases.Get(context.Background(), leaseName("test-ns/test-app"), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "replica-2", *lease.Spec.HolderIdentity)
+}
+
+func TestLeaseName(t *testing.T) {
+	assert.Equal(t, "scale-lock-project-b.sample-app-b", leaseName("project-b/sample-app-b"))
+
+	long := leaseName("ns/" + strings.Repeat("a", 253))
+	assert.LessOrEqual(t, len(long), maxLeaseNameLength)
+	assert.True(t, strings.HasPrefix(long, leaseNamePrefix))
+
+	// A group never shares a Lease with a deployment of the same dotted name
+	assert.Equal(t, "scale-group-lock-x", leaseName(GroupKey("x")))
+	assert.Equal(t, "scale-lock-group.x", leaseName(Key("group", "x")))
+	assert.NotEqual(t, leaseName(GroupKey("x")), leaseName(Key("group", "x")))
+
+	assert.Equal(t, "scale-nodepool-lock-gpu", leaseName(NodePoolKey("gpu")))
+	assert.Equal(t, "scale-cronjob-lock-ns.nightly", leaseName(CronJobKey("ns", "nightly")))
+
+	invalid := leaseName(GroupKey("Inference_B"))
+	assert.True(t, strings.HasPrefix(invalid, groupLeaseNamePrefix))
+	assert.Len(t, invalid, len(groupLeaseNamePrefix)+32)
+}
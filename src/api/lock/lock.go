@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrLocked is returned when a lock is held by another operation and the caller
+// chose not to wait for it
+var ErrLocked = errors.New("another scale operation is in progress for this deployment")
+
+// Locker serializes operations on a single key such as "namespace/name"
+type Locker interface {
+	// Acquire obtains the lock for key and returns a function that releases it.
+	// If wait is false and the lock is already held, ErrLocked is returned.
+	// If wait is true, Acquire blocks until the lock is obtained or ctx is done.
+	Acquire(ctx context.Context, key string, wait bool) (func(), error)
+}
+
+// Key returns the lock key for a deployment
+func Key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// NamespaceKey returns the lock key for operations that read or change the
+// state of a whole namespace, such as quota accounting
+func NamespaceKey(namespace string) string {
+	return namespace
+}
+
+// groupKeyPrefix marks group keys. A colon cannot appear in a namespace or
+// deployment name, so group keys never collide with deployment keys.
+const groupKeyPrefix = "group:"
+
+// GroupKey returns the lock key held while a scale group is being scaled
+func GroupKey(name string) string {
+	return groupKeyPrefix + name
+}
+
+// nodePoolKeyPrefix marks node pool keys, which likewise never collide with
+// deployment or group keys
+const nodePoolKeyPrefix = "nodepool:"
+
+// NodePoolKey returns the lock key held while an AKS node pool is being scaled
+func NodePoolKey(name string) string {
+	return nodePoolKeyPrefix + name
+}
+
+// cronJobKeyPrefix marks CronJob keys, so a CronJob and a deployment of the
+// same name are locked independently
+const cronJobKeyPrefix = "cronjob:"
+
+// CronJobKey returns the lock key for a CronJob
+func CronJobKey(namespace, name string) string {
+	return cronJobKeyPrefix + Key(namespace, name)
+}
+
+// LocalLocker is an in-process Locker
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]*localLock
+}
+
+type localLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewLocalLocker creates a new in-process locker
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{
+		locks: make(map[string]*localLock),
+	}
+}
+
+// Acquire implements Locker
+func (l *LocalLocker) Acquire(ctx context.Context, key string, wait bool) (func(), error) {
+	entry := l.ref(key)
+
+	if wait {
+		select {
+		case entry.ch <- struct{}{}:
+		case <-ctx.Done():
+			l.unref(key)
+			return nil, ctx.Err()
+		}
+	} else {
+		select {
+		case entry.ch <- struct{}{}:
+		default:
+			l.unref(key)
+			return nil, ErrLocked
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-entry.ch
+			l.unref(key)
+		})
+	}, nil
+}
+
+// ref returns the lock entry for key, creating it if needed
+func (l *LocalLocker) ref(key string) *localLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &localLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	return entry
+}
+
+// unref drops a reference to the lock entry and removes it when unused
+func (l *LocalLocker) unref(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.locks[key]
+	if !ok {
+		return
+	}
+	entry.refs--
+	if entry.refs == 0 {
+		delete(l.locks, key)
+	}
+}
+
+// MultiLocker acquires several lockers in order, e.g. an in-process lock
+// followed by a cluster-wide lease
+type MultiLocker struct {
+	lockers []Locker
+}
+
+// NewMultiLocker creates a locker that holds all of the given lockers
+func NewMultiLocker(lockers ...Locker) *MultiLocker {
+	return &MultiLocker{lockers: lockers}
+}
+
+// Acquire implements Locker
+func (m *MultiLocker) Acquire(ctx context.Context, key string, wait bool) (func(), error) {
+	releases := make([]func(), 0, len(m.lockers))
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, locker := range m.lockers {
+		release, err := locker.Acquire(ctx, key, wait)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+
+	return releaseAll, nil
+}
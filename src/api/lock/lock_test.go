@@ -0,0 +1,129 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalLocker_RejectWhenHeld(t *testing.T) {
+	locker := NewLocalLocker()
+
+	release, err := locker.Acquire(context.Background(), Key("ns", "app"), false)
+	require.NoError(t, err)
+
+	_, err = locker.Acquire(context.Background(), Key("ns", "app"), false)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	// A different deployment is independent
+	releaseOther, err := locker.Acquire(context.Background(), Key("ns", "other"), false)
+	require.NoError(t, err)
+	releaseOther()
+
+	release()
+
+	release, err = locker.Acquire(context.Background(), Key("ns", "app"), false)
+	require.NoError(t, err)
+	release()
+	assert.Empty(t, locker.locks)
+}
+
+func TestLocalLocker_WaitQueuesUntilReleased(t *testing.T) {
+	locker := NewLocalLocker()
+
+	release, err := locker.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		releaseWaiter, err := locker.Acquire(context.Background(), "ns/app", true)
+		if err == nil {
+			close(acquired)
+			releaseWaiter()
+		}
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("waiter acquired the lock while it was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter did not acquire the lock after release")
+	}
+}
+
+func TestLocalLocker_WaitHonoursContext(t *testing.T) {
+	locker := NewLocalLocker()
+
+	release, err := locker.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = locker.Acquire(ctx, "ns/app", true)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLocalLocker_ReleaseIsIdempotent(t *testing.T) {
+	locker := NewLocalLocker()
+
+	release, err := locker.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+	release()
+	release()
+
+	release, err = locker.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+	release()
+}
+
+type failingLocker struct {
+	err error
+}
+
+func (f failingLocker) Acquire(ctx context.Context, key string, wait bool) (func(), error) {
+	return nil, f.err
+}
+
+func TestMultiLocker_ReleasesOnPartialFailure(t *testing.T) {
+	local := NewLocalLocker()
+	multi := NewMultiLocker(local, failingLocker{err: errors.New("boom")})
+
+	_, err := multi.Acquire(context.Background(), "ns/app", false)
+	assert.EqualError(t, err, "boom")
+
+	// The local lock must have been released
+	release, err := local.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+	release()
+}
+
+func TestMultiLocker_AcquireAll(t *testing.T) {
+	first := NewLocalLocker()
+	second := NewLocalLocker()
+	multi := NewMultiLocker(first, second)
+
+	release, err := multi.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+
+	_, err = second.Acquire(context.Background(), "ns/app", false)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	release()
+
+	releaseSecond, err := second.Acquire(context.Background(), "ns/app", false)
+	require.NoError(t, err)
+	releaseSecond()
+}
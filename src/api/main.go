@@ -8,12 +8,33 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/torumakabe/aks-scale-to-zero/api/approval"
+	"github.com/torumakabe/aks-scale-to-zero/api/azure"
+	"github.com/torumakabe/aks-scale-to-zero/api/batch"
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+	"github.com/torumakabe/aks-scale-to-zero/api/drain"
+	"github.com/torumakabe/aks-scale-to-zero/api/drift"
+	"github.com/torumakabe/aks-scale-to-zero/api/group"
 	"github.com/torumakabe/aks-scale-to-zero/api/handlers"
+	"github.com/torumakabe/aks-scale-to-zero/api/hibernate"
 	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/metrics"
 	"github.com/torumakabe/aks-scale-to-zero/api/middleware"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/operation"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/prepull"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+	"github.com/torumakabe/aks-scale-to-zero/api/readiness"
+	"github.com/torumakabe/aks-scale-to-zero/api/scalepolicy"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	"github.com/torumakabe/aks-scale-to-zero/api/webhook"
+	"k8s.io/client-go/kubernetes"
 )
 
 func main() {
@@ -22,13 +43,41 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize Kubernetes client
-	k8sClient, err := k8s.NewClient()
+	// Load configuration from CONFIG_FILE and the environment
+	watcher, err := config.NewWatcher(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cfg := watcher.Current()
+
+	// Initialize Kubernetes client, limited to the workloads that opted in
+	scope, err := k8s.NewScope(cfg.Kubernetes.ManagedSelector, cfg.Kubernetes.DeniedNamespaces)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	k8sClient, err := k8s.NewClient(scope)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize Kubernetes client: %v", err)
 		// Continue without Kubernetes client for development
 	}
 
+	// Clientset shared by subsystems that talk to the API server directly
+	var clientset kubernetes.Interface
+	if k8sClient != nil {
+		clientset = k8sClient.GetClientset()
+	}
+
+	// Every replica change, whether requested through the API or made by a
+	// background worker, counts against the per-deployment limits
+	scaleThrottle := throttle.NewLimiter(throttleConfig(cfg))
+	if k8sClient != nil {
+		k8sClient.SetThrottle(scaleThrottle)
+	}
+
+	// Background workers run until the server shuts down
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
 	// Create Gin router
 	router := gin.New()
 
@@ -38,33 +87,281 @@ func main() {
 	router.Use(gin.Recovery())
 
 	// Initialize auth config
-	authConfig := middleware.NewAuthConfig()
+	authConfig := &middleware.AuthConfig{}
+	authConfig.Update(cfg.Auth.APIKey, cfg.Auth.Principals(), cfg.Auth.ExcludedPaths, cfg.Auth.ExcludedPrefixes)
 	router.Use(middleware.APIKeyAuth(authConfig))
 
+	// Initialize rate limiter
+	rateLimiter := middleware.NewRateLimiter(rateLimitConfig(cfg))
+
+	// Initialize idempotency store for retried POST requests
+	idempotencyStore := middleware.NewIdempotencyStore(cfg.IdempotencyTTL.Duration)
+
 	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler(k8sClient)
-	deploymentHandler := handlers.NewDeploymentHandler(k8sClient)
+	healthHandler := handlers.NewHealthHandler(k8sClient, handlers.WithReadinessNamespace(cfg.ReadinessNamespace))
+
+	// Serialize scale operations per deployment. When several replicas run,
+	// a cluster-wide Lease is held in addition to the in-process lock.
+	var locker lock.Locker = lock.NewLocalLocker()
+	if cfg.Kubernetes.DistributedLock && clientset != nil {
+		leaseConfig := lock.NewLeaseConfig()
+		leaseConfig.Namespace = cfg.Kubernetes.Namespace
+		leaseConfig.Identity = cfg.Kubernetes.Identity
+		leaseLocker := lock.NewLeaseLocker(clientset, leaseConfig)
+		locker = lock.NewMultiLocker(locker, leaseLocker)
+	}
+
+	// Scaling policies from the policy ConfigMap and Deployment annotations
+	policyEngine := policy.NewEngine(clientset, policy.NewConfig())
+
+	deploymentOptions := []handlers.DeploymentHandlerOption{
+		handlers.WithLocker(locker),
+		handlers.WithPolicyEngine(policyEngine),
+		handlers.WithConfigWatcher(watcher),
+	}
+
+	// Namespace replica and GPU quotas from the quota ConfigMap
+	var quotaEngine *quota.Engine
+	if clientset != nil {
+		quotaEngine = quota.NewEngine(clientset, quota.NewConfig())
+		deploymentOptions = append(deploymentOptions, handlers.WithQuotaEngine(quotaEngine))
+	}
+
+	// Scale-ups above the policy approval threshold wait for a second person
+	var approvalStore *approval.Store
+	if clientset != nil {
+		approvalConfig := approval.NewConfig()
+		approvalConfig.Namespace = cfg.Kubernetes.Namespace
+		approvalConfig.TTL = cfg.Policies.ApprovalTTL.Duration
+		approvalConfig.Approvers = cfg.Auth.Approvers
+		approvalConfig.Principals = authConfig.PrincipalNames()
+		approvalStore = approval.NewStore(clientset, approvalConfig)
+		deploymentOptions = append(deploymentOptions, handlers.WithApprovalStore(approvalStore))
+		go approvalStore.Run(backgroundCtx, approval.DefaultSweepInterval)
+	}
+
+	// Webhooks subscribed in the notification ConfigMap are told about scale
+	// operations, expiring leases and idle shutdowns
+	var notifier *notify.Notifier
+	if clientset != nil {
+		notifier = notify.NewNotifier(clientset, notify.NewConfig())
+		deploymentOptions = append(deploymentOptions, handlers.WithNotifier(notifier))
+		go notifier.Run(backgroundCtx)
+	}
+
+	// Deployments declaring a drain finish in-flight requests before they are
+	// scaled to zero, tracked as background operations. Drains cut short by a
+	// restart are resumed.
+	operations := operation.NewStore(operation.DefaultRetention)
+	var drains *drain.Manager
+	if k8sClient != nil {
+		drains = drain.NewManager(k8sClient, operations, drain.NewConfig())
+		drains.SetNotifier(notifier)
+		go drains.Recover(backgroundCtx, locker)
+		deploymentOptions = append(deploymentOptions, handlers.WithDrainManager(drains))
+	}
+
+	// Scale-ups with a lease are scaled back to zero, or drained, when it
+	// expires, unless a scaling policy protects the deployment
+	deploymentOptions = append(deploymentOptions, handlers.WithMaxLeaseDuration(cfg.Policies.MaxLeaseDuration.Duration))
+	if k8sClient != nil {
+		leaseManager := lease.NewManager(k8sClient, locker, policyEngine)
+		leaseManager.SetDrainer(drains)
+		leaseManager.SetNotifier(notifier)
+		go leaseManager.Run(backgroundCtx, lease.DefaultInterval)
+	}
+
+	// Whole namespaces are hibernated and woken with the same locks and policies
+	var hibernation *hibernate.Manager
+	if k8sClient != nil {
+		hibernation = hibernate.NewManager(k8sClient, locker, policyEngine, quotaEngine)
+		hibernation.SetNotifier(notifier)
+		hibernation.SetDrainManager(drains)
+	}
+
+	// CronJobs and Jobs that keep node pools busy are quieted with the same
+	// locks, policies, limits and notifications
+	var batches *batch.Manager
+	if k8sClient != nil {
+		batches = batch.NewManager(k8sClient, locker, policyEngine)
+		batches.SetThrottle(scaleThrottle)
+		batches.SetNotifier(notifier)
+	}
+
+	// Scale groups bring related deployments up in dependency order. Their
+	// progress is tracked as background operations, which stop on shutdown.
+	var groups *group.Manager
+	if k8sClient != nil {
+		groups = group.NewManager(k8sClient, locker, policyEngine, quotaEngine, operations, group.NewConfig())
+		groups.SetNotifier(notifier)
+		groups.SetDrainManager(drains)
+		groups.SetContext(backgroundCtx)
+	}
+
+	// ScaleToZeroPolicy resources declare schedules and idle timeouts through
+	// GitOps. Placeholder pods pre-warm nodes ahead of scheduled scale-ups.
+	if k8sClient != nil {
+		dynamicClient, err := k8s.NewDynamicClient()
+		if err != nil {
+			log.Printf("Warning: Failed to initialize dynamic client: %v", err)
+		} else {
+			prewarmer := prewarm.NewManager(clientset, prewarm.NewConfig())
+			policyController := scalepolicy.NewController(dynamicClient, k8sClient, locker, policyEngine, quotaEngine, prewarmer)
+			policyController.SetNotifier(notifier)
+			policyController.SetDefaultTimezone(cfg.Schedules.DefaultTimezone)
+			go policyController.Run(backgroundCtx, cfg.Schedules.Interval.Duration)
+		}
+	}
+
+	// Readiness checks declared on deployments verify that the application,
+	// e.g. a model, is ready after a scale-up
+	if clientset != nil {
+		deploymentOptions = append(deploymentOptions, handlers.WithReadinessChecker(readiness.NewChecker(clientset, readiness.DefaultTimeout)))
+	}
+
+	// Images of opted-in deployments are pulled onto new nodes while they wake
+	if k8sClient != nil {
+		prepuller := prepull.NewManager(k8sClient, prepull.NewConfig())
+		go prepuller.Run(backgroundCtx, prepull.DefaultInterval)
+		deploymentOptions = append(deploymentOptions, handlers.WithPrepuller(prepuller))
+	}
+
+	// Replica changes made outside the API are reported, and reverted for
+	// deployments that opt in
+	registry := metrics.NewRegistry()
+	var detector *drift.Detector
+	if k8sClient != nil {
+		detector = drift.NewDetector(k8sClient, locker, registry)
+		detector.SetNotifier(notifier)
+		go detector.Run(backgroundCtx, drift.DefaultInterval)
+	}
+
+	// AKS node pools are scaled through Azure Resource Manager with the pod's
+	// managed identity
+	var agentPools azure.AgentPoolsClient
+	if cfg.Azure.Enabled() {
+		agentPools = azure.NewClient(azure.Config{
+			SubscriptionID: cfg.Azure.SubscriptionID,
+			ResourceGroup:  cfg.Azure.ResourceGroup,
+			ClusterName:    cfg.Azure.ClusterName,
+			Endpoint:       cfg.Azure.ResourceManagerEndpoint,
+			NodePools:      cfg.Azure.NodePools,
+		}, azure.NewManagedIdentityCredential(cfg.Azure.ClientID, cfg.Azure.ResourceManagerEndpoint))
+	}
+
+	deploymentHandler := handlers.NewDeploymentHandler(k8sClient, deploymentOptions...)
+	quotaHandler := handlers.NewQuotaHandler(k8sClient, quotaEngine)
+	namespaceHandler := handlers.NewNamespaceHandler(hibernation)
+	groupHandler := handlers.NewGroupHandler(k8sClient, groups)
+	operationHandler := handlers.NewOperationHandler(operations)
+	approvalHandler := handlers.NewApprovalHandler(approvalStore, deploymentHandler)
+	configHandler := handlers.NewConfigHandler(watcher)
+	driftHandler := handlers.NewDriftHandler(detector)
+	nodePoolHandler := handlers.NewNodePoolHandler(agentPools, locker, watcher)
+	batchHandler := handlers.NewBatchHandler(batches)
+
+	// Optional admission webhook rejecting replica changes made around the API,
+	// served over TLS on its own port
+	var validator *webhook.Validator
+	var webhookServer *http.Server
+	if cfg.Webhook.Enabled && k8sClient != nil {
+		certificates, err := webhook.NewCertificateReloader(cfg.Webhook.CertFile, cfg.Webhook.KeyFile)
+		if err != nil {
+			log.Fatalf("Invalid webhook configuration: %v", err)
+		}
+		validator = webhook.NewValidator(k8sClient, cfg.Webhook.Mode, cfg.Webhook.AllowedUsers)
+		webhookHandler := handlers.NewWebhookHandler(validator)
+
+		webhookRouter := gin.New()
+		webhookRouter.Use(middleware.RequestIDMiddleware())
+		webhookRouter.Use(middleware.StructuredLogger())
+		webhookRouter.Use(gin.Recovery())
+		webhookRouter.POST("/validate/deployments", webhookHandler.ValidateDeployment)
+
+		webhookServer = &http.Server{
+			Addr:      fmt.Sprintf(":%s", cfg.Webhook.Port),
+			Handler:   webhookRouter,
+			TLSConfig: certificates.TLSConfig(),
+		}
+	}
+
+	// Apply the settings that are safe to change while serving requests
+	watcher.OnChange(func(cfg *config.Config) {
+		authConfig.Update(cfg.Auth.APIKey, cfg.Auth.Principals(), cfg.Auth.ExcludedPaths, cfg.Auth.ExcludedPrefixes)
+		rateLimiter.SetConfig(rateLimitConfig(cfg))
+		scaleThrottle.SetConfig(throttleConfig(cfg))
+		if approvalStore != nil {
+			approvalStore.SetApprovalPolicy(cfg.Policies.ApprovalTTL.Duration, cfg.Auth.Approvers, authConfig.PrincipalNames())
+		}
+		if validator != nil {
+			validator.SetPolicy(cfg.Webhook.Mode, cfg.Webhook.AllowedUsers)
+		}
+	})
+	go watcher.Run(backgroundCtx, config.DefaultWatchInterval)
 
 	// Health check endpoints (no auth required)
 	router.GET("/health", healthHandler.Health)
 	router.GET("/ready", healthHandler.Ready)
 
+	// Prometheus metrics (no auth required by default)
+	router.GET("/metrics", gin.WrapH(registry.Handler()))
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+	// Replayed responses are served before rate limiting so retries are not throttled
+	v1.Use(middleware.Idempotency(idempotencyStore))
+	v1.Use(middleware.RateLimit(rateLimiter))
 	{
 		deployments := v1.Group("/deployments")
 		{
+			deployments.GET("", deploymentHandler.ListDeployments)
+			deployments.POST("/scale-to-zero", deploymentHandler.BulkScaleToZero)
+			deployments.POST("/scale-up", deploymentHandler.BulkScaleUp)
 			deployments.POST("/:namespace/:name/scale-to-zero", deploymentHandler.ScaleToZero)
 			deployments.POST("/:namespace/:name/scale-up", deploymentHandler.ScaleUp)
 			deployments.GET("/:namespace/:name/status", deploymentHandler.GetStatus)
+			deployments.POST("/:namespace/:name/lease/extend", deploymentHandler.ExtendLease)
+			deployments.DELETE("/:namespace/:name/lease", deploymentHandler.ReleaseLease)
+		}
+
+		namespaces := v1.Group("/namespaces")
+		{
+			namespaces.GET("/:namespace/deployments", deploymentHandler.ListDeployments)
+			namespaces.GET("/:namespace/quota", quotaHandler.GetQuota)
+			namespaces.GET("/:namespace/status", namespaceHandler.GetStatus)
+			namespaces.POST("/:namespace/hibernate", namespaceHandler.Hibernate)
+			namespaces.POST("/:namespace/wake", namespaceHandler.Wake)
+			namespaces.POST("/:namespace/cronjobs/:name/suspend", batchHandler.SuspendCronJob)
+			namespaces.POST("/:namespace/cronjobs/:name/resume", batchHandler.ResumeCronJob)
+		}
+
+		scaleGroups := v1.Group("/groups")
+		{
+			scaleGroups.GET("", groupHandler.ListGroups)
+			scaleGroups.GET("/:name", groupHandler.GetGroup)
+			scaleGroups.POST("/:name/scale-up", groupHandler.ScaleUp)
+			scaleGroups.POST("/:name/scale-to-zero", groupHandler.ScaleToZero)
+		}
+
+		v1.POST("/nodepools/:name/scale", nodePoolHandler.Scale)
+		v1.GET("/nodepools/:name/jobs", batchHandler.ListJobs)
+		v1.POST("/nodepools/:name/jobs/terminate", batchHandler.TerminateJobs)
+
+		v1.GET("/operations/:id", operationHandler.GetOperation)
+		v1.GET("/config", configHandler.GetConfig)
+		v1.GET("/drift", driftHandler.ListDrift)
+
+		approvals := v1.Group("/approvals")
+		{
+			approvals.GET("", approvalHandler.ListApprovals)
+			approvals.GET("/:id", approvalHandler.GetApproval)
+			approvals.POST("/:id/approve", approvalHandler.Approve)
+			approvals.POST("/:id/reject", approvalHandler.Reject)
 		}
 	}
 
 	// Server configuration
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := cfg.Port
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", port),
@@ -79,20 +376,52 @@ func main() {
 		}
 	}()
 
+	if webhookServer != nil {
+		go func() {
+			log.Printf("Admission webhook starting on port %s in %s mode", cfg.Webhook.Port, cfg.Webhook.Mode)
+			if err := webhookServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start admission webhook: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopBackground()
 
-	// Give outstanding requests 30 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Give outstanding requests the configured time to complete
+	ctx, cancel := context.WithTimeout(context.Background(), watcher.Current().ShutdownTimeout.Duration)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
+	if webhookServer != nil {
+		if err := webhookServer.Shutdown(ctx); err != nil {
+			log.Printf("Admission webhook forced to shutdown: %v", err)
+		}
+	}
 
 	log.Println("Server exited")
 }
+
+// rateLimitConfig converts the rate limit settings for the middleware
+func rateLimitConfig(cfg *config.Config) *middleware.RateLimitConfig {
+	return &middleware.RateLimitConfig{
+		PrincipalRatePerMinute: cfg.RateLimit.PrincipalPerMinute,
+		PrincipalBurst:         cfg.RateLimit.PrincipalBurst,
+	}
+}
+
+// throttleConfig converts the per-deployment rate limit settings
+func throttleConfig(cfg *config.Config) *throttle.Config {
+	return &throttle.Config{
+		RatePerMinute:     cfg.RateLimit.TargetPerMinute,
+		Burst:             cfg.RateLimit.TargetBurst,
+		DirectionCooldown: cfg.RateLimit.DirectionCooldown.Duration,
+	}
+}
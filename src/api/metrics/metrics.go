@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types in the Prometheus text format
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+// Registry holds counters and gauges and writes them in the Prometheus text
+// exposition format
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// family is a metric name with its series, keyed by label values
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	series     map[string]*series
+}
+
+// series is one combination of label values
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// Counter is a metric that only goes up
+type Counter struct {
+	registry *Registry
+	family   *family
+}
+
+// Gauge is a metric that can be set to any value
+type Gauge struct {
+	registry *Registry
+	family   *family
+}
+
+// Counter registers a counter. Registering the same name again returns the
+// existing counter.
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	return &Counter{registry: r, family: r.register(name, help, typeCounter, labelNames)}
+}
+
+// Gauge registers a gauge. Registering the same name again returns the
+// existing gauge.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{registry: r, family: r.register(name, help, typeGauge, labelNames)}
+}
+
+// register returns the family with name, creating it if needed
+func (r *Registry) register(name, help, metricType string, labelNames []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.metricType != metricType || len(f.labelNames) != len(labelNames) {
+			panic(fmt.Sprintf("metric %s registered twice with different types or labels", name))
+		}
+		return f
+	}
+	f := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		series:     map[string]*series{},
+	}
+	r.families[name] = f
+	return f
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a non-negative value to the series with the given label values
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic("counter cannot decrease")
+	}
+	c.registry.update(c.family, labelValues, func(s *series) { s.value += value })
+}
+
+// Set sets the series with the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.registry.update(g.family, labelValues, func(s *series) { s.value = value })
+}
+
+// Delete removes the series with the given label values
+func (g *Gauge) Delete(labelValues ...string) {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	delete(g.family.series, seriesKey(labelValues))
+}
+
+// Reset removes every series of the gauge
+func (g *Gauge) Reset() {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	g.family.series = map[string]*series{}
+}
+
+// update applies fn to a series, creating it if needed
+func (r *Registry) update(f *family, labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(labelValues)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// Write writes every metric in the text exposition format, sorted by name
+// and label values
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.metricType)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			b.WriteString(f.name)
+			if len(f.labelNames) > 0 {
+				b.WriteByte('{')
+				for i, labelName := range f.labelNames {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", labelName, escapeLabel(s.labelValues[i]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(formatValue(s.value))
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the metrics for scraping
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// formatValue formats a sample value
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and newlines in help text
+func escapeHelp(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(value)
+}
+
+// escapeLabel escapes backslashes, quotes and newlines in label values
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
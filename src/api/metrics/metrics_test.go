@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	// Setup
+	registry := NewRegistry()
+	drift := registry.Gauge("scale_api_drift", "Deployments whose replicas differ from the recorded state.", "namespace", "deployment")
+	corrected := registry.Counter("scale_api_drift_corrected_total", "Drift corrections.")
+
+	// Test
+	drift.Set(1, "project-b", "app-b")
+	drift.Set(1, "project-a", `app "a"`)
+	drift.Set(1, "project-c", "app-c")
+	drift.Delete("project-c", "app-c")
+	corrected.Inc()
+	corrected.Add(2)
+
+	// Assert
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	assert.Equal(t, `# HELP scale_api_drift Deployments whose replicas differ from the recorded state.
+# TYPE scale_api_drift gauge
+scale_api_drift{namespace="project-a",deployment="app \"a\""} 1
+scale_api_drift{namespace="project-b",deployment="app-b"} 1
+# HELP scale_api_drift_corrected_total Drift corrections.
+# TYPE scale_api_drift_corrected_total counter
+scale_api_drift_corrected_total 3
+`, b.String())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	// Setup
+	registry := NewRegistry()
+	registry.Gauge("up", "Whether the API is up.").Set(1)
+
+	// Test
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), "up 1\n")
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	registry := NewRegistry()
+	first := registry.Counter("requests_total", "Requests.", "code")
+	second := registry.Counter("requests_total", "Requests.", "code")
+
+	first.Inc("200")
+	second.Inc("200")
+
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	assert.Contains(t, b.String(), `requests_total{code="200"} 2`)
+	assert.Panics(t, func() { registry.Gauge("requests_total", "Requests.") })
+}
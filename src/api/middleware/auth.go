@@ -2,46 +2,73 @@ package middleware
 
 import (
 	"net/http"
-	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthConfig holds authentication configuration
+// PrincipalKey is the gin context key under which the authenticated caller is stored
+const PrincipalKey = "Principal"
+
+// DefaultPrincipal is the principal of callers using the shared API_KEY
+const DefaultPrincipal = "api-key"
+
+// AuthConfig holds authentication configuration. Update replaces it while
+// requests are being served.
 type AuthConfig struct {
-	APIKey           string
+	mu sync.RWMutex
+
+	APIKey string
+	// APIKeys maps per-user API keys to the principal name they authenticate as
+	APIKeys          map[string]string
 	ExcludedPaths    []string
 	ExcludedPrefixes []string
 }
 
-// NewAuthConfig creates a new auth configuration from environment
-func NewAuthConfig() *AuthConfig {
-	return &AuthConfig{
-		APIKey: os.Getenv("API_KEY"),
-		ExcludedPaths: []string{
-			"/health",
-			"/ready",
-		},
-		ExcludedPrefixes: []string{
-			"/swagger/",
-			"/docs/",
-		},
+// Update replaces the keys and excluded paths
+func (config *AuthConfig) Update(apiKey string, apiKeys map[string]string, excludedPaths, excludedPrefixes []string) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.APIKey = apiKey
+	config.APIKeys = apiKeys
+	config.ExcludedPaths = excludedPaths
+	config.ExcludedPrefixes = excludedPrefixes
+}
+
+// PrincipalNames returns the sorted names callers can authenticate as. It is
+// empty when authentication is disabled.
+func (config *AuthConfig) PrincipalNames() []string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	var names []string
+	if config.APIKey != "" {
+		names = append(names, DefaultPrincipal)
+	}
+	for _, name := range config.APIKeys {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
 // APIKeyAuth returns a middleware for API key authentication
 func APIKeyAuth(config *AuthConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		config.mu.RLock()
+		disabled := config.APIKey == "" && len(config.APIKeys) == 0
+		excluded := isPathExcluded(c.Request.URL.Path, config.ExcludedPaths, config.ExcludedPrefixes)
+		config.mu.RUnlock()
+
 		// Check if authentication is disabled
-		if config.APIKey == "" {
+		if disabled {
 			c.Next()
 			return
 		}
 
 		// Check if path is excluded
-		path := c.Request.URL.Path
-		if isPathExcluded(path, config.ExcludedPaths, config.ExcludedPrefixes) {
+		if excluded {
 			c.Next()
 			return
 		}
@@ -67,7 +94,8 @@ func APIKeyAuth(config *AuthConfig) gin.HandlerFunc {
 		}
 
 		// Validate API key
-		if parts[1] != config.APIKey {
+		principal, ok := config.principalFor(parts[1])
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid API key",
 			})
@@ -76,10 +104,31 @@ func APIKeyAuth(config *AuthConfig) gin.HandlerFunc {
 		}
 
 		// Authentication successful
+		c.Set(PrincipalKey, principal)
 		c.Next()
 	}
 }
 
+// principalFor returns the principal authenticated by an API key
+func (config *AuthConfig) principalFor(key string) (string, bool) {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	if config.APIKey != "" && key == config.APIKey {
+		return DefaultPrincipal, true
+	}
+	principal, ok := config.APIKeys[key]
+	return principal, ok
+}
+
+// Principal returns the identity of the caller for rate limiting and auditing.
+// Unauthenticated callers are identified by their client IP.
+func Principal(c *gin.Context) string {
+	if principal := c.GetString(PrincipalKey); principal != "" {
+		return principal
+	}
+	return "ip:" + c.ClientIP()
+}
+
 // isPathExcluded checks if a path should be excluded from authentication
 func isPathExcluded(path string, excludedPaths []string, excludedPrefixes []string) bool {
 	// Check exact path matches
@@ -103,6 +103,36 @@ func TestAPIKeyAuth(t *testing.T) {
 	}
 }
 
+func TestAPIKeyAuth_PerUserKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := &AuthConfig{}
+	config.Update("shared-key", map[string]string{"alice-key": "alice", "bob-key": "bob"}, nil, nil)
+
+	router := gin.New()
+	router.Use(APIKeyAuth(config))
+	router.GET("/api/v1/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, Principal(c))
+	})
+
+	for key, principal := range map[string]string{
+		"alice-key":  "alice",
+		"bob-key":    "bob",
+		"shared-key": DefaultPrincipal,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/whoami", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, principal, w.Body.String())
+	}
+	assert.Equal(t, []string{"alice", DefaultPrincipal, "bob"}, config.PrincipalNames())
+
+	config.Update("", nil, nil, nil)
+	assert.Empty(t, config.PrincipalNames())
+}
+
 func TestRequireNamespace(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idempotency headers
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// DefaultIdempotencyTTL is how long a stored response is replayed
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the size of client supplied keys
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore keeps the first response for each idempotency key
+type IdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	completed   bool
+	statusCode  int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// NewIdempotencyStore creates a new in-memory idempotency store
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// Idempotency returns a middleware that replays the stored response for retried
+// POST requests carrying the same Idempotency-Key header. Reusing a key for a
+// different request is rejected with 422.
+func Idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		var bodyBytes []byte
+		if c.Request.Body != nil {
+			bodyBytes, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		}
+
+		// Keys are scoped to the caller so different principals cannot
+		// observe each other's responses
+		storeKey := Principal(c) + "\x00" + key
+		fingerprint := requestFingerprint(c.Request, bodyBytes)
+
+		entry, found := store.begin(storeKey, fingerprint)
+		if found {
+			switch {
+			case entry.fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "Idempotency-Key was already used with a different request",
+				})
+				c.Abort()
+			case !entry.completed:
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+				c.Abort()
+			default:
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Data(entry.statusCode, entry.contentType, entry.body)
+				c.Abort()
+			}
+			return
+		}
+
+		capture := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		c.Writer = capture
+
+		// A panicking handler never finishes the entry; release it so the
+		// client can retry instead of getting 409 until the key expires
+		finished := false
+		defer func() {
+			if !finished {
+				store.release(storeKey)
+			}
+		}()
+
+		c.Next()
+
+		store.finish(storeKey, capture.Status(), capture.Header().Get("Content-Type"), capture.body.Bytes())
+		finished = true
+	}
+}
+
+// begin returns the existing entry for key, or records a new in-progress entry
+func (s *IdempotencyStore) begin(key, fingerprint string) (idempotencyEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		return *entry, true
+	}
+
+	s.entries[key] = &idempotencyEntry{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(s.ttl),
+	}
+	return idempotencyEntry{}, false
+}
+
+// finish stores the response for key. Responses that a retry could reasonably
+// change (server errors, rate limiting, lock conflicts) are discarded so the
+// client may retry with the same key.
+func (s *IdempotencyStore) finish(key string, statusCode int, contentType string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !isReplayable(statusCode) {
+		delete(s.entries, key)
+		return
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	entry.completed = true
+	entry.statusCode = statusCode
+	entry.contentType = contentType
+	entry.body = append([]byte(nil), body...)
+}
+
+// release forgets the in-progress entry for key
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && !entry.completed {
+		delete(s.entries, key)
+	}
+}
+
+// sweep evicts expired entries. Callers must hold s.mu.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// isReplayable reports whether a response should be stored for replay
+func isReplayable(statusCode int) bool {
+	switch {
+	case statusCode >= http.StatusInternalServerError:
+		return false
+	case statusCode == http.StatusTooManyRequests, statusCode == http.StatusConflict:
+		return false
+	default:
+		return true
+	}
+}
+
+// requestFingerprint identifies a request by method, path, query and body.
+// JSON bodies are normalized so formatting differences do not matter.
+func requestFingerprint(req *http.Request, body []byte) string {
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if normalized, err := json.Marshal(parsed); err == nil {
+			body = normalized
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "?" + req.URL.RawQuery + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupIdempotencyRouter(store *IdempotencyStore, statusCode int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Idempotency(store))
+
+	router.POST("/deployments/:namespace/:name/scale-up", func(c *gin.Context) {
+		*calls++
+		c.JSON(statusCode, gin.H{"call": *calls})
+	})
+	return router
+}
+
+func postWithKey(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	calls := 0
+	router := setupIdempotencyRouter(NewIdempotencyStore(time.Hour), http.StatusOK, &calls)
+
+	first := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 1, "reason": "ci"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotencyReplayedHeader))
+
+	// Retry with different formatting of the same JSON body
+	second := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"reason":"ci","replicas":1}`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_RejectsKeyReuseWithDifferentBody(t *testing.T) {
+	calls := 0
+	router := setupIdempotencyRouter(NewIdempotencyStore(time.Hour), http.StatusOK, &calls)
+
+	postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 1, "reason": "ci"}`)
+
+	w := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 2, "reason": "ci"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "different request")
+
+	w = postWithKey(router, "/deployments/ns/other/scale-up", "key-1", `{"replicas": 1, "reason": "ci"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	calls := 0
+	router := setupIdempotencyRouter(NewIdempotencyStore(time.Hour), http.StatusOK, &calls)
+
+	postWithKey(router, "/deployments/ns/app/scale-up", "", `{}`)
+	postWithKey(router, "/deployments/ns/app/scale-up", "", `{}`)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_ServerErrorsAreNotStored(t *testing.T) {
+	calls := 0
+	router := setupIdempotencyRouter(NewIdempotencyStore(time.Hour), http.StatusInternalServerError, &calls)
+
+	postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{}`)
+	w := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{}`)
+	assert.Empty(t, w.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_ExpiresAfterTTL(t *testing.T) {
+	calls := 0
+	now := time.Now()
+	store := NewIdempotencyStore(time.Minute)
+	store.now = func() time.Time { return now }
+	router := setupIdempotencyRouter(store, http.StatusOK, &calls)
+
+	postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{}`)
+	now = now.Add(2 * time.Minute)
+
+	// After expiry the key may be reused, even with a different body
+	w := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 3}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_InProgressRequest(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	entered := make(chan struct{})
+	proceed := make(chan struct{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Idempotency(store))
+	router.POST("/slow", func(c *gin.Context) {
+		close(entered)
+		<-proceed
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		postWithKey(router, "/slow", "key-1", `{}`)
+		close(done)
+	}()
+	<-entered
+
+	w := postWithKey(router, "/slow", "key-1", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "still being processed")
+
+	close(proceed)
+	<-done
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewIdempotencyStore(time.Hour)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(Idempotency(store))
+
+	calls := 0
+	router.POST("/deployments/:namespace/:name/scale-up", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{"call": calls})
+	})
+
+	first := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 1}`)
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+
+	// The retry runs the handler instead of reporting the request in progress
+	second := postWithKey(router, "/deployments/ns/app/scale-up", "key-1", `{"replicas": 1}`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_KeyTooLong(t *testing.T) {
+	calls := 0
+	router := setupIdempotencyRouter(NewIdempotencyStore(time.Hour), http.StatusOK, &calls)
+
+	w := postWithKey(router, "/deployments/ns/app/scale-up", strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, calls)
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Default rate limiting values
+const (
+	DefaultPrincipalRatePerMinute = 60
+	DefaultPrincipalBurst         = 20
+
+	// limiterIdleTTL is how long an unused bucket is kept before it is evicted
+	limiterIdleTTL = 10 * time.Minute
+)
+
+// RateLimitConfig holds rate limiting configuration. Limits per deployment are
+// enforced where deployments are scaled, see the throttle package.
+type RateLimitConfig struct {
+	// PrincipalRatePerMinute and PrincipalBurst size the bucket shared by all
+	// requests of one caller
+	PrincipalRatePerMinute float64
+	PrincipalBurst         int
+}
+
+// RateLimiter tracks token buckets per principal
+type RateLimiter struct {
+	config *RateLimitConfig
+	now    func() time.Time
+
+	mu         sync.Mutex
+	principals map[string]*bucket
+	lastSweep  time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a new rate limiter
+func NewRateLimiter(config *RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:     config,
+		now:        time.Now,
+		principals: make(map[string]*bucket),
+	}
+}
+
+// RateLimit returns a middleware that rejects requests exceeding the caller's
+// limit with 429 Too Many Requests and a Retry-After header
+func RateLimit(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := Principal(c)
+		if wait, ok := limiter.allowPrincipal(principal); !ok {
+			tooManyRequests(c, wait, "Rate limit exceeded for caller")
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetConfig replaces the limits. Buckets are recreated so the new limits
+// apply to every caller right away.
+func (l *RateLimiter) SetConfig(config *RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+	l.principals = make(map[string]*bucket)
+}
+
+// allowPrincipal consumes a token from the caller's bucket
+func (l *RateLimiter) allowPrincipal(principal string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.take(l.principals, principal, l.config.PrincipalRatePerMinute, l.config.PrincipalBurst)
+}
+
+// take consumes a token from the named bucket, creating it on first use.
+// Callers must hold l.mu.
+func (l *RateLimiter) take(buckets map[string]*bucket, key string, perMinute float64, burst int) (time.Duration, bool) {
+	if perMinute <= 0 {
+		return 0, true
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(perMinute/60), burst)}
+		buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return time.Minute, false
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// sweep evicts idle buckets so the map does not grow without bound.
+// Callers must hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterIdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.principals {
+		if now.Sub(b.lastSeen) > limiterIdleTTL {
+			delete(l.principals, key)
+		}
+	}
+}
+
+// tooManyRequests aborts the request with 429 and a Retry-After header
+func tooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	retryAfter := SetRetryAfter(c, wait)
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": retryAfter,
+	})
+	c.Abort()
+}
+
+// SetRetryAfter sets the Retry-After header to wait in whole seconds, at
+// least one, and returns the value
+func SetRetryAfter(c *gin.Context, wait time.Duration) int {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	return retryAfter
+}
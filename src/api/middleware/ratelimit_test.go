@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRateLimitRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimit(limiter))
+
+	router.GET("/deployments/:namespace/:name/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+	return router
+}
+
+func doRequest(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_PrincipalBucket(t *testing.T) {
+	limiter := NewRateLimiter(&RateLimitConfig{
+		PrincipalRatePerMinute: 1,
+		PrincipalBurst:         2,
+	})
+	router := setupRateLimitRouter(limiter)
+
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/deployments/ns/app/status").Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/deployments/ns/other/status").Code)
+
+	w := doRequest(router, "GET", "/deployments/ns/app/status")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Rate limit exceeded for caller")
+}
+
+func TestRateLimit_SetConfig(t *testing.T) {
+	limiter := NewRateLimiter(&RateLimitConfig{
+		PrincipalRatePerMinute: 1,
+		PrincipalBurst:         1,
+	})
+	router := setupRateLimitRouter(limiter)
+
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/deployments/ns/app/status").Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/deployments/ns/app/status").Code)
+
+	// Raised limits apply to callers that were already limited
+	limiter.SetConfig(&RateLimitConfig{
+		PrincipalRatePerMinute: 1,
+		PrincipalBurst:         3,
+	})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/deployments/ns/app/status").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/deployments/ns/app/status").Code)
+}
+
+func TestPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/", nil)
+	c.Request.RemoteAddr = "10.0.0.5:1234"
+
+	assert.Equal(t, "ip:10.0.0.5", Principal(c))
+
+	c.Set(PrincipalKey, "api-key")
+	assert.Equal(t, "api-key", Principal(c))
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// ApprovalInfo contains information about a scale-up approval request
+type ApprovalInfo struct {
+	ID              string     `json:"id"`
+	Namespace       string     `json:"namespace"`
+	Deployment      string     `json:"deployment"`
+	Replicas        int32      `json:"replicas"`
+	Reason          string     `json:"reason"`
+	LeaseDuration   string     `json:"lease_duration,omitempty"`
+	LeaseExpiresAt  *time.Time `json:"lease_expires_at,omitempty"`
+	ApprovalReasons []string   `json:"approval_reasons,omitempty"`
+	RequestedBy     string     `json:"requested_by"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	DecidedBy       string     `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	Comment         string     `json:"comment,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// ApprovalDecisionRequest represents the request payload for approving or rejecting
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment" binding:"max=500" validate:"max=500"`
+}
+
+// ApprovalResponse represents the response for approval requests
+type ApprovalResponse struct {
+	Status    string        `json:"status"`
+	Message   string        `json:"message"`
+	Approval  *ApprovalInfo `json:"approval,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// ApprovalListResponse represents the response for listing approval requests
+type ApprovalListResponse struct {
+	Status    string         `json:"status"`
+	Message   string         `json:"message"`
+	Approvals []ApprovalInfo `json:"approvals"`
+	Timestamp time.Time      `json:"timestamp"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// BatchRequest represents the request payload for suspending or resuming a
+// CronJob and for terminating Jobs
+type BatchRequest struct {
+	Reason string `json:"reason" binding:"required,min=1,max=500"`
+}
+
+// CronJobInfo represents a CronJob
+type CronJobInfo struct {
+	Name             string     `json:"name"`
+	Namespace        string     `json:"namespace"`
+	Schedule         string     `json:"schedule"`
+	Suspended        bool       `json:"suspended"`
+	ActiveJobs       int32      `json:"active_jobs"`
+	LastScheduleTime *time.Time `json:"last_schedule_time,omitempty"`
+}
+
+// CronJobResponse represents the response for CronJob suspend and resume requests
+type CronJobResponse struct {
+	Status     string       `json:"status"`
+	Message    string       `json:"message"`
+	CronJob    *CronJobInfo `json:"cronjob,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Violations []string     `json:"violations,omitempty"`
+	Timestamp  time.Time    `json:"timestamp"`
+}
+
+// JobInfo represents an unfinished Job on a node pool
+type JobInfo struct {
+	Name       string     `json:"name"`
+	Namespace  string     `json:"namespace"`
+	CronJob    string     `json:"cronjob,omitempty"`
+	NodePool   string     `json:"node_pool"`
+	ActivePods int32      `json:"active_pods"`
+	StartTime  *time.Time `json:"start_time,omitempty"`
+}
+
+// JobResult represents the outcome of terminating one Job
+type JobResult struct {
+	Name       string   `json:"name"`
+	Namespace  string   `json:"namespace"`
+	Status     string   `json:"status"`
+	Message    string   `json:"message,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// JobListResponse represents the response for listing and terminating the
+// Jobs on a node pool
+type JobListResponse struct {
+	Status    string      `json:"status"`
+	Message   string      `json:"message"`
+	NodePool  string      `json:"node_pool"`
+	Jobs      []JobInfo   `json:"jobs,omitempty"`
+	Results   []JobResult `json:"results,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
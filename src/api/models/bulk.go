@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// Constants for bulk scale response status
+const (
+	// ResponseStatusPartial is returned when some, but not all, deployments
+	// of a bulk operation failed
+	ResponseStatusPartial = "partial"
+	// ResponseStatusSkipped marks deployments not attempted after a failure
+	// with ?failFast=true
+	ResponseStatusSkipped = "skipped"
+)
+
+// BulkScaleResult is the outcome of a bulk operation for one deployment
+type BulkScaleResult struct {
+	Namespace        string         `json:"namespace"`
+	Name             string         `json:"name"`
+	Status           string         `json:"status"`
+	HTTPStatus       int            `json:"http_status,omitempty"`
+	Message          string         `json:"message,omitempty"`
+	PreviousReplicas int32          `json:"previous_replicas"`
+	TargetReplicas   int32          `json:"target_replicas"`
+	Error            string         `json:"error,omitempty"`
+	Violations       []string       `json:"violations,omitempty"`
+	Approval         *ApprovalInfo  `json:"approval,omitempty"`
+	Operation        *OperationInfo `json:"operation,omitempty"`
+}
+
+// BulkScaleSummary counts the results of a bulk operation by status
+type BulkScaleSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Pending   int `json:"pending"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// BulkScaleResponse represents the response for bulk scaling operations
+type BulkScaleResponse struct {
+	Status        string            `json:"status"`
+	Message       string            `json:"message"`
+	LabelSelector string            `json:"label_selector,omitempty"`
+	Namespace     string            `json:"namespace,omitempty"`
+	DryRun        bool              `json:"dry_run,omitempty"`
+	Summary       *BulkScaleSummary `json:"summary,omitempty"`
+	Results       []BulkScaleResult `json:"results,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/config"
+)
+
+// ConfigResponse represents the effective configuration with secrets redacted
+type ConfigResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Source is the configuration file, empty when only the environment is used
+	Source    string         `json:"source,omitempty"`
+	LoadedAt  *time.Time     `json:"loaded_at,omitempty"`
+	Config    *config.Config `json:"config,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
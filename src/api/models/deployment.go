@@ -14,6 +14,17 @@ type ScaleRequest struct {
 type ScaleUpRequest struct {
 	Replicas int32  `json:"replicas" binding:"required,min=1" validate:"required,min=1"`
 	Reason   string `json:"reason" binding:"required,min=1,max=500" validate:"required,min=1,max=500"`
+	// Duration (e.g. "2h") or ExpiresAt time-box the scale-up: when the lease
+	// expires the deployment is scaled back to zero
+	Duration  string     `json:"duration,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// LeaseExtendRequest represents the request payload for extending a scale-up lease.
+// Duration is added to the current expiry; ExpiresAt replaces it.
+type LeaseExtendRequest struct {
+	Duration  string     `json:"duration,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // ScaleResponse represents the response for scaling operations
@@ -22,37 +33,91 @@ type ScaleResponse struct {
 	Message    string          `json:"message"`
 	Deployment *DeploymentInfo `json:"deployment,omitempty"`
 	Error      string          `json:"error,omitempty"`
+	Violations []string        `json:"violations,omitempty"`
+	Approval   *ApprovalInfo   `json:"approval,omitempty"`
+	Operation  *OperationInfo  `json:"operation,omitempty"`
 	Timestamp  time.Time       `json:"timestamp"`
 }
 
 // DeploymentInfo contains information about the deployment
 type DeploymentInfo struct {
-	Name             string     `json:"name"`
-	Namespace        string     `json:"namespace"`
-	Replicas         int32      `json:"replicas"`
-	PreviousReplicas int32      `json:"previous_replicas"`
-	CurrentReplicas  int32      `json:"current_replicas"`
-	TargetReplicas   int32      `json:"target_replicas"`
-	TargetStatus     string     `json:"target_status"`
-	ScaledAt         time.Time  `json:"scaled_at"`
-	ScaledBy         string     `json:"scaled_by,omitempty"`
-	Reason           string     `json:"reason,omitempty"`
-	ScalingReason    string     `json:"scaling_reason,omitempty"`
-	ScheduledScaleUp *time.Time `json:"scheduled_scale_up,omitempty"`
+	Name             string           `json:"name"`
+	Namespace        string           `json:"namespace"`
+	Replicas         int32            `json:"replicas"`
+	PreviousReplicas int32            `json:"previous_replicas"`
+	CurrentReplicas  int32            `json:"current_replicas"`
+	TargetReplicas   int32            `json:"target_replicas"`
+	TargetStatus     string           `json:"target_status"`
+	ScaledAt         time.Time        `json:"scaled_at"`
+	ScaledBy         string           `json:"scaled_by,omitempty"`
+	Reason           string           `json:"reason,omitempty"`
+	ScalingReason    string           `json:"scaling_reason,omitempty"`
+	ScheduledScaleUp *time.Time       `json:"scheduled_scale_up,omitempty"`
+	NodePool         string           `json:"node_pool,omitempty"`
+	DryRun           bool             `json:"dry_run,omitempty"`
+	PolicyDecisions  []string         `json:"policy_decisions,omitempty"`
+	LeaseExpiresAt   *time.Time       `json:"lease_expires_at,omitempty"`
+	Readiness        *ReadinessStatus `json:"readiness,omitempty"`
 }
 
 // DeploymentStatus represents the current status of a deployment
 type DeploymentStatus struct {
-	Name              string    `json:"name"`
-	Namespace         string    `json:"namespace"`
-	Deployment        string    `json:"deployment"`
-	CurrentReplicas   int32     `json:"current_replicas"`
-	DesiredReplicas   int32     `json:"desired_replicas"`
-	AvailableReplicas int32     `json:"available_replicas"`
-	Status            string    `json:"status"`
-	LastScaled        time.Time `json:"last_scaled,omitempty"`
-	LastScaleTime     time.Time `json:"last_scale_time,omitempty"`
-	Message           string    `json:"message,omitempty"`
+	Name              string           `json:"name"`
+	Namespace         string           `json:"namespace"`
+	Deployment        string           `json:"deployment"`
+	CurrentReplicas   int32            `json:"current_replicas"`
+	DesiredReplicas   int32            `json:"desired_replicas"`
+	AvailableReplicas int32            `json:"available_replicas"`
+	Status            string           `json:"status"`
+	NodePool          string           `json:"node_pool,omitempty"`
+	Lease             *LeaseInfo       `json:"lease,omitempty"`
+	Prepull           *PrepullStatus   `json:"prepull,omitempty"`
+	Readiness         *ReadinessStatus `json:"readiness,omitempty"`
+	DrainingSince     *time.Time       `json:"draining_since,omitempty"`
+	Autoscalers       []AutoscalerInfo `json:"autoscalers,omitempty"`
+	LastScaled        time.Time        `json:"last_scaled,omitempty"`
+	LastScaleTime     time.Time        `json:"last_scale_time,omitempty"`
+	Message           string           `json:"message,omitempty"`
+}
+
+// PrepullStatus reports the progress of pulling a deployment's images onto
+// the nodes of its node pool
+type PrepullStatus struct {
+	Images       []string  `json:"images"`
+	DesiredNodes int32     `json:"desired_nodes"`
+	PulledNodes  int32     `json:"pulled_nodes"`
+	FailedNodes  int32     `json:"failed_nodes,omitempty"`
+	Errors       []string  `json:"errors,omitempty"`
+	Complete     bool      `json:"complete"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// ReadinessStatus reports the readiness checks declared on a deployment.
+// Error is set when the checks could not be run, e.g. when they are invalid.
+type ReadinessStatus struct {
+	Ready     bool             `json:"ready"`
+	Checks    []ReadinessCheck `json:"checks,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	CheckedAt time.Time        `json:"checked_at,omitempty"`
+}
+
+// ReadinessCheck is the result of a readiness check against one target
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Target  string `json:"target,omitempty"`
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// AutoscalerInfo describes an HPA or KEDA ScaledObject attached to a
+// deployment. Paused is set while it is paused for a scale to zero.
+type AutoscalerInfo struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	MinReplicas int32  `json:"min_replicas"`
+	MaxReplicas int32  `json:"max_replicas"`
+	Paused      bool   `json:"paused"`
 }
 
 // DeploymentStatusResponse represents the response for deployment status requests
@@ -64,11 +129,29 @@ type DeploymentStatusResponse struct {
 	Timestamp  time.Time         `json:"timestamp"`
 }
 
+// LeaseInfo contains information about a time-boxed scale-up
+type LeaseInfo struct {
+	Namespace  string    `json:"namespace"`
+	Deployment string    `json:"deployment"`
+	Holder     string    `json:"holder,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LeaseResponse represents the response for lease operations
+type LeaseResponse struct {
+	Status    string     `json:"status"`
+	Message   string     `json:"message"`
+	Lease     *LeaseInfo `json:"lease,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
 // Constants for deployment status
 const (
 	StatusActive   = "active"
 	StatusInactive = "inactive"
 	StatusScaling  = "scaling"
+	StatusDraining = "draining"
 	StatusUnknown  = "unknown"
 )
 
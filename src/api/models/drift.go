@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// DeploymentDrift represents a deployment whose replicas were changed outside the API
+type DeploymentDrift struct {
+	Namespace        string    `json:"namespace"`
+	Deployment       string    `json:"deployment"`
+	RecordedReplicas int32     `json:"recorded_replicas"`
+	LiveReplicas     int32     `json:"live_replicas"`
+	RecordedAt       time.Time `json:"recorded_at,omitempty"`
+	DetectedAt       time.Time `json:"detected_at"`
+	Mode             string    `json:"mode"`
+	Corrected        bool      `json:"corrected"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// DriftResponse represents the response for drift requests
+type DriftResponse struct {
+	Status    string            `json:"status"`
+	Message   string            `json:"message"`
+	CheckedAt *time.Time        `json:"checked_at,omitempty"`
+	Drifts    []DeploymentDrift `json:"drifts"`
+	Error     string            `json:"error,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// GroupScaleRequest represents the request payload for scaling a scale group
+type GroupScaleRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// GroupMember represents a member of a scale group and its current state
+type GroupMember struct {
+	Namespace         string   `json:"namespace"`
+	Name              string   `json:"name"`
+	Stage             int      `json:"stage"`
+	Replicas          int32    `json:"replicas"`
+	MinAvailable      int32    `json:"min_available"`
+	DependsOn         []string `json:"depends_on,omitempty"`
+	CurrentReplicas   *int32   `json:"current_replicas,omitempty"`
+	AvailableReplicas *int32   `json:"available_replicas,omitempty"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// ScaleGroup represents a scale group definition
+type ScaleGroup struct {
+	Name             string        `json:"name"`
+	ReadinessTimeout string        `json:"readiness_timeout"`
+	Members          []GroupMember `json:"members"`
+}
+
+// GroupResponse represents the response for scale group requests
+type GroupResponse struct {
+	Status    string      `json:"status"`
+	Message   string      `json:"message"`
+	Group     *ScaleGroup `json:"group,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// GroupListResponse represents the response for listing scale groups
+type GroupListResponse struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	Groups    []string  `json:"groups"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// WorkloadResult is the outcome of hibernating or waking one workload
+type WorkloadResult struct {
+	Kind             string   `json:"kind"`
+	Name             string   `json:"name"`
+	Status           string   `json:"status"`
+	PreviousReplicas int32    `json:"previous_replicas"`
+	TargetReplicas   int32    `json:"target_replicas"`
+	Message          string   `json:"message,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	Violations       []string `json:"violations,omitempty"`
+	// Operation is the drain of a Deployment that declares one
+	Operation *OperationInfo `json:"operation,omitempty"`
+}
+
+// HibernationResponse represents the response for namespace hibernate and wake requests
+type HibernationResponse struct {
+	Status    string           `json:"status"`
+	Message   string           `json:"message"`
+	Namespace string           `json:"namespace"`
+	Results   []WorkloadResult `json:"results,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// WorkloadState represents a workload of a namespace and what waking restores
+type WorkloadState struct {
+	Kind            string     `json:"kind"`
+	Name            string     `json:"name"`
+	Replicas        int32      `json:"replicas"`
+	ReadyReplicas   int32      `json:"ready_replicas"`
+	Hibernated      bool       `json:"hibernated"`
+	RestoreReplicas *int32     `json:"restore_replicas,omitempty"`
+	HibernatedAt    *time.Time `json:"hibernated_at,omitempty"`
+}
+
+// NamespaceStatus represents the hibernation state of a namespace
+type NamespaceStatus struct {
+	Namespace string          `json:"namespace"`
+	State     string          `json:"state"`
+	Workloads []WorkloadState `json:"workloads"`
+}
+
+// NamespaceStatusResponse represents the response for namespace status requests
+type NamespaceStatusResponse struct {
+	Status    string           `json:"status"`
+	Message   string           `json:"message"`
+	Namespace *NamespaceStatus `json:"namespace,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// NodePoolScaleRequest represents the request payload for scaling an AKS node pool.
+// At least one of Count and MinCount is required.
+type NodePoolScaleRequest struct {
+	// Count is the number of nodes
+	Count *int32 `json:"count,omitempty" binding:"omitempty,min=0"`
+	// MinCount is the cluster autoscaler minimum. Raising it above the current
+	// count adds nodes right away.
+	MinCount *int32 `json:"min_count,omitempty" binding:"omitempty,min=0"`
+	Reason   string `json:"reason" binding:"required,min=1,max=500"`
+}
+
+// NodePool represents an AKS node pool
+type NodePool struct {
+	Name              string `json:"name"`
+	Mode              string `json:"mode"`
+	VMSize            string `json:"vm_size"`
+	Count             int32  `json:"count"`
+	MinCount          *int32 `json:"min_count,omitempty"`
+	MaxCount          *int32 `json:"max_count,omitempty"`
+	AutoScaling       bool   `json:"autoscaling"`
+	ProvisioningState string `json:"provisioning_state"`
+}
+
+// NodePoolScaleResponse represents the response for node pool scale requests
+type NodePoolScaleResponse struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	NodePool  *NodePool `json:"node_pool,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// OperationStep represents one step of a background operation
+type OperationStep struct {
+	Stage     int    `json:"stage"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// OperationInfo represents a background operation and its progress
+type OperationInfo struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Target     string          `json:"target"`
+	Reason     string          `json:"reason,omitempty"`
+	StartedBy  string          `json:"started_by,omitempty"`
+	Status     string          `json:"status"`
+	Steps      []OperationStep `json:"steps"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// OperationResponse represents the response for operation requests
+type OperationResponse struct {
+	Status    string         `json:"status"`
+	Message   string         `json:"message"`
+	Operation *OperationInfo `json:"operation,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
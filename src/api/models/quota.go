@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// NamespaceQuota represents the quota of a namespace and its current usage
+type NamespaceQuota struct {
+	Namespace    string            `json:"namespace"`
+	MaxReplicas  *int32            `json:"max_replicas,omitempty"`
+	MaxGPUs      *int64            `json:"max_gpus,omitempty"`
+	UsedReplicas int32             `json:"used_replicas"`
+	UsedGPUs     int64             `json:"used_gpus"`
+	Deployments  []DeploymentUsage `json:"deployments"`
+}
+
+// DeploymentUsage represents what one deployment contributes to namespace usage
+type DeploymentUsage struct {
+	Name       string `json:"name"`
+	Replicas   int32  `json:"replicas"`
+	GPUsPerPod int64  `json:"gpus_per_pod"`
+	GPUs       int64  `json:"gpus"`
+}
+
+// QuotaResponse represents the response for namespace quota requests
+type QuotaResponse struct {
+	Status    string          `json:"status"`
+	Message   string          `json:"message"`
+	Quota     *NamespaceQuota `json:"quota,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
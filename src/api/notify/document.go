@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// SinkType selects how events are formatted for a receiver
+type SinkType string
+
+// Supported sink types
+const (
+	// SinkGeneric posts the event as JSON signed with HMAC-SHA256
+	SinkGeneric SinkType = "generic"
+	// SinkSlack posts a message to a Slack incoming webhook
+	SinkSlack SinkType = "slack"
+	// SinkTeams posts an Adaptive Card to a Microsoft Teams webhook
+	SinkTeams SinkType = "teams"
+)
+
+// Keys of the Secret a sink refers to
+const (
+	// SecretKeyURL holds the webhook URL, which usually embeds a credential
+	SecretKeyURL = "url"
+	// SecretKeySigningKey holds the HMAC key of a generic sink
+	SecretKeySigningKey = "signingKey"
+)
+
+// Sink is a webhook receiving events
+type Sink struct {
+	Type SinkType `json:"type"`
+	URL  string   `json:"url,omitempty"`
+	// SecretRef names a Secret in the ConfigMap namespace holding the URL
+	// and, for generic sinks, the signing key
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+// Subscription sends events of the listed types in the listed namespaces to
+// sinks. Empty namespaces or events match all of them.
+type Subscription struct {
+	Namespaces []string    `json:"namespaces,omitempty"`
+	Events     []EventType `json:"events,omitempty"`
+	Sinks      []string    `json:"sinks"`
+}
+
+// Document is the notification ConfigMap content
+type Document struct {
+	Sinks         map[string]*Sink `json:"sinks,omitempty"`
+	Subscriptions []Subscription   `json:"subscriptions,omitempty"`
+}
+
+// Validate checks that the sink can be delivered to
+func (s *Sink) Validate() error {
+	switch s.Type {
+	case SinkGeneric:
+		if s.SecretRef == "" {
+			return fmt.Errorf("secretRef with a %s is required for generic sinks", SecretKeySigningKey)
+		}
+	case SinkSlack, SinkTeams:
+		if s.URL == "" && s.SecretRef == "" {
+			return fmt.Errorf("url or secretRef is required")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid url %q", s.URL)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the subscription selects the event
+func (s *Subscription) matches(event Event) bool {
+	if len(s.Namespaces) > 0 && !slices.Contains(s.Namespaces, event.Namespace) {
+		return false
+	}
+	return len(s.Events) == 0 || slices.Contains(s.Events, event.Type)
+}
+
+// sinksFor returns the names of the sinks subscribed to an event, each once
+func (d *Document) sinksFor(event Event) []string {
+	seen := map[string]bool{}
+	var names []string
+	for i := range d.Subscriptions {
+		if !d.Subscriptions[i].matches(event) {
+			continue
+		}
+		for _, name := range d.Subscriptions[i].Sinks {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseDocument parses and validates a notification document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse notification document: %w", err)
+	}
+
+	for name, sink := range doc.Sinks {
+		if sink == nil {
+			return nil, fmt.Errorf("invalid sinks.%s: sink is empty", name)
+		}
+		if err := sink.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid sinks.%s: %w", name, err)
+		}
+	}
+	for i, sub := range doc.Subscriptions {
+		if len(sub.Sinks) == 0 {
+			return nil, fmt.Errorf("invalid subscriptions[%d]: at least one sink is required", i)
+		}
+		for _, name := range sub.Sinks {
+			if doc.Sinks[name] == nil {
+				return nil, fmt.Errorf("invalid subscriptions[%d]: unknown sink %q", i, name)
+			}
+		}
+		for _, eventType := range sub.Events {
+			if !slices.Contains(EventTypes, eventType) {
+				return nil, fmt.Errorf("invalid subscriptions[%d]: unknown event %q", i, eventType)
+			}
+		}
+	}
+	return doc, nil
+}
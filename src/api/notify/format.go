@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Sign returns the signature of a generic delivery: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" with the sink's signing key, prefixed with "sha256=".
+// Receivers should recompute it and reject stale timestamps.
+func Sign(key, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Title returns a short headline for an event
+func (e Event) Title() string {
+	switch e.Type {
+	case EventScaled:
+		if e.Replicas == 0 {
+			return fmt.Sprintf("%s/%s scaled to zero", e.Namespace, e.Name)
+		}
+		return fmt.Sprintf("%s/%s scaled to %d replicas", e.Namespace, e.Name, e.Replicas)
+	case EventFailed:
+		return fmt.Sprintf("Failed to scale %s/%s", e.Namespace, e.Name)
+	case EventLeaseExpiring:
+		return fmt.Sprintf("Lease of %s/%s is expiring", e.Namespace, e.Name)
+	case EventIdleShutdown:
+		return fmt.Sprintf("%s/%s scaled to zero after being idle", e.Namespace, e.Name)
+	}
+	return fmt.Sprintf("%s: %s/%s", e.Type, e.Namespace, e.Name)
+}
+
+// Summary returns a one-line description of an event
+func (e Event) Summary() string {
+	var text string
+	switch e.Type {
+	case EventFailed:
+		text = fmt.Sprintf("Deployment %s/%s could not be scaled from %d to %d replicas", e.Namespace, e.Name, e.PreviousReplicas, e.Replicas)
+	case EventLeaseExpiring:
+		text = fmt.Sprintf("Deployment %s/%s will be scaled to zero", e.Namespace, e.Name)
+		if e.LeaseExpiresAt != nil {
+			text += " at " + e.LeaseExpiresAt.UTC().Format(time.RFC3339)
+		}
+	default:
+		text = fmt.Sprintf("Deployment %s/%s scaled from %d to %d replicas", e.Namespace, e.Name, e.PreviousReplicas, e.Replicas)
+	}
+	if e.Principal != "" {
+		text += " by " + e.Principal
+	}
+	if e.Reason != "" {
+		text += ": " + e.Reason
+	}
+	if e.Error != "" {
+		text += " (" + e.Error + ")"
+	}
+	return text
+}
+
+// facts returns the details shown in chat messages
+func (e Event) facts() [][2]string {
+	facts := [][2]string{
+		{"Namespace", e.Namespace},
+		{"Deployment", e.Name},
+		{"Replicas", strconv.Itoa(int(e.PreviousReplicas)) + " → " + strconv.Itoa(int(e.Replicas))},
+	}
+	if e.Principal != "" {
+		facts = append(facts, [2]string{"By", e.Principal})
+	}
+	if e.Reason != "" {
+		facts = append(facts, [2]string{"Reason", e.Reason})
+	}
+	if e.LeaseExpiresAt != nil {
+		facts = append(facts, [2]string{"Lease expires", e.LeaseExpiresAt.UTC().Format(time.RFC3339)})
+	}
+	if e.Error != "" {
+		facts = append(facts, [2]string{"Error", e.Error})
+	}
+	return facts
+}
+
+// payload formats an event for the sink type
+func (s *Sink) payload(e Event) ([]byte, error) {
+	switch s.Type {
+	case SinkSlack:
+		return json.Marshal(slackMessage(e))
+	case SinkTeams:
+		return json.Marshal(teamsMessage(e))
+	}
+	return json.Marshal(e)
+}
+
+// slackMessage formats an event for a Slack incoming webhook. The text is the
+// fallback shown in notifications.
+func slackMessage(e Event) map[string]any {
+	var fields []map[string]any
+	for _, fact := range e.facts() {
+		fields = append(fields, map[string]any{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*%s*\n%s", fact[0], fact[1]),
+		})
+	}
+	return map[string]any{
+		"text": e.Summary(),
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{"type": "mrkdwn", "text": "*" + e.Title() + "*"},
+			},
+			{
+				"type":   "section",
+				"fields": fields,
+			},
+		},
+	}
+}
+
+// teamsMessage formats an event as an Adaptive Card, accepted by Teams
+// workflow webhooks and incoming webhook connectors
+func teamsMessage(e Event) map[string]any {
+	var facts []map[string]any
+	for _, fact := range e.facts() {
+		facts = append(facts, map[string]any{"title": fact[0], "value": fact[1]})
+	}
+	color := "Default"
+	if e.Type == EventFailed {
+		color = "Attention"
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []map[string]any{
+						{"type": "TextBlock", "text": e.Title(), "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+						{"type": "TextBlock", "text": e.Summary(), "wrap": true},
+						{"type": "FactSet", "facts": facts},
+					},
+				},
+			},
+		},
+	}
+}
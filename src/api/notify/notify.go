@@ -0,0 +1,336 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Default notification ConfigMap location, delivery and queue settings
+const (
+	DefaultConfigMapNamespace = "scale-system"
+	DefaultConfigMapName      = "scale-notifications"
+	DefaultCacheTTL           = 30 * time.Second
+	DefaultMaxAttempts        = 4
+	DefaultInitialBackoff     = time.Second
+	DefaultTimeout            = 10 * time.Second
+	DefaultQueueSize          = 256
+
+	// configMapKey is the ConfigMap data key holding the notification document
+	configMapKey = "notifications.yaml"
+)
+
+// Headers of generic webhook deliveries
+const (
+	HeaderEvent     = "X-Scale-Event"
+	HeaderDelivery  = "X-Scale-Delivery"
+	HeaderTimestamp = "X-Scale-Timestamp"
+	HeaderSignature = "X-Scale-Signature"
+)
+
+// EventType identifies what happened to a deployment
+type EventType string
+
+// Event types subscriptions can select
+const (
+	// EventScaled is sent when a workload was scaled, through the API or by a
+	// background operation such as a scale group, hibernation, a schedule, a
+	// drift correction or an expired lease
+	EventScaled EventType = "scaled"
+	// EventFailed is sent when such a scale failed, but not when the
+	// throttle rejected it
+	EventFailed EventType = "failed"
+	// EventLeaseExpiring is sent once per lease shortly before it expires
+	EventLeaseExpiring EventType = "lease_expiring"
+	// EventIdleShutdown is sent when a ScaleToZeroPolicy scaled an idle
+	// deployment to zero
+	EventIdleShutdown EventType = "idle_shutdown"
+)
+
+// EventTypes lists the supported event types
+var EventTypes = []EventType{EventScaled, EventFailed, EventLeaseExpiring, EventIdleShutdown}
+
+// Event is a notification about a deployment
+type Event struct {
+	ID               string     `json:"id"`
+	Type             EventType  `json:"type"`
+	Namespace        string     `json:"namespace"`
+	Name             string     `json:"name"`
+	PreviousReplicas int32      `json:"previous_replicas"`
+	Replicas         int32      `json:"replicas"`
+	Principal        string     `json:"principal,omitempty"`
+	Reason           string     `json:"reason,omitempty"`
+	Error            string     `json:"error,omitempty"`
+	LeaseExpiresAt   *time.Time `json:"lease_expires_at,omitempty"`
+	Time             time.Time  `json:"time"`
+}
+
+// ScaleEvent returns the event reporting that a workload was scaled from
+// previous to replicas, or failed to be when err is set
+func ScaleEvent(namespace, name string, previous, replicas int32, principal, reason string, err error) Event {
+	event := Event{
+		Type:             EventScaled,
+		Namespace:        namespace,
+		Name:             name,
+		PreviousReplicas: previous,
+		Replicas:         replicas,
+		Principal:        principal,
+		Reason:           reason,
+	}
+	if err != nil {
+		event.Type, event.Error = EventFailed, err.Error()
+	}
+	return event
+}
+
+// NotifyScale queues the event reporting that a workload was scaled or failed
+// to be. Changes the throttle rejected are not failures and are not reported.
+func (n *Notifier) NotifyScale(namespace, name string, previous, replicas int32, principal, reason string, err error) {
+	var throttled *throttle.Error
+	if errors.As(err, &throttled) {
+		return
+	}
+	n.Notify(ScaleEvent(namespace, name, previous, replicas, principal, reason, err))
+}
+
+// Config holds notifier configuration
+type Config struct {
+	ConfigMapNamespace string
+	ConfigMapName      string
+	CacheTTL           time.Duration
+	// MaxAttempts is how often a delivery is tried before it is dropped
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled on each retry
+	InitialBackoff time.Duration
+	// Timeout bounds each delivery attempt
+	Timeout   time.Duration
+	QueueSize int
+}
+
+// NewConfig returns the default notifier configuration
+func NewConfig() *Config {
+	return &Config{
+		ConfigMapNamespace: DefaultConfigMapNamespace,
+		ConfigMapName:      DefaultConfigMapName,
+		CacheTTL:           DefaultCacheTTL,
+		MaxAttempts:        DefaultMaxAttempts,
+		InitialBackoff:     DefaultInitialBackoff,
+		Timeout:            DefaultTimeout,
+		QueueSize:          DefaultQueueSize,
+	}
+}
+
+// Notifier delivers events to the webhook sinks subscribed to them in the
+// notification ConfigMap. Events are queued and delivered in the background
+// so scale operations are never held up by a slow receiver.
+type Notifier struct {
+	clientset  kubernetes.Interface
+	config     *Config
+	httpClient *http.Client
+	queue      chan Event
+	now        func() time.Time
+
+	mu       sync.Mutex
+	cached   *Document
+	cachedAt time.Time
+}
+
+// NewNotifier creates a new notifier
+func NewNotifier(clientset kubernetes.Interface, config *Config) *Notifier {
+	return &Notifier{
+		clientset:  clientset,
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		queue:      make(chan Event, config.QueueSize),
+		now:        time.Now,
+	}
+}
+
+// Notify queues an event for delivery. It never blocks: when the queue is
+// full the event is dropped and logged. A nil notifier discards events.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+	if event.ID == "" {
+		event.ID = newID()
+	}
+	if event.Time.IsZero() {
+		event.Time = n.now().UTC()
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		log.Printf("Notification queue full, dropping %s event for deployment %s/%s", event.Type, event.Namespace, event.Name)
+	}
+}
+
+// Run delivers queued events until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			if err := n.Send(ctx, event); err != nil {
+				log.Printf("Failed to deliver %s notification for deployment %s/%s: %v", event.Type, event.Namespace, event.Name, err)
+			}
+		}
+	}
+}
+
+// Send delivers an event to every sink subscribed to it, retrying failed
+// deliveries with exponential backoff. One failing sink does not keep the
+// event from the others.
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	doc, err := n.document(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range doc.sinksFor(event) {
+		if err := n.deliver(ctx, name, doc.Sinks[name], event); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver sends an event to one sink
+func (n *Notifier) deliver(ctx context.Context, name string, sink *Sink, event Event) error {
+	url, signingKey, err := n.credentials(ctx, sink)
+	if err != nil {
+		return err
+	}
+	body, err := sink.payload(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, url, body, sink.Type, signingKey, event)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.config.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Notification to sink %s failed (attempt %d), retrying in %s: %v", name, attempt, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, url string, body []byte, sinkType SinkType, signingKey string, event Event) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sinkType == SinkGeneric {
+		timestamp := strconv.FormatInt(n.now().Unix(), 10)
+		req.Header.Set(HeaderEvent, string(event.Type))
+		req.Header.Set(HeaderDelivery, event.ID)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, Sign(signingKey, timestamp, body))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("receiver responded with %d", resp.StatusCode)
+}
+
+// credentials returns the URL and signing key of a sink. Values from the
+// sink's Secret take precedence over the URL in the ConfigMap.
+func (n *Notifier) credentials(ctx context.Context, sink *Sink) (string, string, error) {
+	if sink.SecretRef == "" {
+		return sink.URL, "", nil
+	}
+
+	secret, err := n.clientset.CoreV1().Secrets(n.config.ConfigMapNamespace).Get(ctx, sink.SecretRef, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get secret %s/%s: %w", n.config.ConfigMapNamespace, sink.SecretRef, err)
+	}
+	url := sink.URL
+	if value := string(secret.Data[SecretKeyURL]); value != "" {
+		url = value
+	}
+	if url == "" {
+		return "", "", fmt.Errorf("secret %s/%s has no %s", n.config.ConfigMapNamespace, sink.SecretRef, SecretKeyURL)
+	}
+	signingKey := string(secret.Data[SecretKeySigningKey])
+	if sink.Type == SinkGeneric && signingKey == "" {
+		return "", "", fmt.Errorf("secret %s/%s has no %s", n.config.ConfigMapNamespace, sink.SecretRef, SecretKeySigningKey)
+	}
+	return url, signingKey, nil
+}
+
+// document returns the notification document from the ConfigMap, cached for CacheTTL
+func (n *Notifier) document(ctx context.Context) (*Document, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.cached != nil && n.now().Sub(n.cachedAt) < n.config.CacheTTL {
+		return n.cached, nil
+	}
+
+	configMap, err := n.clientset.CoreV1().ConfigMaps(n.config.ConfigMapNamespace).Get(ctx, n.config.ConfigMapName, metav1.GetOptions{})
+	var doc *Document
+	switch {
+	case k8serrors.IsNotFound(err):
+		doc = &Document{}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get notification configmap %s/%s: %w", n.config.ConfigMapNamespace, n.config.ConfigMapName, err)
+	default:
+		doc, err = ParseDocument([]byte(configMap.Data[configMapKey]))
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %w", n.config.ConfigMapNamespace, n.config.ConfigMapName, err)
+		}
+	}
+
+	n.cached = doc
+	n.cachedAt = n.now()
+	return doc, nil
+}
+
+// newID returns a random delivery ID receivers can use to drop duplicates
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
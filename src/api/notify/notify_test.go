@@ -0,0 +1,350 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/throttle"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// receiver records the requests sent to an httptest server, answering with
+// the given status codes in turn and 200 afterwards
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server.URL
+}
+
+func newTestNotifier(t *testing.T, document string, secrets map[string]map[string]string) *Notifier {
+	t.Helper()
+
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultConfigMapName, Namespace: DefaultConfigMapNamespace},
+		Data:       map[string]string{configMapKey: document},
+	})
+	for name, data := range secrets {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: DefaultConfigMapNamespace},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		_, err := clientset.CoreV1().Secrets(DefaultConfigMapNamespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	config := NewConfig()
+	config.InitialBackoff = time.Millisecond
+	return NewNotifier(clientset, config)
+}
+
+func scaledEvent() Event {
+	return Event{
+		ID:               "delivery-1",
+		Type:             EventScaled,
+		Namespace:        "project-b",
+		Name:             "sample-app-b",
+		PreviousReplicas: 0,
+		Replicas:         2,
+		Principal:        "alice",
+		Reason:           "Evaluation run",
+		Time:             time.Date(2025, 7, 17, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSend_GenericSigned(t *testing.T) {
+	// Setup
+	recv, url := newReceiver(t)
+	notifier := newTestNotifier(t, `
+sinks:
+  audit:
+    type: generic
+    secretRef: audit-webhook
+subscriptions:
+  - sinks: [audit]
+`, map[string]map[string]string{
+		"audit-webhook": {SecretKeyURL: url, SecretKeySigningKey: "s3cret"},
+	})
+
+	// Test
+	err := notifier.Send(context.Background(), scaledEvent())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 1, recv.count())
+	req, body := recv.requests[0], recv.bodies[0]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "scaled", req.Header.Get(HeaderEvent))
+	assert.Equal(t, "delivery-1", req.Header.Get(HeaderDelivery))
+	assert.Equal(t, Sign("s3cret", req.Header.Get(HeaderTimestamp), body), req.Header.Get(HeaderSignature))
+	assert.True(t, strings.HasPrefix(req.Header.Get(HeaderSignature), "sha256="))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, scaledEvent(), event)
+}
+
+func TestSend_SlackAndTeams(t *testing.T) {
+	// Setup
+	slack, slackURL := newReceiver(t)
+	teams, teamsURL := newReceiver(t)
+	notifier := newTestNotifier(t, `
+sinks:
+  team-b-slack:
+    type: slack
+    url: `+slackURL+`
+  team-b-teams:
+    type: teams
+    secretRef: team-b-teams
+subscriptions:
+  - namespaces: [project-b]
+    events: [scaled, failed]
+    sinks: [team-b-slack, team-b-teams]
+`, map[string]map[string]string{
+		"team-b-teams": {SecretKeyURL: teamsURL},
+	})
+
+	// Test
+	err := notifier.Send(context.Background(), scaledEvent())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 1, slack.count())
+	require.Equal(t, 1, teams.count())
+	assert.Empty(t, slack.requests[0].Header.Get(HeaderSignature))
+
+	var slackMessage struct {
+		Text   string           `json:"text"`
+		Blocks []map[string]any `json:"blocks"`
+	}
+	require.NoError(t, json.Unmarshal(slack.bodies[0], &slackMessage))
+	assert.Equal(t, "Deployment project-b/sample-app-b scaled from 0 to 2 replicas by alice: Evaluation run", slackMessage.Text)
+	assert.Len(t, slackMessage.Blocks, 2)
+
+	var teamsMessage struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type string           `json:"type"`
+				Body []map[string]any `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	require.NoError(t, json.Unmarshal(teams.bodies[0], &teamsMessage))
+	assert.Equal(t, "message", teamsMessage.Type)
+	require.Len(t, teamsMessage.Attachments, 1)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", teamsMessage.Attachments[0].ContentType)
+	assert.Equal(t, "AdaptiveCard", teamsMessage.Attachments[0].Content.Type)
+	assert.Equal(t, "project-b/sample-app-b scaled to 2 replicas", teamsMessage.Attachments[0].Content.Body[0]["text"])
+}
+
+func TestSend_SubscriptionFilters(t *testing.T) {
+	// Setup
+	recv, url := newReceiver(t)
+	notifier := newTestNotifier(t, `
+sinks:
+  ops:
+    type: slack
+    url: `+url+`
+subscriptions:
+  - namespaces: [project-a]
+    sinks: [ops]
+  - events: [idle_shutdown]
+    sinks: [ops]
+`, nil)
+
+	// Test
+	otherNamespace := scaledEvent()
+	require.NoError(t, notifier.Send(context.Background(), otherNamespace))
+	idle := scaledEvent()
+	idle.Type, idle.Replicas = EventIdleShutdown, 0
+	require.NoError(t, notifier.Send(context.Background(), idle))
+
+	// Assert
+	assert.Equal(t, 1, recv.count())
+	assert.Contains(t, string(recv.bodies[0]), "scaled to zero after being idle")
+}
+
+func TestSend_RetriesWithBackoff(t *testing.T) {
+	// Setup
+	recv, url := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	notifier := newTestNotifier(t, `
+sinks:
+  ops:
+    type: slack
+    url: `+url+`
+subscriptions:
+  - sinks: [ops]
+`, nil)
+
+	// Test
+	err := notifier.Send(context.Background(), scaledEvent())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, recv.count())
+}
+
+func TestSend_GivesUp(t *testing.T) {
+	// Setup
+	failing, failingURL := newReceiver(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	rejecting, rejectingURL := newReceiver(t, http.StatusBadRequest)
+	healthy, healthyURL := newReceiver(t)
+	notifier := newTestNotifier(t, `
+sinks:
+  failing:
+    type: slack
+    url: `+failingURL+`
+  rejecting:
+    type: teams
+    url: `+rejectingURL+`
+  healthy:
+    type: slack
+    url: `+healthyURL+`
+subscriptions:
+  - sinks: [failing, rejecting, healthy]
+`, nil)
+
+	// Test
+	err := notifier.Send(context.Background(), scaledEvent())
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sink failing: giving up after 4 attempts")
+	assert.Contains(t, err.Error(), "sink rejecting: giving up after 1 attempts")
+	assert.Equal(t, DefaultMaxAttempts, failing.count())
+	assert.Equal(t, 1, rejecting.count())
+	assert.Equal(t, 1, healthy.count())
+}
+
+func TestNotify_DeliversInBackground(t *testing.T) {
+	// Setup
+	recv, url := newReceiver(t)
+	notifier := newTestNotifier(t, `
+sinks:
+  ops:
+    type: slack
+    url: `+url+`
+subscriptions:
+  - events: [lease_expiring]
+    sinks: [ops]
+`, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	// Test
+	expiresAt := time.Now().Add(10 * time.Minute)
+	notifier.Notify(Event{Type: EventLeaseExpiring, Namespace: "project-b", Name: "sample-app-b", Replicas: 2, PreviousReplicas: 2, LeaseExpiresAt: &expiresAt})
+
+	// Assert
+	assert.Eventually(t, func() bool { return recv.count() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, string(recv.bodies[0]), "Lease of project-b/sample-app-b is expiring")
+
+	// A nil notifier discards events
+	var disabled *Notifier
+	disabled.Notify(scaledEvent())
+}
+
+func TestNotify_NoConfigMap(t *testing.T) {
+	notifier := NewNotifier(fake.NewSimpleClientset(), NewConfig())
+
+	err := notifier.Send(context.Background(), scaledEvent())
+
+	assert.NoError(t, err)
+}
+
+func TestScaleEvent(t *testing.T) {
+	event := ScaleEvent("project-b", "sample-app-b", 0, 2, "alice", "namespace woken", nil)
+	assert.Equal(t, EventScaled, event.Type)
+	assert.Equal(t, int32(0), event.PreviousReplicas)
+	assert.Equal(t, int32(2), event.Replicas)
+	assert.Empty(t, event.Error)
+
+	event = ScaleEvent("project-b", "sample-app-b", 2, 0, "alice", "namespace hibernated", assert.AnError)
+	assert.Equal(t, EventFailed, event.Type)
+	assert.Equal(t, assert.AnError.Error(), event.Error)
+	assert.Equal(t, "namespace hibernated", event.Reason)
+}
+
+func TestNotifyScale_SkipsThrottled(t *testing.T) {
+	notifier := NewNotifier(fake.NewSimpleClientset(), NewConfig())
+
+	notifier.NotifyScale("project-b", "sample-app-b", 2, 0, "alice", "lease held by alice expired", &throttle.Error{Message: "throttled"})
+	assert.Empty(t, notifier.queue)
+
+	notifier.NotifyScale("project-b", "sample-app-b", 2, 0, "alice", "lease held by alice expired", assert.AnError)
+	require.Len(t, notifier.queue, 1)
+	event := <-notifier.queue
+	assert.Equal(t, EventFailed, event.Type)
+	assert.Equal(t, "alice", event.Principal)
+}
+
+func TestParseDocument_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{"unknown field", "sink: {}", "failed to parse"},
+		{"unknown type", "sinks: {a: {type: email, url: https://example.com}}", `unknown type "email"`},
+		{"generic without secret", "sinks: {a: {type: generic, url: https://example.com}}", "secretRef"},
+		{"slack without url", "sinks: {a: {type: slack}}", "url or secretRef is required"},
+		{"invalid url", "sinks: {a: {type: slack, url: 'ftp://example.com'}}", "invalid url"},
+		{"unknown sink", "subscriptions: [{sinks: [a]}]", `unknown sink "a"`},
+		{"unknown event", "sinks: {a: {type: slack, url: https://example.com}}\nsubscriptions: [{events: [deleted], sinks: [a]}]", `unknown event "deleted"`},
+		{"no sinks", "subscriptions: [{events: [scaled]}]", "at least one sink"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDocument([]byte(tt.document))
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package operation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRetention is how long finished operations can still be looked up
+const DefaultRetention = 24 * time.Hour
+
+// Status is the state of an operation or one of its steps
+type Status string
+
+// Operation and step statuses
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// Step is one unit of work of an operation, such as scaling one deployment
+type Step struct {
+	Stage     int
+	Namespace string
+	Name      string
+	Replicas  int32
+	Status    Status
+	Message   string
+}
+
+// Operation is a long-running request executed in the background
+type Operation struct {
+	ID         string
+	Type       string
+	Target     string
+	Reason     string
+	StartedBy  string
+	Status     Status
+	Steps      []Step
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Store keeps operations in memory so that their progress can be polled.
+// Operations are only visible on the API replica that runs them.
+type Store struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu         sync.Mutex
+	operations map[string]*Operation
+}
+
+// NewStore creates a new operation store
+func NewStore(retention time.Duration) *Store {
+	return &Store{
+		retention:  retention,
+		now:        time.Now,
+		operations: make(map[string]*Operation),
+	}
+}
+
+// Start records a new running operation with all steps pending and returns a
+// copy of it
+func (s *Store) Start(opType, target, reason, startedBy string, steps []Step) Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+
+	op := &Operation{
+		ID:        uuid.NewString(),
+		Type:      opType,
+		Target:    target,
+		Reason:    reason,
+		StartedBy: startedBy,
+		Status:    StatusRunning,
+		Steps:     make([]Step, len(steps)),
+		StartedAt: s.now().UTC(),
+	}
+	for i, step := range steps {
+		step.Status = StatusPending
+		op.Steps[i] = step
+	}
+	s.operations[op.ID] = op
+	return op.copy()
+}
+
+// Get returns a copy of an operation
+func (s *Store) Get(id string) (Operation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return op.copy(), true
+}
+
+// UpdateStep sets the status and message of a step
+func (s *Store) UpdateStep(id string, index int, status Status, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if op, ok := s.operations[id]; ok && index < len(op.Steps) {
+		op.Steps[index].Status = status
+		op.Steps[index].Message = message
+	}
+}
+
+// Finish marks an operation as succeeded, or failed if err is not nil. Steps
+// that never ran are marked skipped.
+func (s *Store) Finish(id string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.operations[id]
+	if !ok {
+		return
+	}
+
+	op.Status = StatusSucceeded
+	if err != nil {
+		op.Status = StatusFailed
+		op.Error = err.Error()
+	}
+	for i := range op.Steps {
+		if op.Steps[i].Status == StatusPending {
+			op.Steps[i].Status = StatusSkipped
+		}
+	}
+	op.FinishedAt = s.now().UTC()
+}
+
+// sweep forgets operations that finished more than the retention period ago.
+// The caller must hold s.mu.
+func (s *Store) sweep() {
+	for id, op := range s.operations {
+		if !op.FinishedAt.IsZero() && s.now().Sub(op.FinishedAt) > s.retention {
+			delete(s.operations, id)
+		}
+	}
+}
+
+func (op *Operation) copy() Operation {
+	c := *op
+	c.Steps = append([]Step(nil), op.Steps...)
+	return c
+}
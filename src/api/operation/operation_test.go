@@ -0,0 +1,55 @@
+package operation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Lifecycle(t *testing.T) {
+	store := NewStore(time.Hour)
+
+	started := store.Start("group-scale-up", "inference-b", "Demo", "alice", []Step{
+		{Stage: 0, Namespace: "project-b", Name: "model-server", Replicas: 1},
+		{Stage: 1, Namespace: "project-b", Name: "frontend", Replicas: 2},
+	})
+	assert.Equal(t, StatusRunning, started.Status)
+	assert.Equal(t, StatusPending, started.Steps[0].Status)
+
+	store.UpdateStep(started.ID, 0, StatusSucceeded, "ready")
+	store.Finish(started.ID, errors.New("frontend not ready"))
+
+	got, ok := store.Get(started.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusFailed, got.Status)
+	assert.Equal(t, "frontend not ready", got.Error)
+	assert.Equal(t, StatusSucceeded, got.Steps[0].Status)
+	assert.Equal(t, StatusSkipped, got.Steps[1].Status)
+	assert.False(t, got.FinishedAt.IsZero())
+
+	// Returned operations are copies
+	got.Steps[0].Status = StatusFailed
+	again, _ := store.Get(started.ID)
+	assert.Equal(t, StatusSucceeded, again.Steps[0].Status)
+}
+
+func TestStore_Sweep(t *testing.T) {
+	store := NewStore(time.Hour)
+	base := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return base }
+
+	finished := store.Start("group-scale-up", "a", "", "", nil)
+	store.Finish(finished.ID, nil)
+	running := store.Start("group-scale-up", "b", "", "", nil)
+
+	store.now = func() time.Time { return base.Add(2 * time.Hour) }
+	store.Start("group-scale-up", "c", "", "", nil)
+
+	_, ok := store.Get(finished.ID)
+	assert.False(t, ok)
+	_, ok = store.Get(running.ID)
+	assert.True(t, ok)
+}
@@ -0,0 +1,438 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Deployment annotations declaring a scaling policy
+const (
+	AnnotationMinReplicas    = "scale-to-zero.io/min-replicas"
+	AnnotationMaxReplicas    = "scale-to-zero.io/max-replicas"
+	AnnotationProtected      = "scale-to-zero.io/protected"
+	AnnotationAllowedWindows = "scale-to-zero.io/allowed-windows"
+	AnnotationTimezone       = "scale-to-zero.io/timezone"
+	// AnnotationApprovalReplicas is the replica count above which a scale-up needs approval
+	AnnotationApprovalReplicas = "scale-to-zero.io/approval-replicas"
+)
+
+// Default policy ConfigMap location
+const (
+	DefaultConfigMapNamespace = "scale-system"
+	DefaultConfigMapName      = "scale-policies"
+	DefaultCacheTTL           = 30 * time.Second
+
+	// configMapKey is the ConfigMap data key holding the policy document
+	configMapKey = "policies.yaml"
+)
+
+// ErrInvalidAnnotations is returned by Evaluate when the policy annotations of
+// the deployment cannot be parsed
+var ErrInvalidAnnotations = errors.New("invalid policy annotations")
+
+// Operation is the kind of scale operation being evaluated
+type Operation string
+
+// Scale operations
+const (
+	OperationScaleUp     Operation = "scale-up"
+	OperationScaleToZero Operation = "scale-to-zero"
+	// OperationResume resumes a suspended CronJob. Only allowed windows apply.
+	OperationResume Operation = "resume"
+)
+
+// Policy constrains how a deployment may be scaled
+type Policy struct {
+	// MinReplicas is the smallest replica count a scale-up may request
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the largest replica count a scale-up may request
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// Protected deployments cannot be scaled to zero
+	Protected bool `json:"protected,omitempty"`
+	// AllowedWindows restrict when scale-ups may happen, e.g. "Mon-Fri 08:00-20:00"
+	AllowedWindows []string `json:"allowedWindows,omitempty"`
+	// Timezone for AllowedWindows, defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+	// ApprovalReplicas is the largest replica count a scale-up may request
+	// without a second person's approval
+	ApprovalReplicas *int32 `json:"approvalReplicas,omitempty"`
+}
+
+// Document is the policy ConfigMap content. More specific entries are
+// evaluated in addition to, not instead of, less specific ones.
+type Document struct {
+	Defaults    *Policy            `json:"defaults,omitempty"`
+	Namespaces  map[string]*Policy `json:"namespaces,omitempty"`
+	Deployments map[string]*Policy `json:"deployments,omitempty"`
+}
+
+// Request describes a scale operation to evaluate
+type Request struct {
+	Namespace   string
+	Name        string
+	Annotations map[string]string
+	Operation   Operation
+	Replicas    int32
+}
+
+// Decision is the outcome of a policy evaluation
+type Decision struct {
+	Allowed bool
+	// StatusCode is the HTTP status to respond with when the request is denied:
+	// 403 for protected workloads and closed windows, 422 for replica bounds
+	StatusCode int
+	// Violations explain why the request was denied
+	Violations []string
+	// Notes list the policies that were applied
+	Notes []string
+	// ApprovalReasons explain why an allowed scale-up must be approved before
+	// it is executed. Empty if no approval is needed.
+	ApprovalReasons []string
+}
+
+// RequiresApproval reports whether the operation needs approval before it is executed
+func (d *Decision) RequiresApproval() bool {
+	return len(d.ApprovalReasons) > 0
+}
+
+// Config holds policy engine configuration
+type Config struct {
+	ConfigMapNamespace string
+	ConfigMapName      string
+	CacheTTL           time.Duration
+}
+
+// NewConfig returns the default policy engine configuration
+func NewConfig() *Config {
+	return &Config{
+		ConfigMapNamespace: DefaultConfigMapNamespace,
+		ConfigMapName:      DefaultConfigMapName,
+		CacheTTL:           DefaultCacheTTL,
+	}
+}
+
+// Engine evaluates scaling policies declared in the policy ConfigMap and in
+// Deployment annotations. When several sources apply, the strictest wins.
+type Engine struct {
+	clientset kubernetes.Interface
+	config    *Config
+	now       func() time.Time
+
+	mu       sync.Mutex
+	cached   *Document
+	cachedAt time.Time
+}
+
+// NewEngine creates a new policy engine. A nil clientset evaluates annotations only.
+func NewEngine(clientset kubernetes.Interface, config *Config) *Engine {
+	return &Engine{
+		clientset: clientset,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// source is a policy together with where it was declared
+type source struct {
+	name   string
+	policy *Policy
+}
+
+// Evaluate checks a scale request against all applicable policies
+func (e *Engine) Evaluate(ctx context.Context, req Request) (*Decision, error) {
+	doc, err := e.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the protected annotation applies to scale-to-zero, so a broken
+	// annotation about scale-ups cannot keep a deployment running
+	annotations := req.Annotations
+	if req.Operation == OperationScaleToZero {
+		annotations = map[string]string{}
+		if value, ok := req.Annotations[AnnotationProtected]; ok {
+			annotations[AnnotationProtected] = value
+		}
+	}
+	annotationPolicy, err := FromAnnotations(annotations)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnnotations, err)
+	}
+
+	var sources []source
+	if doc.Defaults != nil {
+		sources = append(sources, source{name: "policy defaults", policy: doc.Defaults})
+	}
+	if p := doc.Namespaces[req.Namespace]; p != nil {
+		sources = append(sources, source{name: fmt.Sprintf("namespace %s policy", req.Namespace), policy: p})
+	}
+	if p := doc.Deployments[req.Namespace+"/"+req.Name]; p != nil {
+		sources = append(sources, source{name: fmt.Sprintf("deployment %s/%s policy", req.Namespace, req.Name), policy: p})
+	}
+	if annotationPolicy != nil {
+		sources = append(sources, source{name: "deployment annotations", policy: annotationPolicy})
+	}
+
+	decision := &Decision{Allowed: true}
+	for _, src := range sources {
+		if err := e.apply(decision, src, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(decision.Violations) > 0 {
+		decision.Allowed = false
+	}
+	return decision, nil
+}
+
+// apply evaluates one policy source and records violations and notes
+func (e *Engine) apply(decision *Decision, src source, req Request) error {
+	p := src.policy
+
+	switch req.Operation {
+	case OperationScaleToZero:
+		if p.Protected {
+			decision.deny(http.StatusForbidden, fmt.Sprintf("deployment is protected and cannot be scaled to zero (%s)", src.name))
+		}
+
+	case OperationScaleUp:
+		if p.MaxReplicas != nil {
+			if req.Replicas > *p.MaxReplicas {
+				decision.deny(http.StatusUnprocessableEntity, fmt.Sprintf("requested %d replicas exceeds maximum of %d (%s)", req.Replicas, *p.MaxReplicas, src.name))
+			} else {
+				decision.Notes = append(decision.Notes, fmt.Sprintf("max replicas %d (%s)", *p.MaxReplicas, src.name))
+			}
+		}
+		if p.MinReplicas != nil {
+			if req.Replicas < *p.MinReplicas {
+				decision.deny(http.StatusUnprocessableEntity, fmt.Sprintf("requested %d replicas is below minimum of %d (%s)", req.Replicas, *p.MinReplicas, src.name))
+			} else {
+				decision.Notes = append(decision.Notes, fmt.Sprintf("min replicas %d (%s)", *p.MinReplicas, src.name))
+			}
+		}
+		if err := e.applyWindows(decision, src, req.Operation); err != nil {
+			return err
+		}
+		if p.ApprovalReplicas != nil && req.Replicas > *p.ApprovalReplicas {
+			decision.ApprovalReasons = append(decision.ApprovalReasons, fmt.Sprintf("requested %d replicas exceeds approval threshold of %d (%s)", req.Replicas, *p.ApprovalReplicas, src.name))
+		}
+
+	case OperationResume:
+		if err := e.applyWindows(decision, src, req.Operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyWindows denies the operation outside the policy's allowed windows
+func (e *Engine) applyWindows(decision *Decision, src source, op Operation) error {
+	p := src.policy
+	if len(p.AllowedWindows) == 0 {
+		return nil
+	}
+	open, err := p.windowOpen(e.now())
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", src.name, err)
+	}
+	windows := strings.Join(p.AllowedWindows, ", ")
+	if !open {
+		decision.deny(http.StatusForbidden, fmt.Sprintf("%s is only allowed during %s %s (%s)", op, windows, p.location(), src.name))
+	} else {
+		decision.Notes = append(decision.Notes, fmt.Sprintf("within allowed window %s %s (%s)", windows, p.location(), src.name))
+	}
+	return nil
+}
+
+// deny records a violation. 403 takes precedence over 422 because a closed
+// window or protected workload cannot be fixed by changing the request.
+func (d *Decision) deny(statusCode int, violation string) {
+	d.Violations = append(d.Violations, violation)
+	if d.StatusCode != http.StatusForbidden {
+		d.StatusCode = statusCode
+	}
+}
+
+// windowOpen reports whether now falls into any allowed window
+func (p *Policy) windowOpen(now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(p.location())
+	if err != nil {
+		return false, fmt.Errorf("unknown timezone %q: %w", p.Timezone, err)
+	}
+
+	for _, value := range p.AllowedWindows {
+		window, err := ParseWindow(value)
+		if err != nil {
+			return false, err
+		}
+		if window.Contains(now.In(loc)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// location returns the policy timezone name
+func (p *Policy) location() string {
+	if p.Timezone == "" {
+		return "UTC"
+	}
+	return p.Timezone
+}
+
+// Validate checks that the policy is well formed
+func (p *Policy) Validate() error {
+	if p.MinReplicas != nil && *p.MinReplicas < 1 {
+		return fmt.Errorf("minReplicas must be at least 1")
+	}
+	if p.MaxReplicas != nil && *p.MaxReplicas < 1 {
+		return fmt.Errorf("maxReplicas must be at least 1")
+	}
+	if p.ApprovalReplicas != nil && *p.ApprovalReplicas < 0 {
+		return fmt.Errorf("approvalReplicas must not be negative")
+	}
+	if p.MinReplicas != nil && p.MaxReplicas != nil && *p.MinReplicas > *p.MaxReplicas {
+		return fmt.Errorf("minReplicas %d is greater than maxReplicas %d", *p.MinReplicas, *p.MaxReplicas)
+	}
+	for _, value := range p.AllowedWindows {
+		if _, err := ParseWindow(value); err != nil {
+			return err
+		}
+	}
+	if _, err := time.LoadLocation(p.location()); err != nil {
+		return fmt.Errorf("unknown timezone %q", p.Timezone)
+	}
+	return nil
+}
+
+// FromAnnotations builds a policy from Deployment annotations. It returns nil
+// if no policy annotations are present.
+func FromAnnotations(annotations map[string]string) (*Policy, error) {
+	p := &Policy{}
+	found := false
+
+	for key, target := range map[string]**int32{
+		AnnotationMinReplicas:      &p.MinReplicas,
+		AnnotationMaxReplicas:      &p.MaxReplicas,
+		AnnotationApprovalReplicas: &p.ApprovalReplicas,
+	} {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s=%q: %w", key, value, err)
+		}
+		replicas := int32(n)
+		*target = &replicas
+		found = true
+	}
+
+	if value, ok := annotations[AnnotationProtected]; ok {
+		protected, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s=%q: %w", AnnotationProtected, value, err)
+		}
+		p.Protected = protected
+		found = true
+	}
+
+	if value, ok := annotations[AnnotationAllowedWindows]; ok {
+		for _, window := range strings.Split(value, ";") {
+			if window = strings.TrimSpace(window); window != "" {
+				p.AllowedWindows = append(p.AllowedWindows, window)
+			}
+		}
+		found = true
+	}
+
+	if value, ok := annotations[AnnotationTimezone]; ok {
+		p.Timezone = strings.TrimSpace(value)
+	}
+
+	if !found {
+		return nil, nil
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy annotations: %w", err)
+	}
+	return p, nil
+}
+
+// ParseDocument parses and validates a policy document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse policy document: %w", err)
+	}
+
+	check := func(name string, p *Policy) error {
+		if p == nil {
+			return nil
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid policy %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := check("defaults", doc.Defaults); err != nil {
+		return nil, err
+	}
+	for name, p := range doc.Namespaces {
+		if err := check("namespaces."+name, p); err != nil {
+			return nil, err
+		}
+	}
+	for name, p := range doc.Deployments {
+		if err := check("deployments."+name, p); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// document returns the policy document from the ConfigMap, cached for CacheTTL
+func (e *Engine) document(ctx context.Context) (*Document, error) {
+	if e.clientset == nil {
+		return &Document{}, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cached != nil && e.now().Sub(e.cachedAt) < e.config.CacheTTL {
+		return e.cached, nil
+	}
+
+	configMap, err := e.clientset.CoreV1().ConfigMaps(e.config.ConfigMapNamespace).Get(ctx, e.config.ConfigMapName, metav1.GetOptions{})
+	var doc *Document
+	switch {
+	case k8serrors.IsNotFound(err):
+		doc = &Document{}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get policy configmap %s/%s: %w", e.config.ConfigMapNamespace, e.config.ConfigMapName, err)
+	default:
+		doc, err = ParseDocument([]byte(configMap.Data[configMapKey]))
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %w", e.config.ConfigMapNamespace, e.config.ConfigMapName, err)
+		}
+	}
+
+	e.cached = doc
+	e.cachedAt = e.now()
+	return doc, nil
+}
@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testDocument = `
+defaults:
+  maxReplicas: 10
+namespaces:
+  project-b:
+    maxReplicas: 4
+deployments:
+  project-b/sample-app-b:
+    maxReplicas: 2
+    allowedWindows: ["Mon-Fri 08:00-20:00"]
+    timezone: Asia/Tokyo
+  project-a/critical:
+    protected: true
+`
+
+func newTestEngine(t *testing.T, document string) *Engine {
+	t.Helper()
+
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultConfigMapName, Namespace: DefaultConfigMapNamespace},
+		Data:       map[string]string{configMapKey: document},
+	})
+	engine := NewEngine(clientset, NewConfig())
+	// Monday 2025-07-14 10:00 in Tokyo
+	engine.now = func() time.Time { return time.Date(2025, 7, 14, 1, 0, 0, 0, time.UTC) }
+	return engine
+}
+
+func TestEvaluate_MaxReplicas(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-b",
+		Name:      "sample-app-b",
+		Operation: OperationScaleUp,
+		Replicas:  50,
+	})
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, http.StatusUnprocessableEntity, decision.StatusCode)
+	assert.Len(t, decision.Violations, 3)
+	assert.Contains(t, decision.Violations[2], "exceeds maximum of 2 (deployment project-b/sample-app-b policy)")
+}
+
+func TestEvaluate_AllowedWithNotes(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-b",
+		Name:      "sample-app-b",
+		Operation: OperationScaleUp,
+		Replicas:  2,
+	})
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Contains(t, decision.Notes, "max replicas 2 (deployment project-b/sample-app-b policy)")
+	assert.Contains(t, decision.Notes, "within allowed window Mon-Fri 08:00-20:00 Asia/Tokyo (deployment project-b/sample-app-b policy)")
+}
+
+func TestEvaluate_OutsideWindow(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+	// Saturday in Tokyo
+	engine.now = func() time.Time { return time.Date(2025, 7, 19, 1, 0, 0, 0, time.UTC) }
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-b",
+		Name:      "sample-app-b",
+		Operation: OperationScaleUp,
+		Replicas:  1,
+	})
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, http.StatusForbidden, decision.StatusCode)
+	assert.Contains(t, decision.Violations[0], "only allowed during Mon-Fri 08:00-20:00 Asia/Tokyo")
+}
+
+func TestEvaluate_Resume(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+	// Saturday in Tokyo
+	engine.now = func() time.Time { return time.Date(2025, 7, 19, 1, 0, 0, 0, time.UTC) }
+
+	// Replica bounds do not apply to resuming a CronJob, only windows
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-b",
+		Name:      "sample-app-b",
+		Operation: OperationResume,
+	})
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, []string{"resume is only allowed during Mon-Fri 08:00-20:00 Asia/Tokyo (deployment project-b/sample-app-b policy)"}, decision.Violations)
+}
+
+func TestEvaluate_ProtectedDeployment(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-a",
+		Name:      "critical",
+		Operation: OperationScaleToZero,
+	})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, http.StatusForbidden, decision.StatusCode)
+
+	// Scale-ups of a protected deployment are still allowed
+	decision, err = engine.Evaluate(context.Background(), Request{
+		Namespace: "project-a",
+		Name:      "critical",
+		Operation: OperationScaleUp,
+		Replicas:  1,
+	})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestEvaluate_AnnotationsAreStricter(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-a",
+		Name:      "web",
+		Annotations: map[string]string{
+			AnnotationMaxReplicas: "3",
+			AnnotationProtected:   "true",
+		},
+		Operation: OperationScaleUp,
+		Replicas:  5,
+	})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, []string{"requested 5 replicas exceeds maximum of 3 (deployment annotations)"}, decision.Violations)
+}
+
+func TestEvaluate_InvalidAnnotation(t *testing.T) {
+	engine := NewEngine(nil, NewConfig())
+
+	_, err := engine.Evaluate(context.Background(), Request{
+		Namespace:   "project-a",
+		Name:        "web",
+		Annotations: map[string]string{AnnotationMaxReplicas: "lots"},
+		Operation:   OperationScaleUp,
+		Replicas:    1,
+	})
+	assert.ErrorIs(t, err, ErrInvalidAnnotations)
+	assert.ErrorContains(t, err, AnnotationMaxReplicas)
+
+	// Scale-to-zero only reads the protected annotation
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace:   "project-a",
+		Name:        "web",
+		Annotations: map[string]string{AnnotationMaxReplicas: "lots"},
+		Operation:   OperationScaleToZero,
+	})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	_, err = engine.Evaluate(context.Background(), Request{
+		Namespace:   "project-a",
+		Name:        "web",
+		Annotations: map[string]string{AnnotationProtected: "maybe"},
+		Operation:   OperationScaleToZero,
+	})
+	assert.ErrorIs(t, err, ErrInvalidAnnotations)
+}
+
+func TestEvaluate_MissingConfigMap(t *testing.T) {
+	engine := NewEngine(fake.NewSimpleClientset(), NewConfig())
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace: "project-a",
+		Name:      "web",
+		Operation: OperationScaleUp,
+		Replicas:  100,
+	})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestEvaluate_InvalidDocument(t *testing.T) {
+	engine := newTestEngine(t, "defaults:\n  maxReplicas: 0\n")
+
+	_, err := engine.Evaluate(context.Background(), Request{Namespace: "ns", Name: "app", Operation: OperationScaleUp, Replicas: 1})
+	assert.ErrorContains(t, err, "maxReplicas must be at least 1")
+}
+
+func TestFromAnnotations(t *testing.T) {
+	p, err := FromAnnotations(map[string]string{"unrelated": "value"})
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = FromAnnotations(map[string]string{
+		AnnotationMinReplicas:    "1",
+		AnnotationMaxReplicas:    "2",
+		AnnotationAllowedWindows: "Mon-Fri 08:00-20:00; Sat 10:00-12:00",
+		AnnotationTimezone:       "Asia/Tokyo",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), *p.MinReplicas)
+	assert.Equal(t, int32(2), *p.MaxReplicas)
+	assert.Equal(t, []string{"Mon-Fri 08:00-20:00", "Sat 10:00-12:00"}, p.AllowedWindows)
+	assert.Equal(t, "Asia/Tokyo", p.Timezone)
+
+	_, err = FromAnnotations(map[string]string{AnnotationMinReplicas: "3", AnnotationMaxReplicas: "2"})
+	assert.Error(t, err)
+}
+
+func TestEvaluate_ApprovalThreshold(t *testing.T) {
+	engine := newTestEngine(t, "namespaces:\n  project-b:\n    approvalReplicas: 1\n")
+
+	decision, err := engine.Evaluate(context.Background(), Request{
+		Namespace:   "project-b",
+		Name:        "sample-app-b",
+		Annotations: map[string]string{AnnotationApprovalReplicas: "2"},
+		Operation:   OperationScaleUp,
+		Replicas:    2,
+	})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.True(t, decision.RequiresApproval())
+	assert.Equal(t, []string{"requested 2 replicas exceeds approval threshold of 1 (namespace project-b policy)"}, decision.ApprovalReasons)
+
+	decision, err = engine.Evaluate(context.Background(), Request{
+		Namespace: "project-b",
+		Name:      "sample-app-b",
+		Operation: OperationScaleUp,
+		Replicas:  1,
+	})
+	require.NoError(t, err)
+	assert.False(t, decision.RequiresApproval())
+}
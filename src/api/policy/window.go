@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring weekly time window such as "Mon-Fri 08:00-20:00"
+type Window struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+	raw   string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses a window of the form "<days> <HH:MM>-<HH:MM>".
+// Days are a comma separated list of weekdays or ranges (e.g. "Mon-Fri",
+// "Sat,Sun", "*"). An end time earlier than the start wraps past midnight.
+func ParseWindow(value string) (Window, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return Window{}, fmt.Errorf("invalid window %q: expected \"<days> <HH:MM>-<HH:MM>\"", value)
+	}
+
+	window := Window{raw: value}
+	if err := parseDays(fields[0], &window.days); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", value, err)
+	}
+
+	start, end, found := strings.Cut(fields[1], "-")
+	if !found {
+		return Window{}, fmt.Errorf("invalid window %q: expected time range HH:MM-HH:MM", value)
+	}
+
+	var err error
+	if window.start, err = parseClock(start); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", value, err)
+	}
+	if window.end, err = parseClock(end); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", value, err)
+	}
+	if window.start == window.end {
+		return Window{}, fmt.Errorf("invalid window %q: start and end must differ", value)
+	}
+
+	return window, nil
+}
+
+// Contains reports whether t falls within the window in t's location
+func (w Window) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	if w.start < w.end {
+		return w.days[t.Weekday()] && offset >= w.start && offset < w.end
+	}
+
+	// Overnight window: the part after midnight belongs to the previous day
+	if offset >= w.start {
+		return w.days[t.Weekday()]
+	}
+	if offset < w.end {
+		return w.days[(t.Weekday()+6)%7]
+	}
+	return false
+}
+
+// NextStart returns the first time at or after t when the window opens
+func (w Window) NextStart(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		start := day.Add(w.start)
+		if !start.Before(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the window as it was declared
+func (w Window) String() string {
+	return w.raw
+}
+
+// parseDays parses a weekday list into a set
+func parseDays(value string, days *[7]bool) error {
+	if value == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		from, to, isRange := strings.Cut(strings.ToLower(part), "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", from)
+		}
+		if !isRange {
+			days[first] = true
+			continue
+		}
+
+		last, ok := weekdays[to]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", to)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into an offset from midnight. "24:00" is accepted as end of day.
+func parseClock(value string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", value)
+	}
+	if hours < 0 || hours > 24 || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
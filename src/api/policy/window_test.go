@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "weekday range", value: "Mon-Fri 08:00-20:00"},
+		{name: "day list", value: "Sat,Sun 10:00-12:00"},
+		{name: "every day", value: "* 00:00-24:00"},
+		{name: "overnight", value: "Fri 22:00-02:00"},
+		{name: "wrapping day range", value: "Fri-Mon 09:00-17:00"},
+		{name: "missing time", value: "Mon-Fri", wantErr: true},
+		{name: "unknown day", value: "Funday 08:00-20:00", wantErr: true},
+		{name: "bad time", value: "Mon 8:00-20:00", wantErr: true},
+		{name: "out of range", value: "Mon 08:00-25:00", wantErr: true},
+		{name: "empty range", value: "Mon 08:00-08:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWindow(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	// 2025-07-14 is a Monday
+	at := func(day int, hour, minute int) time.Time {
+		return time.Date(2025, 7, 14+day, hour, minute, 0, 0, time.UTC)
+	}
+
+	business, err := ParseWindow("Mon-Fri 08:00-20:00")
+	require.NoError(t, err)
+	assert.True(t, business.Contains(at(0, 8, 0)))
+	assert.True(t, business.Contains(at(4, 19, 59)))
+	assert.False(t, business.Contains(at(0, 20, 0)))
+	assert.False(t, business.Contains(at(0, 7, 59)))
+	assert.False(t, business.Contains(at(5, 12, 0)))
+
+	overnight, err := ParseWindow("Fri 22:00-02:00")
+	require.NoError(t, err)
+	assert.True(t, overnight.Contains(at(4, 23, 0)))
+	assert.True(t, overnight.Contains(at(5, 1, 0)))
+	assert.False(t, overnight.Contains(at(5, 23, 0)))
+	assert.False(t, overnight.Contains(at(4, 1, 0)))
+
+	weekend, err := ParseWindow("Fri-Mon 09:00-17:00")
+	require.NoError(t, err)
+	assert.True(t, weekend.Contains(at(6, 10, 0)))
+	assert.True(t, weekend.Contains(at(0, 10, 0)))
+	assert.False(t, weekend.Contains(at(2, 10, 0)))
+}
+
+func TestWindow_NextStart(t *testing.T) {
+	window, err := ParseWindow("Mon-Fri 08:00-20:00")
+	require.NoError(t, err)
+
+	// Friday evening -> Monday morning
+	friday := time.Date(2025, 7, 18, 21, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 7, 21, 8, 0, 0, 0, time.UTC), window.NextStart(friday))
+
+	// Tuesday early morning -> same day
+	tuesday := time.Date(2025, 7, 15, 6, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 7, 15, 8, 0, 0, 0, time.UTC), window.NextStart(tuesday))
+}
@@ -0,0 +1,402 @@
+package prepull
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/drift"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// AnnotationPrepull opts a deployment in to image pre-pulling with "true"
+const AnnotationPrepull = "scale-to-zero.io/prepull"
+
+// Labels and annotations of pre-pull DaemonSets
+const (
+	// LabelPrepullFor names the deployment a pre-pull DaemonSet pulls images for
+	LabelPrepullFor = "scale-to-zero.io/prepull-for"
+	// AnnotationExpiresAt is when a pre-pull DaemonSet is deleted even if the
+	// deployment is not available yet (RFC 3339)
+	AnnotationExpiresAt = "scale-to-zero.io/prepull-expires-at"
+)
+
+// Default configuration values
+const (
+	// DefaultInterval is how often deployments are checked for pre-pulling
+	DefaultInterval = 15 * time.Second
+	// DefaultMaxDuration bounds how long a pre-pull DaemonSet is kept, so
+	// images that cannot be pulled or run do not keep it crash-looping
+	DefaultMaxDuration = 15 * time.Minute
+)
+
+// Config holds pre-pull DaemonSet configuration
+type Config struct {
+	// Image runs after the images are pulled and keeps the pod ready
+	Image string
+	// Command runs in each pulled image. The images need a shell; images
+	// without one, e.g. distroless images, fail and are reported in Progress.
+	Command []string
+	// MaxDuration is how long a DaemonSet is kept at most
+	MaxDuration time.Duration
+}
+
+// NewConfig returns the default pre-pull configuration
+func NewConfig() *Config {
+	return &Config{
+		Image:       prewarm.DefaultImage,
+		Command:     []string{"sh", "-c", "exit 0"},
+		MaxDuration: DefaultMaxDuration,
+	}
+}
+
+// Progress is the state of a deployment's image pre-pull
+type Progress struct {
+	Images []string
+	// DesiredNodes is the number of nodes in the pool
+	DesiredNodes int32
+	// PulledNodes is the number of nodes that have pulled every image
+	PulledNodes int32
+	// FailedNodes is the number of nodes where an image could not be pulled
+	// or its command failed
+	FailedNodes int32
+	// Errors describes the failures, one entry per image and reason
+	Errors    []string
+	StartedAt time.Time
+}
+
+// Complete reports whether every node in the pool has pulled the images
+func (p *Progress) Complete() bool {
+	return p.DesiredNodes > 0 && p.PulledNodes >= p.DesiredNodes
+}
+
+// Manager pulls the container images of waking deployments onto the nodes of
+// their node pool. A DaemonSet targeting the pool runs each image as an init
+// container, so nodes added by the scale-up start pulling as soon as they
+// join, in parallel with the deployment's own pods being scheduled.
+type Manager struct {
+	k8sClient k8s.ClientInterface
+	clientset kubernetes.Interface
+	config    *Config
+	now       func() time.Time
+
+	// timedOut holds when the DaemonSets deleted at their expiry were deleted,
+	// so they are not recreated while placeholder pods are still pre-warming
+	timedOut map[string]time.Time
+}
+
+// NewManager creates a new pre-pull manager
+func NewManager(k8sClient k8s.ClientInterface, config *Config) *Manager {
+	return &Manager{
+		k8sClient: k8sClient,
+		clientset: k8sClient.GetClientset(),
+		config:    config,
+		now:       time.Now,
+		timedOut:  map[string]time.Time{},
+	}
+}
+
+// Enabled reports whether a deployment's annotations opt in to pre-pulling
+func Enabled(annotations map[string]string) bool {
+	return annotations[AnnotationPrepull] == "true"
+}
+
+// Run checks deployments every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(ctx); err != nil {
+				log.Printf("Failed to check deployments for image pre-pull: %v", err)
+			}
+		}
+	}
+}
+
+// Check creates a pre-pull DaemonSet for every opted-in deployment that is
+// waking up: scaled up from zero through the API within MaxDuration and not
+// available yet, or with placeholder pods pre-warming its nodes. The
+// DaemonSet is deleted once the deployment is available, scaled to zero or no
+// longer opted in, and at the latest after MaxDuration.
+func (m *Manager) Check(ctx context.Context) error {
+	deployments, err := m.k8sClient.ListDeployments(ctx, "", "")
+	if err != nil {
+		return err
+	}
+	daemonSets, err := m.clientset.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: LabelPrepullFor})
+	if err != nil {
+		return fmt.Errorf("failed to list pre-pull daemonsets: %w", err)
+	}
+	placeholders, err := m.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: prewarm.LabelPlaceholderFor})
+	if err != nil {
+		return fmt.Errorf("failed to list placeholder pods: %w", err)
+	}
+
+	now := m.now()
+	for key, at := range m.timedOut {
+		if now.Sub(at) > m.config.MaxDuration {
+			delete(m.timedOut, key)
+		}
+	}
+
+	prewarming := map[string]bool{}
+	for _, pod := range placeholders.Items {
+		prewarming[lock.Key(pod.Namespace, pod.Labels[prewarm.LabelPlaceholderFor])] = true
+	}
+	existing := map[string]*appsv1.DaemonSet{}
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		existing[lock.Key(ds.Namespace, ds.Labels[LabelPrepullFor])] = ds
+	}
+
+	for _, deployment := range deployments {
+		key := lock.Key(deployment.Namespace, deployment.Name)
+		if !Enabled(deployment.Annotations) {
+			continue
+		}
+		if ds, ok := existing[key]; ok {
+			starting := deployment.DesiredReplicas > 0 && deployment.AvailableReplicas < deployment.DesiredReplicas
+			if (starting || prewarming[key]) && !expired(ds, now) {
+				delete(existing, key)
+			}
+			continue
+		}
+		if _, ok := m.timedOut[key]; ok {
+			continue
+		}
+		if m.wakingFromZero(deployment, now) || prewarming[key] {
+			if err := m.create(ctx, deployment, now.Add(m.config.MaxDuration)); err != nil {
+				log.Printf("Failed to start image pre-pull for deployment %s: %v", key, err)
+			}
+		}
+	}
+
+	// The remaining DaemonSets are no longer needed or have expired
+	for key, ds := range existing {
+		err := m.clientset.AppsV1().DaemonSets(ds.Namespace).Delete(ctx, ds.Name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("Failed to delete pre-pull daemonset %s/%s: %v", ds.Namespace, ds.Name, err)
+			continue
+		}
+		if expired(ds, now) {
+			m.timedOut[key] = now
+			log.Printf("Image pre-pull for deployment %s did not finish within %s", key, m.config.MaxDuration)
+			continue
+		}
+		log.Printf("Finished image pre-pull for deployment %s", key)
+	}
+	return nil
+}
+
+// wakingFromZero reports whether a deployment was scaled up through the API
+// within MaxDuration and none of its pods is available yet. Deployments that
+// roll out or scale between non-zero counts already have the images on their
+// nodes.
+func (m *Manager) wakingFromZero(deployment *k8s.DeploymentStatus, now time.Time) bool {
+	if deployment.DesiredReplicas == 0 || deployment.AvailableReplicas > 0 {
+		return false
+	}
+	recorded, recordedAt, ok := drift.Recorded(deployment.Annotations)
+	return ok && recorded == deployment.DesiredReplicas && now.Sub(recordedAt) < m.config.MaxDuration
+}
+
+// expired reports whether a DaemonSet has reached its expiry. DaemonSets
+// without a valid expiry are treated as expired.
+func expired(ds *appsv1.DaemonSet, now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, ds.Annotations[AnnotationExpiresAt])
+	return err != nil || !now.Before(expiresAt)
+}
+
+// Progress returns the pre-pull state of a deployment, or nil when no images
+// are being pre-pulled for it
+func (m *Manager) Progress(ctx context.Context, namespace, name string) (*Progress, error) {
+	ds, err := m.clientset.AppsV1().DaemonSets(namespace).Get(ctx, daemonSetName(name), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pre-pull daemonset: %w", err)
+	}
+
+	progress := &Progress{
+		DesiredNodes: ds.Status.DesiredNumberScheduled,
+		PulledNodes:  ds.Status.NumberReady,
+		StartedAt:    ds.CreationTimestamp.Time,
+	}
+	for _, container := range ds.Spec.Template.Spec.InitContainers {
+		progress.Images = append(progress.Images, container.Image)
+	}
+
+	pods, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: LabelPrepullFor + "=" + name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pre-pull pods: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, pod := range pods.Items {
+		failures := podFailures(&pod)
+		if len(failures) == 0 {
+			continue
+		}
+		progress.FailedNodes++
+		for _, failure := range failures {
+			if !seen[failure] {
+				seen[failure] = true
+				progress.Errors = append(progress.Errors, failure)
+			}
+		}
+	}
+	sort.Strings(progress.Errors)
+	return progress, nil
+}
+
+// pullFailureReasons are the waiting reasons of init containers that will not
+// succeed without a change to the image or the pre-pull command
+var pullFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerError":       true,
+	"CreateContainerConfigError": true,
+	"RunContainerError":          true,
+}
+
+// podFailures describes the init containers of a pre-pull pod that failed
+func podFailures(pod *corev1.Pod) []string {
+	var failures []string
+	for _, status := range pod.Status.InitContainerStatuses {
+		switch {
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+			failures = append(failures, fmt.Sprintf("%s: exited with code %d", status.Image, status.State.Terminated.ExitCode))
+		case status.State.Waiting != nil && pullFailureReasons[status.State.Waiting.Reason]:
+			failure := fmt.Sprintf("%s: %s", status.Image, status.State.Waiting.Reason)
+			if last := status.LastTerminationState.Terminated; last != nil && last.ExitCode != 0 {
+				failure += fmt.Sprintf(" (exited with code %d)", last.ExitCode)
+			}
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// create creates the pre-pull DaemonSet of a deployment, deleted at
+// expiresAt at the latest
+func (m *Manager) create(ctx context.Context, status *k8s.DeploymentStatus, expiresAt time.Time) error {
+	deployment, err := m.clientset.AppsV1().Deployments(status.Namespace).Get(ctx, status.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment: %w", err)
+	}
+	ds, err := m.daemonSet(deployment, status.NodePool)
+	if err != nil {
+		return err
+	}
+	ds.Annotations = map[string]string{AnnotationExpiresAt: expiresAt.UTC().Format(time.RFC3339)}
+	if _, err := m.clientset.AppsV1().DaemonSets(ds.Namespace).Create(ctx, ds, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create pre-pull daemonset: %w", err)
+	}
+	log.Printf("Started image pre-pull for deployment %s/%s: %d images", deployment.Namespace, deployment.Name, len(ds.Spec.Template.Spec.InitContainers))
+	return nil
+}
+
+// daemonSet returns a DaemonSet running on the nodes the deployment's pods
+// are scheduled to, with an init container per image. nodePool is used when
+// the pod template does not select nodes itself.
+func (m *Manager) daemonSet(deployment *appsv1.Deployment, nodePool string) (*appsv1.DaemonSet, error) {
+	template := deployment.Spec.Template.Spec
+
+	nodeSelector := make(map[string]string, len(template.NodeSelector))
+	for k, v := range template.NodeSelector {
+		nodeSelector[k] = v
+	}
+	var affinity *corev1.Affinity
+	if template.Affinity != nil && template.Affinity.NodeAffinity != nil {
+		affinity = &corev1.Affinity{NodeAffinity: template.Affinity.NodeAffinity.DeepCopy()}
+	}
+	if len(nodeSelector) == 0 && affinity == nil {
+		// Without node selection the DaemonSet would pull onto every node
+		if nodePool == "" {
+			return nil, fmt.Errorf("deployment does not select a node pool")
+		}
+		nodeSelector[k8s.AgentPoolLabel] = nodePool
+	}
+	tolerations := make([]corev1.Toleration, len(template.Tolerations))
+	for i := range template.Tolerations {
+		template.Tolerations[i].DeepCopyInto(&tolerations[i])
+	}
+
+	var pulls []corev1.Container
+	seen := map[string]bool{}
+	for _, container := range append(append([]corev1.Container{}, template.InitContainers...), template.Containers...) {
+		if container.Image == "" || seen[container.Image] {
+			continue
+		}
+		seen[container.Image] = true
+		pulls = append(pulls, corev1.Container{
+			Name:            fmt.Sprintf("pull-%d", len(pulls)),
+			Image:           container.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         m.config.Command,
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("16Mi"),
+			}},
+		})
+	}
+
+	labels := map[string]string{
+		LabelPrepullFor:                deployment.Name,
+		"app.kubernetes.io/managed-by": "scale-api",
+	}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      daemonSetName(deployment.Name),
+			Namespace: deployment.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{LabelPrepullFor: deployment.Name}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector:                  nodeSelector,
+					Affinity:                      affinity,
+					Tolerations:                   tolerations,
+					ServiceAccountName:            template.ServiceAccountName,
+					ImagePullSecrets:              template.ImagePullSecrets,
+					AutomountServiceAccountToken:  ptr.To(false),
+					TerminationGracePeriodSeconds: ptr.To[int64](0),
+					InitContainers:                pulls,
+					Containers: []corev1.Container{{
+						Name:  "pause",
+						Image: m.config.Image,
+						Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("1m"),
+							corev1.ResourceMemory: resource.MustParse("8Mi"),
+						}},
+					}},
+				},
+			},
+		},
+	}, nil
+}
+
+// daemonSetName returns the name of a deployment's pre-pull DaemonSet
+func daemonSetName(deployment string) string {
+	return deployment + "-prepull"
+}
@@ -0,0 +1,248 @@
+package prepull
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func tritonDeployment(name string, nodeSelector map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-b"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeSelector:     nodeSelector,
+					Tolerations:      []corev1.Toleration{{Key: "sku", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "acr"}},
+					InitContainers:   []corev1.Container{{Name: "model-loader", Image: "example.azurecr.io/model-loader:1.0"}},
+					Containers: []corev1.Container{
+						{Name: "triton", Image: "nvcr.io/nvidia/tritonserver:24.05-py3"},
+						{Name: "metrics", Image: "example.azurecr.io/model-loader:1.0"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// wakingStatus returns a deployment scaled to desired through the API just now
+func wakingStatus(name string, available, desired int32, prepull bool) *k8s.DeploymentStatus {
+	status := mocks.MockDeploymentStatus(name, "project-b", available, desired)
+	status.NodePool = "projectb"
+	status.AvailableReplicas = available
+	status.Annotations = map[string]string{
+		k8s.AnnotationRecordedReplicas: strconv.Itoa(int(desired)),
+		k8s.AnnotationRecordedAt:       time.Now().UTC().Format(time.RFC3339),
+	}
+	if prepull {
+		status.Annotations[AnnotationPrepull] = "true"
+	}
+	return status
+}
+
+func setupManager(t *testing.T, objects ...runtime.Object) (*mocks.MockK8sClient, *fake.Clientset, *Manager) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset(objects...)
+	mockClient := mocks.NewMockK8sClient()
+	mockClient.On("GetClientset").Return(clientset)
+	return mockClient, clientset, NewManager(mockClient, NewConfig())
+}
+
+func TestCheck(t *testing.T) {
+	// Setup
+	mockClient, clientset, manager := setupManager(t,
+		tritonDeployment("triton", map[string]string{k8s.AgentPoolLabel: "projectb"}),
+		tritonDeployment("not-opted-in", nil),
+	)
+
+	// Mock expectations
+	waking := []*k8s.DeploymentStatus{wakingStatus("triton", 0, 2, true), wakingStatus("not-opted-in", 0, 2, false)}
+	mockClient.On("ListDeployments", mock.Anything, "", "").Return(waking, nil).Once()
+
+	// Test
+	require.NoError(t, manager.Check(context.Background()))
+
+	// Assert
+	daemonSets, err := clientset.AppsV1().DaemonSets("project-b").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, daemonSets.Items, 1)
+	ds := daemonSets.Items[0]
+	assert.Equal(t, "triton-prepull", ds.Name)
+	assert.NotEmpty(t, ds.Annotations[AnnotationExpiresAt])
+	assert.Equal(t, "triton", ds.Labels[LabelPrepullFor])
+
+	spec := ds.Spec.Template.Spec
+	assert.Equal(t, map[string]string{k8s.AgentPoolLabel: "projectb"}, spec.NodeSelector)
+	assert.Equal(t, "sku", spec.Tolerations[0].Key)
+	assert.Equal(t, "acr", spec.ImagePullSecrets[0].Name)
+	require.Len(t, spec.InitContainers, 2)
+	assert.Equal(t, "example.azurecr.io/model-loader:1.0", spec.InitContainers[0].Image)
+	assert.Equal(t, "nvcr.io/nvidia/tritonserver:24.05-py3", spec.InitContainers[1].Image)
+	assert.Equal(t, []string{"sh", "-c", "exit 0"}, spec.InitContainers[1].Command)
+
+	// The DaemonSet is deleted once the deployment is available
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{wakingStatus("triton", 2, 2, true)}, nil).Once()
+	require.NoError(t, manager.Check(context.Background()))
+
+	daemonSets, err = clientset.AppsV1().DaemonSets("project-b").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, daemonSets.Items)
+}
+
+func TestCheck_OnlyFromZero(t *testing.T) {
+	// Setup
+	mockClient, clientset, manager := setupManager(t, tritonDeployment("triton", nil))
+
+	scaledOut := wakingStatus("triton", 1, 3, true)
+	crashLooping := wakingStatus("triton", 0, 2, true)
+	crashLooping.Annotations[k8s.AnnotationRecordedAt] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	outsideAPI := wakingStatus("triton", 0, 2, true)
+	delete(outsideAPI.Annotations, k8s.AnnotationRecordedReplicas)
+
+	for _, status := range []*k8s.DeploymentStatus{scaledOut, crashLooping, outsideAPI} {
+		// Mock expectations
+		mockClient.On("ListDeployments", mock.Anything, "", "").Return([]*k8s.DeploymentStatus{status}, nil).Once()
+
+		// Test
+		require.NoError(t, manager.Check(context.Background()))
+
+		// Assert
+		daemonSets, err := clientset.AppsV1().DaemonSets("project-b").List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, daemonSets.Items)
+	}
+}
+
+func TestCheck_Expired(t *testing.T) {
+	// Setup
+	mockClient, clientset, manager := setupManager(t, tritonDeployment("triton", nil))
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{wakingStatus("triton", 0, 2, true)}, nil)
+	require.NoError(t, manager.Check(context.Background()))
+	_, err := clientset.AppsV1().DaemonSets("project-b").Get(context.Background(), "triton-prepull", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// Test: the deployment never becomes available
+	now = now.Add(DefaultMaxDuration)
+	require.NoError(t, manager.Check(context.Background()))
+
+	// Assert: the DaemonSet is deleted and not created again
+	_, err = clientset.AppsV1().DaemonSets("project-b").Get(context.Background(), "triton-prepull", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	now = now.Add(DefaultInterval)
+	require.NoError(t, manager.Check(context.Background()))
+	_, err = clientset.AppsV1().DaemonSets("project-b").Get(context.Background(), "triton-prepull", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestCheck_Prewarming(t *testing.T) {
+	// Setup
+	placeholder := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "triton-placeholder-abcde", Namespace: "project-b",
+		Labels: map[string]string{prewarm.LabelPlaceholderFor: "triton"},
+	}}
+	mockClient, clientset, manager := setupManager(t, tritonDeployment("triton", nil), placeholder)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "", "").
+		Return([]*k8s.DeploymentStatus{wakingStatus("triton", 0, 0, true)}, nil)
+
+	// Test
+	require.NoError(t, manager.Check(context.Background()))
+
+	// Assert: the resolved node pool is selected
+	ds, err := clientset.AppsV1().DaemonSets("project-b").Get(context.Background(), "triton-prepull", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{k8s.AgentPoolLabel: "projectb"}, ds.Spec.Template.Spec.NodeSelector)
+}
+
+func TestProgress(t *testing.T) {
+	// Setup
+	_, clientset, manager := setupManager(t)
+
+	// Test: nothing is pre-pulled
+	progress, err := manager.Progress(context.Background(), "project-b", "triton")
+	require.NoError(t, err)
+	assert.Nil(t, progress)
+
+	ds, err := manager.daemonSet(tritonDeployment("triton", nil), "projectb")
+	require.NoError(t, err)
+	ds.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberReady: 1}
+	_, err = clientset.AppsV1().DaemonSets("project-b").Create(context.Background(), ds, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Test
+	progress, err = manager.Progress(context.Background(), "project-b", "triton")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), progress.DesiredNodes)
+	assert.Equal(t, int32(1), progress.PulledNodes)
+	assert.False(t, progress.Complete())
+	assert.Len(t, progress.Images, 2)
+}
+
+func TestProgress_Failures(t *testing.T) {
+	// Setup
+	failedPod := func(name string, statuses ...corev1.ContainerStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-b", Labels: map[string]string{LabelPrepullFor: "triton"}},
+			Status:     corev1.PodStatus{InitContainerStatuses: statuses},
+		}
+	}
+	noShell := corev1.ContainerStatus{
+		Image:                "gcr.io/distroless/static:nonroot",
+		State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 128}},
+	}
+	pulling := corev1.ContainerStatus{
+		Image: "nvcr.io/nvidia/tritonserver:24.05-py3",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+	}
+	_, clientset, manager := setupManager(t,
+		failedPod("triton-prepull-a", noShell),
+		failedPod("triton-prepull-b", noShell),
+		failedPod("triton-prepull-c", pulling),
+	)
+	ds, err := manager.daemonSet(tritonDeployment("triton", nil), "projectb")
+	require.NoError(t, err)
+	ds.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 3}
+	_, err = clientset.AppsV1().DaemonSets("project-b").Create(context.Background(), ds, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Test
+	progress, err := manager.Progress(context.Background(), "project-b", "triton")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), progress.FailedNodes)
+	assert.Equal(t, []string{"gcr.io/distroless/static:nonroot: CrashLoopBackOff (exited with code 128)"}, progress.Errors)
+	assert.False(t, progress.Complete())
+}
+
+func TestDaemonSet_NoNodePool(t *testing.T) {
+	_, _, manager := setupManager(t)
+
+	_, err := manager.daemonSet(tritonDeployment("triton", nil), "")
+	assert.ErrorContains(t, err, "does not select a node pool")
+}
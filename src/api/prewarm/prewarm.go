@@ -0,0 +1,288 @@
+package prewarm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// Labels and annotations of placeholder pods
+const (
+	// LabelPlaceholderFor names the deployment a placeholder pod warms a node for
+	LabelPlaceholderFor = "scale-to-zero.io/placeholder-for"
+	// AnnotationExpiresAt is when a placeholder pod is deleted even if the
+	// deployment's pods have not been scheduled (RFC 3339)
+	AnnotationExpiresAt = "scale-to-zero.io/placeholder-expires-at"
+)
+
+// Default configuration values
+const (
+	// DefaultPriorityClassName is the PriorityClass of placeholder pods. Its
+	// negative priority lets any workload preempt them, while staying above
+	// the cluster autoscaler's expendable pods cutoff (-10).
+	DefaultPriorityClassName = "scale-api-placeholder"
+	// DefaultImage is the pause image AKS nodes already have
+	DefaultImage = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+)
+
+// Config holds placeholder pod configuration
+type Config struct {
+	PriorityClassName string
+	Image             string
+}
+
+// NewConfig returns the default placeholder pod configuration
+func NewConfig() *Config {
+	return &Config{
+		PriorityClassName: DefaultPriorityClassName,
+		Image:             DefaultImage,
+	}
+}
+
+// Manager creates placeholder pods that make the cluster autoscaler add the
+// nodes a deployment needs before it is scaled up, and deletes them once the
+// deployment's own pods are scheduled
+type Manager struct {
+	clientset kubernetes.Interface
+	config    *Config
+	now       func() time.Time
+}
+
+// NewManager creates a new pre-warm manager
+func NewManager(clientset kubernetes.Interface, config *Config) *Manager {
+	return &Manager{
+		clientset: clientset,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// Ensure makes sure count placeholder pods exist for a deployment, each
+// requesting the resources of one of its pods on the same nodes. They are
+// deleted at expiresAt at the latest. It returns the number of pods created.
+func (m *Manager) Ensure(ctx context.Context, namespace, name string, count int32, expiresAt time.Time) (int, error) {
+	existing, err := m.placeholders(ctx, namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	missing := int(count) - len(existing)
+	if missing <= 0 {
+		return 0, nil
+	}
+
+	deployment, err := m.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	created := 0
+	for i := 0; i < missing; i++ {
+		pod := m.placeholderPod(deployment, expiresAt)
+		if _, err := m.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+			return created, fmt.Errorf("failed to create placeholder pod: %w", err)
+		}
+		created++
+	}
+	log.Printf("Created %d placeholder pods to pre-warm nodes for deployment %s/%s", created, namespace, name)
+	return created, nil
+}
+
+// Release deletes the placeholder pods of a deployment once all of its pods
+// are scheduled. It reports whether no placeholders remain.
+func (m *Manager) Release(ctx context.Context, namespace, name string) (bool, error) {
+	placeholders, err := m.placeholders(ctx, namespace, name)
+	if err != nil || len(placeholders) == 0 {
+		return err == nil, err
+	}
+
+	deployment, err := m.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	scheduled, err := m.scheduledPods(ctx, deployment)
+	if err != nil {
+		return false, err
+	}
+	if scheduled < ptr.Deref(deployment.Spec.Replicas, 1) {
+		return false, nil
+	}
+
+	if err := m.delete(ctx, placeholders); err != nil {
+		return false, err
+	}
+	log.Printf("Deleted %d placeholder pods: deployment %s/%s is scheduled", len(placeholders), namespace, name)
+	return true, nil
+}
+
+// Sweep deletes expired placeholder pods in all namespaces, e.g. when a
+// schedule was removed or the deployment never scaled up
+func (m *Manager) Sweep(ctx context.Context) error {
+	pods, err := m.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: LabelPlaceholderFor})
+	if err != nil {
+		return fmt.Errorf("failed to list placeholder pods: %w", err)
+	}
+
+	now := m.now()
+	var expired []corev1.Pod
+	for _, pod := range pods.Items {
+		expiresAt, err := time.Parse(time.RFC3339, pod.Annotations[AnnotationExpiresAt])
+		if err != nil || !now.Before(expiresAt) {
+			expired = append(expired, pod)
+		}
+	}
+	if len(expired) > 0 {
+		log.Printf("Deleting %d expired placeholder pods", len(expired))
+	}
+	return m.delete(ctx, expired)
+}
+
+// placeholders lists the placeholder pods of a deployment
+func (m *Manager) placeholders(ctx context.Context, namespace, name string) ([]corev1.Pod, error) {
+	selector := labels.SelectorFromSet(labels.Set{LabelPlaceholderFor: name}).String()
+	pods, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list placeholder pods: %w", err)
+	}
+	return pods.Items, nil
+}
+
+// scheduledPods counts the deployment's pods that are bound to a node
+func (m *Manager) scheduledPods(ctx context.Context, deployment *appsv1.Deployment) (int32, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return 0, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+	pods, err := m.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var scheduled int32
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil && pod.Labels[LabelPlaceholderFor] == "" {
+			scheduled++
+		}
+	}
+	return scheduled, nil
+}
+
+// delete deletes pods, ignoring those already gone
+func (m *Manager) delete(ctx context.Context, pods []corev1.Pod) error {
+	for _, pod := range pods {
+		err := m.clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To[int64](0)})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete placeholder pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// placeholderPod returns a pause pod scheduled like one pod of the deployment
+func (m *Manager) placeholderPod(deployment *appsv1.Deployment, expiresAt time.Time) *corev1.Pod {
+	template := deployment.Spec.Template.Spec
+
+	var affinity *corev1.Affinity
+	if template.Affinity != nil && template.Affinity.NodeAffinity != nil {
+		affinity = &corev1.Affinity{NodeAffinity: template.Affinity.NodeAffinity.DeepCopy()}
+	}
+	tolerations := make([]corev1.Toleration, len(template.Tolerations))
+	for i := range template.Tolerations {
+		template.Tolerations[i].DeepCopyInto(&tolerations[i])
+	}
+	nodeSelector := make(map[string]string, len(template.NodeSelector))
+	for k, v := range template.NodeSelector {
+		nodeSelector[k] = v
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deployment.Name + "-placeholder-" + utilrand.String(5),
+			Namespace: deployment.Namespace,
+			Labels: map[string]string{
+				LabelPlaceholderFor:            deployment.Name,
+				"app.kubernetes.io/managed-by": "scale-api",
+			},
+			Annotations: map[string]string{
+				AnnotationExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: corev1.PodSpec{
+			PriorityClassName:             m.config.PriorityClassName,
+			NodeSelector:                  nodeSelector,
+			Affinity:                      affinity,
+			Tolerations:                   tolerations,
+			TerminationGracePeriodSeconds: ptr.To[int64](0),
+			AutomountServiceAccountToken:  ptr.To(false),
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   ptr.To(true),
+				RunAsUser:      ptr.To[int64](65535),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name:      "placeholder",
+				Image:     m.config.Image,
+				Resources: podResources(template.Containers),
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: ptr.To(false),
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+}
+
+// podResources sums the requests of containers, so the placeholder needs the
+// same room on a node. Extended resources such as GPUs are only set as
+// limits; they are copied as limits and requests.
+func podResources(containers []corev1.Container) corev1.ResourceRequirements {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	add := func(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+		sum := list[name]
+		sum.Add(quantity)
+		list[name] = sum
+	}
+
+	for _, container := range containers {
+		for name, quantity := range container.Resources.Requests {
+			add(requests, name, quantity)
+		}
+		for name, quantity := range container.Resources.Limits {
+			if isStandardResource(name) {
+				continue
+			}
+			add(limits, name, quantity)
+			if _, ok := container.Resources.Requests[name]; !ok {
+				add(requests, name, quantity)
+			}
+		}
+	}
+
+	resources := corev1.ResourceRequirements{}
+	if len(requests) > 0 {
+		resources.Requests = requests
+	}
+	if len(limits) > 0 {
+		resources.Limits = limits
+	}
+	return resources
+}
+
+// isStandardResource reports whether a resource may be requested below its limit
+func isStandardResource(name corev1.ResourceName) bool {
+	switch name {
+	case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package prewarm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func gpuDeployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b", Namespace: "project-b"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-app-b"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "sample-app-b"}},
+				Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{"kubernetes.azure.com/agentpool": "projectb"},
+					Tolerations:  []corev1.Toleration{{Key: "sku", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+					Containers: []corev1.Container{
+						{
+							Name: "model",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("8Gi")},
+								Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi"), "nvidia.com/gpu": resource.MustParse("1")},
+							},
+						},
+						{
+							Name:      "sidecar",
+							Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func listPlaceholders(t *testing.T, clientset *fake.Clientset) []corev1.Pod {
+	t.Helper()
+	pods, err := clientset.CoreV1().Pods("project-b").List(context.Background(), metav1.ListOptions{LabelSelector: LabelPlaceholderFor})
+	require.NoError(t, err)
+	return pods.Items
+}
+
+func TestEnsure(t *testing.T) {
+	// Setup
+	clientset := fake.NewSimpleClientset(gpuDeployment(0))
+	manager := NewManager(clientset, NewConfig())
+	expiresAt := time.Date(2025, 7, 14, 9, 15, 0, 0, time.UTC)
+
+	// Test
+	created, err := manager.Ensure(context.Background(), "project-b", "sample-app-b", 2, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	// Existing placeholders are counted
+	created, err = manager.Ensure(context.Background(), "project-b", "sample-app-b", 2, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	// Assert
+	pods := listPlaceholders(t, clientset)
+	require.Len(t, pods, 2)
+	pod := pods[0]
+	assert.Equal(t, "sample-app-b", pod.Labels[LabelPlaceholderFor])
+	assert.Equal(t, "2025-07-14T09:15:00Z", pod.Annotations[AnnotationExpiresAt])
+	assert.Equal(t, DefaultPriorityClassName, pod.Spec.PriorityClassName)
+	assert.Equal(t, map[string]string{"kubernetes.azure.com/agentpool": "projectb"}, pod.Spec.NodeSelector)
+	assert.Equal(t, "sku", pod.Spec.Tolerations[0].Key)
+
+	resources := pod.Spec.Containers[0].Resources
+	assert.True(t, resources.Requests.Cpu().Equal(resource.MustParse("2500m")))
+	assert.True(t, resources.Requests.Memory().Equal(resource.MustParse("8Gi")))
+	gpus := resources.Limits["nvidia.com/gpu"]
+	assert.Equal(t, int64(1), gpus.Value())
+	gpus = resources.Requests["nvidia.com/gpu"]
+	assert.Equal(t, int64(1), gpus.Value())
+	_, ok := resources.Limits[corev1.ResourceMemory]
+	assert.False(t, ok)
+}
+
+func TestRelease(t *testing.T) {
+	// Setup
+	clientset := fake.NewSimpleClientset(gpuDeployment(1))
+	manager := NewManager(clientset, NewConfig())
+	_, err := manager.Ensure(context.Background(), "project-b", "sample-app-b", 1, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	pending := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "sample-app-b-abc", Namespace: "project-b", Labels: map[string]string{"app": "sample-app-b"},
+	}}
+	_, err = clientset.CoreV1().Pods("project-b").Create(context.Background(), pending, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Test: kept while the real pod is pending
+	released, err := manager.Release(context.Background(), "project-b", "sample-app-b")
+	require.NoError(t, err)
+	assert.False(t, released)
+	assert.Len(t, listPlaceholders(t, clientset), 1)
+
+	// Test: deleted once it is scheduled
+	pending.Spec.NodeName = "aks-projectb-12345-vmss000000"
+	_, err = clientset.CoreV1().Pods("project-b").Update(context.Background(), pending, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	released, err = manager.Release(context.Background(), "project-b", "sample-app-b")
+	require.NoError(t, err)
+	assert.True(t, released)
+	assert.Empty(t, listPlaceholders(t, clientset))
+}
+
+func TestSweep(t *testing.T) {
+	// Setup
+	clientset := fake.NewSimpleClientset(gpuDeployment(0))
+	manager := NewManager(clientset, NewConfig())
+	now := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+
+	_, err := manager.Ensure(context.Background(), "project-b", "sample-app-b", 1, now.Add(-time.Minute))
+	require.NoError(t, err)
+
+	// Test
+	require.NoError(t, manager.Sweep(context.Background()))
+
+	// Assert
+	assert.Empty(t, listPlaceholders(t, clientset))
+}
@@ -0,0 +1,277 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Default quota ConfigMap location
+const (
+	DefaultConfigMapNamespace = "scale-system"
+	DefaultConfigMapName      = "scale-quotas"
+	DefaultCacheTTL           = 30 * time.Second
+
+	// configMapKey is the ConfigMap data key holding the quota document
+	configMapKey = "quotas.yaml"
+)
+
+// Quota limits what the scaled-up workloads of a namespace may consume in total
+type Quota struct {
+	// MaxReplicas is the total desired replicas across all deployments
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// MaxGPUs is the total nvidia.com/gpu requested by all replicas
+	MaxGPUs *int64 `json:"maxGPUs,omitempty"`
+}
+
+// Document is the quota ConfigMap content. A namespace entry replaces the
+// defaults field by field.
+type Document struct {
+	Defaults   *Quota            `json:"defaults,omitempty"`
+	Namespaces map[string]*Quota `json:"namespaces,omitempty"`
+}
+
+// DeploymentUsage is what one deployment contributes to its namespace usage
+type DeploymentUsage struct {
+	Name       string
+	Replicas   int32
+	GPUsPerPod int64
+	GPUs       int64
+}
+
+// Usage is the current consumption of a namespace against its quota
+type Usage struct {
+	Namespace    string
+	Quota        Quota
+	UsedReplicas int32
+	UsedGPUs     int64
+	Deployments  []DeploymentUsage
+}
+
+// Decision is the outcome of a quota check
+type Decision struct {
+	Allowed bool
+	// Violations explain why the request was denied
+	Violations []string
+	// Replicas and GPUs are the namespace totals after the operation
+	Replicas int32
+	GPUs     int64
+}
+
+// Config holds quota engine configuration
+type Config struct {
+	ConfigMapNamespace string
+	ConfigMapName      string
+	CacheTTL           time.Duration
+}
+
+// NewConfig returns the default quota engine configuration
+func NewConfig() *Config {
+	return &Config{
+		ConfigMapNamespace: DefaultConfigMapNamespace,
+		ConfigMapName:      DefaultConfigMapName,
+		CacheTTL:           DefaultCacheTTL,
+	}
+}
+
+// Engine enforces per-namespace replica and GPU quotas declared in the quota ConfigMap
+type Engine struct {
+	clientset kubernetes.Interface
+	config    *Config
+	now       func() time.Time
+
+	mu       sync.Mutex
+	cached   *Document
+	cachedAt time.Time
+}
+
+// NewEngine creates a new quota engine
+func NewEngine(clientset kubernetes.Interface, config *Config) *Engine {
+	return &Engine{
+		clientset: clientset,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// Usage returns the quota of a namespace and the replicas and GPUs currently
+// requested by its deployments
+func (e *Engine) Usage(ctx context.Context, namespace string) (*Usage, error) {
+	doc, err := e.document(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := e.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments in namespace %s: %w", namespace, err)
+	}
+
+	usage := &Usage{
+		Namespace: namespace,
+		Quota:     doc.quotaFor(namespace),
+	}
+	for _, deployment := range deployments.Items {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if replicas == 0 {
+			continue
+		}
+
+		gpusPerPod := k8s.GPUsPerPod(&deployment.Spec.Template.Spec)
+		usage.Deployments = append(usage.Deployments, DeploymentUsage{
+			Name:       deployment.Name,
+			Replicas:   replicas,
+			GPUsPerPod: gpusPerPod,
+			GPUs:       int64(replicas) * gpusPerPod,
+		})
+		usage.UsedReplicas += replicas
+		usage.UsedGPUs += int64(replicas) * gpusPerPod
+	}
+
+	sort.Slice(usage.Deployments, func(i, j int) bool {
+		return usage.Deployments[i].Name < usage.Deployments[j].Name
+	})
+	return usage, nil
+}
+
+// Check reports whether scaling namespace/name to replicas keeps the namespace
+// within its quota. Operations that do not increase usage are always allowed,
+// so a namespace over quota can still be scaled down.
+func (e *Engine) Check(ctx context.Context, namespace, name string, replicas int32) (*Decision, error) {
+	usage, err := e.Usage(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	gpusPerPod, err := e.gpusPerPod(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &Decision{
+		Allowed:  true,
+		Replicas: usage.UsedReplicas,
+		GPUs:     usage.UsedGPUs,
+	}
+	for _, d := range usage.Deployments {
+		if d.Name == name {
+			decision.Replicas -= d.Replicas
+			decision.GPUs -= d.GPUs
+		}
+	}
+	decision.Replicas += replicas
+	decision.GPUs += int64(replicas) * gpusPerPod
+
+	if limit := usage.Quota.MaxReplicas; limit != nil && decision.Replicas > *limit && decision.Replicas > usage.UsedReplicas {
+		decision.Allowed = false
+		decision.Violations = append(decision.Violations, fmt.Sprintf(
+			"namespace %s would use %d replicas, exceeding its quota of %d (currently %d)",
+			namespace, decision.Replicas, *limit, usage.UsedReplicas))
+	}
+	if limit := usage.Quota.MaxGPUs; limit != nil && decision.GPUs > *limit && decision.GPUs > usage.UsedGPUs {
+		decision.Allowed = false
+		decision.Violations = append(decision.Violations, fmt.Sprintf(
+			"namespace %s would use %d GPUs, exceeding its quota of %d (currently %d)",
+			namespace, decision.GPUs, *limit, usage.UsedGPUs))
+	}
+	return decision, nil
+}
+
+// gpusPerPod returns the GPUs requested by one replica of the deployment
+func (e *Engine) gpusPerPod(ctx context.Context, namespace, name string) (int64, error) {
+	deployment, err := e.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+	}
+	return k8s.GPUsPerPod(&deployment.Spec.Template.Spec), nil
+}
+
+// quotaFor merges the namespace entry over the defaults
+func (d *Document) quotaFor(namespace string) Quota {
+	var q Quota
+	if d.Defaults != nil {
+		q = *d.Defaults
+	}
+	if ns := d.Namespaces[namespace]; ns != nil {
+		if ns.MaxReplicas != nil {
+			q.MaxReplicas = ns.MaxReplicas
+		}
+		if ns.MaxGPUs != nil {
+			q.MaxGPUs = ns.MaxGPUs
+		}
+	}
+	return q
+}
+
+// Validate checks that the quota limits are consistent
+func (q *Quota) Validate() error {
+	if q.MaxReplicas != nil && *q.MaxReplicas < 0 {
+		return fmt.Errorf("maxReplicas must not be negative")
+	}
+	if q.MaxGPUs != nil && *q.MaxGPUs < 0 {
+		return fmt.Errorf("maxGPUs must not be negative")
+	}
+	return nil
+}
+
+// ParseDocument parses and validates a quota document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse quota document: %w", err)
+	}
+
+	if doc.Defaults != nil {
+		if err := doc.Defaults.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid quota defaults: %w", err)
+		}
+	}
+	for name, q := range doc.Namespaces {
+		if q == nil {
+			continue
+		}
+		if err := q.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid quota namespaces.%s: %w", name, err)
+		}
+	}
+	return doc, nil
+}
+
+// document returns the quota document from the ConfigMap, cached for CacheTTL
+func (e *Engine) document(ctx context.Context) (*Document, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cached != nil && e.now().Sub(e.cachedAt) < e.config.CacheTTL {
+		return e.cached, nil
+	}
+
+	configMap, err := e.clientset.CoreV1().ConfigMaps(e.config.ConfigMapNamespace).Get(ctx, e.config.ConfigMapName, metav1.GetOptions{})
+	var doc *Document
+	switch {
+	case k8serrors.IsNotFound(err):
+		doc = &Document{}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get quota configmap %s/%s: %w", e.config.ConfigMapNamespace, e.config.ConfigMapName, err)
+	default:
+		doc, err = ParseDocument([]byte(configMap.Data[configMapKey]))
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %w", e.config.ConfigMapNamespace, e.config.ConfigMapName, err)
+		}
+	}
+
+	e.cached = doc
+	e.cachedAt = e.now()
+	return doc, nil
+}
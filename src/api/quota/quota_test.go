@@ -0,0 +1,160 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+const testDocument = `
+defaults:
+  maxReplicas: 20
+namespaces:
+  project-b:
+    maxReplicas: 5
+    maxGPUs: 3
+`
+
+func deployment(namespace, name string, replicas int32, gpus int64) *appsv1.Deployment {
+	container := corev1.Container{Name: name}
+	if gpus > 0 {
+		container.Resources.Limits = corev1.ResourceList{
+			"nvidia.com/gpu": *resource.NewQuantity(gpus, resource.DecimalSI),
+		}
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{container}}},
+		},
+	}
+}
+
+func newTestEngine(t *testing.T, document string, objects ...runtime.Object) *Engine {
+	t.Helper()
+
+	objects = append(objects, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultConfigMapName, Namespace: DefaultConfigMapNamespace},
+		Data:       map[string]string{configMapKey: document},
+	})
+	return NewEngine(fake.NewSimpleClientset(objects...), NewConfig())
+}
+
+func TestUsage(t *testing.T) {
+	engine := newTestEngine(t, testDocument,
+		deployment("project-b", "inference", 2, 1),
+		deployment("project-b", "api", 1, 0),
+		deployment("project-b", "idle", 0, 1),
+		deployment("project-a", "other", 3, 1),
+	)
+
+	usage, err := engine.Usage(context.Background(), "project-b")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), *usage.Quota.MaxReplicas)
+	assert.Equal(t, int64(3), *usage.Quota.MaxGPUs)
+	assert.Equal(t, int32(3), usage.UsedReplicas)
+	assert.Equal(t, int64(2), usage.UsedGPUs)
+	assert.Equal(t, []DeploymentUsage{
+		{Name: "api", Replicas: 1},
+		{Name: "inference", Replicas: 2, GPUsPerPod: 1, GPUs: 2},
+	}, usage.Deployments)
+}
+
+func TestUsage_DefaultsApply(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	usage, err := engine.Usage(context.Background(), "project-c")
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(20), *usage.Quota.MaxReplicas)
+	assert.Nil(t, usage.Quota.MaxGPUs)
+}
+
+func TestCheck_GPUQuotaExceeded(t *testing.T) {
+	engine := newTestEngine(t, testDocument,
+		deployment("project-b", "inference", 2, 1),
+		deployment("project-b", "training", 0, 2),
+	)
+
+	decision, err := engine.Check(context.Background(), "project-b", "training", 1)
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, int64(4), decision.GPUs)
+	assert.Equal(t, []string{"namespace project-b would use 4 GPUs, exceeding its quota of 3 (currently 2)"}, decision.Violations)
+}
+
+func TestCheck_ReplacesCurrentReplicas(t *testing.T) {
+	engine := newTestEngine(t, testDocument,
+		deployment("project-b", "inference", 2, 1),
+	)
+
+	// Scaling inference from 2 to 3 replicas uses 3 GPUs in total, not 5
+	decision, err := engine.Check(context.Background(), "project-b", "inference", 3)
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, int32(3), decision.Replicas)
+	assert.Equal(t, int64(3), decision.GPUs)
+}
+
+func TestCheck_ReplicaQuotaExceeded(t *testing.T) {
+	engine := newTestEngine(t, testDocument,
+		deployment("project-b", "api", 4, 0),
+		deployment("project-b", "web", 0, 0),
+	)
+
+	decision, err := engine.Check(context.Background(), "project-b", "web", 2)
+
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Contains(t, decision.Violations[0], "would use 6 replicas, exceeding its quota of 5")
+}
+
+func TestCheck_OverQuotaNamespaceCanShrink(t *testing.T) {
+	// The quota was lowered after the deployment was scaled up
+	engine := newTestEngine(t, testDocument,
+		deployment("project-b", "inference", 5, 1),
+	)
+
+	decision, err := engine.Check(context.Background(), "project-b", "inference", 4)
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestCheck_NoConfigMap(t *testing.T) {
+	engine := NewEngine(fake.NewSimpleClientset(deployment("project-b", "inference", 0, 1)), NewConfig())
+
+	decision, err := engine.Check(context.Background(), "project-b", "inference", 100)
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestCheck_DeploymentNotFound(t *testing.T) {
+	engine := newTestEngine(t, testDocument)
+
+	_, err := engine.Check(context.Background(), "project-b", "missing", 1)
+
+	assert.Error(t, err)
+}
+
+func TestParseDocument_Invalid(t *testing.T) {
+	_, err := ParseDocument([]byte("namespaces:\n  project-b:\n    maxGPUs: -1\n"))
+	assert.ErrorContains(t, err, "maxGPUs must not be negative")
+
+	_, err = ParseDocument([]byte("defaults:\n  maxPods: 1\n"))
+	assert.Error(t, err)
+}
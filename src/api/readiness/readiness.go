@@ -0,0 +1,382 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// AnnotationChecks declares the readiness checks of a deployment as a JSON or
+// YAML list of Check
+const AnnotationChecks = "scale-to-zero.io/readiness-checks"
+
+// Built-in check types
+const (
+	TypeHTTP   = "http"
+	TypeTriton = "triton"
+	TypeTCP    = "tcp"
+)
+
+// Default check settings
+const (
+	DefaultTimeout    = 5 * time.Second
+	DefaultTritonPort = 8000
+	// maxBodySize bounds how much of a response body is searched
+	maxBodySize = 1 << 20
+)
+
+// Check is a readiness check declared on a deployment. Checks run against
+// every ready pod of the deployment, or once against Host when it is set.
+type Check struct {
+	Type string `json:"type"`
+	// Name identifies the check in results and defaults to the type
+	Name string `json:"name,omitempty"`
+	// Host names a Service of the deployment: a Service in the same
+	// namespace selecting its pods, optionally qualified with the namespace
+	// ("svc.namespace", "svc.namespace.svc[.cluster.local]"). The check
+	// connects to the Service's cluster IP.
+	Host string `json:"host,omitempty"`
+	Port int32  `json:"port,omitempty"`
+
+	// HTTP checks
+	Scheme       string `json:"scheme,omitempty"`
+	Path         string `json:"path,omitempty"`
+	ExpectStatus int    `json:"expectStatus,omitempty"`
+	// ExpectBody must be contained in the response body
+	ExpectBody string `json:"expectBody,omitempty"`
+
+	// Triton checks
+	Model   string `json:"model,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Verifier checks whether one address ("host:port") is ready
+type Verifier interface {
+	Verify(ctx context.Context, address string) error
+}
+
+// Factory builds the verifier of a declared check
+type Factory func(check Check, client *http.Client) (Verifier, error)
+
+// Result is the outcome of a check against one target
+type Result struct {
+	Name    string
+	Type    string
+	Target  string
+	Ready   bool
+	Message string
+}
+
+// Report is the outcome of all readiness checks of a deployment
+type Report struct {
+	Ready     bool
+	Results   []Result
+	CheckedAt time.Time
+}
+
+// ParseChecks parses the readiness checks declared in a deployment's
+// annotations. It returns nil when none are declared.
+func ParseChecks(annotations map[string]string) ([]Check, error) {
+	value := strings.TrimSpace(annotations[AnnotationChecks])
+	if value == "" {
+		return nil, nil
+	}
+	var checks []Check
+	if err := yaml.UnmarshalStrict([]byte(value), &checks); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", AnnotationChecks, err)
+	}
+	for i := range checks {
+		if checks[i].Type == "" {
+			return nil, fmt.Errorf("invalid %s annotation: check %d has no type", AnnotationChecks, i)
+		}
+		if checks[i].Name == "" {
+			checks[i].Name = checks[i].Type
+		}
+	}
+	return checks, nil
+}
+
+// Checker runs the readiness checks declared on deployments after they are
+// scaled up. Verifiers for further check types can be registered.
+type Checker struct {
+	clientset  kubernetes.Interface
+	httpClient *http.Client
+	factories  map[string]Factory
+	now        func() time.Time
+}
+
+// NewChecker creates a new readiness checker with the built-in check types.
+// Each check times out after timeout.
+func NewChecker(clientset kubernetes.Interface, timeout time.Duration) *Checker {
+	c := &Checker{
+		clientset:  clientset,
+		httpClient: &http.Client{Timeout: timeout},
+		factories:  map[string]Factory{},
+		now:        time.Now,
+	}
+	c.Register(TypeHTTP, newHTTPVerifier)
+	c.Register(TypeTriton, newTritonVerifier)
+	c.Register(TypeTCP, newTCPVerifier)
+	return c
+}
+
+// Register adds or replaces the verifier factory of a check type
+func (c *Checker) Register(checkType string, factory Factory) {
+	c.factories[checkType] = factory
+}
+
+// Check runs the readiness checks declared on a deployment. It returns nil
+// when the deployment declares no checks.
+func (c *Checker) Check(ctx context.Context, namespace, name string, annotations map[string]string) (*Report, error) {
+	checks, err := ParseChecks(annotations)
+	if err != nil || len(checks) == 0 {
+		return nil, err
+	}
+
+	verifiers := make([]Verifier, len(checks))
+	for i, check := range checks {
+		factory, ok := c.factories[check.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown readiness check type %q", check.Type)
+		}
+		if verifiers[i], err = factory(check, c.httpClient); err != nil {
+			return nil, fmt.Errorf("invalid readiness check %q: %w", check.Name, err)
+		}
+	}
+
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	var podIPs []string
+	serviceIPs := map[string]string{}
+	for _, check := range checks {
+		if check.Host != "" {
+			if serviceIPs[check.Host], err = c.serviceIP(ctx, deployment, check.Host); err != nil {
+				return nil, fmt.Errorf("invalid readiness check %q: %w", check.Name, err)
+			}
+		} else if podIPs == nil {
+			if podIPs, err = c.readyPodIPs(ctx, deployment); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	report := &Report{Ready: true, CheckedAt: c.now()}
+	for i, check := range checks {
+		hosts := podIPs
+		if check.Host != "" {
+			hosts = []string{serviceIPs[check.Host]}
+		}
+		if len(hosts) == 0 {
+			report.Ready = false
+			report.Results = append(report.Results, Result{Name: check.Name, Type: check.Type, Message: "no ready pods"})
+			continue
+		}
+		for _, host := range hosts {
+			address := net.JoinHostPort(host, strconv.Itoa(int(port(check))))
+			result := Result{Name: check.Name, Type: check.Type, Target: address, Ready: true}
+			if err := verifiers[i].Verify(ctx, address); err != nil {
+				result.Ready = false
+				result.Message = err.Error()
+				report.Ready = false
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// serviceIP resolves the host of a check to the cluster IP of a Service of
+// the deployment. Other hosts are rejected so an annotation cannot make the
+// API probe arbitrary endpoints.
+func (c *Checker) serviceIP(ctx context.Context, deployment *appsv1.Deployment, host string) (string, error) {
+	name := serviceName(host, deployment.Namespace)
+	if name == "" {
+		return "", fmt.Errorf("host %q is not a Service in namespace %s", host, deployment.Namespace)
+	}
+	service, err := c.clientset.CoreV1().Services(deployment.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("host %q is not a Service in namespace %s", host, deployment.Namespace)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get service %s: %w", name, err)
+	}
+	if len(service.Spec.Selector) == 0 || !labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(deployment.Spec.Template.Labels)) {
+		return "", fmt.Errorf("service %s does not select the pods of deployment %s", name, deployment.Name)
+	}
+	if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone {
+		return "", fmt.Errorf("service %s has no cluster IP", name)
+	}
+	return service.Spec.ClusterIP, nil
+}
+
+// serviceName returns the Service name of a host naming a Service in
+// namespace, or "" for any other host
+func serviceName(host, namespace string) string {
+	name, domain, qualified := strings.Cut(host, ".")
+	if qualified {
+		switch domain {
+		case namespace, namespace + ".svc", namespace + ".svc.cluster.local":
+		default:
+			return ""
+		}
+	}
+	if len(validation.IsDNS1035Label(name)) > 0 {
+		return ""
+	}
+	return name
+}
+
+// readyPodIPs returns the IPs of the deployment's pods that Kubernetes
+// considers ready
+func (c *Checker) readyPodIPs(ctx context.Context, deployment *appsv1.Deployment) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+	pods, err := c.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var ips []string
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil || pod.Labels[prewarm.LabelPlaceholderFor] != "" {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ips = append(ips, pod.Status.PodIP)
+				break
+			}
+		}
+	}
+	return ips, nil
+}
+
+// port returns the port a check connects to
+func port(check Check) int32 {
+	if check.Port == 0 && check.Type == TypeTriton {
+		return DefaultTritonPort
+	}
+	return check.Port
+}
+
+// httpVerifier expects a status code and optionally a body from a GET request
+type httpVerifier struct {
+	client       *http.Client
+	scheme       string
+	path         string
+	expectStatus int
+	expectBody   string
+}
+
+func newHTTPVerifier(check Check, client *http.Client) (Verifier, error) {
+	if check.Port <= 0 {
+		return nil, fmt.Errorf("port is required")
+	}
+	v := &httpVerifier{
+		client:       client,
+		scheme:       check.Scheme,
+		path:         check.Path,
+		expectStatus: check.ExpectStatus,
+		expectBody:   check.ExpectBody,
+	}
+	if v.scheme == "" {
+		v.scheme = "http"
+	}
+	if v.scheme != "http" && v.scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if !strings.HasPrefix(v.path, "/") {
+		v.path = "/" + v.path
+	}
+	if v.expectStatus == 0 {
+		v.expectStatus = http.StatusOK
+	}
+	return v, nil
+}
+
+func (v *httpVerifier) Verify(ctx context.Context, address string) error {
+	return get(ctx, v.client, v.scheme+"://"+address+v.path, v.expectStatus, v.expectBody)
+}
+
+// newTritonVerifier checks a model with the KServe v2 protocol's model
+// readiness endpoint of Triton Inference Server
+func newTritonVerifier(check Check, client *http.Client) (Verifier, error) {
+	if check.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	path := "/v2/models/" + url.PathEscape(check.Model)
+	if check.Version != "" {
+		path += "/versions/" + url.PathEscape(check.Version)
+	}
+	return &httpVerifier{client: client, scheme: "http", path: path + "/ready", expectStatus: http.StatusOK}, nil
+}
+
+// tcpVerifier expects a port to accept connections
+type tcpVerifier struct {
+	timeout time.Duration
+}
+
+func newTCPVerifier(check Check, client *http.Client) (Verifier, error) {
+	if check.Port <= 0 {
+		return nil, fmt.Errorf("port is required")
+	}
+	return &tcpVerifier{timeout: client.Timeout}, nil
+}
+
+func (v *tcpVerifier) Verify(ctx context.Context, address string) error {
+	dialer := net.Dialer{Timeout: v.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// get sends a GET request and compares the response with the expectation
+func get(ctx context.Context, client *http.Client, target string, expectStatus int, expectBody string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != expectStatus {
+		return fmt.Errorf("GET %s returned %d, expected %d", req.URL.Path, resp.StatusCode, expectStatus)
+	}
+	if expectBody == "" {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if !strings.Contains(string(body), expectBody) {
+		return fmt.Errorf("GET %s response does not contain %q", req.URL.Path, expectBody)
+	}
+	return nil
+}
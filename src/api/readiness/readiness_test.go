@@ -0,0 +1,245 @@
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// hostPort splits the address of a test server
+func hostPort(t *testing.T, rawURL string) (string, int32) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(rawURL[len("http://"):])
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return host, int32(p)
+}
+
+// tritonServer serves the model readiness endpoint with resnet50 loaded
+func tritonServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/models/resnet50/ready", "/v2/models/resnet50/versions/1/ready":
+			w.WriteHeader(http.StatusOK)
+		case "/healthz":
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// sampleAppB returns the sample-app-b deployment and its Service with clusterIP
+func sampleAppB(clusterIP string) []runtime.Object {
+	podLabels := map[string]string{"app": "sample-app-b"}
+	return []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b", Namespace: "project-b"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: podLabels}},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b", Namespace: "project-b"},
+			Spec:       corev1.ServiceSpec{Selector: podLabels, ClusterIP: clusterIP},
+		},
+	}
+}
+
+func TestParseChecks(t *testing.T) {
+	checks, err := ParseChecks(map[string]string{AnnotationChecks: `
+- type: triton
+  model: resnet50
+- type: http
+  name: health
+  port: 8080
+  path: /healthz
+`})
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+	assert.Equal(t, "triton", checks[0].Name)
+	assert.Equal(t, "resnet50", checks[0].Model)
+	assert.Equal(t, "health", checks[1].Name)
+
+	checks, err = ParseChecks(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, checks)
+
+	_, err = ParseChecks(map[string]string{AnnotationChecks: `[{"port": 80}]`})
+	assert.ErrorContains(t, err, "has no type")
+
+	_, err = ParseChecks(map[string]string{AnnotationChecks: `[{"type": "http", "expect": 200}]`})
+	assert.Error(t, err)
+}
+
+func TestCheck_Host(t *testing.T) {
+	// Setup
+	server := tritonServer(t)
+	host, port := hostPort(t, server.URL)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	_, tcpPort := hostPort(t, "http://"+listener.Addr().String())
+
+	checker := NewChecker(fake.NewSimpleClientset(sampleAppB(host)...), DefaultTimeout)
+
+	tests := []struct {
+		name      string
+		check     Check
+		wantReady bool
+		wantMsg   string
+	}{
+		{name: "triton model ready", check: Check{Type: TypeTriton, Model: "resnet50", Port: port}, wantReady: true},
+		{name: "triton model version ready", check: Check{Type: TypeTriton, Model: "resnet50", Version: "1", Port: port}, wantReady: true},
+		{name: "triton model not loaded", check: Check{Type: TypeTriton, Model: "densenet", Port: port}, wantMsg: "returned 400, expected 200"},
+		{name: "http body", check: Check{Type: TypeHTTP, Port: port, Path: "/healthz", ExpectBody: `"ok"`}, wantReady: true},
+		{name: "http unexpected body", check: Check{Type: TypeHTTP, Port: port, Path: "/healthz", ExpectBody: "loaded"}, wantMsg: `does not contain "loaded"`},
+		{name: "http expected status", check: Check{Type: TypeHTTP, Port: port, Path: "/missing", ExpectStatus: http.StatusBadRequest}, wantReady: true},
+		{name: "tcp", check: Check{Type: TypeTCP, Port: tcpPort}, wantReady: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check.Host = "sample-app-b.project-b.svc"
+			value, err := json.Marshal([]Check{tt.check})
+			require.NoError(t, err)
+
+			// Test
+			report, err := checker.Check(context.Background(), "project-b", "sample-app-b", map[string]string{AnnotationChecks: string(value)})
+
+			// Assert
+			require.NoError(t, err)
+			require.Len(t, report.Results, 1)
+			assert.Equal(t, tt.wantReady, report.Ready)
+			assert.Contains(t, report.Results[0].Message, tt.wantMsg)
+			assert.Equal(t, host, strings.Split(report.Results[0].Target, ":")[0])
+		})
+	}
+}
+
+func TestCheck_HostRestricted(t *testing.T) {
+	// Setup
+	other := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "project-b"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "other"}, ClusterIP: "10.0.0.2"},
+	}
+	headless := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "project-b"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "sample-app-b"}, ClusterIP: corev1.ClusterIPNone},
+	}
+	checker := NewChecker(fake.NewSimpleClientset(append(sampleAppB("10.0.0.1"), other, headless)...), DefaultTimeout)
+
+	tests := []struct {
+		host    string
+		wantErr string
+	}{
+		{host: "169.254.169.254", wantErr: "is not a Service in namespace project-b"},
+		{host: "kubernetes.default.svc", wantErr: "is not a Service in namespace project-b"},
+		{host: "example.com", wantErr: "is not a Service in namespace project-b"},
+		{host: "missing", wantErr: "is not a Service in namespace project-b"},
+		{host: "other", wantErr: "does not select the pods of deployment sample-app-b"},
+		{host: "headless", wantErr: "has no cluster IP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			annotations := map[string]string{AnnotationChecks: `[{"type":"tcp","host":"` + tt.host + `","port":80}]`}
+
+			// Test
+			_, err := checker.Check(context.Background(), "project-b", "sample-app-b", annotations)
+
+			// Assert
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCheck_Pods(t *testing.T) {
+	// Setup
+	server := tritonServer(t)
+	_, port := hostPort(t, server.URL)
+
+	pod := func(name string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-b", Labels: map[string]string{"app": "sample-app-b"}},
+			Status: corev1.PodStatus{
+				PodIP:      "127.0.0.1",
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-app-b", Namespace: "project-b"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-app-b"}}},
+	}
+	clientset := fake.NewSimpleClientset(deployment, pod("sample-app-b-1", corev1.ConditionTrue), pod("sample-app-b-2", corev1.ConditionFalse))
+	checker := NewChecker(clientset, DefaultTimeout)
+	now := time.Date(2025, 7, 14, 9, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+
+	annotations := map[string]string{AnnotationChecks: `[{"type":"triton","model":"resnet50","port":` + strconv.Itoa(int(port)) + `}]`}
+
+	// Test
+	report, err := checker.Check(context.Background(), "project-b", "sample-app-b", annotations)
+
+	// Assert: only the ready pod is checked
+	require.NoError(t, err)
+	assert.True(t, report.Ready)
+	assert.Equal(t, now, report.CheckedAt)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))), report.Results[0].Target)
+
+	// Without ready pods the check fails
+	require.NoError(t, clientset.CoreV1().Pods("project-b").Delete(context.Background(), "sample-app-b-1", metav1.DeleteOptions{}))
+	report, err = checker.Check(context.Background(), "project-b", "sample-app-b", annotations)
+	require.NoError(t, err)
+	assert.False(t, report.Ready)
+	assert.Equal(t, "no ready pods", report.Results[0].Message)
+}
+
+type alwaysReady struct{}
+
+func (alwaysReady) Verify(context.Context, string) error { return nil }
+
+func TestCheck_Register(t *testing.T) {
+	checker := NewChecker(fake.NewSimpleClientset(sampleAppB("10.0.0.1")...), DefaultTimeout)
+	annotations := map[string]string{AnnotationChecks: `[{"type":"grpc","host":"sample-app-b","port":8001}]`}
+
+	_, err := checker.Check(context.Background(), "project-b", "sample-app-b", annotations)
+	assert.ErrorContains(t, err, `unknown readiness check type "grpc"`)
+
+	checker.Register("grpc", func(Check, *http.Client) (Verifier, error) { return alwaysReady{}, nil })
+	report, err := checker.Check(context.Background(), "project-b", "sample-app-b", annotations)
+	require.NoError(t, err)
+	assert.True(t, report.Ready)
+	assert.Equal(t, "10.0.0.1:8001", report.Results[0].Target)
+}
+
+func TestCheck_InvalidCheck(t *testing.T) {
+	checker := NewChecker(fake.NewSimpleClientset(), DefaultTimeout)
+
+	_, err := checker.Check(context.Background(), "project-b", "sample-app-b", map[string]string{AnnotationChecks: `[{"type":"triton"}]`})
+	assert.ErrorContains(t, err, "model is required")
+
+	_, err = checker.Check(context.Background(), "project-b", "sample-app-b", map[string]string{AnnotationChecks: `[{"type":"http"}]`})
+	assert.ErrorContains(t, err, "port is required")
+}
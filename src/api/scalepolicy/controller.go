@@ -0,0 +1,423 @@
+package scalepolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/notify"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	"github.com/torumakabe/aks-scale-to-zero/api/quota"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+// AnnotationIdleSince records when a running deployment was first seen
+// outside its policy's schedules (RFC 3339)
+const AnnotationIdleSince = "scale-to-zero.io/idle-since"
+
+// Default reconcile interval and timezone of policies without one
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimezone = "UTC"
+)
+
+// Controller reconciles ScaleToZeroPolicy resources. Deployments are scaled
+// through the Kubernetes client under the same locks, policies and quotas as
+// API requests.
+type Controller struct {
+	dynamicClient dynamic.Interface
+	k8sClient     k8s.ClientInterface
+	locker        lock.Locker
+	policyEngine  *policy.Engine
+	quotaEngine   *quota.Engine
+	prewarmer     *prewarm.Manager
+	notifier      *notify.Notifier
+	timezone      string
+	now           func() time.Time
+}
+
+// NewController creates a new ScaleToZeroPolicy controller. quotaEngine and
+// prewarmer may be nil; without a prewarmer, prewarm settings are ignored.
+func NewController(dynamicClient dynamic.Interface, k8sClient k8s.ClientInterface, locker lock.Locker, policyEngine *policy.Engine, quotaEngine *quota.Engine, prewarmer *prewarm.Manager) *Controller {
+	return &Controller{
+		dynamicClient: dynamicClient,
+		k8sClient:     k8sClient,
+		locker:        locker,
+		policyEngine:  policyEngine,
+		quotaEngine:   quotaEngine,
+		prewarmer:     prewarmer,
+		timezone:      DefaultTimezone,
+		now:           time.Now,
+	}
+}
+
+// SetNotifier sets the notifier told when a deployment is scaled, idle ones
+// to zero included, or fails to be
+func (c *Controller) SetNotifier(notifier *notify.Notifier) {
+	c.notifier = notifier
+}
+
+// SetDefaultTimezone sets the timezone of policies that do not set one
+func (c *Controller) SetDefaultTimezone(timezone string) {
+	c.timezone = timezone
+}
+
+// Run reconciles all policies every interval until ctx is done
+func (c *Controller) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.ReconcileAll(ctx); err != nil {
+				log.Printf("Failed to reconcile scale-to-zero policies: %v", err)
+			}
+		}
+	}
+}
+
+// ReconcileAll reconciles every policy in the cluster. A deployment selected
+// by several policies is managed by the oldest one.
+func (c *Controller) ReconcileAll(ctx context.Context) error {
+	if c.prewarmer != nil {
+		if err := c.prewarmer.Sweep(ctx); err != nil {
+			log.Printf("Failed to delete expired placeholder pods: %v", err)
+		}
+	}
+
+	list, err := c.dynamicClient.Resource(GroupVersionResource).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if k8serrors.IsNotFound(err) {
+		// The CRD is not installed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", Resource, err)
+	}
+
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := items[i].GetCreationTimestamp(), items[j].GetCreationTimestamp()
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return objectKey(&items[i]) < objectKey(&items[j])
+	})
+
+	claimed := map[string]string{}
+	for i := range items {
+		if err := c.reconcile(ctx, &items[i], claimed); err != nil {
+			log.Printf("Failed to reconcile %s %s: %v", Kind, objectKey(&items[i]), err)
+		}
+	}
+	return nil
+}
+
+// reconcile scales the deployments selected by one policy and writes its
+// status. claimed maps deployments to the policy that manages them.
+func (c *Controller) reconcile(ctx context.Context, obj *unstructured.Unstructured, claimed map[string]string) error {
+	p := &ScaleToZeroPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, p); err != nil {
+		status := ScaleToZeroPolicyStatus{ObservedGeneration: obj.GetGeneration()}
+		setCondition(&status, obj, ConditionReady, metav1.ConditionFalse, ReasonInvalidSpec, err.Error())
+		return c.updateStatus(ctx, obj, &status)
+	}
+	if p.Spec.Timezone == "" {
+		p.Spec.Timezone = c.timezone
+	}
+
+	status := ScaleToZeroPolicyStatus{
+		ObservedGeneration: p.Generation,
+		Conditions:         p.Status.Conditions,
+	}
+	if err := p.Spec.Validate(); err != nil {
+		setCondition(&status, obj, ConditionReady, metav1.ConditionFalse, ReasonInvalidSpec, err.Error())
+		meta.RemoveStatusCondition(&status.Conditions, ConditionScheduleActive)
+		return c.updateStatus(ctx, obj, &status)
+	}
+
+	schedule, replicas := p.Spec.activeSchedule(c.now())
+	if schedule != nil {
+		status.ActiveSchedule = schedule.Window
+		setCondition(&status, obj, ConditionScheduleActive, metav1.ConditionTrue, ReasonWithinSchedule,
+			fmt.Sprintf("%s %s: %d replicas", schedule.Window, p.Spec.location(), replicas))
+	} else {
+		setCondition(&status, obj, ConditionScheduleActive, metav1.ConditionFalse, ReasonOutsideSchedule,
+			"no schedule is open")
+	}
+
+	selector := ""
+	if p.Spec.Selector != nil {
+		// Validate has already parsed the selector
+		parsed, _ := metav1.LabelSelectorAsSelector(p.Spec.Selector)
+		selector = parsed.String()
+	}
+	deployments, err := c.k8sClient.ListDeployments(ctx, p.Namespace, selector)
+	if err != nil {
+		setCondition(&status, obj, ConditionReady, metav1.ConditionFalse, ReasonReconcileFailed,
+			fmt.Sprintf("failed to list deployments: %v", err))
+		if updateErr := c.updateStatus(ctx, obj, &status); updateErr != nil {
+			log.Printf("Failed to update status of %s %s: %v", Kind, objectKey(obj), updateErr)
+		}
+		return err
+	}
+	sort.Slice(deployments, func(i, j int) bool { return deployments[i].Name < deployments[j].Name })
+
+	var failures, conflicts []string
+	for _, d := range deployments {
+		key := lock.Key(d.Namespace, d.Name)
+		if owner, ok := claimed[key]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%s (managed by %s)", d.Name, owner))
+			continue
+		}
+		claimed[key] = objectKey(obj)
+		status.Deployments = append(status.Deployments, d.Name)
+
+		err := c.reconcileDeployment(ctx, p, d.Namespace, d.Name, replicas)
+		if err != nil && !errors.Is(err, lock.ErrLocked) {
+			failures = append(failures, fmt.Sprintf("%s: %v", d.Name, err))
+		}
+		if c.prewarmer != nil && p.Spec.Prewarm != nil {
+			if err := c.prewarmDeployment(ctx, p, d.Namespace, d.Name, d.DesiredReplicas, replicas); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: pre-warm: %v", d.Name, err))
+			}
+		}
+	}
+
+	switch {
+	case len(failures) > 0:
+		setCondition(&status, obj, ConditionReady, metav1.ConditionFalse, ReasonReconcileFailed, strings.Join(failures, "; "))
+	case len(conflicts) > 0:
+		setCondition(&status, obj, ConditionReady, metav1.ConditionFalse, ReasonConflict,
+			"deployments selected by an older policy: "+strings.Join(conflicts, ", "))
+	default:
+		setCondition(&status, obj, ConditionReady, metav1.ConditionTrue, ReasonReconciled,
+			fmt.Sprintf("%d deployments reconciled", len(status.Deployments)))
+	}
+	return c.updateStatus(ctx, obj, &status)
+}
+
+// reconcileDeployment brings one deployment to the state its policy asks for.
+// scheduled is the replica count of the open schedule, zero outside schedules.
+// Deployments locked by an ongoing operation are retried on the next run.
+func (c *Controller) reconcileDeployment(ctx context.Context, p *ScaleToZeroPolicy, namespace, name string, scheduled int32) error {
+	release, err := c.locker.Acquire(ctx, lock.Key(namespace, name), false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	status, err := c.k8sClient.GetDeploymentStatus(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	holder := leaseHolder(p)
+	current := status.DesiredReplicas
+	l, leased := lease.FromAnnotations(status.Annotations)
+	leased = leased && status.Labels[lease.LabelLeased] == "true"
+	ownLease := leased && l.Holder == holder
+
+	if scheduled > 0 {
+		if target := p.Spec.clamp(max(current, scheduled)); target != current {
+			if err := c.scale(ctx, p, namespace, name, status.Annotations, current, target, notify.EventScaled, "within the policy's schedules"); err != nil {
+				return err
+			}
+		}
+		if _, idle := status.Annotations[AnnotationIdleSince]; idle {
+			if err := c.k8sClient.PatchDeploymentMetadata(ctx, namespace, name, nil, map[string]*string{AnnotationIdleSince: nil}); err != nil {
+				return err
+			}
+		}
+		// Renew the lease before half of it has run out. Leases taken through
+		// the API are left alone.
+		if d := p.Spec.leaseDuration(); d > 0 && (!leased || ownLease) && (!ownLease || l.ExpiresAt.Before(now.Add(d/2))) {
+			return lease.Set(ctx, c.k8sClient, namespace, name, lease.Lease{
+				ExpiresAt: now.Add(d).UTC().Truncate(time.Second),
+				Holder:    holder,
+			})
+		}
+		return nil
+	}
+
+	if current == 0 {
+		return c.clearMetadata(ctx, namespace, name, status.Annotations, ownLease)
+	}
+	// A lease taken through the API decides when the deployment is scaled down
+	if leased && !ownLease {
+		return nil
+	}
+
+	if timeout := p.Spec.idleTimeout(); timeout > 0 {
+		idleSince, err := time.Parse(time.RFC3339, status.Annotations[AnnotationIdleSince])
+		if err != nil {
+			value := now.UTC().Format(time.RFC3339)
+			return c.k8sClient.PatchDeploymentMetadata(ctx, namespace, name, nil, map[string]*string{AnnotationIdleSince: &value})
+		}
+		if now.Sub(idleSince) < timeout {
+			if target := p.Spec.clamp(current); target != current {
+				return c.scale(ctx, p, namespace, name, status.Annotations, current, target, notify.EventScaled, "limited to the policy's replica range")
+			}
+			return nil
+		}
+	}
+
+	eventType, reason := notify.EventScaled, "outside the policy's schedules"
+	if timeout := p.Spec.idleTimeout(); timeout > 0 {
+		eventType, reason = notify.EventIdleShutdown, fmt.Sprintf("idle for %s outside the policy's schedules", timeout)
+	}
+	if err := c.scale(ctx, p, namespace, name, status.Annotations, current, 0, eventType, reason); err != nil {
+		return err
+	}
+	return c.clearMetadata(ctx, namespace, name, status.Annotations, ownLease)
+}
+
+// prewarmDeployment creates placeholder pods for the replicas a schedule opening
+// within the lead time will add, and deletes them once the deployment's pods
+// are scheduled. Placeholders expire one lead time after the schedule opens.
+func (c *Controller) prewarmDeployment(ctx context.Context, p *ScaleToZeroPolicy, namespace, name string, current, scheduled int32) error {
+	if scheduled > 0 {
+		_, err := c.prewarmer.Release(ctx, namespace, name)
+		return err
+	}
+
+	lead := p.Spec.Prewarm.LeadTime.Duration
+	schedule, replicas, opens := p.Spec.upcomingSchedule(c.now(), lead)
+	if schedule == nil {
+		return nil
+	}
+	if missing := p.Spec.clamp(max(current, replicas)) - current; missing > 0 {
+		_, err := c.prewarmer.Ensure(ctx, namespace, name, missing, opens.Add(lead))
+		return err
+	}
+	return nil
+}
+
+// scale checks the scaling policies, and namespace quotas for scale-ups, then
+// scales the deployment. The scale is notified as eventType, or as failed.
+func (c *Controller) scale(ctx context.Context, p *ScaleToZeroPolicy, namespace, name string, annotations map[string]string, current, replicas int32, eventType notify.EventType, reason string) error {
+	req := policy.Request{
+		Namespace:   namespace,
+		Name:        name,
+		Annotations: annotations,
+		Operation:   policy.OperationScaleUp,
+		Replicas:    replicas,
+	}
+	if replicas == 0 {
+		req.Operation = policy.OperationScaleToZero
+	}
+	decision, err := c.policyEngine.Evaluate(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("scaling policy violation: %s", strings.Join(decision.Violations, "; "))
+	}
+	if replicas > current && decision.RequiresApproval() {
+		return fmt.Errorf("scale-up requires approval: %s", strings.Join(decision.ApprovalReasons, "; "))
+	}
+
+	if replicas > current && c.quotaEngine != nil {
+		releaseNamespace, err := c.locker.Acquire(ctx, lock.NamespaceKey(namespace), false)
+		if err != nil {
+			return err
+		}
+		defer releaseNamespace()
+
+		quotaDecision, err := c.quotaEngine.Check(ctx, namespace, name, replicas)
+		if err != nil {
+			return err
+		}
+		if !quotaDecision.Allowed {
+			return fmt.Errorf("namespace quota exceeded: %s", strings.Join(quotaDecision.Violations, "; "))
+		}
+	}
+
+	if err := c.k8sClient.ScaleDeployment(ctx, namespace, name, replicas); err != nil {
+		c.notifier.NotifyScale(namespace, name, current, replicas, leaseHolder(p), reason, err)
+		return err
+	}
+	event := notify.ScaleEvent(namespace, name, current, replicas, leaseHolder(p), reason, nil)
+	event.Type = eventType
+	c.notifier.Notify(event)
+	log.Printf("Scale-to-zero policy scaled deployment %s/%s from %d to %d replicas", namespace, name, current, replicas)
+	return nil
+}
+
+// clearMetadata removes the idle marker and the policy's own lease
+func (c *Controller) clearMetadata(ctx context.Context, namespace, name string, annotations map[string]string, ownLease bool) error {
+	patch := map[string]*string{}
+	if _, ok := annotations[AnnotationIdleSince]; ok {
+		patch[AnnotationIdleSince] = nil
+	}
+	var labels map[string]*string
+	if ownLease {
+		labels = map[string]*string{lease.LabelLeased: nil}
+		patch[lease.AnnotationExpiresAt] = nil
+		patch[lease.AnnotationHolder] = nil
+		if _, ok := annotations[lease.AnnotationExpiryNotified]; ok {
+			patch[lease.AnnotationExpiryNotified] = nil
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	return c.k8sClient.PatchDeploymentMetadata(ctx, namespace, name, labels, patch)
+}
+
+// updateStatus writes the status back to the policy if it changed
+func (c *Controller) updateStatus(ctx context.Context, obj *unstructured.Unstructured, status *ScaleToZeroPolicyStatus) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+	if previous, ok := obj.Object["status"]; ok && equality.Semantic.DeepEqual(previous, content) {
+		return nil
+	}
+
+	updated := obj.DeepCopy()
+	updated.Object["status"] = content
+	_, err = c.dynamicClient.Resource(GroupVersionResource).Namespace(obj.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// setCondition sets a status condition for the policy's current generation
+func setCondition(status *ScaleToZeroPolicyStatus, obj *unstructured.Unstructured, conditionType string, value metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             value,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// leaseHolder names the policy as the holder of the leases it records
+func leaseHolder(p *ScaleToZeroPolicy) string {
+	return strings.ToLower(Kind) + "/" + p.Namespace + "/" + p.Name
+}
+
+// objectKey returns namespace/name of a policy
+func objectKey(obj *unstructured.Unstructured) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,366 @@
+package scalepolicy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/lease"
+	"github.com/torumakabe/aks-scale-to-zero/api/lock"
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	"github.com/torumakabe/aks-scale-to-zero/api/prewarm"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// monday10 is within "Mon-Fri 08:00-20:00"
+var monday10 = time.Date(2025, 7, 14, 10, 0, 0, 0, time.UTC)
+
+// newPolicy returns a ScaleToZeroPolicy object for the fake dynamic client
+func newPolicy(name string, created time.Time, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Group + "/" + Version,
+		"kind":       Kind,
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  "project-a",
+			"generation": int64(1),
+		},
+		"spec": spec,
+	}}
+	obj.SetCreationTimestamp(metav1.NewTime(created))
+	return obj
+}
+
+func setupController(t *testing.T, mockClient *mocks.MockK8sClient, objects ...runtime.Object) *Controller {
+	t.Helper()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GroupVersionResource: Kind + "List"}, objects...)
+	controller := NewController(dynamicClient, mockClient, lock.NewLocalLocker(), policy.NewEngine(nil, policy.NewConfig()), nil, nil)
+	controller.now = func() time.Time { return monday10 }
+	return controller
+}
+
+// getPolicy reads a policy back from the fake dynamic client
+func getPolicy(t *testing.T, c *Controller, name string) *ScaleToZeroPolicy {
+	t.Helper()
+	obj, err := c.dynamicClient.Resource(GroupVersionResource).Namespace("project-a").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	p := &ScaleToZeroPolicy{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, p))
+	return p
+}
+
+var businessHours = map[string]interface{}{
+	"selector":      map[string]interface{}{"matchLabels": map[string]interface{}{"app": "sample-app-a"}},
+	"schedules":     []interface{}{map[string]interface{}{"window": "Mon-Fri 08:00-20:00", "replicas": int64(2)}},
+	"idleTimeout":   "30m",
+	"maxReplicas":   int64(4),
+	"leaseDuration": "12h",
+}
+
+func TestReconcile_ScheduleScalesUp(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	controller := setupController(t, mockClient, newPolicy("business-hours", monday10, businessHours))
+	app := mocks.MockDeploymentStatus("sample-app-a", "project-a", 0, 0)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "project-a", "app=sample-app-a").Return([]*k8s.DeploymentStatus{app}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(app, nil)
+	mockClient.On("ScaleDeployment", mock.Anything, "project-a", "sample-app-a", int32(2)).Return(nil)
+	mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-a", "sample-app-a", mock.Anything, mock.Anything).Return(nil)
+
+	// Test
+	err := controller.ReconcileAll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+
+	patch := mockClient.Calls[len(mockClient.Calls)-1].Arguments
+	annotations := patch.Get(4).(map[string]*string)
+	assert.Equal(t, "2025-07-14T22:00:00Z", *annotations[lease.AnnotationExpiresAt])
+	assert.Equal(t, "scaletozeropolicy/project-a/business-hours", *annotations[lease.AnnotationHolder])
+
+	p := getPolicy(t, controller, "business-hours")
+	assert.Equal(t, int64(1), p.Status.ObservedGeneration)
+	assert.Equal(t, "Mon-Fri 08:00-20:00", p.Status.ActiveSchedule)
+	assert.Equal(t, []string{"sample-app-a"}, p.Status.Deployments)
+	assert.True(t, meta.IsStatusConditionTrue(p.Status.Conditions, ConditionReady))
+	assert.True(t, meta.IsStatusConditionTrue(p.Status.Conditions, ConditionScheduleActive))
+}
+
+func TestReconcile_DefaultTimezone(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	controller := setupController(t, mockClient, newPolicy("business-hours", monday10, businessHours))
+	controller.SetDefaultTimezone("America/Los_Angeles")
+	app := mocks.MockDeploymentStatus("sample-app-a", "project-a", 0, 0)
+
+	// Mock expectations
+	mockClient.On("ListDeployments", mock.Anything, "project-a", "app=sample-app-a").Return([]*k8s.DeploymentStatus{app}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(app, nil).Maybe()
+
+	// Test
+	err := controller.ReconcileAll(context.Background())
+
+	// Assert: Monday 10:00 UTC is 03:00 in Los Angeles, outside the window
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	p := getPolicy(t, controller, "business-hours")
+	assert.Empty(t, p.Status.ActiveSchedule)
+	assert.False(t, meta.IsStatusConditionTrue(p.Status.Conditions, ConditionScheduleActive))
+}
+
+func TestReconcile_IdleTimeout(t *testing.T) {
+	saturday := time.Date(2025, 7, 19, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		wantScale   bool
+		wantPatch   map[string]*string
+	}{
+		{
+			name:      "marks idle",
+			wantPatch: map[string]*string{AnnotationIdleSince: ptr("2025-07-19T10:00:00Z")},
+		},
+		{
+			name:        "within idle timeout",
+			annotations: map[string]string{AnnotationIdleSince: "2025-07-19T09:45:00Z"},
+		},
+		{
+			name: "idle timeout expired",
+			annotations: map[string]string{
+				AnnotationIdleSince:       "2025-07-19T09:00:00Z",
+				lease.AnnotationExpiresAt: "2025-07-19T12:00:00Z",
+				lease.AnnotationHolder:    "scaletozeropolicy/project-a/business-hours",
+			},
+			labels:    map[string]string{lease.LabelLeased: "true"},
+			wantScale: true,
+			wantPatch: map[string]*string{AnnotationIdleSince: nil, lease.AnnotationExpiresAt: nil, lease.AnnotationHolder: nil},
+		},
+		{
+			name: "leased through the API",
+			annotations: map[string]string{
+				AnnotationIdleSince:       "2025-07-19T09:00:00Z",
+				lease.AnnotationExpiresAt: "2025-07-19T12:00:00Z",
+				lease.AnnotationHolder:    "alice",
+			},
+			labels: map[string]string{lease.LabelLeased: "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockClient := mocks.NewMockK8sClient()
+			controller := setupController(t, mockClient, newPolicy("business-hours", monday10, businessHours))
+			controller.now = func() time.Time { return saturday }
+			app := mocks.MockDeploymentStatus("sample-app-a", "project-a", 2, 2)
+			app.Annotations = tt.annotations
+			app.Labels = tt.labels
+
+			// Mock expectations
+			mockClient.On("ListDeployments", mock.Anything, "project-a", "app=sample-app-a").Return([]*k8s.DeploymentStatus{app}, nil)
+			mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(app, nil)
+			if tt.wantScale {
+				mockClient.On("ScaleDeployment", mock.Anything, "project-a", "sample-app-a", int32(0)).Return(nil)
+			}
+			if tt.wantPatch != nil {
+				mockClient.On("PatchDeploymentMetadata", mock.Anything, "project-a", "sample-app-a", mock.Anything, tt.wantPatch).Return(nil)
+			}
+
+			// Test
+			err := controller.ReconcileAll(context.Background())
+
+			// Assert
+			require.NoError(t, err)
+			mockClient.AssertExpectations(t)
+			if !tt.wantScale {
+				mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			if tt.wantPatch == nil {
+				mockClient.AssertNotCalled(t, "PatchDeploymentMetadata", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			p := getPolicy(t, controller, "business-hours")
+			assert.True(t, meta.IsStatusConditionTrue(p.Status.Conditions, ConditionReady))
+			assert.True(t, meta.IsStatusConditionFalse(p.Status.Conditions, ConditionScheduleActive))
+		})
+	}
+}
+
+func TestReconcile_InvalidSpec(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	controller := setupController(t, mockClient, newPolicy("broken", monday10, map[string]interface{}{
+		"schedules": []interface{}{map[string]interface{}{"window": "weekdays"}},
+	}))
+
+	// Test
+	err := controller.ReconcileAll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "ListDeployments", mock.Anything, mock.Anything, mock.Anything)
+
+	p := getPolicy(t, controller, "broken")
+	ready := meta.FindStatusCondition(p.Status.Conditions, ConditionReady)
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, ReasonInvalidSpec, ready.Reason)
+	assert.Contains(t, ready.Message, "invalid window")
+}
+
+func TestReconcile_ConflictingPolicies(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	controller := setupController(t, mockClient,
+		newPolicy("newer", monday10, map[string]interface{}{}),
+		newPolicy("older", monday10.Add(-time.Hour), map[string]interface{}{}),
+	)
+	app := mocks.MockDeploymentStatus("sample-app-a", "project-a", 0, 0)
+
+	// Mock expectations - nothing to do for a deployment at zero outside schedules
+	mockClient.On("ListDeployments", mock.Anything, "project-a", "").Return([]*k8s.DeploymentStatus{app}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(app, nil).Once()
+
+	// Test
+	err := controller.ReconcileAll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+
+	older := getPolicy(t, controller, "older")
+	assert.True(t, meta.IsStatusConditionTrue(older.Status.Conditions, ConditionReady))
+	assert.Equal(t, []string{"sample-app-a"}, older.Status.Deployments)
+
+	newer := getPolicy(t, controller, "newer")
+	ready := meta.FindStatusCondition(newer.Status.Conditions, ConditionReady)
+	require.NotNil(t, ready)
+	assert.Equal(t, ReasonConflict, ready.Reason)
+	assert.Contains(t, ready.Message, "project-a/older")
+	assert.Empty(t, newer.Status.Deployments)
+}
+
+func TestReconcile_Prewarm(t *testing.T) {
+	// Setup
+	spec := map[string]interface{}{}
+	for k, v := range businessHours {
+		spec[k] = v
+	}
+	spec["prewarm"] = map[string]interface{}{"leadTime": "15m"}
+
+	mockClient := mocks.NewMockK8sClient()
+	controller := setupController(t, mockClient, newPolicy("business-hours", monday10, spec))
+	zero := int32(0)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-app-a", Namespace: "project-a"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &zero,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-app-a"}},
+		},
+	}
+	clientset := fake.NewSimpleClientset(deployment)
+	controller.prewarmer = prewarm.NewManager(clientset, prewarm.NewConfig())
+
+	app := mocks.MockDeploymentStatus("sample-app-a", "project-a", 0, 0)
+	mockClient.On("ListDeployments", mock.Anything, "project-a", "app=sample-app-a").Return([]*k8s.DeploymentStatus{app}, nil)
+	mockClient.On("GetDeploymentStatus", mock.Anything, "project-a", "sample-app-a").Return(app, nil)
+
+	placeholders := func() []corev1.Pod {
+		pods, err := clientset.CoreV1().Pods("project-a").List(context.Background(), metav1.ListOptions{LabelSelector: prewarm.LabelPlaceholderFor})
+		require.NoError(t, err)
+		return pods.Items
+	}
+
+	// Test: 20 minutes before the schedule opens nothing happens
+	controller.now = func() time.Time { return time.Date(2025, 7, 14, 7, 40, 0, 0, time.UTC) }
+	require.NoError(t, controller.ReconcileAll(context.Background()))
+	assert.Empty(t, placeholders())
+
+	// Test: within the lead time a placeholder is created per scheduled replica
+	controller.now = func() time.Time { return time.Date(2025, 7, 14, 7, 50, 0, 0, time.UTC) }
+	require.NoError(t, controller.ReconcileAll(context.Background()))
+
+	// Assert
+	pods := placeholders()
+	require.Len(t, pods, 2)
+	assert.Equal(t, "2025-07-14T08:15:00Z", pods[0].Annotations[prewarm.AnnotationExpiresAt])
+	assert.True(t, meta.IsStatusConditionTrue(getPolicy(t, controller, "business-hours").Status.Conditions, ConditionReady))
+	mockClient.AssertNotCalled(t, "ScaleDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestValidate(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		name    string
+		spec    ScaleToZeroPolicySpec
+		wantErr string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			spec: ScaleToZeroPolicySpec{
+				Schedules:   []Schedule{{Window: "Mon-Fri 08:00-20:00", Replicas: replicas(2)}},
+				Timezone:    "Asia/Tokyo",
+				MinReplicas: replicas(1),
+				MaxReplicas: replicas(4),
+			},
+		},
+		{
+			name:    "invalid selector",
+			spec:    ScaleToZeroPolicySpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"a b": "c"}}},
+			wantErr: "invalid selector",
+		},
+		{name: "unknown timezone", spec: ScaleToZeroPolicySpec{Timezone: "Mars/Olympus"}, wantErr: "unknown timezone"},
+		{name: "min above max", spec: ScaleToZeroPolicySpec{MinReplicas: replicas(3), MaxReplicas: replicas(2)}, wantErr: "greater than maxReplicas"},
+		{
+			name:    "schedule above max",
+			spec:    ScaleToZeroPolicySpec{MaxReplicas: replicas(2), Schedules: []Schedule{{Window: "Sat 10:00-12:00", Replicas: replicas(3)}}},
+			wantErr: "outside minReplicas/maxReplicas",
+		},
+		{
+			name:    "prewarm without lead time",
+			spec:    ScaleToZeroPolicySpec{Prewarm: &Prewarm{}},
+			wantErr: "prewarm.leadTime",
+		},
+		{
+			name:    "negative idle timeout",
+			spec:    ScaleToZeroPolicySpec{IdleTimeout: &metav1.Duration{Duration: -time.Minute}},
+			wantErr: "idleTimeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
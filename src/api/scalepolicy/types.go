@@ -0,0 +1,237 @@
+package scalepolicy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/policy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ScaleToZeroPolicy resource
+const (
+	Group    = "scale-to-zero.io"
+	Version  = "v1alpha1"
+	Kind     = "ScaleToZeroPolicy"
+	Resource = "scaletozeropolicies"
+)
+
+// MaxPrewarmLeadTime is the longest pre-warm lead time
+const MaxPrewarmLeadTime = 6 * time.Hour
+
+// GroupVersionResource identifies ScaleToZeroPolicy objects for the dynamic client
+var GroupVersionResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: Resource}
+
+// Condition types written to the policy status
+const (
+	// ConditionReady is true when every selected deployment was reconciled
+	ConditionReady = "Ready"
+	// ConditionScheduleActive is true while one of the schedules is open
+	ConditionScheduleActive = "ScheduleActive"
+)
+
+// Condition reasons
+const (
+	ReasonReconciled      = "Reconciled"
+	ReasonInvalidSpec     = "InvalidSpec"
+	ReasonReconcileFailed = "ReconcileFailed"
+	ReasonConflict        = "Conflict"
+	ReasonWithinSchedule  = "WithinSchedule"
+	ReasonOutsideSchedule = "OutsideSchedule"
+)
+
+// ScaleToZeroPolicy declares how the deployments it selects are scaled
+type ScaleToZeroPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScaleToZeroPolicySpec   `json:"spec"`
+	Status ScaleToZeroPolicyStatus `json:"status,omitempty"`
+}
+
+// ScaleToZeroPolicySpec is the desired scaling behavior
+type ScaleToZeroPolicySpec struct {
+	// Selector matches the deployments in the policy's namespace. An empty
+	// selector matches every managed deployment in the namespace.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Schedules are the windows during which the deployments are scaled up
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// Timezone for the schedule windows, defaults to the controller's
+	// default timezone (UTC unless configured)
+	Timezone string `json:"timezone,omitempty"`
+	// IdleTimeout is how long a deployment keeps running outside the
+	// schedules before it is scaled to zero. Zero scales it down immediately.
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	// MinReplicas is the smallest replica count of a running deployment
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the largest replica count of a running deployment
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// LeaseDuration records a scale-up lease on the deployments scaled up for
+	// a schedule, so they are scaled back to zero even if the policy is deleted
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+	// Prewarm adds the nodes the deployments need before a schedule opens
+	Prewarm *Prewarm `json:"prewarm,omitempty"`
+}
+
+// Prewarm creates low-priority placeholder pods ahead of each schedule so the
+// cluster autoscaler provisions nodes before the deployments scale up
+type Prewarm struct {
+	// LeadTime is how long before a schedule opens the placeholders are created
+	LeadTime metav1.Duration `json:"leadTime"`
+}
+
+// Schedule is a weekly window with the replica count to run during it
+type Schedule struct {
+	// Window such as "Mon-Fri 08:00-20:00", in the same format as policy allowedWindows
+	Window string `json:"window"`
+	// Replicas during the window, defaults to minReplicas or 1
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// ScaleToZeroPolicyStatus is the observed state written back by the controller
+type ScaleToZeroPolicyStatus struct {
+	// ObservedGeneration is the spec generation last reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ActiveSchedule is the window currently open, if any
+	ActiveSchedule string `json:"activeSchedule,omitempty"`
+	// Deployments lists the deployments the policy manages
+	Deployments []string `json:"deployments,omitempty"`
+	// Conditions are Ready and ScheduleActive
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Validate checks that the spec is well formed
+func (s *ScaleToZeroPolicySpec) Validate() error {
+	if _, err := metav1.LabelSelectorAsSelector(s.Selector); err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	if _, err := time.LoadLocation(s.location()); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	if s.MinReplicas != nil && *s.MinReplicas < 1 {
+		return fmt.Errorf("minReplicas must be at least 1")
+	}
+	if s.MaxReplicas != nil && *s.MaxReplicas < 1 {
+		return fmt.Errorf("maxReplicas must be at least 1")
+	}
+	if s.MinReplicas != nil && s.MaxReplicas != nil && *s.MinReplicas > *s.MaxReplicas {
+		return fmt.Errorf("minReplicas %d is greater than maxReplicas %d", *s.MinReplicas, *s.MaxReplicas)
+	}
+	if s.IdleTimeout != nil && s.IdleTimeout.Duration < 0 {
+		return fmt.Errorf("idleTimeout must not be negative")
+	}
+	if s.LeaseDuration != nil && s.LeaseDuration.Duration < 0 {
+		return fmt.Errorf("leaseDuration must not be negative")
+	}
+	if s.Prewarm != nil && (s.Prewarm.LeadTime.Duration <= 0 || s.Prewarm.LeadTime.Duration > MaxPrewarmLeadTime) {
+		return fmt.Errorf("prewarm.leadTime must be greater than 0 and at most %s", MaxPrewarmLeadTime)
+	}
+	for i, schedule := range s.Schedules {
+		if _, err := policy.ParseWindow(schedule.Window); err != nil {
+			return fmt.Errorf("schedules[%d]: %w", i, err)
+		}
+		if schedule.Replicas != nil {
+			replicas := *schedule.Replicas
+			if replicas < 1 {
+				return fmt.Errorf("schedules[%d]: replicas must be at least 1", i)
+			}
+			if s.clamp(replicas) != replicas {
+				return fmt.Errorf("schedules[%d]: replicas %d is outside minReplicas/maxReplicas", i, replicas)
+			}
+		}
+	}
+	return nil
+}
+
+// activeSchedule returns the open schedule with the most replicas and its
+// replica count, or nil if no schedule is open
+func (s *ScaleToZeroPolicySpec) activeSchedule(now time.Time) (*Schedule, int32) {
+	loc, err := time.LoadLocation(s.location())
+	if err != nil {
+		return nil, 0
+	}
+
+	var active *Schedule
+	var replicas int32
+	for i := range s.Schedules {
+		window, err := policy.ParseWindow(s.Schedules[i].Window)
+		if err != nil || !window.Contains(now.In(loc)) {
+			continue
+		}
+		if r := s.scheduleReplicas(&s.Schedules[i]); active == nil || r > replicas {
+			active, replicas = &s.Schedules[i], r
+		}
+	}
+	return active, replicas
+}
+
+// upcomingSchedule returns the schedule opening within lead of now with the
+// most replicas, its replica count and when it opens, or nil if none opens
+func (s *ScaleToZeroPolicySpec) upcomingSchedule(now time.Time, lead time.Duration) (*Schedule, int32, time.Time) {
+	loc, err := time.LoadLocation(s.location())
+	if err != nil {
+		return nil, 0, time.Time{}
+	}
+
+	var upcoming *Schedule
+	var replicas int32
+	var opens time.Time
+	for i := range s.Schedules {
+		window, err := policy.ParseWindow(s.Schedules[i].Window)
+		if err != nil {
+			continue
+		}
+		start := window.NextStart(now.In(loc))
+		if start.IsZero() || start.Sub(now) > lead {
+			continue
+		}
+		if r := s.scheduleReplicas(&s.Schedules[i]); upcoming == nil || r > replicas {
+			upcoming, replicas, opens = &s.Schedules[i], r, start
+		}
+	}
+	return upcoming, replicas, opens
+}
+
+// scheduleReplicas returns the replica count of a schedule
+func (s *ScaleToZeroPolicySpec) scheduleReplicas(schedule *Schedule) int32 {
+	if schedule.Replicas != nil {
+		return *schedule.Replicas
+	}
+	return s.clamp(1)
+}
+
+// clamp keeps a running replica count within minReplicas and maxReplicas
+func (s *ScaleToZeroPolicySpec) clamp(replicas int32) int32 {
+	if s.MinReplicas != nil && replicas < *s.MinReplicas {
+		replicas = *s.MinReplicas
+	}
+	if s.MaxReplicas != nil && replicas > *s.MaxReplicas {
+		replicas = *s.MaxReplicas
+	}
+	return replicas
+}
+
+// idleTimeout returns the idle timeout, zero if unset
+func (s *ScaleToZeroPolicySpec) idleTimeout() time.Duration {
+	if s.IdleTimeout == nil {
+		return 0
+	}
+	return s.IdleTimeout.Duration
+}
+
+// leaseDuration returns the lease duration, zero if unset
+func (s *ScaleToZeroPolicySpec) leaseDuration() time.Duration {
+	if s.LeaseDuration == nil {
+		return 0
+	}
+	return s.LeaseDuration.Duration
+}
+
+// location returns the schedule timezone name
+func (s *ScaleToZeroPolicySpec) location() string {
+	if s.Timezone == "" {
+		return DefaultTimezone
+	}
+	return s.Timezone
+}
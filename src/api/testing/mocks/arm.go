@@ -0,0 +1,111 @@
+package mocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/azure"
+)
+
+// StaticToken is a token source that always returns the same token
+type StaticToken string
+
+// Token returns the static token
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// ARMServer is a local stand-in for the Azure Resource Manager Agent Pools
+// API. It serves GET and PUT for the agent pools it holds.
+type ARMServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	pools map[string]map[string]any
+	// Updates records the properties of every PUT by agent pool name
+	Updates map[string][]map[string]any
+}
+
+// NewARMServer starts a stand-in serving pools, keyed by agent pool name
+func NewARMServer(pools map[string]map[string]any) *ARMServer {
+	s := &ARMServer{pools: pools, Updates: map[string][]map[string]any{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns an azure.Config pointing at the stand-in
+func (s *ARMServer) Config() azure.Config {
+	return azure.Config{
+		SubscriptionID: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "rg-test",
+		ClusterName:    "aks-test",
+		Endpoint:       s.URL,
+	}
+}
+
+// Pool returns the properties currently stored for an agent pool
+func (s *ARMServer) Pool(name string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pools[name]
+}
+
+func (s *ARMServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		writeARMError(w, http.StatusUnauthorized, "AuthenticationFailed", "missing bearer token")
+		return
+	}
+	prefix := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-test/providers/Microsoft.ContainerService/managedClusters/aks-test/agentPools/"
+	name, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok || r.URL.Query().Get("api-version") != azure.APIVersion {
+		writeARMError(w, http.StatusBadRequest, "InvalidResourceReference", "unexpected request "+r.URL.String())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	properties, ok := s.pools[name]
+	if !ok {
+		writeARMError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("agent pool %s not found", name))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		var body struct {
+			Properties map[string]any `json:"properties"`
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			writeARMError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		s.Updates[name] = append(s.Updates[name], body.Properties)
+		properties = body.Properties
+		properties["provisioningState"] = "Scaling"
+		s.pools[name] = properties
+	default:
+		writeARMError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"name": name, "properties": properties})
+}
+
+func writeARMError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
+
+// Ensure StaticToken implements azure.TokenSource
+var _ azure.TokenSource = StaticToken("")
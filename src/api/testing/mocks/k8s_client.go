@@ -46,6 +46,87 @@ func (m *MockK8sClient) GetDeploymentStatus(ctx context.Context, namespace, name
 	return nil, args.Error(1)
 }
 
+// DryRunScaleDeployment returns the deployment status as it would be after scaling
+func (m *MockK8sClient) DryRunScaleDeployment(ctx context.Context, namespace, name string, replicas int32) (*k8s.DeploymentStatus, error) {
+	args := m.Called(ctx, namespace, name, replicas)
+	if args.Get(0) != nil {
+		return args.Get(0).(*k8s.DeploymentStatus), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ListDeployments lists the deployments matching labelSelector
+func (m *MockK8sClient) ListDeployments(ctx context.Context, namespace, labelSelector string) ([]*k8s.DeploymentStatus, error) {
+	args := m.Called(ctx, namespace, labelSelector)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*k8s.DeploymentStatus), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// PatchDeploymentMetadata sets labels and annotations on a deployment
+func (m *MockK8sClient) PatchDeploymentMetadata(ctx context.Context, namespace, name string, labels, annotations map[string]*string) error {
+	args := m.Called(ctx, namespace, name, labels, annotations)
+	return args.Error(0)
+}
+
+// ListWorkloads lists the CronJobs, Deployments and StatefulSets in a namespace
+func (m *MockK8sClient) ListWorkloads(ctx context.Context, namespace string) ([]*k8s.Workload, error) {
+	args := m.Called(ctx, namespace)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*k8s.Workload), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// ScaleWorkload scales a Deployment or StatefulSet to the specified number of replicas
+func (m *MockK8sClient) ScaleWorkload(ctx context.Context, kind, namespace, name string, replicas int32) error {
+	args := m.Called(ctx, kind, namespace, name, replicas)
+	return args.Error(0)
+}
+
+// PatchWorkloadAnnotations sets annotations on a Deployment or StatefulSet
+func (m *MockK8sClient) PatchWorkloadAnnotations(ctx context.Context, kind, namespace, name string, annotations map[string]*string) error {
+	args := m.Called(ctx, kind, namespace, name, annotations)
+	return args.Error(0)
+}
+
+// RecordDeploymentEvent records a Kubernetes Event on a deployment
+func (m *MockK8sClient) RecordDeploymentEvent(ctx context.Context, namespace, name, eventType, reason, message string) error {
+	args := m.Called(ctx, namespace, name, eventType, reason, message)
+	return args.Error(0)
+}
+
+// GetCronJob retrieves a CronJob
+func (m *MockK8sClient) GetCronJob(ctx context.Context, namespace, name string) (*k8s.CronJob, error) {
+	args := m.Called(ctx, namespace, name)
+	if args.Get(0) != nil {
+		return args.Get(0).(*k8s.CronJob), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// SuspendCronJob suspends or resumes a CronJob
+func (m *MockK8sClient) SuspendCronJob(ctx context.Context, namespace, name string, suspend bool) error {
+	args := m.Called(ctx, namespace, name, suspend)
+	return args.Error(0)
+}
+
+// ListJobs lists the unfinished Jobs on a node pool
+func (m *MockK8sClient) ListJobs(ctx context.Context, namespace, nodePool string) ([]*k8s.Job, error) {
+	args := m.Called(ctx, namespace, nodePool)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*k8s.Job), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// DeleteJob terminates a Job
+func (m *MockK8sClient) DeleteJob(ctx context.Context, namespace, name string) error {
+	args := m.Called(ctx, namespace, name)
+	return args.Error(0)
+}
+
 // MockDeploymentStatus creates a mock deployment status for testing
 func MockDeploymentStatus(name, namespace string, current, desired int32) *k8s.DeploymentStatus {
 	return &k8s.DeploymentStatus{
@@ -58,3 +139,15 @@ func MockDeploymentStatus(name, namespace string, current, desired int32) *k8s.D
 		CreationTime:      time.Now().Add(-1 * time.Hour),
 	}
 }
+
+// CheckNamespace checks that a namespace is managed
+func (m *MockK8sClient) CheckNamespace(namespace string) error {
+	args := m.Called(namespace)
+	return args.Error(0)
+}
+
+// CheckDeployment checks that a deployment is managed
+func (m *MockK8sClient) CheckDeployment(ctx context.Context, namespace, name string, deploymentLabels map[string]string) error {
+	args := m.Called(ctx, namespace, name, deploymentLabels)
+	return args.Error(0)
+}
@@ -0,0 +1,222 @@
+package throttle
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Default per-deployment limits
+const (
+	DefaultRatePerMinute     = 6
+	DefaultBurst             = 3
+	DefaultDirectionCooldown = 30 * time.Second
+
+	// idleTTL is how long an unused bucket is kept before it is evicted
+	idleTTL = 10 * time.Minute
+)
+
+// kindDeployment is the workload kind Reserve limits. Its keys carry no kind
+// prefix.
+const kindDeployment = "Deployment"
+
+// Scale directions used for the opposite-direction cooldown
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+// Config holds the per-deployment limits
+type Config struct {
+	// RatePerMinute and Burst size the bucket shared by all scale operations
+	// on one namespace/deployment
+	RatePerMinute float64
+	Burst         int
+	// DirectionCooldown is the minimum interval between a scale-up and a
+	// scale-to-zero (or vice versa) on the same deployment. Zero disables it.
+	DirectionCooldown time.Duration
+}
+
+// NewConfig returns the default limits
+func NewConfig() *Config {
+	return &Config{
+		RatePerMinute:     DefaultRatePerMinute,
+		Burst:             DefaultBurst,
+		DirectionCooldown: DefaultDirectionCooldown,
+	}
+}
+
+// Error is returned when a scale operation is throttled
+type Error struct {
+	Message string
+	// RetryAfter is how long the caller should wait before trying again
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Limiter limits how often each deployment is scaled, whoever scales it: API
+// requests, groups, hibernation, leases and the ScaleToZeroPolicy controller
+// all go through the Kubernetes client, which reserves a slot here before it
+// changes the replicas.
+type Limiter struct {
+	config *Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	targets   map[string]*bucket
+	lastScale map[string]scaleRecord
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type scaleRecord struct {
+	direction string
+	at        time.Time
+}
+
+// NewLimiter creates a new limiter
+func NewLimiter(config *Config) *Limiter {
+	return &Limiter{
+		config:    config,
+		now:       time.Now,
+		targets:   make(map[string]*bucket),
+		lastScale: make(map[string]scaleRecord),
+	}
+}
+
+// SetConfig replaces the limits. Buckets are recreated so the new limits
+// apply to every deployment right away.
+func (l *Limiter) SetConfig(config *Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+	l.targets = make(map[string]*bucket)
+}
+
+// Reserve checks the cooldown and the deployment's bucket and, when both
+// allow it, records the operation in the same step so concurrent operations
+// cannot both pass. Scaling to zero is a scale-down, any other replica count a
+// scale-up. The returned undo gives the slot back when the operation fails.
+// A nil limiter allows everything.
+func (l *Limiter) Reserve(namespace, name string, replicas int32) (func(), error) {
+	return l.ReserveKind(kindDeployment, namespace, name, replicas)
+}
+
+// ReserveKind is Reserve for another workload kind such as "CronJob". Each
+// kind has its own buckets and cooldowns, so suspending CronJob foo does not
+// hold back the next scale of Deployment foo.
+func (l *Limiter) ReserveKind(kind, namespace, name string, replicas int32) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	target := namespace + "/" + name
+	key := target
+	if kind != kindDeployment {
+		key = strings.ToLower(kind) + ":" + target
+	}
+	direction := directionUp
+	if replicas == 0 {
+		direction = directionDown
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	previous, hadPrevious := l.lastScale[key]
+	if l.config.DirectionCooldown > 0 && hadPrevious && previous.direction != direction {
+		if remaining := l.config.DirectionCooldown - now.Sub(previous.at); remaining > 0 {
+			return nil, &Error{
+				Message:    fmt.Sprintf("%s %s was recently scaled in the opposite direction", kind, target),
+				RetryAfter: remaining,
+			}
+		}
+	}
+
+	reservation, wait, ok := l.take(key, now)
+	if !ok {
+		return nil, &Error{
+			Message:    fmt.Sprintf("Rate limit exceeded for %s %s", strings.ToLower(kind), target),
+			RetryAfter: wait,
+		}
+	}
+
+	record := scaleRecord{direction: direction, at: now}
+	l.lastScale[key] = record
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if reservation != nil {
+			// Cancelling at the reservation time returns the token even
+			// though it was granted immediately
+			reservation.CancelAt(record.at)
+		}
+		// A later operation may have replaced the record already
+		if l.lastScale[key] != record {
+			return
+		}
+		if hadPrevious {
+			l.lastScale[key] = previous
+		} else {
+			delete(l.lastScale, key)
+		}
+	}, nil
+}
+
+// take consumes a token from the target's bucket, creating it on first use.
+// Callers must hold l.mu.
+func (l *Limiter) take(target string, now time.Time) (*rate.Reservation, time.Duration, bool) {
+	if l.config.RatePerMinute <= 0 {
+		return nil, 0, true
+	}
+
+	b, ok := l.targets[target]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.config.RatePerMinute/60), l.config.Burst)}
+		l.targets[target] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return nil, time.Minute, false
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return nil, delay, false
+	}
+	return reservation, 0, true
+}
+
+// sweep evicts idle buckets and expired cooldowns so the maps do not grow
+// without bound. Callers must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.targets {
+		if now.Sub(b.lastSeen) > idleTTL {
+			delete(l.targets, key)
+		}
+	}
+	for key, record := range l.lastScale {
+		if now.Sub(record.at) > l.config.DirectionCooldown {
+			delete(l.lastScale, key)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserve_TargetBucket(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(&Config{RatePerMinute: 1, Burst: 1})
+	limiter.now = func() time.Time { return now }
+
+	_, err := limiter.Reserve("ns", "app", 2)
+	require.NoError(t, err)
+
+	_, err = limiter.Reserve("ns", "app", 3)
+	var throttled *Error
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, time.Minute, throttled.RetryAfter)
+	assert.Contains(t, throttled.Error(), "Rate limit exceeded for deployment ns/app")
+
+	// Other deployments are not affected
+	_, err = limiter.Reserve("ns", "other", 2)
+	assert.NoError(t, err)
+}
+
+func TestReserve_DirectionCooldown(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(&Config{DirectionCooldown: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	_, err := limiter.Reserve("ns", "app", 2)
+	require.NoError(t, err)
+
+	// Same direction is allowed
+	_, err = limiter.Reserve("ns", "app", 4)
+	require.NoError(t, err)
+
+	// Opposite direction within the cooldown is rejected
+	now = now.Add(20 * time.Second)
+	_, err = limiter.Reserve("ns", "app", 0)
+	var throttled *Error
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, 40*time.Second, throttled.RetryAfter)
+	assert.Contains(t, throttled.Error(), "opposite direction")
+
+	// After the cooldown the opposite direction is allowed again
+	now = now.Add(41 * time.Second)
+	_, err = limiter.Reserve("ns", "app", 0)
+	assert.NoError(t, err)
+}
+
+func TestReserveKind_SeparateFromDeployments(t *testing.T) {
+	limiter := NewLimiter(&Config{RatePerMinute: 1, Burst: 1, DirectionCooldown: time.Minute})
+
+	_, err := limiter.ReserveKind("CronJob", "ns", "app", 0)
+	require.NoError(t, err)
+
+	// The deployment of the same name has its own bucket and cooldown
+	_, err = limiter.Reserve("ns", "app", 2)
+	require.NoError(t, err)
+
+	_, err = limiter.ReserveKind("CronJob", "ns", "app", 1)
+	var throttled *Error
+	require.True(t, errors.As(err, &throttled))
+	assert.Contains(t, throttled.Error(), "CronJob ns/app was recently scaled in the opposite direction")
+}
+
+func TestReserve_UndoGivesTheSlotBack(t *testing.T) {
+	limiter := NewLimiter(&Config{RatePerMinute: 1, Burst: 1, DirectionCooldown: time.Minute})
+
+	// A failed scale-up neither consumes the token nor starts the cooldown
+	undo, err := limiter.Reserve("ns", "app", 2)
+	require.NoError(t, err)
+	undo()
+
+	_, err = limiter.Reserve("ns", "app", 0)
+	assert.NoError(t, err)
+}
+
+func TestReserve_SetConfig(t *testing.T) {
+	limiter := NewLimiter(&Config{RatePerMinute: 1, Burst: 1})
+
+	_, err := limiter.Reserve("ns", "app", 2)
+	require.NoError(t, err)
+	_, err = limiter.Reserve("ns", "app", 2)
+	require.Error(t, err)
+
+	// Raised limits apply to deployments that were already limited
+	limiter.SetConfig(&Config{RatePerMinute: 1, Burst: 3})
+	for i := 0; i < 3; i++ {
+		_, err = limiter.Reserve("ns", "app", 2)
+		require.NoError(t, err)
+	}
+	_, err = limiter.Reserve("ns", "app", 2)
+	assert.Error(t, err)
+}
+
+func TestReserve_NilLimiter(t *testing.T) {
+	var limiter *Limiter
+
+	undo, err := limiter.Reserve("ns", "app", 0)
+
+	require.NoError(t, err)
+	undo()
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves the webhook certificate from files, reloading
+// them when the certificate changes so rotated Secrets are picked up
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertificateReloader loads the key pair, failing if it is not valid
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the TLS configuration of the webhook server
+func (r *CertificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// GetCertificate returns the current certificate. A certificate that fails to
+// reload is logged and the previous one is kept.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(r.modTime) {
+		if err := r.reloadLocked(); err != nil {
+			log.Printf("Failed to reload webhook certificate, keeping the previous one: %v", err)
+		}
+	}
+	return r.cert, nil
+}
+
+// reload loads the key pair
+func (r *CertificateReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked()
+}
+
+// reloadLocked loads the key pair. The caller must hold r.mu.
+func (r *CertificateReloader) reloadLocked() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read webhook certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a self-signed certificate for commonName with the given serial number
+func writeKeyPair(t *testing.T, certFile, keyFile, commonName string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+// serialOf returns the serial number of the leaf certificate
+func serialOf(t *testing.T, cert *tls.Certificate) int64 {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertificateReloader(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile, "scale-api.scale-system.svc", 1)
+
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	config := reloader.TLSConfig()
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), serialOf(t, cert))
+
+	// Rotated certificate is picked up
+	writeKeyPair(t, certFile, keyFile, "scale-api.scale-system.svc", 2)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), serialOf(t, cert))
+
+	// An invalid certificate keeps the previous one
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	evenLater := later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, evenLater, evenLater))
+
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), serialOf(t, cert))
+}
+
+func TestNewCertificateReloader_Missing(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	assert.ErrorContains(t, err, "failed to read webhook certificate")
+}
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Modes for replica changes made outside the Scale API
+const (
+	// ModeEnforce denies the change
+	ModeEnforce = "enforce"
+	// ModeWarn allows the change, returning a warning to the client and logging it
+	ModeWarn = "warn"
+)
+
+// Default webhook server settings
+const (
+	DefaultPort     = "8443"
+	DefaultCertFile = "/etc/webhook/tls/tls.crt"
+	DefaultKeyFile  = "/etc/webhook/tls/tls.key"
+	// DefaultServiceAccount is the user the Scale API calls the API server as
+	DefaultServiceAccount = "system:serviceaccount:scale-system:scale-api-sa"
+	// HPAServiceAccount is the user the HPA controller changes replicas as
+	HPAServiceAccount = "system:serviceaccount:kube-system:horizontal-pod-autoscaler"
+	// KEDAServiceAccount is the user the KEDA operator of the AKS add-on
+	// changes replicas as when it scales from and to zero
+	KEDAServiceAccount = "system:serviceaccount:kube-system:keda-operator"
+)
+
+// DefaultAllowedUsers returns the users allowed to change replicas by
+// default: the Scale API and the autoscalers it pauses and resumes
+func DefaultAllowedUsers() []string {
+	return []string{DefaultServiceAccount, HPAServiceAccount, KEDAServiceAccount}
+}
+
+// Validator reviews updates to deployments and their scale subresource and
+// rejects replica changes to managed deployments that were not made by an
+// allowed user
+type Validator struct {
+	k8sClient k8s.ClientInterface
+
+	mu           sync.RWMutex
+	mode         string
+	allowedUsers map[string]bool
+}
+
+// NewValidator creates a new validator. The Kubernetes client decides which
+// deployments are managed.
+func NewValidator(k8sClient k8s.ClientInterface, mode string, allowedUsers []string) *Validator {
+	v := &Validator{k8sClient: k8sClient}
+	v.SetPolicy(mode, allowedUsers)
+	return v
+}
+
+// SetPolicy replaces the mode and the users allowed to change replicas
+func (v *Validator) SetPolicy(mode string, allowedUsers []string) {
+	users := make(map[string]bool, len(allowedUsers))
+	for _, user := range allowedUsers {
+		users[user] = true
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mode = mode
+	v.allowedUsers = users
+}
+
+// policy returns the mode and whether user may change replicas
+func (v *Validator) policy(user string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.mode, v.allowedUsers[user]
+}
+
+// Review decides an admission request
+func (v *Validator) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1.Update {
+		return response
+	}
+
+	oldReplicas, newReplicas, deploymentLabels, err := replicaChange(req)
+	if err != nil {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: err.Error(),
+		}
+		return response
+	}
+	if oldReplicas == newReplicas {
+		return response
+	}
+
+	mode, allowed := v.policy(req.UserInfo.Username)
+	if allowed {
+		return response
+	}
+
+	// Only deployments in the client's scope are protected. The check runs in
+	// the API server's admission path, so it reads no more than the deployment.
+	if err := v.k8sClient.CheckDeployment(ctx, req.Namespace, req.Name, deploymentLabels); err != nil {
+		if !errors.Is(err, k8s.ErrNotManaged) {
+			log.Printf("Allowing replica change of deployment %s/%s that could not be checked: %v", req.Namespace, req.Name, err)
+		}
+		return response
+	}
+
+	message := fmt.Sprintf("replicas of managed deployment %s/%s may only be changed through the Scale API (%s changed them from %d to %d)",
+		req.Namespace, req.Name, req.UserInfo.Username, oldReplicas, newReplicas)
+	if mode == ModeWarn {
+		log.Printf("Out-of-band replica change allowed in warn mode: %s", message)
+		response.Warnings = []string{message}
+		return response
+	}
+
+	log.Printf("Out-of-band replica change denied: %s", message)
+	response.Allowed = false
+	response.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: message,
+	}
+	return response
+}
+
+// replicaChange returns the replica count before and after an update of a
+// deployment or its scale subresource, and the labels of an updated
+// deployment. A Scale carries no labels, so they are nil for the scale
+// subresource. Other subresources never change replicas.
+func replicaChange(req *admissionv1.AdmissionRequest) (int32, int32, map[string]string, error) {
+	switch req.SubResource {
+	case "":
+		var oldDeployment, newDeployment appsv1.Deployment
+		if err := json.Unmarshal(req.OldObject.Raw, &oldDeployment); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to decode old deployment: %w", err)
+		}
+		if err := json.Unmarshal(req.Object.Raw, &newDeployment); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to decode deployment: %w", err)
+		}
+		// The deployment as it was decides whether it was managed, so the
+		// opt-in label cannot be removed in the same update
+		deploymentLabels := oldDeployment.Labels
+		if deploymentLabels == nil {
+			deploymentLabels = map[string]string{}
+		}
+		return replicas(oldDeployment.Spec.Replicas), replicas(newDeployment.Spec.Replicas), deploymentLabels, nil
+	case "scale":
+		var oldScale, newScale autoscalingv1.Scale
+		if err := json.Unmarshal(req.OldObject.Raw, &oldScale); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to decode old scale: %w", err)
+		}
+		if err := json.Unmarshal(req.Object.Raw, &newScale); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to decode scale: %w", err)
+		}
+		return oldScale.Spec.Replicas, newScale.Spec.Replicas, nil, nil
+	default:
+		return 0, 0, nil, nil
+	}
+}
+
+// replicas returns the replica count of a deployment spec, which defaults to 1
+func replicas(value *int32) int32 {
+	if value == nil {
+		return 1
+	}
+	return *value
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/torumakabe/aks-scale-to-zero/api/k8s"
+	"github.com/torumakabe/aks-scale-to-zero/api/testing/mocks"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// rawJSON encodes an object for an admission request
+func rawJSON(t *testing.T, obj interface{}) runtime.RawExtension {
+	t.Helper()
+	data, err := json.Marshal(obj)
+	assert.NoError(t, err)
+	return runtime.RawExtension{Raw: data}
+}
+
+func scaleRequest(t *testing.T, user string, from, to int32) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:         "uid-1",
+		Namespace:   "project-a",
+		Name:        "sample-app-a",
+		SubResource: "scale",
+		Operation:   admissionv1.Update,
+		UserInfo:    authenticationv1.UserInfo{Username: user},
+		OldObject:   rawJSON(t, autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: from}}),
+		Object:      rawJSON(t, autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: to}}),
+	}
+}
+
+func deploymentRequest(t *testing.T, user string, from, to int32, image string) *admissionv1.AdmissionRequest {
+	deployment := func(replicas int32, image string) appsv1.Deployment {
+		d := appsv1.Deployment{}
+		d.Labels = map[string]string{"scale-to-zero.io/managed": "true"}
+		d.Spec.Replicas = &replicas
+		d.Spec.Template.Annotations = map[string]string{"image": image}
+		return d
+	}
+	return &admissionv1.AdmissionRequest{
+		UID:       "uid-2",
+		Namespace: "project-a",
+		Name:      "sample-app-a",
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: user},
+		OldObject: rawJSON(t, deployment(from, "v1")),
+		Object:    rawJSON(t, deployment(to, image)),
+	}
+}
+
+func TestReview(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		request     func(t *testing.T) *admissionv1.AdmissionRequest
+		managed     error
+		wantAllowed bool
+		wantCode    int32
+		wantWarning bool
+	}{
+		{
+			name:     "kubectl scale denied",
+			mode:     ModeEnforce,
+			request:  func(t *testing.T) *admissionv1.AdmissionRequest { return scaleRequest(t, "alice", 0, 3) },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "kubectl scale warned",
+			mode:        ModeWarn,
+			request:     func(t *testing.T) *admissionv1.AdmissionRequest { return scaleRequest(t, "alice", 0, 3) },
+			wantAllowed: true,
+			wantWarning: true,
+		},
+		{
+			name:     "deployment edit denied",
+			mode:     ModeEnforce,
+			request:  func(t *testing.T) *admissionv1.AdmissionRequest { return deploymentRequest(t, "alice", 2, 5, "v1") },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "scale api allowed",
+			mode:        ModeEnforce,
+			request:     func(t *testing.T) *admissionv1.AdmissionRequest { return scaleRequest(t, DefaultServiceAccount, 0, 3) },
+			wantAllowed: true,
+		},
+		{
+			name:        "rollout without replica change",
+			mode:        ModeEnforce,
+			request:     func(t *testing.T) *admissionv1.AdmissionRequest { return deploymentRequest(t, "alice", 2, 2, "v2") },
+			wantAllowed: true,
+		},
+		{
+			name:        "unmanaged deployment",
+			mode:        ModeEnforce,
+			request:     func(t *testing.T) *admissionv1.AdmissionRequest { return scaleRequest(t, "alice", 0, 3) },
+			managed:     k8s.ErrNotManaged,
+			wantAllowed: true,
+		},
+		{
+			name: "status update",
+			mode: ModeEnforce,
+			request: func(t *testing.T) *admissionv1.AdmissionRequest {
+				req := scaleRequest(t, "alice", 0, 3)
+				req.SubResource = "status"
+				return req
+			},
+			wantAllowed: true,
+		},
+		{
+			name: "undecodable object",
+			mode: ModeEnforce,
+			request: func(t *testing.T) *admissionv1.AdmissionRequest {
+				req := scaleRequest(t, "alice", 0, 3)
+				req.Object.Raw = []byte("{")
+				return req
+			},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			mockClient := mocks.NewMockK8sClient()
+			validator := NewValidator(mockClient, tt.mode, []string{DefaultServiceAccount})
+			req := tt.request(t)
+
+			// Mock expectations
+			mockClient.On("CheckDeployment", mock.Anything, "project-a", "sample-app-a", mock.Anything).Return(tt.managed)
+
+			// Test
+			response := validator.Review(context.Background(), req)
+
+			// Assert
+			assert.Equal(t, req.UID, response.UID)
+			assert.Equal(t, tt.wantAllowed, response.Allowed)
+			if tt.wantCode != 0 {
+				if assert.NotNil(t, response.Result) {
+					assert.Equal(t, tt.wantCode, response.Result.Code)
+				}
+			}
+			assert.Equal(t, tt.wantWarning, len(response.Warnings) > 0)
+		})
+	}
+}
+
+func TestReview_LookupFailureAllows(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	validator := NewValidator(mockClient, ModeEnforce, nil)
+
+	// Mock expectations
+	mockClient.On("CheckDeployment", mock.Anything, "project-a", "sample-app-a", map[string]string(nil)).Return(errors.New("timeout"))
+
+	// Test
+	response := validator.Review(context.Background(), scaleRequest(t, "alice", 0, 3))
+
+	// Assert
+	assert.True(t, response.Allowed)
+}
+
+func TestSetPolicy(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	validator := NewValidator(mockClient, ModeEnforce, []string{DefaultServiceAccount})
+	hpa := "system:serviceaccount:kube-system:horizontal-pod-autoscaler"
+
+	// Test
+	validator.SetPolicy(ModeEnforce, []string{DefaultServiceAccount, hpa})
+	response := validator.Review(context.Background(), scaleRequest(t, hpa, 1, 4))
+
+	// Assert
+	assert.True(t, response.Allowed)
+	mockClient.AssertNotCalled(t, "CheckDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReview_DefaultAllowsAutoscalers(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	validator := NewValidator(mockClient, ModeEnforce, DefaultAllowedUsers())
+
+	for _, user := range []string{HPAServiceAccount, KEDAServiceAccount} {
+		// Test
+		response := validator.Review(context.Background(), scaleRequest(t, user, 1, 4))
+
+		// Assert
+		assert.True(t, response.Allowed, user)
+	}
+	mockClient.AssertNotCalled(t, "CheckDeployment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReview_DeploymentLabels(t *testing.T) {
+	// Setup
+	mockClient := mocks.NewMockK8sClient()
+	validator := NewValidator(mockClient, ModeEnforce, nil)
+
+	// Mock expectations - the labels of the admitted deployment are used
+	mockClient.On("CheckDeployment", mock.Anything, "project-a", "sample-app-a",
+		map[string]string{"scale-to-zero.io/managed": "true"}).Return(nil)
+
+	// Test
+	response := validator.Review(context.Background(), deploymentRequest(t, "alice", 2, 5, "v1"))
+
+	// Assert
+	assert.False(t, response.Allowed)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "GetDeploymentStatus", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -28,6 +28,8 @@ SCALE_API_NAMESPACE="scale-system"
 SCALE_API_SERVICE="scale-api"
 API_TIMEOUT=30
 SCALE_WAIT_TIME=10
+# Scale API rejects opposite-direction operations within RATE_LIMIT_DIRECTION_COOLDOWN (default 30s)
+DIRECTION_COOLDOWN_WAIT=30
 
 # Initialize test suite
 init_test_suite "Scale API Integration Test" "Tests Scale API endpoints and replica count changes"
@@ -95,6 +97,7 @@ run_test "Verify scale down to 2 replicas" \
     "[ \$(get_replica_count '$NAMESPACE' '$DEPLOYMENT') -eq 2 ]"
 
 # Test scale-to-zero endpoint
+sleep $DIRECTION_COOLDOWN_WAIT
 run_test "Scale to zero via API" \
     "curl -s -X POST --max-time $API_TIMEOUT -H 'Content-Type: application/json' -d '{\"reason\": \"api-test-scale-to-zero\"}' '$SCALE_API_ENDPOINT/api/v1/deployments/$NAMESPACE/$DEPLOYMENT/scale-to-zero' | test_json_field - '.status' 'success'"
 
@@ -106,6 +109,7 @@ run_test "Verify deployment scaled to zero" \
     "[ \$(get_replica_count '$NAMESPACE' '$DEPLOYMENT') -eq 0 ]"
 
 # Scale back up from zero
+sleep $DIRECTION_COOLDOWN_WAIT
 run_test "Scale up from zero to 1 replica" \
     "curl -s -X POST --max-time $API_TIMEOUT -H 'Content-Type: application/json' -d '{\"replicas\": 1, \"reason\": \"api-test-recovery\"}' '$SCALE_API_ENDPOINT/api/v1/deployments/$NAMESPACE/$DEPLOYMENT/scale-up' | test_json_field - '.status' 'success'"
 